@@ -16,8 +16,10 @@ package orm
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"strings"
 
@@ -26,12 +28,18 @@ import (
 	"github.com/beego/beego/v2/client/orm/internal/models"
 )
 
-type commander interface {
+// Commander is the command of RunCommand, it parses the arguments after the command name
+type Commander interface {
 	Parse([]string)
 	Run() error
 }
 
-var commands = make(map[string]commander)
+var commands = make(map[string]Commander)
+
+// RegisterCommand adds the command of RunCommand, such as migrate registered by the package migration
+func RegisterCommand(name string, cmd Commander) {
+	commands[name] = cmd
+}
 
 // print help.
 func printHelp(errs ...string) {
@@ -39,6 +47,9 @@ func printHelp(errs ...string) {
 
     syncdb     - auto create tables
     sqlall     - print sql of create tables
+    sqldiff    - print sql of migrating tables to the models
+    generate   - generate the models and the initial migration from tables
+    migrate    - run the migrations by up, down, status, redo or to, need to import the package migration
     help       - print this help
 `
 
@@ -66,7 +77,10 @@ func RunCommand() {
 
 	if cmd, ok := commands[name]; ok {
 		cmd.Parse(os.Args[3:])
-		cmd.Run()
+		if err := cmd.Run(); err != nil {
+			fmt.Println(err.Error())
+			os.Exit(2)
+		}
 		os.Exit(0)
 	} else {
 		if name == "" {
@@ -84,6 +98,8 @@ type commandSyncDb struct {
 	verbose   bool
 	noInfo    bool
 	rtOnError bool
+	// sync all the registered tenants instead of al
+	tenants bool
 }
 
 // Parse the orm command line arguments.
@@ -94,6 +110,7 @@ func (d *commandSyncDb) Parse(args []string) {
 	flagSet.StringVar(&name, "db", "default", "DataBase alias name")
 	flagSet.BoolVar(&d.force, "force", false, "drop tables before create")
 	flagSet.BoolVar(&d.verbose, "v", false, "verbose info")
+	flagSet.BoolVar(&d.tenants, "tenants", false, "sync all the registered tenants")
 	flagSet.Parse(args)
 
 	d.al = getDbAlias(name)
@@ -101,6 +118,10 @@ func (d *commandSyncDb) Parse(args []string) {
 
 // Run orm line command.
 func (d *commandSyncDb) Run() error {
+	if d.tenants {
+		return d.runTenants()
+	}
+
 	var drops []string
 	var err error
 	if d.force {
@@ -110,23 +131,36 @@ func (d *commandSyncDb) Run() error {
 		}
 	}
 
-	db := d.al.DB
+	db, release := tenantQuerier(d.al)
+	defer release()
 
 	if d.force && len(drops) > 0 {
 		for i, mi := range defaultModelCache.AllOrdered() {
-			query := drops[i]
-			if !d.noInfo {
-				fmt.Printf("drop table `%s`\n", mi.Table)
-			}
-			_, err := db.Exec(query)
-			if d.verbose {
-				fmt.Printf("    %s\n\n", query)
+			queries := []string{drops[i]}
+			tables := []string{d.al.DbBaser.TableName(mi)}
+			if s, _ := getSharding(mi); s != nil {
+				queries, tables = queries[:0], tables[:0]
+				Q := d.al.DbBaser.TableQuote()
+				for _, al := range shardAliases(d.al, mi) {
+					table := al.DbBaser.TableName(mi)
+					queries = append(queries, fmt.Sprintf(`DROP TABLE IF EXISTS %s%s%s`, Q, table, Q))
+					tables = append(tables, table)
+				}
 			}
-			if err != nil {
-				if d.rtOnError {
-					return err
+			for j, query := range queries {
+				if !d.noInfo {
+					fmt.Printf("drop table `%s`\n", tables[j])
+				}
+				_, err := db.Exec(query)
+				if d.verbose {
+					fmt.Printf("    %s\n\n", query)
+				}
+				if err != nil {
+					if d.rtOnError {
+						return err
+					}
+					fmt.Printf("    %s\n", err.Error())
 				}
-				fmt.Printf("    %s\n", err.Error())
 			}
 		}
 	}
@@ -146,39 +180,85 @@ func (d *commandSyncDb) Run() error {
 
 	ctx := context.Background()
 	for i, mi := range defaultModelCache.AllOrdered() {
+		table := d.al.DbBaser.TableName(mi)
 
 		if !models.IsApplicableTableForDB(mi.AddrField, d.al.Name) {
-			fmt.Printf("table `%s` is not applicable to database '%s'\n", mi.Table, d.al.Name)
+			fmt.Printf("table `%s` is not applicable to database '%s'\n", table, d.al.Name)
 			continue
 		}
 
-		if tables[mi.Table] {
+		// the shards are created instead of the table of the sharded model
+		if s, _ := getSharding(mi); s != nil {
+			for _, al := range shardAliases(d.al, mi) {
+				query, shardIndexes := getTableCreateSQL(al, mi)
+				if err := d.syncTable(ctx, db, al, mi, tables, query, shardIndexes); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		if err := d.syncTable(ctx, db, d.al, mi, tables, createQueries[i], indexes[table]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// syncTable creates the table of the model, or adds the missing columns and indexes if the table exists.
+// It returns the error only if rtOnError is set.
+func (d *commandSyncDb) syncTable(ctx context.Context, db dbQuerier, al *alias, mi *models.ModelInfo, tables map[string]bool,
+	createQuery string, indexes []dbIndex,
+) error {
+	table := al.DbBaser.TableName(mi)
+
+	if tables[table] {
+		if !d.noInfo {
+			fmt.Printf("table `%s` already exists, skip\n", table)
+		}
+
+		var fields []*models.FieldInfo
+		columns, err := al.DbBaser.GetColumns(ctx, db, table)
+		if err != nil {
+			if d.rtOnError {
+				return err
+			}
+			fmt.Printf("    %s\n", err.Error())
+		}
+
+		for _, fi := range mi.Fields.FieldsDB {
+			if _, ok := columns[fi.Column]; !ok {
+				fields = append(fields, fi)
+			}
+		}
+
+		for _, fi := range fields {
+			query := getColumnAddQuery(al, fi)
+
 			if !d.noInfo {
-				fmt.Printf("table `%s` already exists, skip\n", mi.Table)
+				fmt.Printf("add column `%s` for table `%s`\n", fi.FullName, table)
 			}
 
-			var fields []*models.FieldInfo
-			columns, err := d.al.DbBaser.GetColumns(ctx, db, mi.Table)
+			_, err := db.Exec(query)
+			if d.verbose {
+				fmt.Printf("    %s\n", query)
+			}
 			if err != nil {
 				if d.rtOnError {
 					return err
 				}
 				fmt.Printf("    %s\n", err.Error())
 			}
+		}
 
-			for _, fi := range mi.Fields.FieldsDB {
-				if _, ok := columns[fi.Column]; !ok {
-					fields = append(fields, fi)
-				}
-			}
-
-			for _, fi := range fields {
-				query := getColumnAddQuery(d.al, fi)
-
+		for _, idx := range indexes {
+			if !al.DbBaser.IndexExists(ctx, db, idx.Table, idx.Name) {
 				if !d.noInfo {
-					fmt.Printf("add column `%s` for table `%s`\n", fi.FullName, mi.Table)
+					fmt.Printf("create index `%s` for table `%s`\n", idx.Name, idx.Table)
 				}
 
+				query := idx.SQL
 				_, err := db.Exec(query)
 				if d.verbose {
 					fmt.Printf("    %s\n", query)
@@ -190,57 +270,36 @@ func (d *commandSyncDb) Run() error {
 					fmt.Printf("    %s\n", err.Error())
 				}
 			}
+		}
 
-			for _, idx := range indexes[mi.Table] {
-				if !d.al.DbBaser.IndexExists(ctx, db, idx.Table, idx.Name) {
-					if !d.noInfo {
-						fmt.Printf("create index `%s` for table `%s`\n", idx.Name, idx.Table)
-					}
-
-					query := idx.SQL
-					_, err := db.Exec(query)
-					if d.verbose {
-						fmt.Printf("    %s\n", query)
-					}
-					if err != nil {
-						if d.rtOnError {
-							return err
-						}
-						fmt.Printf("    %s\n", err.Error())
-					}
-				}
-			}
+		return nil
+	}
 
-			continue
-		}
+	if !d.noInfo {
+		fmt.Printf("create table `%s` \n", table)
+	}
 
-		if !d.noInfo {
-			fmt.Printf("create table `%s` \n", mi.Table)
-		}
+	queries := []string{createQuery}
+	for _, idx := range indexes {
+		queries = append(queries, idx.SQL)
+	}
 
-		queries := []string{createQueries[i]}
-		for _, idx := range indexes[mi.Table] {
-			queries = append(queries, idx.SQL)
+	for _, query := range queries {
+		_, err := db.Exec(query)
+		if d.verbose {
+			query = "    " + strings.Join(strings.Split(query, "\n"), "\n    ")
+			fmt.Println(query)
 		}
-
-		for _, query := range queries {
-			_, err := db.Exec(query)
-			if d.verbose {
-				query = "    " + strings.Join(strings.Split(query, "\n"), "\n    ")
-				fmt.Println(query)
-			}
-			if err != nil {
-				if d.rtOnError {
-					return err
-				}
-				fmt.Printf("    %s\n", err.Error())
+		if err != nil {
+			if d.rtOnError {
+				return err
 			}
-		}
-		if d.verbose {
-			fmt.Println("")
+			fmt.Printf("    %s\n", err.Error())
 		}
 	}
-
+	if d.verbose {
+		fmt.Println("")
+	}
 	return nil
 }
 
@@ -269,7 +328,7 @@ func (d *commandSQLAll) Run() error {
 	var all []string
 	for i, mi := range defaultModelCache.AllOrdered() {
 		queries := []string{createQueries[i]}
-		for _, idx := range indexes[mi.Table] {
+		for _, idx := range indexes[d.al.DbBaser.TableName(mi)] {
 			queries = append(queries, idx.SQL)
 		}
 		sql := strings.Join(queries, "\n")
@@ -280,9 +339,129 @@ func (d *commandSQLAll) Run() error {
 	return nil
 }
 
+// schema diff commander interface implement.
+type commandSQLDiff struct {
+	al   *alias
+	down bool
+}
+
+// Parse orm command line arguments.
+func (d *commandSQLDiff) Parse(args []string) {
+	var name string
+
+	flagSet := flag.NewFlagSet("orm command: sqldiff", flag.ExitOnError)
+	flagSet.StringVar(&name, "db", "default", "DataBase alias name")
+	flagSet.BoolVar(&d.down, "down", false, "print sql of reverting the migration")
+	flagSet.Parse(args)
+
+	d.al = getDbAlias(name)
+}
+
+// Run orm line command.
+func (d *commandSQLDiff) Run() error {
+	diff, err := diffSchema(context.Background(), defaultModelCache, d.al)
+	if err != nil {
+		return err
+	}
+	if diff.IsEmpty() {
+		fmt.Println("-- no change")
+		return nil
+	}
+
+	changes := diff.Changes
+	if d.down {
+		changes = make([]*SchemaChange, 0, len(diff.Changes))
+		for i := len(diff.Changes) - 1; i >= 0; i-- {
+			changes = append(changes, diff.Changes[i])
+		}
+	}
+	var all []string
+	for _, c := range changes {
+		queries := c.Up
+		if d.down {
+			queries = c.Down
+		}
+		all = append(all, fmt.Sprintf("-- %s\n%s", c, strings.Join(queries, "\n")))
+	}
+	fmt.Println(strings.Join(all, "\n\n"))
+
+	return nil
+}
+
+// generate models commander interface implement.
+type commandGenerate struct {
+	al        *alias
+	pkg       string
+	out       string
+	tables    []string
+	migration string
+	name      string
+}
+
+// Parse orm command line arguments.
+func (d *commandGenerate) Parse(args []string) {
+	var name, tables string
+
+	flagSet := flag.NewFlagSet("orm command: generate", flag.ExitOnError)
+	flagSet.StringVar(&name, "db", "default", "DataBase alias name")
+	flagSet.StringVar(&d.pkg, "pkg", "models", "package name of the models")
+	flagSet.StringVar(&d.out, "out", "", "file of the models, print them if it's empty")
+	flagSet.StringVar(&tables, "tables", "", "comma separated tables, all the tables if it's empty")
+	flagSet.StringVar(&d.migration, "migration", "", "directory of the initial migration, no migration if it's empty")
+	flagSet.StringVar(&d.name, "name", "init", "name of the initial migration")
+	flagSet.Parse(args)
+
+	d.al = getDbAlias(name)
+	for _, table := range strings.Split(tables, ",") {
+		if table = strings.TrimSpace(table); table != "" {
+			d.tables = append(d.tables, table)
+		}
+	}
+}
+
+// Run orm line command.
+func (d *commandGenerate) Run() error {
+	ctx := context.Background()
+	if d.migration != "" && migrationWriter == nil {
+		return errors.New("the migration writer is not registered, import github.com/beego/beego/v2/client/orm/migration")
+	}
+
+	var w io.Writer = os.Stdout
+	if d.out != "" {
+		f, err := os.Create(d.out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := GenerateModels(ctx, w, d.al.Name, d.pkg, d.tables...); err != nil {
+		return err
+	}
+	if d.out != "" {
+		fmt.Fprintf(os.Stderr, "write models %s\n", d.out)
+	}
+
+	if d.migration == "" {
+		return nil
+	}
+	diff, err := DumpSchema(ctx, d.al.Name, d.tables...)
+	if err != nil {
+		return err
+	}
+	path, err := migrationWriter(d.migration, d.name, diff)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "write migration %s\n", path)
+	return nil
+}
+
 func init() {
 	commands["syncdb"] = new(commandSyncDb)
 	commands["sqlall"] = new(commandSQLAll)
+	commands["sqldiff"] = new(commandSQLDiff)
+	commands["generate"] = new(commandGenerate)
 }
 
 // RunSyncdb run syncdb command line.
@@ -38,6 +38,15 @@ func getColumnTyp(al *alias, fi *models.FieldInfo) (col string) {
 		col = strings.ReplaceAll(col, "%COL%", fi.Column)
 	}()
 
+	if col = getFielderDbType(al, fi); col != "" {
+		return
+	}
+	if len(fi.Enum) > 0 {
+		defer func() {
+			col = getEnumColumnTyp(al, fi, col)
+		}()
+	}
+
 checkColumn:
 	switch fieldType {
 	case TypeBooleanField:
@@ -107,6 +116,9 @@ checkColumn:
 		}
 		col = T["jsonb"]
 	case RelForeignKey, RelOneToOne:
+		if col = getFielderDbType(al, fi.RelModelInfo.Fields.Pk); col != "" {
+			break
+		}
 		fieldType = fi.RelModelInfo.Fields.Pk.FieldType
 		fieldSize = fi.RelModelInfo.Fields.Pk.Size
 		goto checkColumn
@@ -115,6 +127,21 @@ checkColumn:
 	return
 }
 
+// getEnumColumnTyp Get the enum column type of mysql,
+// or the column type col with the CHECK constraint of the enum values for the others.
+func getEnumColumnTyp(al *alias, fi *models.FieldInfo, col string) string {
+	values := make([]string, 0, len(fi.Enum))
+	for _, v := range fi.Enum {
+		values = append(values, "'"+strings.ReplaceAll(v, "'", "''")+"'")
+	}
+	switch al.Driver {
+	case DRMySQL, DRTiDB:
+		return fmt.Sprintf("enum(%s)", strings.Join(values, ","))
+	}
+	Q := al.DbBaser.TableQuote()
+	return fmt.Sprintf("%s CHECK(%s%s%s IN (%s))", col, Q, fi.Column, Q, strings.Join(values, ","))
+}
+
 // create alter sql string.
 func getColumnAddQuery(al *alias, fi *models.FieldInfo) string {
 	Q := al.DbBaser.TableQuote()
@@ -125,7 +152,7 @@ func getColumnAddQuery(al *alias, fi *models.FieldInfo) string {
 	}
 
 	return fmt.Sprintf("ALTER TABLE %s%s%s ADD COLUMN %s%s%s %s %s",
-		Q, fi.Mi.Table, Q,
+		Q, al.DbBaser.TableName(fi.Mi), Q,
 		Q, fi.Column, Q,
 		typ, getColumnDefault(fi),
 	)
@@ -133,44 +160,55 @@ func getColumnAddQuery(al *alias, fi *models.FieldInfo) string {
 
 // Get string value for the attribute "DEFAULT" for the CREATE, ALTER commands
 func getColumnDefault(fi *models.FieldInfo) string {
-	var v, t, d string
+	d, quoted, ok := getColumnDefaultValue(fi)
+	if !ok {
+		return ""
+	}
+	if quoted {
+		return fmt.Sprintf(" DEFAULT '%s' ", d)
+	}
+	return fmt.Sprintf(" DEFAULT %s ", d)
+}
+
+// Get the value of the attribute "DEFAULT" and whether it should be quoted.
+// ok is false if the column has no DEFAULT attribute.
+func getColumnDefaultValue(fi *models.FieldInfo) (v string, quoted bool, ok bool) {
+	var d string
 
 	// Skip default attribute if field is in relations
 	if fi.Rel || fi.Reverse {
-		return v
+		return
 	}
 
-	t = " DEFAULT '%s' "
+	quoted = true
 
 	// These defaults will be useful if there no config value orm:"default" and NOT NULL is on
 	switch fi.FieldType {
 	case TypeTimeField, TypeDateField, TypeDateTimeField, TypeTextField:
-		return v
+		return
 
 	case TypeBitField, TypeSmallIntegerField, TypeIntegerField,
 		TypeBigIntegerField, TypePositiveBitField, TypePositiveSmallIntegerField,
 		TypePositiveIntegerField, TypePositiveBigIntegerField, TypeFloatField,
 		TypeDecimalField:
-		t = " DEFAULT %s "
+		quoted = false
 		d = "0"
 	case TypeBooleanField:
-		t = " DEFAULT %s "
+		quoted = false
 		d = "FALSE"
 	case TypeJSONField, TypeJsonbField:
 		d = "{}"
 	}
 
 	if fi.ColDefault {
-		if !fi.Initial.Exist() {
-			v = fmt.Sprintf(t, "")
-		} else {
-			v = fmt.Sprintf(t, fi.Initial.String())
-		}
-	} else {
-		if !fi.Null {
-			v = fmt.Sprintf(t, d)
+		if fi.Initial.Exist() {
+			v = fi.Initial.String()
 		}
+		return v, quoted, true
 	}
-
-	return v
+	// the zero values of the registered Fielder and the enum may be invalid for the column
+	if !fi.Null && !isRegisteredFielder(fi) && len(fi.Enum) == 0 {
+		return d, quoted, true
+	}
+	return "", quoted, false
 }
@@ -1,6 +1,7 @@
 package orm
 
 import (
+	"reflect"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -28,6 +29,61 @@ func Test_getColumnTyp(t *testing.T) {
 			},
 			wantCol: `bigint CHECK("my_col" >= 0)`,
 		},
+		{
+			name: "registered field type",
+			fi: &models.FieldInfo{
+				FieldType: TypeCharField,
+				IsFielder: true,
+				AddrValue: reflect.ValueOf(new(UUIDField)),
+				Size:      255,
+			},
+			al: &alias{
+				Driver:  DRPostgres,
+				DbBaser: newdbBasePostgres(),
+			},
+			wantCol: "uuid",
+		},
+		{
+			name: "registered field type fallback",
+			fi: &models.FieldInfo{
+				FieldType: TypeTextField,
+				IsFielder: true,
+				AddrValue: reflect.ValueOf(new(StringArrayField)),
+			},
+			al: &alias{
+				Driver:  DRMySQL,
+				DbBaser: newdbBaseMysql(),
+			},
+			wantCol: "longtext",
+		},
+		{
+			name: "enum of mysql",
+			fi: &models.FieldInfo{
+				FieldType: TypeVarCharField,
+				Column:    "status",
+				Size:      20,
+				Enum:      []string{"draft", "it's"},
+			},
+			al: &alias{
+				Driver:  DRMySQL,
+				DbBaser: newdbBaseMysql(),
+			},
+			wantCol: "enum('draft','it''s')",
+		},
+		{
+			name: "enum of postgres",
+			fi: &models.FieldInfo{
+				FieldType: TypeVarCharField,
+				Column:    "status",
+				Size:      20,
+				Enum:      []string{"draft", "published"},
+			},
+			al: &alias{
+				Driver:  DRPostgres,
+				DbBaser: newdbBasePostgres(),
+			},
+			wantCol: `varchar(20) CHECK("status" IN ('draft','published'))`,
+		},
 	}
 
 	for _, tc := range testCases {
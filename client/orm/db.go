@@ -20,6 +20,7 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"slices"
 	"strings"
 	"time"
 
@@ -62,11 +63,43 @@ var operators = map[string]bool{
 	// "week_day":    true,
 	"isnull": true,
 	// "search":      true,
+	"jcontains": true,
+	"has_key":   true,
+	"overlap":   true,
+}
+
+// the operators which can compare with a subquery or an OuterRef
+var refOperators = map[string]bool{
+	"exact": true,
+	"gt":    true,
+	"gte":   true,
+	"lt":    true,
+	"lte":   true,
+	"eq":    true,
+	"ne":    true,
+}
+
+// the operators of the json field, which are generated by GenerateJSONOperatorSQL
+var jsonOperators = map[string]bool{
+	"jcontains": true,
+	"has_key":   true,
+}
+
+// the operators of the array field, which are generated by GenerateArrayOperatorSQL
+var arrayOperators = map[string]bool{
+	"contains": true,
+	"overlap":  true,
 }
 
 // an instance of dbBaser interface/
 type dbBase struct {
 	ins dbBaser
+	// the prefix of the table names, see TenantTablePrefix
+	tablePrefix string
+	// the schema of the tenant, see TenantSchema
+	schema string
+	// the shard tables of the sharded models, see RegisterSharding
+	shardTables map[string]string
 }
 
 // check dbBase implements dbBaser interface.
@@ -261,6 +294,9 @@ func (d *dbBase) collectFieldValue(mi *models.ModelInfo, fi *models.FieldInfo, i
 			}
 		}
 	}
+	if len(fi.Enum) > 0 && value != nil && !slices.Contains(fi.Enum, utils.ToStr(value)) {
+		return nil, fmt.Errorf("the value `%v` of field `%s` should be one of %s", value, fi.FullName, strings.Join(fi.Enum, ", "))
+	}
 	return value, nil
 }
 
@@ -280,7 +316,7 @@ func (d *dbBase) PrepareInsert(ctx context.Context, q dbQuerier, mi *models.Mode
 	sep := fmt.Sprintf("%s, %s", Q, Q)
 	columns := strings.Join(dbcols, sep)
 
-	query := fmt.Sprintf("INSERT INTO %s%s%s (%s%s%s) VALUES (%s)", Q, mi.Table, Q, Q, columns, Q, qmarks)
+	query := fmt.Sprintf("INSERT INTO %s%s%s (%s%s%s) VALUES (%s)", Q, d.ins.TableName(mi), Q, Q, columns, Q, qmarks)
 
 	d.ins.ReplaceMarks(&query)
 
@@ -305,6 +341,9 @@ func (d *dbBase) InsertStmt(ctx context.Context, stmt stmtQuerier, mi *models.Mo
 	}
 	res, err := stmt.ExecContext(ctx, values...)
 	if err == nil {
+		if mi.Fields.CompositePk != nil {
+			return 0, nil
+		}
 		return res.LastInsertId()
 	}
 	return 0, err
@@ -325,12 +364,12 @@ func (d *dbBase) Read(ctx context.Context, q dbQuerier, mi *models.ModelInfo, in
 		}
 	} else {
 		// default use pk value as where condtion.
-		pkColumn, pkValue, ok := getExistPk(mi, ind)
+		pkColumns, pkValues, ok := getExistPks(mi, ind)
 		if !ok {
 			return ErrMissPK
 		}
-		whereCols = []string{pkColumn}
-		args = append(args, pkValue)
+		whereCols = pkColumns
+		args = append(args, pkValues...)
 	}
 
 	Q := d.ins.TableQuote()
@@ -347,7 +386,12 @@ func (d *dbBase) Read(ctx context.Context, q dbQuerier, mi *models.ModelInfo, in
 		forUpdate = "FOR UPDATE"
 	}
 
-	query := fmt.Sprintf("SELECT %s%s%s FROM %s%s%s WHERE %s%s%s = ? %s", Q, sels, Q, Q, mi.Table, Q, Q, wheres, Q, forUpdate)
+	softDelete := ""
+	if fi := mi.Fields.SoftDelete; fi != nil && !isWithDeleted(ctx) {
+		softDelete = fmt.Sprintf(" AND %s%s%s IS NULL", Q, fi.Column, Q)
+	}
+
+	query := fmt.Sprintf("SELECT %s%s%s FROM %s%s%s WHERE %s%s%s = ?%s %s", Q, sels, Q, Q, d.ins.TableName(mi), Q, Q, wheres, Q, softDelete, forUpdate)
 
 	refs := make([]interface{}, colsNum)
 	for i := range refs {
@@ -392,6 +436,14 @@ func (d *dbBase) Insert(ctx context.Context, q dbQuerier, mi *models.ModelInfo,
 
 // InsertMulti multi-insert sql with given slice struct reflect.Value.
 func (d *dbBase) InsertMulti(ctx context.Context, q dbQuerier, mi *models.ModelInfo, sind reflect.Value, bulk int, tz *time.Location) (int64, error) {
+	return d.insertMulti(ctx, q, mi, sind, bulk, tz, nil)
+}
+
+// insertMulti insert the models in bulk,
+// onConflict returns the clause appended to the insert sql by the inserted column names.
+func (d *dbBase) insertMulti(ctx context.Context, q dbQuerier, mi *models.ModelInfo, sind reflect.Value, bulk int, tz *time.Location,
+	onConflict func(names []string) (string, error),
+) (int64, error) {
 	var (
 		cnt    int64
 		nums   int
@@ -430,7 +482,15 @@ func (d *dbBase) InsertMulti(ctx context.Context, q dbQuerier, mi *models.ModelI
 		}
 
 		if i > 1 && i%bulk == 0 || length == i {
-			num, err := d.InsertValue(ctx, q, mi, true, names, values[:nums])
+			var (
+				num int64
+				err error
+			)
+			if onConflict == nil {
+				num, err = d.InsertValue(ctx, q, mi, true, names, values[:nums])
+			} else {
+				num, err = d.insertOnConflict(ctx, q, mi, names, values[:nums], onConflict)
+			}
 			if err != nil {
 				return cnt, err
 			}
@@ -447,6 +507,21 @@ func (d *dbBase) InsertMulti(ctx context.Context, q dbQuerier, mi *models.ModelI
 	return cnt, err
 }
 
+func (d *dbBase) insertOnConflict(ctx context.Context, q dbQuerier, mi *models.ModelInfo, names []string, values []interface{},
+	onConflict func(names []string) (string, error),
+) (int64, error) {
+	clause, err := onConflict(names)
+	if err != nil {
+		return 0, err
+	}
+	query := d.InsertValueSQL(names, values, true, mi) + " " + clause
+	res, err := q.ExecContext(ctx, query, values...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
 // InsertValue execute insert sql with given struct and given values.
 // insert the given values, not the field values in struct.
 func (d *dbBase) InsertValue(ctx context.Context, q dbQuerier, mi *models.ModelInfo, isMulti bool, names []string, values []interface{}) (int64, error) {
@@ -458,6 +533,10 @@ func (d *dbBase) InsertValue(ctx context.Context, q dbQuerier, mi *models.ModelI
 			if isMulti {
 				return res.RowsAffected()
 			}
+			// there is no single id for the composite primary key
+			if mi.Fields.CompositePk != nil {
+				return 0, nil
+			}
 
 			lastInsertId, err := res.LastInsertId()
 			if err != nil {
@@ -483,7 +562,7 @@ func (d *dbBase) InsertValueSQL(names []string, values []interface{}, isMulti bo
 
 	_, _ = buf.WriteString("INSERT INTO ")
 	_, _ = buf.WriteString(Q)
-	_, _ = buf.WriteString(mi.Table)
+	_, _ = buf.WriteString(d.ins.TableName(mi))
 	_, _ = buf.WriteString(Q)
 
 	_, _ = buf.WriteString(" (")
@@ -534,6 +613,11 @@ func (d *dbBase) InsertOrUpdate(ctx context.Context, q dbQuerier, mi *models.Mod
 		return 0, err
 	}
 
+	// the conflict columns are the composite primary key by default
+	if len(args) == 0 && a.Driver == DRPostgres && mi.Fields.CompositePk != nil {
+		args = []string{strings.Join(getPkColumns(mi, d.ins.TableQuote(), ""), ", ")}
+	}
+
 	query, err := d.InsertOrUpdateSQL(names, &values, mi, a, args...)
 
 	if err != nil {
@@ -543,6 +627,9 @@ func (d *dbBase) InsertOrUpdate(ctx context.Context, q dbQuerier, mi *models.Mod
 	if !d.ins.HasReturningID(mi, &query) {
 		res, err := q.ExecContext(ctx, query, values...)
 		if err == nil {
+			if mi.Fields.CompositePk != nil {
+				return 0, nil
+			}
 			lastInsertId, err := res.LastInsertId()
 			if err != nil {
 				DebugLog.Println(ErrLastInsertIdUnavailable, ':', err)
@@ -594,7 +681,7 @@ func (d *dbBase) InsertOrUpdateSQL(names []string, values *[]interface{}, mi *mo
 
 	_, _ = buf.WriteString("INSERT INTO ")
 	_, _ = buf.WriteString(quote)
-	_, _ = buf.WriteString(mi.Table)
+	_, _ = buf.WriteString(d.ins.TableName(mi))
 	_, _ = buf.WriteString(quote)
 	_, _ = buf.WriteString(" (")
 
@@ -651,7 +738,7 @@ func (d *dbBase) InsertOrUpdateSQL(names []string, values *[]interface{}, mi *mo
 					_, _ = buf.WriteString("=(select ")
 					_, _ = buf.WriteString(valueStr)
 					_, _ = buf.WriteString(" from ")
-					_, _ = buf.WriteString(mi.Table)
+					_, _ = buf.WriteString(d.ins.TableName(mi))
 					_, _ = buf.WriteString(" where ")
 					_, _ = buf.WriteString(args0)
 					_, _ = buf.WriteString(" = ? )")
@@ -674,9 +761,235 @@ func (d *dbBase) InsertOrUpdateSQL(names []string, values *[]interface{}, mi *mo
 	return query, nil
 }
 
+// InsertOrUpdateMulti insert the models in bulk,
+// and update the updateCols of the rows which conflict with the conflictCols.
+// The conflictCols default to the pk, and the updateCols default to the inserted columns
+// except the conflictCols, the pk and the auto_now_add columns.
+func (d *dbBase) InsertOrUpdateMulti(ctx context.Context, q dbQuerier, mi *models.ModelInfo, sind reflect.Value, bulk int, a *alias, conflictCols []string, updateCols []string) (int64, error) {
+	conflicts, err := getColumns(mi, conflictCols)
+	if err != nil {
+		return 0, err
+	}
+	if len(conflicts) == 0 {
+		for _, fi := range mi.Fields.PkFields() {
+			conflicts = append(conflicts, fi.Column)
+		}
+	}
+	updates, err := getColumns(mi, updateCols)
+	if err != nil {
+		return 0, err
+	}
+
+	onConflict := func(names []string) (string, error) {
+		if len(updates) == 0 {
+			for _, name := range names {
+				fi := mi.Fields.GetByColumn(name)
+				if fi.Pk || fi.AutoNowAdd || slices.Contains(conflicts, name) || slices.Contains(mi.Fields.CompositePk, fi) {
+					continue
+				}
+				updates = append(updates, name)
+			}
+		}
+		return d.onConflictSQL(a, conflicts, updates)
+	}
+	if bulk > 1 {
+		return d.insertMulti(ctx, q, mi, sind, bulk, a.TZ, onConflict)
+	}
+
+	// insertMulti flushes every bulk rows only when bulk > 1, like InsertMulti the models are upserted one by one
+	if sind.Kind() == reflect.Array && !sind.CanAddr() {
+		arr := reflect.New(sind.Type()).Elem()
+		arr.Set(sind)
+		sind = arr
+	}
+	var cnt int64
+	for i := 0; i < sind.Len(); i++ {
+		num, err := d.insertMulti(ctx, q, mi, sind.Slice(i, i+1), 1, a.TZ, onConflict)
+		cnt += num
+		if err != nil {
+			return cnt, err
+		}
+	}
+	return cnt, nil
+}
+
+func (d *dbBase) onConflictSQL(a *alias, conflicts []string, updates []string) (string, error) {
+	if len(updates) == 0 {
+		return "", fmt.Errorf("<InsertOrUpdateMulti> no column to update")
+	}
+
+	Q := d.ins.TableQuote()
+
+	buf := buffers.Get()
+	defer buffers.Put(buf)
+
+	switch a.Driver {
+	case DRMySQL, DRTiDB:
+		_, _ = buf.WriteString("ON DUPLICATE KEY UPDATE ")
+		for i, col := range updates {
+			if i > 0 {
+				_, _ = buf.WriteString(", ")
+			}
+			_, _ = buf.WriteString(fmt.Sprintf("%s%s%s = VALUES(%s%s%s)", Q, col, Q, Q, col, Q))
+		}
+	case DRPostgres, DRSqlite:
+		_, _ = buf.WriteString("ON CONFLICT (")
+		for i, col := range conflicts {
+			if i > 0 {
+				_, _ = buf.WriteString(", ")
+			}
+			_, _ = buf.WriteString(Q + col + Q)
+		}
+		_, _ = buf.WriteString(") DO UPDATE SET ")
+		for i, col := range updates {
+			if i > 0 {
+				_, _ = buf.WriteString(", ")
+			}
+			_, _ = buf.WriteString(fmt.Sprintf("%s%s%s = EXCLUDED.%s%s%s", Q, col, Q, Q, col, Q))
+		}
+	default:
+		return "", fmt.Errorf("`%s` nonsupport InsertOrUpdateMulti in beego", a.DriverName)
+	}
+	return buf.String(), nil
+}
+
+// UpdateMulti update the cols of the models in bulk by pk,
+// every column is set by a CASE WHEN expression of the pk, so the models can have different values.
+// The cols default to all the columns except the pk and the auto_now_add columns.
+func (d *dbBase) UpdateMulti(ctx context.Context, q dbQuerier, mi *models.ModelInfo, sind reflect.Value, bulk int, tz *time.Location, cols []string) (int64, error) {
+	if mi.Fields.Version != nil {
+		return 0, fmt.Errorf("<UpdateMulti> model `%s` has the version field, use Update instead", mi.FullName)
+	}
+	if mi.Fields.Pk == nil {
+		return 0, fmt.Errorf("<UpdateMulti> model `%s` has no single pk, use Update instead", mi.FullName)
+	}
+
+	var names []string
+	if len(cols) == 0 {
+		cols = mi.Fields.DBcols
+	}
+	setAutoNow := true
+	for _, col := range cols {
+		fi, ok := mi.Fields.GetByAny(col)
+		if !ok {
+			return 0, fmt.Errorf("wrong field/column name `%s`", col)
+		}
+		if fi.Pk || fi.AutoNowAdd || !fi.DBcol {
+			continue
+		}
+		if fi.AutoNow {
+			setAutoNow = false
+		}
+		names = append(names, fi.Column)
+	}
+	// the auto_now columns are updated like Update does,
+	// collectValues sets them to now and writes the value back to the models
+	if setAutoNow {
+		for _, fi := range mi.Fields.FieldsDB {
+			if fi.AutoNow {
+				names = append(names, fi.Column)
+			}
+		}
+	}
+	if len(names) == 0 {
+		return 0, ErrArgs
+	}
+
+	if bulk <= 0 {
+		bulk = sind.Len()
+	}
+
+	var cnt int64
+	for start := 0; start < sind.Len(); start += bulk {
+		end := start + bulk
+		if end > sind.Len() {
+			end = sind.Len()
+		}
+
+		pks := make([]interface{}, 0, end-start)
+		rows := make([][]interface{}, 0, end-start)
+		for i := start; i < end; i++ {
+			ind := reflect.Indirect(sind.Index(i))
+			_, pk, ok := getExistPk(mi, ind)
+			if !ok {
+				return cnt, ErrMissPK
+			}
+			values, _, err := d.collectValues(mi, ind, names, true, false, nil, tz)
+			if err != nil {
+				return cnt, err
+			}
+			pks = append(pks, pk)
+			rows = append(rows, values)
+		}
+
+		query, args := d.updateMultiSQL(mi, names, pks, rows)
+		res, err := q.ExecContext(ctx, query, args...)
+		if err != nil {
+			return cnt, err
+		}
+		num, err := res.RowsAffected()
+		if err != nil {
+			return cnt, err
+		}
+		cnt += num
+	}
+	return cnt, nil
+}
+
+// updateMultiSQL generate the sql like:
+//
+//	UPDATE t SET c = CASE pk WHEN ? THEN ? WHEN ? THEN ? ELSE c END WHERE pk IN (?, ?)
+//
+// the ELSE makes postgres infer the type of the values from the column
+func (d *dbBase) updateMultiSQL(mi *models.ModelInfo, names []string, pks []interface{}, rows [][]interface{}) (string, []interface{}) {
+	Q := d.ins.TableQuote()
+	pkCol := Q + mi.Fields.Pk.Column + Q
+
+	buf := buffers.Get()
+	defer buffers.Put(buf)
+
+	args := make([]interface{}, 0, len(names)*len(pks)*2+len(pks))
+
+	_, _ = buf.WriteString("UPDATE ")
+	_, _ = buf.WriteString(Q + d.ins.TableName(mi) + Q)
+	_, _ = buf.WriteString(" SET ")
+	for i, name := range names {
+		if i > 0 {
+			_, _ = buf.WriteString(", ")
+		}
+		col := Q + name + Q
+		_, _ = buf.WriteString(col)
+		_, _ = buf.WriteString(" = CASE ")
+		_, _ = buf.WriteString(pkCol)
+		for j, pk := range pks {
+			_, _ = buf.WriteString(" WHEN ? THEN ?")
+			args = append(args, pk, rows[j][i])
+		}
+		_, _ = buf.WriteString(" ELSE ")
+		_, _ = buf.WriteString(col)
+		_, _ = buf.WriteString(" END")
+	}
+
+	_, _ = buf.WriteString(" WHERE ")
+	_, _ = buf.WriteString(pkCol)
+	_, _ = buf.WriteString(" IN (")
+	for i, pk := range pks {
+		if i > 0 {
+			_, _ = buf.WriteString(", ")
+		}
+		_, _ = buf.WriteString("?")
+		args = append(args, pk)
+	}
+	_, _ = buf.WriteString(")")
+
+	query := buf.String()
+	d.ins.ReplaceMarks(&query)
+	return query, args
+}
+
 // Update execute update sql dbQuerier with given struct reflect.Value.
 func (d *dbBase) Update(ctx context.Context, q dbQuerier, mi *models.ModelInfo, ind reflect.Value, tz *time.Location, cols []string) (int64, error) {
-	pkName, pkValue, ok := getExistPk(mi, ind)
+	pkNames, pkValues, ok := getExistPks(mi, ind)
 	if !ok {
 		return 0, ErrMissPK
 	}
@@ -712,6 +1025,16 @@ func (d *dbBase) Update(ctx context.Context, q dbQuerier, mi *models.ModelInfo,
 		setValues = append(setValues[0:index], setValues[index+1:]...)
 	}
 
+	// the columns of the composite primary key are the where condition only
+	if mi.Fields.CompositePk != nil {
+		for i := len(setNames) - 1; i >= 0; i-- {
+			if slices.Contains(pkNames, setNames[i]) {
+				setNames = append(setNames[0:i], setNames[i+1:]...)
+				setValues = append(setValues[0:i], setValues[i+1:]...)
+			}
+		}
+	}
+
 	if !findAutoNow {
 		for col, info := range mi.Fields.Columns {
 			if info.AutoNow {
@@ -721,18 +1044,51 @@ func (d *dbBase) Update(ctx context.Context, q dbQuerier, mi *models.ModelInfo,
 		}
 	}
 
-	setValues = append(setValues, pkValue)
+	whereNames := pkNames
+	whereValues := pkValues
+
+	// optimistic locking, the version is increased and checked
+	vfi := mi.Fields.Version
+	var version int64
+	if vfi != nil {
+		for i, col := range setNames {
+			if col == vfi.Column {
+				setNames = append(setNames[0:i], setNames[i+1:]...)
+				setValues = append(setValues[0:i], setValues[i+1:]...)
+				break
+			}
+		}
+		version = getVersion(vfi, ind)
+		setNames = append(setNames, vfi.Column)
+		setValues = append(setValues, version+1)
+		whereNames = append(whereNames, vfi.Column)
+		whereValues = append(whereValues, version)
+	}
 
-	query := d.UpdateSQL(setNames, pkName, mi)
+	query := d.updateSQL(setNames, whereNames, mi)
 
-	res, err := q.ExecContext(ctx, query, setValues...)
-	if err == nil {
-		return res.RowsAffected()
+	res, err := q.ExecContext(ctx, query, append(setValues, whereValues...)...)
+	if err != nil {
+		return 0, err
 	}
-	return 0, err
+	num, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if vfi != nil {
+		if num == 0 {
+			return 0, ErrStaleObject
+		}
+		setVersion(vfi, ind, version+1)
+	}
+	return num, nil
 }
 
 func (d *dbBase) UpdateSQL(setNames []string, pkName string, mi *models.ModelInfo) string {
+	return d.updateSQL(setNames, []string{pkName}, mi)
+}
+
+func (d *dbBase) updateSQL(setNames []string, whereNames []string, mi *models.ModelInfo) string {
 	buf := buffers.Get()
 	defer buffers.Put(buf)
 
@@ -740,7 +1096,7 @@ func (d *dbBase) UpdateSQL(setNames []string, pkName string, mi *models.ModelInf
 
 	_, _ = buf.WriteString("UPDATE ")
 	_, _ = buf.WriteString(Q)
-	_, _ = buf.WriteString(mi.Table)
+	_, _ = buf.WriteString(d.ins.TableName(mi))
 	_, _ = buf.WriteString(Q)
 	_, _ = buf.WriteString(" SET ")
 
@@ -755,10 +1111,15 @@ func (d *dbBase) UpdateSQL(setNames []string, pkName string, mi *models.ModelInf
 	}
 
 	_, _ = buf.WriteString(" WHERE ")
-	_, _ = buf.WriteString(Q)
-	_, _ = buf.WriteString(pkName)
-	_, _ = buf.WriteString(Q)
-	_, _ = buf.WriteString(" = ?")
+	for i, name := range whereNames {
+		if i > 0 {
+			_, _ = buf.WriteString(" AND ")
+		}
+		_, _ = buf.WriteString(Q)
+		_, _ = buf.WriteString(name)
+		_, _ = buf.WriteString(Q)
+		_, _ = buf.WriteString(" = ?")
+	}
 
 	query := buf.String()
 	d.ins.ReplaceMarks(&query)
@@ -781,12 +1142,16 @@ func (d *dbBase) Delete(ctx context.Context, q dbQuerier, mi *models.ModelInfo,
 		}
 	} else {
 		// default use pk value as where condtion.
-		pkColumn, pkValue, ok := getExistPk(mi, ind)
+		pkColumns, pkValues, ok := getExistPks(mi, ind)
 		if !ok {
 			return 0, ErrMissPK
 		}
-		whereCols = []string{pkColumn}
-		args = append(args, pkValue)
+		whereCols = pkColumns
+		args = append(args, pkValues...)
+	}
+
+	if mi.Fields.SoftDelete != nil && !isHardDelete(ctx, nil) {
+		return d.softDelete(ctx, q, mi, ind, tz, whereCols, args)
 	}
 
 	query := d.DeleteSQL(whereCols, mi)
@@ -808,6 +1173,35 @@ func (d *dbBase) Delete(ctx context.Context, q dbQuerier, mi *models.ModelInfo,
 	return 0, err
 }
 
+// softDelete set the soft_delete field to now, the rows which are deleted already are skipped.
+func (d *dbBase) softDelete(ctx context.Context, q dbQuerier, mi *models.ModelInfo, ind reflect.Value, tz *time.Location, whereCols []string, args []interface{}) (int64, error) {
+	fi := mi.Fields.SoftDelete
+	Q := d.ins.TableQuote()
+
+	sep := fmt.Sprintf("%s = ? AND %s", Q, Q)
+	wheres := strings.Join(whereCols, sep)
+	query := fmt.Sprintf("UPDATE %s%s%s SET %s%s%s = ? WHERE %s%s%s = ? AND %s%s%s IS NULL",
+		Q, d.ins.TableName(mi), Q, Q, fi.Column, Q, Q, wheres, Q, Q, fi.Column, Q)
+	d.ins.ReplaceMarks(&query)
+
+	tnow := time.Now()
+	value := tnow
+	d.ins.TimeToDB(&value, tz)
+
+	res, err := q.ExecContext(ctx, query, append([]interface{}{value}, args...)...)
+	if err != nil {
+		return 0, err
+	}
+	num, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if num > 0 {
+		setSoftDeleted(fi, ind, tnow.In(DefaultTimeLoc))
+	}
+	return num, nil
+}
+
 func (d *dbBase) DeleteSQL(whereCols []string, mi *models.ModelInfo) string {
 	buf := buffers.Get()
 	defer buffers.Put(buf)
@@ -816,7 +1210,7 @@ func (d *dbBase) DeleteSQL(whereCols []string, mi *models.ModelInfo) string {
 
 	_, _ = buf.WriteString("DELETE FROM ")
 	_, _ = buf.WriteString(Q)
-	_, _ = buf.WriteString(mi.Table)
+	_, _ = buf.WriteString(d.ins.TableName(mi))
 	_, _ = buf.WriteString(Q)
 	_, _ = buf.WriteString(" WHERE ")
 
@@ -856,12 +1250,14 @@ func (d *dbBase) UpdateBatch(ctx context.Context, q dbQuerier, qs *querySet, mi
 	}
 
 	tables := newDbTables(mi, d.ins)
+	tables.ctx = ctx
 	var specifyIndexes string
 	if qs != nil {
 		tables.parseRelated(qs.related, qs.relDepth)
-		specifyIndexes = tables.getIndexSql(mi.Table, qs.useIndex, qs.indexes)
+		specifyIndexes = tables.getIndexSql(d.ins.TableName(mi), qs.useIndex, qs.indexes)
 	}
 
+	cond = getSoftDeleteCond(ctx, qs, mi, cond)
 	where, args := tables.getCondSQL(cond, false, tz)
 
 	values = append(values, args...)
@@ -885,7 +1281,7 @@ func (d *dbBase) UpdateBatchSQL(mi *models.ModelInfo, cols []string, values []in
 
 	_, _ = buf.WriteString("UPDATE ")
 	_, _ = buf.WriteString(quote)
-	_, _ = buf.WriteString(mi.Table)
+	_, _ = buf.WriteString(d.ins.TableName(mi))
 	_, _ = buf.WriteString(quote)
 
 	if d.ins.SupportUpdateJoin() {
@@ -903,17 +1299,13 @@ func (d *dbBase) UpdateBatchSQL(mi *models.ModelInfo, cols []string, values []in
 		d.buildSetSQL(buf, cols, values)
 
 		_, _ = buf.WriteString(" WHERE ")
-		_, _ = buf.WriteString(quote)
-		_, _ = buf.WriteString(mi.Fields.Pk.Column)
-		_, _ = buf.WriteString(quote)
+		_, _ = buf.WriteString(getPkRowSQL(getPkColumns(mi, quote, "")))
 		_, _ = buf.WriteString(" IN ( ")
-		_, _ = buf.WriteString("SELECT T0.")
-		_, _ = buf.WriteString(quote)
-		_, _ = buf.WriteString(mi.Fields.Pk.Column)
-		_, _ = buf.WriteString(quote)
+		_, _ = buf.WriteString("SELECT ")
+		_, _ = buf.WriteString(strings.Join(getPkColumns(mi, quote, "T0."), ", "))
 		_, _ = buf.WriteString(" FROM ")
 		_, _ = buf.WriteString(quote)
-		_, _ = buf.WriteString(mi.Table)
+		_, _ = buf.WriteString(d.ins.TableName(mi))
 		_, _ = buf.WriteString(quote)
 		_, _ = buf.WriteString(" T0 ")
 		_, _ = buf.WriteString(specifyIndexes)
@@ -1013,25 +1405,39 @@ func (d *dbBase) deleteRels(ctx context.Context, q dbQuerier, mi *models.ModelIn
 // DeleteBatch delete table-related records.
 func (d *dbBase) DeleteBatch(ctx context.Context, q dbQuerier, qs *querySet, mi *models.ModelInfo, cond *Condition, tz *time.Location) (int64, error) {
 	tables := newDbTables(mi, d.ins)
+	tables.ctx = ctx
 	tables.skipEnd = true
 
 	var specifyIndexes string
 	if qs != nil {
 		tables.parseRelated(qs.related, qs.relDepth)
-		specifyIndexes = tables.getIndexSql(mi.Table, qs.useIndex, qs.indexes)
+		specifyIndexes = tables.getIndexSql(d.ins.TableName(mi), qs.useIndex, qs.indexes)
 	}
 
 	if cond == nil || cond.IsEmpty() {
 		panic(fmt.Errorf("delete operation cannot execute without condition"))
 	}
 
+	if fi := mi.Fields.SoftDelete; fi != nil && !isHardDelete(ctx, qs) {
+		// the rows selected by OnlyDeleted are deleted already
+		if qs != nil && qs.onlyDeleted {
+			return 0, nil
+		}
+		tnow := time.Now()
+		d.ins.TimeToDB(&tnow, tz)
+		return d.UpdateBatch(ctx, q, qs, mi, cond, Params{fi.Column: tnow}, tz)
+	}
+
 	Q := d.ins.TableQuote()
 
+	// the hard delete removes the soft deleted rows too, unless OnlyDeleted is used
+	cond = getSoftDeleteCond(WithDeleted(ctx), qs, mi, cond)
 	where, args := tables.getCondSQL(cond, false, tz)
 	join := tables.getJoinSQL()
 
-	cols := fmt.Sprintf("T0.%s%s%s", Q, mi.Fields.Pk.Column, Q)
-	query := fmt.Sprintf("SELECT %s FROM %s%s%s T0 %s%s%s", cols, Q, mi.Table, Q, specifyIndexes, join, where)
+	pkFields := mi.Fields.PkFields()
+	cols := strings.Join(getPkColumns(mi, Q, "T0."), ", ")
+	query := fmt.Sprintf("SELECT %s FROM %s%s%s T0 %s%s%s", cols, Q, d.ins.TableName(mi), Q, specifyIndexes, join, where)
 
 	d.ins.ReplaceMarks(&query)
 
@@ -1043,18 +1449,24 @@ func (d *dbBase) DeleteBatch(ctx context.Context, q dbQuerier, qs *querySet, mi
 	rs = r
 	defer rs.Close()
 
-	var ref interface{}
+	refs := make([]interface{}, len(pkFields))
+	for i := range refs {
+		var ref interface{}
+		refs[i] = &ref
+	}
 	args = make([]interface{}, 0)
 	cnt := 0
 	for rs.Next() {
-		if err := rs.Scan(&ref); err != nil {
+		if err := rs.Scan(refs...); err != nil {
 			return 0, err
 		}
-		pkValue, err := d.convertValueFromDB(mi.Fields.Pk, reflect.ValueOf(ref).Interface(), tz)
-		if err != nil {
-			return 0, err
+		for i, fi := range pkFields {
+			pkValue, err := d.convertValueFromDB(fi, reflect.ValueOf(refs[i]).Elem().Interface(), tz)
+			if err != nil {
+				return 0, err
+			}
+			args = append(args, pkValue)
 		}
-		args = append(args, pkValue)
 		cnt++
 	}
 
@@ -1066,12 +1478,16 @@ func (d *dbBase) DeleteBatch(ctx context.Context, q dbQuerier, qs *querySet, mi
 		return 0, nil
 	}
 
-	marks := make([]string, len(args))
+	marks := make([]string, len(pkFields))
 	for i := range marks {
 		marks[i] = "?"
 	}
-	sqlIn := fmt.Sprintf("IN (%s)", strings.Join(marks, ", "))
-	query = fmt.Sprintf("DELETE FROM %s%s%s WHERE %s%s%s %s", Q, mi.Table, Q, Q, mi.Fields.Pk.Column, Q, sqlIn)
+	rows := make([]string, cnt)
+	for i := range rows {
+		rows[i] = getPkRowSQL(marks)
+	}
+	sqlIn := fmt.Sprintf("IN (%s)", strings.Join(rows, ", "))
+	query = fmt.Sprintf("DELETE FROM %s%s%s WHERE %s %s", Q, d.ins.TableName(mi), Q, getPkRowSQL(getPkColumns(mi, Q, "")), sqlIn)
 
 	d.ins.ReplaceMarks(&query)
 	res, err := q.ExecContext(ctx, query, args...)
@@ -1093,6 +1509,7 @@ func (d *dbBase) DeleteBatch(ctx context.Context, q dbQuerier, qs *querySet, mi
 
 // ReadBatch read related records.
 func (d *dbBase) ReadBatch(ctx context.Context, q dbQuerier, qs querySet, mi *models.ModelInfo, cond *Condition, container interface{}, tz *time.Location, cols []string) (int64, error) {
+	cond = getSoftDeleteCond(ctx, &qs, mi, cond)
 	val := reflect.ValueOf(container)
 	ind := reflect.Indirect(val)
 
@@ -1121,10 +1538,68 @@ func (d *dbBase) ReadBatch(ctx context.Context, q dbQuerier, qs querySet, mi *mo
 		unregister = fn != mi.FullName
 	}
 
+	var rowMi *models.ModelInfo
 	if unregister {
 		RegisterModel(container)
+		rowMi, _ = defaultModelCache.Get(name)
 	}
 
+	slice := ind
+	var cnt int64
+	_, err := d.readRows(ctx, q, qs, mi, rowMi, cond, tz, cols, func(mind reflect.Value) error {
+		if one {
+			if cnt == 0 {
+				ind.Set(mind)
+			}
+		} else {
+			if cnt == 0 {
+				// you can use an empty & caped container list
+				// orm will not replace it
+				if ind.Len() != 0 {
+					// if container is not empty
+					// create a new one
+					slice = reflect.New(ind.Type()).Elem()
+				}
+			}
+
+			if isPtr {
+				slice = reflect.Append(slice, mind.Addr())
+			} else {
+				slice = reflect.Append(slice, mind)
+			}
+		}
+		cnt++
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if !one {
+		if cnt > 0 {
+			ind.Set(slice)
+		} else {
+			// when a result is empty and container is nil
+			// to Set an empty container
+			if ind.IsNil() {
+				ind.Set(reflect.MakeSlice(ind.Type(), 0, 0))
+			}
+		}
+	}
+
+	return cnt, nil
+}
+
+// IterateBatch read the records one by one and call fn with the value of each model,
+// it stops and returns the error if fn returns error.
+func (d *dbBase) IterateBatch(ctx context.Context, q dbQuerier, qs querySet, mi *models.ModelInfo, cond *Condition, tz *time.Location, cols []string, fn func(reflect.Value) error) (int64, error) {
+	cond = getSoftDeleteCond(ctx, &qs, mi, cond)
+	return d.readRows(ctx, q, qs, mi, nil, cond, tz, cols, fn)
+}
+
+// readRows execute the select sql of ReadBatch and scan the rows into new models of rowMi,
+// rowMi is nil if the rows are scanned into the models of mi.
+func (d *dbBase) readRows(ctx context.Context, q dbQuerier, qs querySet, mi *models.ModelInfo, rowMi *models.ModelInfo, cond *Condition, tz *time.Location, cols []string, fn func(reflect.Value) error) (int64, error) {
 	var tCols []string
 	if len(cols) > 0 {
 		hasRel := len(qs.related) > 0 || qs.relDepth > 0
@@ -1157,7 +1632,9 @@ func (d *dbBase) ReadBatch(ctx context.Context, q dbQuerier, qs querySet, mi *mo
 	}
 
 	tables := newDbTables(mi, d.ins)
+	tables.ctx = ctx
 	tables.parseRelated(qs.related, qs.relDepth)
+	tables.withDeleted = isWithDeleted(ctx) || qs.withDeleted
 
 	colsNum := len(tCols)
 
@@ -1176,9 +1653,8 @@ func (d *dbBase) ReadBatch(ctx context.Context, q dbQuerier, qs querySet, mi *mo
 
 	defer rs.Close()
 
-	slice := ind
-	if unregister {
-		mi, _ = defaultModelCache.Get(name)
+	if rowMi != nil {
+		mi = rowMi
 		tCols = mi.Fields.DBcols
 		colsNum = len(tCols)
 	}
@@ -1190,102 +1666,71 @@ func (d *dbBase) ReadBatch(ctx context.Context, q dbQuerier, qs querySet, mi *mo
 	}
 	var cnt int64
 	for rs.Next() {
-		if one && cnt == 0 || !one {
-			if err := rs.Scan(refs...); err != nil {
-				return 0, err
-			}
+		if err := rs.Scan(refs...); err != nil {
+			return cnt, err
+		}
 
-			elm := reflect.New(mi.AddrField.Elem().Type())
-			mind := reflect.Indirect(elm)
-
-			cacheV := make(map[string]*reflect.Value)
-			cacheM := make(map[string]*models.ModelInfo)
-			trefs := refs
-
-			d.setColsValues(mi, &mind, tCols, refs[:len(tCols)], tz)
-			trefs = refs[len(tCols):]
-
-			for _, tbl := range tables.tables {
-				// loop selected tables
-				if tbl.sel {
-					last := mind
-					names := ""
-					mmi := mi
-					// loop cascade models
-					for _, name := range tbl.names {
-						names += name
-						if val, ok := cacheV[names]; ok {
-							last = *val
-							mmi = cacheM[names]
-						} else {
-							fi := mmi.Fields.GetByName(name)
-							lastm := mmi
-							mmi = fi.RelModelInfo
-							field := last
-							if last.Kind() != reflect.Invalid {
-								field = reflect.Indirect(last.FieldByIndex(fi.FieldIndex))
-								if field.IsValid() {
-									d.setColsValues(mmi, &field, mmi.Fields.DBcols, trefs[:len(mmi.Fields.DBcols)], tz)
-									for _, fi := range mmi.Fields.FieldsReverse {
-										if fi.InModel && fi.ReverseFieldInfo.Mi == lastm {
-											if fi.ReverseFieldInfo != nil {
-												f := field.FieldByIndex(fi.FieldIndex)
-												if f.Kind() == reflect.Ptr {
-													f.Set(last.Addr())
-												}
+		elm := reflect.New(mi.AddrField.Elem().Type())
+		mind := reflect.Indirect(elm)
+
+		cacheV := make(map[string]*reflect.Value)
+		cacheM := make(map[string]*models.ModelInfo)
+		trefs := refs
+
+		d.setColsValues(mi, &mind, tCols, refs[:len(tCols)], tz)
+		trefs = refs[len(tCols):]
+
+		for _, tbl := range tables.tables {
+			// loop selected tables
+			if tbl.sel {
+				last := mind
+				names := ""
+				mmi := mi
+				// loop cascade models
+				for _, name := range tbl.names {
+					names += name
+					if val, ok := cacheV[names]; ok {
+						last = *val
+						mmi = cacheM[names]
+					} else {
+						fi := mmi.Fields.GetByName(name)
+						lastm := mmi
+						mmi = fi.RelModelInfo
+						field := last
+						if last.Kind() != reflect.Invalid {
+							field = reflect.Indirect(last.FieldByIndex(fi.FieldIndex))
+							if field.IsValid() {
+								d.setColsValues(mmi, &field, mmi.Fields.DBcols, trefs[:len(mmi.Fields.DBcols)], tz)
+								for _, fi := range mmi.Fields.FieldsReverse {
+									if fi.InModel && fi.ReverseFieldInfo.Mi == lastm {
+										if fi.ReverseFieldInfo != nil {
+											f := field.FieldByIndex(fi.FieldIndex)
+											if f.Kind() == reflect.Ptr {
+												f.Set(last.Addr())
 											}
 										}
 									}
-									last = field
 								}
+								last = field
 							}
-							cacheV[names] = &field
-							cacheM[names] = mmi
 						}
+						cacheV[names] = &field
+						cacheM[names] = mmi
 					}
-					trefs = trefs[len(mmi.Fields.DBcols):]
 				}
+				trefs = trefs[len(mmi.Fields.DBcols):]
 			}
+		}
 
-			if one {
-				ind.Set(mind)
-			} else {
-				if cnt == 0 {
-					// you can use an empty & caped container list
-					// orm will not replace it
-					if ind.Len() != 0 {
-						// if container is not empty
-						// create a new one
-						slice = reflect.New(ind.Type()).Elem()
-					}
-				}
-
-				if isPtr {
-					slice = reflect.Append(slice, mind.Addr())
-				} else {
-					slice = reflect.Append(slice, mind)
-				}
-			}
+		if err := fn(mind); err != nil {
+			return cnt, err
 		}
 		cnt++
 	}
 
 	if err = rs.Err(); err != nil {
-		return 0, err
-	}
-
-	if !one {
-		if cnt > 0 {
-			ind.Set(slice)
-		} else {
-			// when a result is empty and container is nil
-			// to Set an empty container
-			if ind.IsNil() {
-				ind.Set(reflect.MakeSlice(ind.Type(), 0, 0))
-			}
-		}
+		return cnt, err
 	}
-
 	return cnt, nil
 }
 
@@ -1323,10 +1768,13 @@ func (d *dbBase) readSQL(buf buffers.Buffer, tables *dbTables, tCols []string, c
 
 	where, args := tables.getCondSQL(cond, false, tz)
 	groupBy := tables.getGroupSQL(qs.groups)
+	tables.setAggregates(qs.annotations)
+	having, havingArgs := tables.getHavingSQL(qs.having, tz)
+	args = append(args, havingArgs...)
 	orderBy := tables.getOrderSQL(qs.orders)
 	limit := tables.getLimitSQL(mi, qs.offset, qs.limit)
 	join := tables.getJoinSQL()
-	specifyIndexes := tables.getIndexSql(mi.Table, qs.useIndex, qs.indexes)
+	specifyIndexes := tables.getIndexSql(d.ins.TableName(mi), qs.useIndex, qs.indexes)
 
 	_, _ = buf.WriteString("SELECT ")
 
@@ -1363,13 +1811,14 @@ func (d *dbBase) readSQL(buf buffers.Buffer, tables *dbTables, tCols []string, c
 
 	_, _ = buf.WriteString(" FROM ")
 	_, _ = buf.WriteString(quote)
-	_, _ = buf.WriteString(mi.Table)
+	_, _ = buf.WriteString(d.ins.TableName(mi))
 	_, _ = buf.WriteString(quote)
 	_, _ = buf.WriteString(" T0 ")
 	_, _ = buf.WriteString(specifyIndexes)
 	_, _ = buf.WriteString(join)
 	_, _ = buf.WriteString(where)
 	_, _ = buf.WriteString(groupBy)
+	_, _ = buf.WriteString(having)
 	_, _ = buf.WriteString(orderBy)
 	_, _ = buf.WriteString(limit)
 
@@ -1382,16 +1831,17 @@ func (d *dbBase) readSQL(buf buffers.Buffer, tables *dbTables, tCols []string, c
 
 // Count excute count sql and return count result int64.
 func (d *dbBase) Count(ctx context.Context, q dbQuerier, qs querySet, mi *models.ModelInfo, cond *Condition, tz *time.Location) (cnt int64, err error) {
-
-	query, args := d.countSQL(qs, mi, cond, tz)
+	cond = getSoftDeleteCond(ctx, &qs, mi, cond)
+	query, args := d.countSQL(ctx, qs, mi, cond, tz)
 
 	row := q.QueryRowContext(ctx, query, args...)
 	err = row.Scan(&cnt)
 	return
 }
 
-func (d *dbBase) countSQL(qs querySet, mi *models.ModelInfo, cond *Condition, tz *time.Location) (string, []interface{}) {
+func (d *dbBase) countSQL(ctx context.Context, qs querySet, mi *models.ModelInfo, cond *Condition, tz *time.Location) (string, []interface{}) {
 	tables := newDbTables(mi, d.ins)
+	tables.ctx = ctx
 	tables.parseRelated(qs.related, qs.relDepth)
 
 	buf := buffers.Get()
@@ -1480,6 +1930,36 @@ func (d *dbBase) GenerateOperatorLeftCol(*models.FieldInfo, string, *string) {
 	// default not use
 }
 
+// GenerateJSONPathCol generate the value of the json path in the json column col by JSON_EXTRACT,
+// the value is unquoted to text unless typed is true.
+func (d *dbBase) GenerateJSONPathCol(col string, path []string, typed bool) string {
+	if typed {
+		return fmt.Sprintf("JSON_EXTRACT(%s, '%s')", col, getJSONPath(path))
+	}
+	return fmt.Sprintf("JSON_UNQUOTE(JSON_EXTRACT(%s, '%s'))", col, getJSONPath(path))
+}
+
+// GenerateJSONOperatorSQL generate the condition of the json operator jcontains or has_key
+// for the value of the json path in the json column col.
+func (d *dbBase) GenerateJSONOperatorSQL(col string, path []string, operator string, args []interface{}) (string, []interface{}) {
+	switch operator {
+	case "jcontains":
+		if len(path) == 0 {
+			return fmt.Sprintf("JSON_CONTAINS(%s, ?)", col), []interface{}{getJSONArg(operator, args)}
+		}
+		return fmt.Sprintf("JSON_CONTAINS(%s, ?, '%s')", col, getJSONPath(path)), []interface{}{getJSONArg(operator, args)}
+	default:
+		key := getJSONKey(operator, args)
+		return fmt.Sprintf("JSON_CONTAINS_PATH(%s, 'one', '%s')", col, getJSONPath(append(slices.Clone(path), key))), nil
+	}
+}
+
+// GenerateArrayOperatorSQL generate the condition of the array operator contains or overlap,
+// the arrays are only supported by postgres.
+func (d *dbBase) GenerateArrayOperatorSQL(col string, operator string, arg interface{}) (string, []interface{}) {
+	panic(fmt.Errorf("operator `%s` of the array field is only supported by postgres", operator))
+}
+
 // Set values to struct column.
 func (d *dbBase) setColsValues(mi *models.ModelInfo, ind *reflect.Value, cols []string, values []interface{}, tz *time.Location) {
 	for i, column := range cols {
@@ -1508,6 +1988,14 @@ func (d *dbBase) convertValueFromDB(fi *models.FieldInfo, val interface{}, tz *t
 		return nil, nil
 	}
 
+	// the registered Fielder converts the value of the driver by itself
+	if isRegisteredFielder(fi) {
+		if b, ok := val.([]byte); ok {
+			return string(b), nil
+		}
+		return val, nil
+	}
+
 	var value interface{}
 	var tErr error
 
@@ -1670,6 +2158,10 @@ end:
 func (d *dbBase) setFieldValue(fi *models.FieldInfo, value interface{}, field reflect.Value) (interface{}, error) {
 	fieldType := fi.FieldType
 	isNative := !fi.IsFielder
+	// the value of the registered Fielder is not converted by the field type
+	if isRegisteredFielder(fi) {
+		goto setFielder
+	}
 
 setValue:
 	switch {
@@ -1845,6 +2337,7 @@ setValue:
 		}
 	}
 
+setFielder:
 	if !isNative {
 		fd := field.Addr().Interface().(models.Fielder)
 		err := fd.SetRaw(value)
@@ -1859,6 +2352,7 @@ setValue:
 
 // ReadValues query sql, read values , save to *[]ParamList.
 func (d *dbBase) ReadValues(ctx context.Context, q dbQuerier, qs querySet, mi *models.ModelInfo, cond *Condition, exprs []string, container interface{}, tz *time.Location) (int64, error) {
+	cond = getSoftDeleteCond(ctx, &qs, mi, cond)
 	var (
 		maps  []Params
 		lists []ParamsList
@@ -1890,13 +2384,19 @@ func (d *dbBase) ReadValues(ctx context.Context, q dbQuerier, qs querySet, mi *m
 	}
 
 	tables := newDbTables(mi, d.ins)
+	tables.ctx = ctx
 
 	var (
 		cols  []string
 		infos []*models.FieldInfo
+		aggs  []*Aggregation
 	)
 
-	hasExprs := len(exprs) > 0
+	if len(exprs) == 0 && len(qs.annotations) > 0 {
+		exprs = qs.groups
+	}
+
+	hasExprs := len(exprs) > 0 || len(qs.annotations) > 0
 
 	Q := d.ins.TableQuote()
 
@@ -1911,6 +2411,14 @@ func (d *dbBase) ReadValues(ctx context.Context, q dbQuerier, qs querySet, mi *m
 			cols = append(cols, fmt.Sprintf("%s.%s%s%s %s%s%s", index, Q, fi.Column, Q, Q, name, Q))
 			infos = append(infos, fi)
 		}
+		aggs = make([]*Aggregation, len(cols), len(cols)+len(qs.annotations))
+		for i := range qs.annotations {
+			a := &qs.annotations[i]
+			col, fi := tables.getAggregationSQL(*a)
+			cols = append(cols, fmt.Sprintf("%s %s%s%s", col, Q, a.Alias(), Q))
+			infos = append(infos, fi)
+			aggs = append(aggs, a)
+		}
 	} else {
 		cols = make([]string, 0, len(mi.Fields.DBcols))
 		infos = make([]*models.FieldInfo, 0, len(exprs))
@@ -1920,6 +2428,10 @@ func (d *dbBase) ReadValues(ctx context.Context, q dbQuerier, qs querySet, mi *m
 		}
 	}
 
+	if aggs == nil {
+		aggs = make([]*Aggregation, len(cols))
+	}
+
 	query, args := d.readValuesSQL(tables, cols, qs, mi, cond, tz)
 
 	rs, err := q.QueryContext(ctx, query, args...)
@@ -1959,7 +2471,7 @@ func (d *dbBase) ReadValues(ctx context.Context, q dbQuerier, qs querySet, mi *m
 
 				val := reflect.Indirect(reflect.ValueOf(ref)).Interface()
 
-				value, err := d.convertValueFromDB(fi, val, tz)
+				value, err := d.convertAggregationValue(aggs[i], fi, val, tz)
 				if err != nil {
 					panic(fmt.Errorf("db value convert failed `%v` %s", val, err.Error()))
 				}
@@ -1974,7 +2486,7 @@ func (d *dbBase) ReadValues(ctx context.Context, q dbQuerier, qs querySet, mi *m
 
 				val := reflect.Indirect(reflect.ValueOf(ref)).Interface()
 
-				value, err := d.convertValueFromDB(fi, val, tz)
+				value, err := d.convertAggregationValue(aggs[i], fi, val, tz)
 				if err != nil {
 					panic(fmt.Errorf("db value convert failed `%v` %s", val, err.Error()))
 				}
@@ -1988,7 +2500,7 @@ func (d *dbBase) ReadValues(ctx context.Context, q dbQuerier, qs querySet, mi *m
 
 				val := reflect.Indirect(reflect.ValueOf(ref)).Interface()
 
-				value, err := d.convertValueFromDB(fi, val, tz)
+				value, err := d.convertAggregationValue(aggs[i], fi, val, tz)
 				if err != nil {
 					panic(fmt.Errorf("db value convert failed `%v` %s", val, err.Error()))
 				}
@@ -2043,6 +2555,34 @@ func (d *dbBase) TableQuote() string {
 	return "`"
 }
 
+// TableName return the table name of the model with the table prefix, or the shard table of the sharded model.
+func (d *dbBase) TableName(mi *models.ModelInfo) string {
+	if table, ok := d.shardTables[mi.Table]; ok {
+		return d.tablePrefix + table
+	}
+	return d.tablePrefix + mi.Table
+}
+
+func (d *dbBase) setTablePrefix(prefix string) {
+	d.tablePrefix = prefix
+}
+
+func (d *dbBase) tableNames() (string, map[string]string) {
+	return d.tablePrefix, d.shardTables
+}
+
+func (d *dbBase) setSchema(schema string) {
+	d.schema = schema
+}
+
+func (d *dbBase) tableSchema() string {
+	return d.schema
+}
+
+func (d *dbBase) setShardTables(tables map[string]string) {
+	d.shardTables = tables
+}
+
 // ReplaceMarks replace value placeholder in parametered sql string.
 func (d *dbBase) ReplaceMarks(query *string) {
 	// default use `?` as mark, do nothing
@@ -2145,6 +2685,31 @@ func (d *dbBase) IndexExists(context.Context, dbQuerier, string, string) bool {
 	panic(ErrNotImplement)
 }
 
+// not implement.
+func (d *dbBase) GetSchemaColumns(context.Context, dbQuerier, string) ([]*schemaColumn, error) {
+	return nil, ErrNotImplement
+}
+
+// not implement.
+func (d *dbBase) GetSchemaIndexes(context.Context, dbQuerier, string) ([]*schemaIndex, error) {
+	return nil, ErrNotImplement
+}
+
+// not implement.
+func (d *dbBase) GetSchemaKeys(context.Context, dbQuerier, string) (*schemaKeys, error) {
+	return nil, ErrNotImplement
+}
+
+// NormalizeColumnType lower the column type and remove the redundant spaces and the CHECK constraints,
+// so that the types of models and database can be compared.
+func (d *dbBase) NormalizeColumnType(typ string) string {
+	typ = strings.Join(strings.Fields(strings.ToLower(typ)), " ")
+	if i := strings.Index(typ, " check"); i >= 0 {
+		typ = typ[:i]
+	}
+	return strings.ReplaceAll(typ, ", ", ",")
+}
+
 // GenerateSpecifyIndex return a specifying index clause
 func (d *dbBase) GenerateSpecifyIndex(tableName string, useIndex int, indexes []string) string {
 	var s []string
@@ -72,8 +72,49 @@ var (
 		DRPostgres: newdbBasePostgres(),
 		DRTiDB:     newdbBaseTidb(),
 	}
+	dbBaserCreators = map[DriverType]func() dbBaser{
+		DRMySQL:    newdbBaseMysql,
+		DRSqlite:   newdbBaseSqlite,
+		DROracle:   newdbBaseOracle,
+		DRPostgres: newdbBasePostgres,
+		DRTiDB:     newdbBaseTidb,
+	}
 )
 
+// tableNamer changes the table names and the schema of the dbBaser
+type tableNamer interface {
+	setTablePrefix(prefix string)
+	tableNames() (prefix string, shardTables map[string]string)
+	setShardTables(tables map[string]string)
+	setSchema(schema string)
+	tableSchema() string
+}
+
+// newdbBaserOfTenant creates the dbBaser of the driver which adds the prefix to the table names,
+// and inspects the tables of the schema
+func newdbBaserOfTenant(driver DriverType, prefix, schema string) dbBaser {
+	d := dbBaserCreators[driver]()
+	d.(tableNamer).setTablePrefix(prefix)
+	d.(tableNamer).setSchema(schema)
+	return d
+}
+
+// newdbBaserWithShard creates the dbBaser of the driver like base, which uses the shard table instead of the table
+func newdbBaserWithShard(driver DriverType, base dbBaser, table, shard string) dbBaser {
+	prefix, shards := base.(tableNamer).tableNames()
+	tables := make(map[string]string, len(shards)+1)
+	for k, v := range shards {
+		tables[k] = v
+	}
+	tables[table] = shard
+
+	d := dbBaserCreators[driver]()
+	d.(tableNamer).setTablePrefix(prefix)
+	d.(tableNamer).setShardTables(tables)
+	d.(tableNamer).setSchema(base.(tableNamer).tableSchema())
+	return d
+}
+
 // database alias cacher.
 type _dbCache struct {
 	mux   sync.RWMutex
@@ -295,6 +336,16 @@ type alias struct {
 	DbBaser         dbBaser
 	TZ              *time.Location
 	Engine          string
+	ReplicaSources  []string
+	Replicas        []*DB
+	Balancer        ReplicaBalancer
+	// Tenant is the name of the tenant using the alias, see RegisterTenant
+	Tenant string
+	// Schema is the schema of the tenant set as the search_path, see TenantSchema
+	Schema string
+
+	replicaDBs []*sql.DB
+	router     *replicaRouter
 }
 
 func detectTZ(al *alias) {
@@ -412,6 +463,10 @@ func newAliasWithDb(aliasName, driverName string, db *sql.DB, params ...DBOption
 		return nil, fmt.Errorf("Register db Ping `%s`, %s", aliasName, err.Error())
 	}
 
+	if err := al.openReplicas(); err != nil {
+		return nil, err
+	}
+
 	detectTZ(al)
 
 	return al, nil
@@ -435,22 +490,34 @@ func SetMaxOpenConns(aliasName string, maxOpenConns int) {
 func (al *alias) SetMaxIdleConns(maxIdleConns int) {
 	al.MaxIdleConns = maxIdleConns
 	al.DB.DB.SetMaxIdleConns(maxIdleConns)
+	for _, r := range al.Replicas {
+		r.DB.SetMaxIdleConns(maxIdleConns)
+	}
 }
 
 // SetMaxOpenConns Change the max open conns for *sql.DB, use specify database alias name
 func (al *alias) SetMaxOpenConns(maxOpenConns int) {
 	al.MaxOpenConns = maxOpenConns
 	al.DB.DB.SetMaxOpenConns(maxOpenConns)
+	for _, r := range al.Replicas {
+		r.DB.SetMaxOpenConns(maxOpenConns)
+	}
 }
 
 func (al *alias) SetConnMaxLifetime(lifeTime time.Duration) {
 	al.ConnMaxLifetime = lifeTime
 	al.DB.DB.SetConnMaxLifetime(lifeTime)
+	for _, r := range al.Replicas {
+		r.DB.SetConnMaxLifetime(lifeTime)
+	}
 }
 
 func (al *alias) SetConnMaxIdleTime(idleTime time.Duration) {
 	al.ConnMaxIdletime = idleTime
 	al.DB.DB.SetConnMaxIdleTime(idleTime)
+	for _, r := range al.Replicas {
+		r.DB.SetConnMaxIdleTime(idleTime)
+	}
 }
 
 // AddAliasWthDB add a aliasName for the drivename
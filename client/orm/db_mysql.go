@@ -16,8 +16,11 @@ package orm
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"reflect"
+	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/beego/beego/v2/client/orm/internal/models"
@@ -104,6 +107,134 @@ func (d *dbBaseMysql) IndexExists(ctx context.Context, db dbQuerier, table strin
 	return cnt > 0
 }
 
+// GetSchemaColumns Get the columns of table in their ordinal order.
+func (d *dbBaseMysql) GetSchemaColumns(ctx context.Context, db dbQuerier, table string) ([]*schemaColumn, error) {
+	rows, err := db.QueryContext(ctx, "SELECT COLUMN_NAME, COLUMN_TYPE, IS_NULLABLE, COLUMN_DEFAULT FROM information_schema.columns "+
+		"WHERE table_schema = DATABASE() AND table_name = ? ORDER BY ORDINAL_POSITION", table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns []*schemaColumn
+	for rows.Next() {
+		var (
+			col  schemaColumn
+			null string
+			def  sql.NullString
+		)
+		if err := rows.Scan(&col.Name, &col.Type, &null, &def); err != nil {
+			return nil, err
+		}
+		col.Null = null == "YES"
+		if def.Valid {
+			col.HasDefault = true
+			col.Default = mysqlDefaultExpr(def.String)
+		}
+		columns = append(columns, &col)
+	}
+	return columns, rows.Err()
+}
+
+// mysql reports the default value of string columns without quotes,
+// turn it into an expression which can be used in DDL.
+func mysqlDefaultExpr(v string) string {
+	if strings.HasPrefix(v, "'") {
+		return v
+	}
+	if _, err := strconv.ParseFloat(v, 64); err == nil {
+		return v
+	}
+	if strings.HasPrefix(strings.ToUpper(v), "CURRENT_TIMESTAMP") {
+		return v
+	}
+	return "'" + strings.ReplaceAll(v, "'", "''") + "'"
+}
+
+// GetSchemaIndexes Get the indexes of table, except the primary key.
+func (d *dbBaseMysql) GetSchemaIndexes(ctx context.Context, db dbQuerier, table string) ([]*schemaIndex, error) {
+	rows, err := db.QueryContext(ctx, "SELECT INDEX_NAME, NON_UNIQUE, COLUMN_NAME FROM information_schema.statistics "+
+		"WHERE table_schema = DATABASE() AND table_name = ? AND INDEX_NAME != 'PRIMARY' ORDER BY INDEX_NAME, SEQ_IN_INDEX", table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var indexes []*schemaIndex
+	for rows.Next() {
+		var (
+			name, column string
+			nonUnique    int
+		)
+		if err := rows.Scan(&name, &nonUnique, &column); err != nil {
+			return nil, err
+		}
+		indexes = appendSchemaIndex(indexes, name, nonUnique == 0, false, column)
+	}
+	return indexes, rows.Err()
+}
+
+// GetSchemaKeys Get the primary key and the foreign keys of table.
+func (d *dbBaseMysql) GetSchemaKeys(ctx context.Context, db dbQuerier, table string) (*schemaKeys, error) {
+	rows, err := db.QueryContext(ctx, "SELECT s.COLUMN_NAME, c.EXTRA FROM information_schema.statistics s "+
+		"JOIN information_schema.columns c ON c.table_schema = s.table_schema AND c.table_name = s.table_name AND c.column_name = s.column_name "+
+		"WHERE s.table_schema = DATABASE() AND s.table_name = ? AND s.INDEX_NAME = 'PRIMARY' ORDER BY s.SEQ_IN_INDEX", table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := &schemaKeys{}
+	for rows.Next() {
+		var column, extra string
+		if err := rows.Scan(&column, &extra); err != nil {
+			return nil, err
+		}
+		keys.Pk = append(keys.Pk, column)
+		if strings.Contains(strings.ToLower(extra), "auto_increment") {
+			keys.Auto = column
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	fkRows, err := db.QueryContext(ctx, "SELECT CONSTRAINT_NAME, COLUMN_NAME, REFERENCED_TABLE_NAME, REFERENCED_COLUMN_NAME FROM information_schema.key_column_usage "+
+		"WHERE table_schema = DATABASE() AND table_name = ? AND REFERENCED_TABLE_NAME IS NOT NULL ORDER BY CONSTRAINT_NAME, ORDINAL_POSITION", table)
+	if err != nil {
+		return nil, err
+	}
+	defer fkRows.Close()
+
+	for fkRows.Next() {
+		fk := &schemaForeignKey{}
+		if err := fkRows.Scan(&fk.Name, &fk.Column, &fk.RefTable, &fk.RefColumn); err != nil {
+			return nil, err
+		}
+		keys.Foreign = append(keys.Foreign, fk)
+	}
+	return keys, fkRows.Err()
+}
+
+var (
+	mysqlIntWidth  = regexp.MustCompile(`^(tinyint|smallint|mediumint|int|bigint)\(\d+\)`)
+	mysqlTypeAlias = strings.NewReplacer("integer", "int", "double precision", "double", "numeric", "decimal", "boolean", "tinyint(1)")
+)
+
+// NormalizeColumnType removes the display width of integers and unifies the aliases,
+// e.g. "integer" and "int(11)" are both "int", "bool" is "tinyint(1)".
+func (d *dbBaseMysql) NormalizeColumnType(typ string) string {
+	typ = d.dbBase.NormalizeColumnType(typ)
+	if typ == "bool" {
+		return "tinyint(1)"
+	}
+	typ = mysqlTypeAlias.Replace(typ)
+	if typ == "tinyint(1)" {
+		return typ
+	}
+	return mysqlIntWidth.ReplaceAllString(typ, "$1")
+}
+
 // InsertOrUpdate a row
 // If your primary key or unique column conflict will update
 // If no will insert
@@ -152,7 +283,7 @@ func (d *dbBaseMysql) InsertOrUpdate(ctx context.Context, q dbQuerier, mi *model
 	columns := strings.Join(names, sep)
 
 	// conflitValue maybe is an int,can`t use fmt.Sprintf
-	query := fmt.Sprintf("INSERT INTO %s%s%s (%s%s%s) VALUES (%s) %s "+qupdates, Q, mi.Table, Q, Q, columns, Q, qmarks, iouStr)
+	query := fmt.Sprintf("INSERT INTO %s%s%s (%s%s%s) VALUES (%s) %s "+qupdates, Q, d.ins.TableName(mi), Q, Q, columns, Q, qmarks, iouStr)
 
 	d.ins.ReplaceMarks(&query)
 
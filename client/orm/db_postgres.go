@@ -16,8 +16,10 @@ package orm
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"strconv"
+	"strings"
 
 	"github.com/beego/beego/v2/client/orm/internal/models"
 )
@@ -87,6 +89,42 @@ func (d *dbBasePostgres) GenerateOperatorLeftCol(fi *models.FieldInfo, operator
 	}
 }
 
+// GenerateJSONPathCol generate the value of the json path by #>> as text,
+// or by #> as jsonb if typed is true, which compares the numbers and sorts by the json types.
+func (d *dbBasePostgres) GenerateJSONPathCol(col string, path []string, typed bool) string {
+	if typed {
+		return fmt.Sprintf("(%s::jsonb #> '%s')", col, getPostgresJSONPath(path))
+	}
+	return fmt.Sprintf("(%s #>> '%s')", col, getPostgresJSONPath(path))
+}
+
+// GenerateJSONOperatorSQL generate the condition of jcontains by @> and has_key by jsonb_exists.
+func (d *dbBasePostgres) GenerateJSONOperatorSQL(col string, path []string, operator string, args []interface{}) (string, []interface{}) {
+	value := col + "::jsonb"
+	if len(path) > 0 {
+		value = fmt.Sprintf("(%s #> '%s')", value, getPostgresJSONPath(path))
+	}
+	switch operator {
+	case "jcontains":
+		return fmt.Sprintf("%s @> ?::jsonb", value), []interface{}{getJSONArg(operator, args)}
+	default:
+		return fmt.Sprintf("jsonb_exists(%s, ?)", value), []interface{}{getJSONKey(operator, args)}
+	}
+}
+
+// GenerateArrayOperatorSQL generate the condition of contains by @> and overlap by &&.
+func (d *dbBasePostgres) GenerateArrayOperatorSQL(col string, operator string, arg interface{}) (string, []interface{}) {
+	if operator == "overlap" {
+		return fmt.Sprintf("%s && ?", col), []interface{}{arg}
+	}
+	return fmt.Sprintf("%s @> ?", col), []interface{}{arg}
+}
+
+// the json path of postgresql is a text array, such as '{address,city}'.
+func getPostgresJSONPath(path []string) string {
+	return "{" + strings.Join(path, ",") + "}"
+}
+
 // postgresql unsupports updating joined record.
 func (d *dbBasePostgres) SupportUpdateJoin() bool {
 	return false
@@ -132,6 +170,9 @@ func (d *dbBasePostgres) ReplaceMarks(query *string) {
 // make returning sql support for postgresql.
 func (d *dbBasePostgres) HasReturningID(mi *models.ModelInfo, query *string) bool {
 	fi := mi.Fields.Pk
+	if fi == nil {
+		return false
+	}
 	if fi.FieldType&IsPositiveIntegerField == 0 && fi.FieldType&IsIntegerField == 0 {
 		return false
 	}
@@ -151,9 +192,9 @@ func (d *dbBasePostgres) setval(ctx context.Context, db dbQuerier, mi *models.Mo
 	Q := d.ins.TableQuote()
 	for _, name := range autoFields {
 		query := fmt.Sprintf("SELECT setval(pg_get_serial_sequence('%s', '%s'), (SELECT MAX(%s%s%s) FROM %s%s%s));",
-			mi.Table, name,
+			d.ins.TableName(mi), name,
 			Q, name, Q,
-			Q, mi.Table, Q)
+			Q, d.ins.TableName(mi), Q)
 		if _, err := db.ExecContext(ctx, query); err != nil {
 			return err
 		}
@@ -161,14 +202,22 @@ func (d *dbBasePostgres) setval(ctx context.Context, db dbQuerier, mi *models.Mo
 	return nil
 }
 
-// show table sql for postgresql.
+// show table sql for postgresql, only the schema in the search path is shown for the tenant schema.
 func (d *dbBasePostgres) ShowTablesQuery() string {
-	return "SELECT table_name FROM information_schema.tables WHERE table_type = 'BASE TABLE' AND table_schema NOT IN ('pg_catalog', 'information_schema')"
+	return "SELECT table_name FROM information_schema.tables WHERE table_type = 'BASE TABLE' AND " + d.schemaCond("table_schema")
 }
 
 // show table Columns sql for postgresql.
 func (d *dbBasePostgres) ShowColumnsQuery(table string) string {
-	return fmt.Sprintf("SELECT column_name, data_type, is_nullable FROM information_schema.Columns where table_schema NOT IN ('pg_catalog', 'information_schema') and table_name = '%s'", table)
+	return fmt.Sprintf("SELECT column_name, data_type, is_nullable FROM information_schema.Columns where %s and table_name = '%s'", d.schemaCond("table_schema"), table)
+}
+
+// schemaCond returns the condition of the schema column, the tenant schema only searches the schema in the search path
+func (d *dbBasePostgres) schemaCond(column string) string {
+	if d.schema != "" {
+		return column + " = ANY(current_schemas(false))"
+	}
+	return column + " NOT IN ('pg_catalog', 'information_schema')"
 }
 
 // Get column types of postgresql.
@@ -179,12 +228,159 @@ func (d *dbBasePostgres) DbTypes() map[string]string {
 // check index exist in postgresql.
 func (d *dbBasePostgres) IndexExists(ctx context.Context, db dbQuerier, table string, name string) bool {
 	query := fmt.Sprintf("SELECT COUNT(*) FROM pg_indexes WHERE tablename = '%s' AND indexname = '%s'", table, name)
+	if d.schema != "" {
+		query += " AND " + d.schemaCond("schemaname")
+	}
 	row := db.QueryRowContext(ctx, query)
 	var cnt int
 	row.Scan(&cnt)
 	return cnt > 0
 }
 
+// GetSchemaColumns Get the columns of table in their ordinal order.
+// Only the table visible in the search path is inspected.
+func (d *dbBasePostgres) GetSchemaColumns(ctx context.Context, db dbQuerier, table string) ([]*schemaColumn, error) {
+	query := `SELECT a.attname, format_type(a.atttypid, a.atttypmod), NOT a.attnotnull, pg_get_expr(ad.adbin, ad.adrelid)
+FROM pg_attribute a
+JOIN pg_class c ON c.oid = a.attrelid
+LEFT JOIN pg_attrdef ad ON ad.adrelid = a.attrelid AND ad.adnum = a.attnum
+WHERE c.relname = $1 AND c.relkind = 'r' AND pg_table_is_visible(c.oid) AND a.attnum > 0 AND NOT a.attisdropped
+ORDER BY a.attnum`
+	rows, err := db.QueryContext(ctx, query, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns []*schemaColumn
+	for rows.Next() {
+		var (
+			col schemaColumn
+			def sql.NullString
+		)
+		if err := rows.Scan(&col.Name, &col.Type, &col.Null, &def); err != nil {
+			return nil, err
+		}
+		col.HasDefault = def.Valid
+		col.Default = def.String
+		columns = append(columns, &col)
+	}
+	return columns, rows.Err()
+}
+
+// GetSchemaIndexes Get the indexes of table, except the primary key.
+func (d *dbBasePostgres) GetSchemaIndexes(ctx context.Context, db dbQuerier, table string) ([]*schemaIndex, error) {
+	query := `SELECT i.relname, ix.indisunique, con.oid IS NOT NULL, a.attname
+FROM pg_index ix
+JOIN pg_class t ON t.oid = ix.indrelid
+JOIN pg_class i ON i.oid = ix.indexrelid
+JOIN pg_attribute a ON a.attrelid = t.oid AND a.attnum = ANY(ix.indkey)
+LEFT JOIN pg_constraint con ON con.conindid = ix.indexrelid AND con.contype = 'u'
+WHERE t.relname = $1 AND pg_table_is_visible(t.oid) AND NOT ix.indisprimary
+ORDER BY i.relname, array_position(ix.indkey::int2[], a.attnum)`
+	rows, err := db.QueryContext(ctx, query, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var indexes []*schemaIndex
+	for rows.Next() {
+		var (
+			name, column       string
+			unique, constraint bool
+		)
+		if err := rows.Scan(&name, &unique, &constraint, &column); err != nil {
+			return nil, err
+		}
+		indexes = appendSchemaIndex(indexes, name, unique, constraint, column)
+	}
+	return indexes, rows.Err()
+}
+
+// GetSchemaKeys Get the primary key and the foreign keys of table,
+// the serial and identity columns are the auto increment columns.
+func (d *dbBasePostgres) GetSchemaKeys(ctx context.Context, db dbQuerier, table string) (*schemaKeys, error) {
+	query := `SELECT a.attname, COALESCE(pg_get_expr(ad.adbin, ad.adrelid), ''), a.attidentity::text
+FROM pg_index ix
+JOIN pg_class t ON t.oid = ix.indrelid
+JOIN pg_attribute a ON a.attrelid = t.oid AND a.attnum = ANY(ix.indkey)
+LEFT JOIN pg_attrdef ad ON ad.adrelid = a.attrelid AND ad.adnum = a.attnum
+WHERE t.relname = $1 AND pg_table_is_visible(t.oid) AND ix.indisprimary
+ORDER BY array_position(ix.indkey::int2[], a.attnum)`
+	rows, err := db.QueryContext(ctx, query, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := &schemaKeys{}
+	for rows.Next() {
+		var column, def, identity string
+		if err := rows.Scan(&column, &def, &identity); err != nil {
+			return nil, err
+		}
+		keys.Pk = append(keys.Pk, column)
+		if identity != "" || strings.HasPrefix(def, "nextval(") {
+			keys.Auto = column
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	query = `SELECT con.conname, a.attname, rt.relname, ra.attname
+FROM pg_constraint con
+JOIN pg_class t ON t.oid = con.conrelid
+JOIN pg_class rt ON rt.oid = con.confrelid
+JOIN LATERAL unnest(con.conkey, con.confkey) AS k(attnum, refnum) ON true
+JOIN pg_attribute a ON a.attrelid = con.conrelid AND a.attnum = k.attnum
+JOIN pg_attribute ra ON ra.attrelid = con.confrelid AND ra.attnum = k.refnum
+WHERE con.contype = 'f' AND t.relname = $1 AND pg_table_is_visible(t.oid)
+ORDER BY con.conname`
+	fkRows, err := db.QueryContext(ctx, query, table)
+	if err != nil {
+		return nil, err
+	}
+	defer fkRows.Close()
+
+	for fkRows.Next() {
+		fk := &schemaForeignKey{}
+		if err := fkRows.Scan(&fk.Name, &fk.Column, &fk.RefTable, &fk.RefColumn); err != nil {
+			return nil, err
+		}
+		keys.Foreign = append(keys.Foreign, fk)
+	}
+	return keys, fkRows.Err()
+}
+
+var postgresTypeAlias = map[string]string{
+	"bigserial":   "bigint",
+	"serial":      "integer",
+	"varchar":     "character varying",
+	"char":        "character",
+	"bool":        "boolean",
+	"int":         "integer",
+	"int2":        "smallint",
+	"int4":        "integer",
+	"int8":        "bigint",
+	"float8":      "double precision",
+	"timestamptz": "timestamp with time zone",
+}
+
+// NormalizeColumnType unifies the aliases to the names reported by format_type.
+func (d *dbBasePostgres) NormalizeColumnType(typ string) string {
+	typ = d.dbBase.NormalizeColumnType(typ)
+	name, args := typ, ""
+	if i := strings.Index(typ, "("); i >= 0 {
+		name, args = typ[:i], typ[i:]
+	}
+	if alias, ok := postgresTypeAlias[name]; ok {
+		name = alias
+	}
+	return name + args
+}
+
 // GenerateSpecifyIndex return a specifying index clause
 func (d *dbBasePostgres) GenerateSpecifyIndex(tableName string, useIndex int, indexes []string) string {
 	DebugLog.Println("[WARN] Not support any specifying index action, so that action is ignored")
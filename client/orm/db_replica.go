@@ -0,0 +1,243 @@
+// Copyright 2023 beego. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package orm
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// ReplicaBalancer picks the replica which serves a read query.
+// Returning nil means reading from the primary.
+type ReplicaBalancer interface {
+	Pick(ctx context.Context, replicas []*DB) *DB
+}
+
+// RoundRobinBalancer picks the replicas in turn
+type RoundRobinBalancer struct {
+	next uint64
+}
+
+func (b *RoundRobinBalancer) Pick(_ context.Context, replicas []*DB) *DB {
+	n := atomic.AddUint64(&b.next, 1)
+	return replicas[(n-1)%uint64(len(replicas))]
+}
+
+// RandomBalancer picks a replica randomly
+type RandomBalancer struct{}
+
+func (RandomBalancer) Pick(_ context.Context, replicas []*DB) *DB {
+	return replicas[rand.Intn(len(replicas))]
+}
+
+// Replicas return a hint about the data sources of the read replicas,
+// they are opened with the same driver as the primary.
+func Replicas(dataSources ...string) DBOption {
+	return func(al *alias) {
+		al.ReplicaSources = append(al.ReplicaSources, dataSources...)
+	}
+}
+
+// ReplicaDBs return a hint about the opened read replicas
+func ReplicaDBs(dbs ...*sql.DB) DBOption {
+	return func(al *alias) {
+		al.replicaDBs = append(al.replicaDBs, dbs...)
+	}
+}
+
+// ReplicaBalance return a hint about the ReplicaBalancer, RoundRobinBalancer is used by default
+func ReplicaBalance(b ReplicaBalancer) DBOption {
+	return func(al *alias) {
+		al.Balancer = b
+	}
+}
+
+type forcePrimaryKey struct{}
+
+// ForcePrimary returns a context which makes the read queries go to the primary,
+// it is used to read your own writes which may not reach the replicas yet.
+func ForcePrimary(ctx context.Context) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, forcePrimaryKey{}, true)
+}
+
+// IsForcePrimary checks whether the ctx is created by ForcePrimary
+func IsForcePrimary(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	v, _ := ctx.Value(forcePrimaryKey{}).(bool)
+	return v
+}
+
+// isReplicaQuery checks whether the query only reads data without locking the rows
+func isReplicaQuery(query string) bool {
+	q := strings.ToUpper(strings.TrimSpace(query))
+	if !strings.HasPrefix(q, "SELECT") {
+		return false
+	}
+	for _, lock := range []string{" FOR UPDATE", " FOR SHARE", " FOR NO KEY UPDATE", " FOR KEY SHARE", " LOCK IN SHARE MODE"} {
+		if strings.Contains(q, lock) {
+			return false
+		}
+	}
+	return true
+}
+
+// openReplicas opens the replicas registered by Replicas and ReplicaDBs
+func (al *alias) openReplicas() error {
+	if len(al.ReplicaSources) == 0 && len(al.replicaDBs) == 0 {
+		return nil
+	}
+
+	dbs := al.replicaDBs
+	for _, source := range al.ReplicaSources {
+		db, err := sql.Open(al.DriverName, source)
+		if err != nil {
+			al.closeReplicas(dbs[len(al.replicaDBs):])
+			return fmt.Errorf("Register replica db `%s`, %s", al.Name, err.Error())
+		}
+		dbs = append(dbs, db)
+	}
+
+	for _, db := range dbs {
+		if err := db.Ping(); err != nil {
+			al.closeReplicas(dbs[len(al.replicaDBs):])
+			return fmt.Errorf("Register replica db Ping `%s`, %s", al.Name, err.Error())
+		}
+	}
+
+	for _, db := range dbs {
+		replica := &DB{
+			RWMutex: new(sync.RWMutex),
+			DB:      db,
+		}
+		if al.StmtCacheSize > 0 {
+			stmtCache, err := newStmtDecoratorLruWithEvict(al.StmtCacheSize)
+			if err != nil {
+				return err
+			}
+			replica.stmtDecorators = stmtCache
+			replica.stmtDecoratorsLimit = al.StmtCacheSize
+		}
+		al.applyConnSettings(db)
+		al.Replicas = append(al.Replicas, replica)
+	}
+
+	if al.Balancer == nil {
+		al.Balancer = &RoundRobinBalancer{}
+	}
+	al.router = &replicaRouter{al: al}
+	return nil
+}
+
+func (*alias) closeReplicas(dbs []*sql.DB) {
+	for _, db := range dbs {
+		_ = db.Close()
+	}
+}
+
+// applyConnSettings sync the connection pool settings of the primary to the replica
+func (al *alias) applyConnSettings(db *sql.DB) {
+	if al.MaxIdleConns != 0 {
+		db.SetMaxIdleConns(al.MaxIdleConns)
+	}
+	if al.MaxOpenConns != 0 {
+		db.SetMaxOpenConns(al.MaxOpenConns)
+	}
+	if al.ConnMaxLifetime != 0 {
+		db.SetConnMaxLifetime(al.ConnMaxLifetime)
+	}
+	if al.ConnMaxIdletime != 0 {
+		db.SetConnMaxIdleTime(al.ConnMaxIdletime)
+	}
+}
+
+// querier return the dbQuerier used by Ormer,
+// it routes the read queries to the replicas if there are any.
+func (al *alias) querier() dbQuerier {
+	if al.router != nil {
+		return al.router
+	}
+	return al.DB
+}
+
+// replicaRouter sends the SELECT queries without row locks to the replicas, and the others to the primary.
+// The statements which modify data are sent with the ctx created by ForcePrimary.
+type replicaRouter struct {
+	al *alias
+}
+
+var (
+	_ dbQuerier = new(replicaRouter)
+	_ txer      = new(replicaRouter)
+)
+
+func (r *replicaRouter) reader(ctx context.Context, query string) *DB {
+	if IsForcePrimary(ctx) || !isReplicaQuery(query) {
+		return r.al.DB
+	}
+	if db := r.al.Balancer.Pick(ctx, r.al.Replicas); db != nil {
+		return db
+	}
+	return r.al.DB
+}
+
+func (r *replicaRouter) Begin() (*sql.Tx, error) {
+	return r.al.DB.Begin()
+}
+
+func (r *replicaRouter) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	return r.al.DB.BeginTx(ctx, opts)
+}
+
+func (r *replicaRouter) Prepare(query string) (*sql.Stmt, error) {
+	return r.al.DB.Prepare(query)
+}
+
+func (r *replicaRouter) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return r.al.DB.PrepareContext(ctx, query)
+}
+
+func (r *replicaRouter) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return r.al.DB.Exec(query, args...)
+}
+
+func (r *replicaRouter) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return r.al.DB.ExecContext(ctx, query, args...)
+}
+
+func (r *replicaRouter) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return r.QueryContext(context.Background(), query, args...)
+}
+
+func (r *replicaRouter) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return r.reader(ctx, query).QueryContext(ctx, query, args...)
+}
+
+func (r *replicaRouter) QueryRow(query string, args ...interface{}) *sql.Row {
+	return r.QueryRowContext(context.Background(), query, args...)
+}
+
+func (r *replicaRouter) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return r.reader(ctx, query).QueryRowContext(ctx, query, args...)
+}
@@ -0,0 +1,102 @@
+// Copyright 2023 beego. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package orm
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type primaryBalancer struct{}
+
+func (primaryBalancer) Pick(context.Context, []*DB) *DB {
+	return nil
+}
+
+func TestReplicas(t *testing.T) {
+	dir := t.TempDir()
+	sources := make([]string, 0, 3)
+	for _, name := range []string{"primary", "replica1", "replica2"} {
+		source := filepath.Join(dir, name+".db")
+		db, err := sql.Open("sqlite3", source)
+		require.NoError(t, err)
+		_, err = db.Exec("CREATE TABLE replica_test (name text)")
+		require.NoError(t, err)
+		_, err = db.Exec("INSERT INTO replica_test (name) VALUES (?)", name)
+		require.NoError(t, err)
+		require.NoError(t, db.Close())
+		sources = append(sources, source)
+	}
+
+	err := RegisterDataBase("test-replicas", "sqlite3", sources[0],
+		Replicas(sources[1:]...), MaxIdleConnections(5))
+	require.NoError(t, err)
+	al := getDbAlias("test-replicas")
+	assert.Len(t, al.Replicas, 2)
+	assert.IsType(t, &RoundRobinBalancer{}, al.Balancer)
+
+	// NewOrmUsingDB bootstraps the models, which are registered by the tests later
+	newOrm := func(name string) Ormer {
+		al := getDbAlias(name)
+		return &orm{ormBase: ormBase{alias: al, db: al.querier()}}
+	}
+	o := newOrm("test-replicas")
+	read := func(ctx context.Context, o QueryExecutor) string {
+		var name string
+		require.NoError(t, o.RawWithCtx(ctx, "SELECT name FROM replica_test").QueryRow(&name))
+		return name
+	}
+	ctx := context.Background()
+	assert.Equal(t, "replica1", read(ctx, o))
+	assert.Equal(t, "replica2", read(ctx, o))
+	assert.Equal(t, "replica1", read(ctx, o))
+	assert.Equal(t, "primary", read(ForcePrimary(ctx), o))
+
+	_, err = o.Raw("UPDATE replica_test SET name = ?", "primary-updated").Exec()
+	require.NoError(t, err)
+	assert.Equal(t, "primary-updated", read(ForcePrimary(ctx), o))
+	assert.Equal(t, "replica2", read(ctx, o))
+
+	err = o.DoTx(func(ctx context.Context, txOrm TxOrmer) error {
+		assert.Equal(t, "primary-updated", read(ctx, txOrm))
+		return nil
+	})
+	require.NoError(t, err)
+
+	err = RegisterDataBase("test-replicas-balancer", "sqlite3", sources[0],
+		Replicas(sources[1:]...), ReplicaBalance(primaryBalancer{}))
+	require.NoError(t, err)
+	assert.Equal(t, "primary-updated", read(ctx, newOrm("test-replicas-balancer")))
+}
+
+func TestIsReplicaQuery(t *testing.T) {
+	assert.True(t, isReplicaQuery(" select * from user where id = ?"))
+	assert.False(t, isReplicaQuery("SELECT * FROM user WHERE id = ? FOR UPDATE"))
+	assert.False(t, isReplicaQuery("select * from user for share"))
+	assert.False(t, isReplicaQuery("SELECT * FROM user LOCK IN SHARE MODE"))
+	assert.False(t, isReplicaQuery("INSERT INTO user (name) VALUES ($1) RETURNING id"))
+	assert.False(t, isReplicaQuery("UPDATE user SET name = ?"))
+}
+
+func TestForcePrimary(t *testing.T) {
+	ctx := context.Background()
+	assert.False(t, IsForcePrimary(ctx))
+	assert.True(t, IsForcePrimary(ForcePrimary(ctx)))
+}
@@ -17,8 +17,12 @@ package orm
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"reflect"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -96,6 +100,70 @@ func (d *dbBaseSqlite) GenerateOperatorLeftCol(fi *models.FieldInfo, operator st
 	}
 }
 
+// GenerateJSONPathCol generate the value of the json path by json_extract,
+// which returns the sql values for the json scalars, so typed is ignored.
+func (d *dbBaseSqlite) GenerateJSONPathCol(col string, path []string, _ bool) string {
+	return fmt.Sprintf("json_extract(%s, '%s')", col, getJSONPath(path))
+}
+
+// GenerateJSONOperatorSQL generate the condition of the json operator,
+// sqlite has no json containment, so the json value of jcontains is compared by its scalars.
+func (d *dbBaseSqlite) GenerateJSONOperatorSQL(col string, path []string, operator string, args []interface{}) (string, []interface{}) {
+	switch operator {
+	case "jcontains":
+		var value interface{}
+		if err := json.Unmarshal([]byte(getJSONArg(operator, args)), &value); err != nil {
+			panic(fmt.Errorf("operator `%s` need a json value, %s", operator, err))
+		}
+		wheres, params := d.jsonContainsSQL(col, path, value)
+		if len(wheres) == 0 {
+			return fmt.Sprintf("json_type(%s, '%s') IS NOT NULL", col, getJSONPath(path)), nil
+		}
+		return "(" + strings.Join(wheres, " AND ") + ")", params
+	default:
+		key := getJSONKey(operator, args)
+		return fmt.Sprintf("json_type(%s, '%s') IS NOT NULL", col, getJSONPath(append(slices.Clone(path), key))), nil
+	}
+}
+
+// jsonContainsSQL compares the scalars in the json value with the json path,
+// the scalars in the arrays are compared with any element of the arrays.
+func (d *dbBaseSqlite) jsonContainsSQL(col string, path []string, value interface{}) ([]string, []interface{}) {
+	var wheres []string
+	var params []interface{}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			if !jsonPathKey.MatchString(key) {
+				panic(fmt.Errorf("operator `jcontains` need json keys of word characters not `%s`", key))
+			}
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			w, p := d.jsonContainsSQL(col, append(slices.Clone(path), key), v[key])
+			wheres = append(wheres, w...)
+			params = append(params, p...)
+		}
+	case []interface{}:
+		for _, elm := range v {
+			switch elm.(type) {
+			case map[string]interface{}, []interface{}:
+				panic(fmt.Errorf("operator `jcontains` of sqlite only support the scalars in the arrays"))
+			}
+			wheres = append(wheres, fmt.Sprintf("EXISTS (SELECT 1 FROM json_each(%s, '%s') WHERE value = ?)", col, getJSONPath(path)))
+			params = append(params, elm)
+		}
+	case nil:
+		wheres = append(wheres, fmt.Sprintf("json_type(%s, '%s') = 'null'", col, getJSONPath(path)))
+	default:
+		wheres = append(wheres, fmt.Sprintf("json_extract(%s, '%s') = ?", col, getJSONPath(path)))
+		params = append(params, v)
+	}
+	return wheres, params
+}
+
 // unable updating joined record in sqlite.
 func (d *dbBaseSqlite) SupportUpdateJoin() bool {
 	return false
@@ -160,6 +228,159 @@ func (d *dbBaseSqlite) IndexExists(ctx context.Context, db dbQuerier, table stri
 	return false
 }
 
+// GetSchemaColumns Get the columns of table in their ordinal order.
+func (d *dbBaseSqlite) GetSchemaColumns(ctx context.Context, db dbQuerier, table string) ([]*schemaColumn, error) {
+	rows, err := db.QueryContext(ctx, d.ins.ShowColumnsQuery(table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns []*schemaColumn
+	for rows.Next() {
+		var (
+			col     schemaColumn
+			cid, pk int
+			notNull bool
+			def     sql.NullString
+		)
+		if err := rows.Scan(&cid, &col.Name, &col.Type, &notNull, &def, &pk); err != nil {
+			return nil, err
+		}
+		col.Null = !notNull
+		col.HasDefault = def.Valid
+		col.Default = def.String
+		columns = append(columns, &col)
+	}
+	return columns, rows.Err()
+}
+
+// GetSchemaIndexes Get the indexes of table, except the primary key.
+// The indexes created by UNIQUE constraints are marked, they can't be dropped by DROP INDEX.
+func (d *dbBaseSqlite) GetSchemaIndexes(ctx context.Context, db dbQuerier, table string) ([]*schemaIndex, error) {
+	rows, err := db.QueryContext(ctx, fmt.Sprintf("PRAGMA index_list('%s')", table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []*schemaIndex
+	for rows.Next() {
+		var (
+			seq, partial int
+			name, origin string
+			unique       bool
+		)
+		if err := rows.Scan(&seq, &name, &unique, &origin, &partial); err != nil {
+			return nil, err
+		}
+		if origin == "pk" {
+			continue
+		}
+		list = append(list, &schemaIndex{Name: name, Unique: unique, Constraint: origin == "u"})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, index := range list {
+		columns, err := d.getIndexColumns(ctx, db, index.Name)
+		if err != nil {
+			return nil, err
+		}
+		index.Columns = columns
+	}
+	return list, nil
+}
+
+// GetSchemaKeys Get the primary key and the foreign keys of table,
+// the INTEGER PRIMARY KEY column is the auto increment column.
+func (d *dbBaseSqlite) GetSchemaKeys(ctx context.Context, db dbQuerier, table string) (*schemaKeys, error) {
+	rows, err := db.QueryContext(ctx, d.ins.ShowColumnsQuery(table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	type pkColumn struct {
+		name string
+		typ  string
+		pos  int
+	}
+	var pks []pkColumn
+	for rows.Next() {
+		var (
+			cid, pk   int
+			notNull   bool
+			name, typ string
+			def       sql.NullString
+		)
+		if err := rows.Scan(&cid, &name, &typ, &notNull, &def, &pk); err != nil {
+			return nil, err
+		}
+		if pk > 0 {
+			pks = append(pks, pkColumn{name: name, typ: typ, pos: pk})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	slices.SortFunc(pks, func(a, b pkColumn) int {
+		return a.pos - b.pos
+	})
+
+	keys := &schemaKeys{}
+	for _, pk := range pks {
+		keys.Pk = append(keys.Pk, pk.name)
+	}
+	if len(pks) == 1 && strings.EqualFold(pks[0].typ, "integer") {
+		keys.Auto = pks[0].name
+	}
+
+	fkRows, err := db.QueryContext(ctx, fmt.Sprintf("PRAGMA foreign_key_list('%s')", table))
+	if err != nil {
+		return nil, err
+	}
+	defer fkRows.Close()
+
+	for fkRows.Next() {
+		var (
+			id, seq                   int
+			to                        sql.NullString
+			onUpdate, onDelete, match string
+		)
+		fk := &schemaForeignKey{}
+		if err := fkRows.Scan(&id, &seq, &fk.RefTable, &fk.Column, &to, &onUpdate, &onDelete, &match); err != nil {
+			return nil, err
+		}
+		fk.Name = strconv.Itoa(id)
+		fk.RefColumn = to.String
+		keys.Foreign = append(keys.Foreign, fk)
+	}
+	return keys, fkRows.Err()
+}
+
+func (d *dbBaseSqlite) getIndexColumns(ctx context.Context, db dbQuerier, index string) ([]string, error) {
+	rows, err := db.QueryContext(ctx, fmt.Sprintf("PRAGMA index_info('%s')", index))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns []string
+	for rows.Next() {
+		var (
+			seqno, cid int
+			name       string
+		)
+		if err := rows.Scan(&seqno, &cid, &name); err != nil {
+			return nil, err
+		}
+		columns = append(columns, name)
+	}
+	return columns, rows.Err()
+}
+
 // GenerateSpecifyIndex return a specifying index clause
 func (d *dbBaseSqlite) GenerateSpecifyIndex(tableName string, useIndex int, indexes []string) string {
 	var s []string
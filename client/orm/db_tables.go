@@ -15,6 +15,7 @@
 package orm
 
 import (
+	"context"
 	"fmt"
 	"strings"
 	"time"
@@ -45,6 +46,19 @@ type dbTables struct {
 	mi      *models.ModelInfo
 	base    dbBaser
 	skipEnd bool
+
+	// prefix of the table aliases, which is not empty in the subqueries
+	prefix string
+	// the tables of the outer query, which the OuterRef refers to
+	outer *dbTables
+	// the number of the subqueries
+	subs int
+	// the annotations by the aliases, which can be used in having and order
+	aggregates map[string]Aggregation
+	// the selected related tables keep the soft deleted rows
+	withDeleted bool
+	// the context of the query, which decides the soft delete conditions of the subqueries
+	ctx context.Context
 }
 
 // set table info to collection.
@@ -58,7 +72,7 @@ func (t *dbTables) set(names []string, mi *models.ModelInfo, fi *models.FieldInf
 		j.inner = inner
 	} else {
 		i := len(t.tables) + 1
-		jt := &dbTable{i, fmt.Sprintf("T%d", i), name, names, false, inner, mi, fi, nil}
+		jt := &dbTable{i, fmt.Sprintf("%sT%d", t.prefix, i), name, names, false, inner, mi, fi, nil}
 		t.tablesM[name] = jt
 		t.tables = append(t.tables, jt)
 	}
@@ -70,7 +84,7 @@ func (t *dbTables) add(names []string, mi *models.ModelInfo, fi *models.FieldInf
 	name := strings.Join(names, ExprSep)
 	if _, ok := t.tablesM[name]; !ok {
 		i := len(t.tables) + 1
-		jt := &dbTable{i, fmt.Sprintf("T%d", i), name, names, false, inner, mi, fi, nil}
+		jt := &dbTable{i, fmt.Sprintf("%sT%d", t.prefix, i), name, names, false, inner, mi, fi, nil}
 		t.tablesM[name] = jt
 		t.tables = append(t.tables, jt)
 		return jt, true
@@ -171,8 +185,20 @@ func (t *dbTables) parseRelated(rels []string, depth int) {
 func (t *dbTables) getJoinSQL() (join string) {
 	Q := t.base.TableQuote()
 
+	// the selected related tables exclude the soft deleted rows in the ON clause,
+	// they and the tables joined after them use LEFT OUTER JOIN to keep the rows of the main table
+	outer := make(map[*dbTable]bool)
 	for _, jt := range t.tables {
-		if jt.inner {
+		var softDelete string
+		if sfi := jt.mi.Fields.SoftDelete; sfi != nil && jt.sel && !t.withDeleted {
+			softDelete = fmt.Sprintf(" AND %s.%s%s%s IS NULL", jt.index, Q, sfi.Column, Q)
+			outer[jt] = true
+		}
+		if jt.jtl != nil && outer[jt.jtl] {
+			outer[jt] = true
+		}
+
+		if jt.inner && !outer[jt] {
 			join += "INNER JOIN "
 		} else {
 			join += "LEFT OUTER JOIN "
@@ -182,12 +208,12 @@ func (t *dbTables) getJoinSQL() (join string) {
 			t1, t2 string
 			c1, c2 string
 		)
-		t1 = "T0"
+		t1 = t.prefix + "T0"
 		if jt.jtl != nil {
 			t1 = jt.jtl.index
 		}
 		t2 = jt.index
-		table = jt.mi.Table
+		table = t.base.TableName(jt.mi)
 
 		switch {
 		case jt.fi.FieldType == RelManyToMany || jt.fi.FieldType == RelReverseMany || jt.fi.Reverse && jt.fi.ReverseFieldInfo.FieldType == RelManyToMany:
@@ -208,8 +234,8 @@ func (t *dbTables) getJoinSQL() (join string) {
 			}
 		}
 
-		join += fmt.Sprintf("%s%s%s %s ON %s.%s%s%s = %s.%s%s%s ", Q, table, Q, t2,
-			t2, Q, c2, Q, t1, Q, c1, Q)
+		join += fmt.Sprintf("%s%s%s %s ON %s.%s%s%s = %s.%s%s%s%s ", Q, table, Q, t2,
+			t2, Q, c2, Q, t1, Q, c1, Q, softDelete)
 	}
 	return
 }
@@ -289,7 +315,7 @@ loopFor:
 		loopEnd:
 
 			if i == 0 || jtl == nil {
-				index = "T0"
+				index = t.prefix + "T0"
 			} else {
 				index = jtl.index
 			}
@@ -329,6 +355,31 @@ loopFor:
 	return
 }
 
+// parse the expressions with the json path of the json field, such as Extra__json__address__city,
+// ok is false if there is no json field followed by the json segment.
+func (t *dbTables) parseJSONExprs(mi *models.ModelInfo, exprs []string) (index string, fi *models.FieldInfo, path []string, ok bool) {
+	for i := 1; i < len(exprs); i++ {
+		if exprs[i] != "json" {
+			continue
+		}
+		index, _, fi, ok = t.parseExprs(mi, exprs[:i])
+		if !ok || !isJSONField(fi) {
+			return "", nil, nil, false
+		}
+		path = exprs[i+1:]
+		if len(path) == 0 {
+			panic(fmt.Errorf("json path is empty in `%s`", strings.Join(exprs, ExprSep)))
+		}
+		for _, key := range path {
+			if !jsonPathKey.MatchString(key) {
+				panic(fmt.Errorf("json path key `%s` can only contain word characters and -", key))
+			}
+		}
+		return index, fi, path, true
+	}
+	return "", nil, nil, false
+}
+
 // generate condition sql.
 func (t *dbTables) getCondSQL(cond *Condition, sub bool, tz *time.Location) (where string, params []interface{}) {
 	if cond == nil || cond.IsEmpty() {
@@ -357,6 +408,10 @@ func (t *dbTables) getCondSQL(cond *Condition, sub bool, tz *time.Location) (whe
 			}
 			where += w
 			params = append(params, ps...)
+		} else if p.sub != nil {
+			w, ps := t.getSubQuerySQL(p.sub, tz)
+			where += fmt.Sprintf("EXISTS (%s) ", w)
+			params = append(params, ps...)
 		} else {
 			exprs := p.exprs
 
@@ -367,25 +422,67 @@ func (t *dbTables) getCondSQL(cond *Condition, sub bool, tz *time.Location) (whe
 				exprs = exprs[:num]
 			}
 
-			index, _, fi, suc := t.parseExprs(mi, exprs)
-			if !suc {
-				panic(fmt.Errorf("unknown field/column name `%s`", strings.Join(p.exprs, ExprSep)))
+			var (
+				leftCol string
+				fi      *models.FieldInfo
+				path    []string
+				isJSON  bool
+			)
+			if a, ok := t.aggregates[strings.Join(exprs, ExprSep)]; ok {
+				leftCol, fi = t.getAggregationSQL(a)
+			} else {
+				var index string
+				index, fi, path, isJSON = t.parseJSONExprs(mi, exprs)
+				if !isJSON {
+					var suc bool
+					index, _, fi, suc = t.parseExprs(mi, exprs)
+					if !suc {
+						panic(fmt.Errorf("unknown field/column name `%s`", strings.Join(p.exprs, ExprSep)))
+					}
+				}
+				leftCol = fmt.Sprintf("%s.%s%s%s", index, Q, fi.Column, Q)
 			}
 
 			if operator == "" {
 				operator = "exact"
 			}
 
+			if arrayOperators[operator] && fi != nil && isArrayField(fi) && !isJSON && !p.isRaw {
+				w, args := t.base.GenerateArrayOperatorSQL(leftCol, operator, getArrayArg(fi, p.args))
+				where += w + " "
+				params = append(params, args...)
+				continue
+			}
+			if operator == "overlap" && !p.isRaw {
+				panic(fmt.Errorf("operator `%s` need an array field but `%s` is not", operator, strings.Join(exprs, ExprSep)))
+			}
+
+			if jsonOperators[operator] && !p.isRaw {
+				if fi == nil || !isJSONField(fi) {
+					panic(fmt.Errorf("operator `%s` need a json field but `%s` is not", operator, strings.Join(exprs, ExprSep)))
+				}
+				w, args := t.base.GenerateJSONOperatorSQL(leftCol, path, operator, p.args)
+				where += w + " "
+				params = append(params, args...)
+				continue
+			}
+
 			var operSQL string
 			var args []interface{}
 			if p.isRaw {
 				operSQL = p.sql
+			} else if refSQL, refArgs, ok := t.getRefOperatorSQL(operator, p.args, tz); ok {
+				operSQL, args = refSQL, refArgs
 			} else {
 				operSQL, args = t.base.GenerateOperatorSQL(mi, fi, operator, p.args, tz)
 			}
 
-			leftCol := fmt.Sprintf("%s.%s%s%s", index, Q, fi.Column, Q)
-			t.base.GenerateOperatorLeftCol(fi, operator, &leftCol)
+			if isJSON {
+				leftCol = t.base.GenerateJSONPathCol(leftCol, path, isNumericArg(args))
+			}
+			if fi != nil {
+				t.base.GenerateOperatorLeftCol(fi, operator, &leftCol)
+			}
 
 			where += fmt.Sprintf("%s %s ", leftCol, operSQL)
 			params = append(params, args...)
@@ -400,6 +497,144 @@ func (t *dbTables) getCondSQL(cond *Condition, sub bool, tz *time.Location) (whe
 	return
 }
 
+// generate the sql of the operator whose value is a subquery or an OuterRef,
+// ok is false if the value is neither of them.
+func (t *dbTables) getRefOperatorSQL(operator string, args []interface{}, tz *time.Location) (sql string, params []interface{}, ok bool) {
+	if len(args) != 1 {
+		return "", nil, false
+	}
+	switch arg := args[0].(type) {
+	case QuerySeter:
+		qs, ok := arg.(*querySet)
+		if !ok {
+			panic(fmt.Errorf("operator `%s` unsupported QuerySeter `%T`", operator, arg))
+		}
+		sub, params := t.getSubQuerySQL(qs, tz)
+		if operator == "in" {
+			return fmt.Sprintf("IN (%s)", sub), params, true
+		}
+		if !refOperators[operator] {
+			panic(fmt.Errorf("operator `%s` does not support the subquery", operator))
+		}
+		return strings.Replace(t.base.OperatorSQL(operator), "?", "("+sub+")", 1), params, true
+	case OuterRef:
+		if t.outer == nil {
+			panic(fmt.Errorf("OuterRef `%s` can only be used in a subquery", arg))
+		}
+		if !refOperators[operator] {
+			panic(fmt.Errorf("operator `%s` does not support the OuterRef", operator))
+		}
+		index, _, fi, suc := t.outer.parseExprs(t.outer.mi, strings.Split(string(arg), ExprSep))
+		if !suc {
+			panic(fmt.Errorf("unknown field/column name `%s` of the OuterRef", arg))
+		}
+		Q := t.base.TableQuote()
+		col := fmt.Sprintf("%s.%s%s%s", index, Q, fi.Column, Q)
+		return strings.Replace(t.base.OperatorSQL(operator), "?", col, 1), nil, true
+	}
+	return "", nil, false
+}
+
+// generate the sql of the subquery, which selects the aggregate or the annotations if any,
+// else the group by fields, else the primary key.
+// the tables of it are prefixed to refer to the tables of the outer query.
+func (t *dbTables) getSubQuerySQL(qs *querySet, tz *time.Location) (string, []interface{}) {
+	t.subs++
+	tables := newDbTables(qs.mi, t.base)
+	tables.prefix = fmt.Sprintf("%sS%d", t.prefix, t.subs)
+	tables.outer = t
+	tables.ctx = t.ctx
+	if tables.ctx == nil {
+		tables.ctx = context.Background()
+	}
+
+	Q := t.base.TableQuote()
+
+	var cols []string
+	switch {
+	case qs.aggregate != "":
+		cols = []string{qs.aggregate}
+	case len(qs.annotations) > 0:
+		for _, a := range qs.annotations {
+			col, _ := tables.getAggregationSQL(a)
+			cols = append(cols, col)
+		}
+	case len(qs.groups) > 0:
+		for _, group := range qs.groups {
+			index, _, fi, suc := tables.parseExprs(qs.mi, strings.Split(group, ExprSep))
+			if !suc {
+				panic(fmt.Errorf("unknown field/column name `%s`", group))
+			}
+			cols = append(cols, fmt.Sprintf("%s.%s%s%s", index, Q, fi.Column, Q))
+		}
+	default:
+		cols = getPkColumns(qs.mi, Q, tables.prefix+"T0.")
+	}
+
+	cond := getSoftDeleteCond(tables.ctx, qs, qs.mi, qs.cond)
+	where, args := tables.getCondSQL(cond, false, tz)
+	groupBy := tables.getGroupSQL(qs.groups)
+	tables.setAggregates(qs.annotations)
+	having, havingArgs := tables.getHavingSQL(qs.having, tz)
+	args = append(args, havingArgs...)
+	limit := ""
+	if qs.limit > 0 {
+		limit = tables.getLimitSQL(qs.mi, qs.offset, qs.limit)
+	}
+	join := tables.getJoinSQL()
+
+	sql := "SELECT "
+	if qs.distinct {
+		sql += "DISTINCT "
+	}
+	sql += fmt.Sprintf("%s FROM %s%s%s %sT0 %s%s%s%s%s", strings.Join(cols, ", "), Q, tables.base.TableName(qs.mi), Q, tables.prefix,
+		join, where, groupBy, having, limit)
+	return strings.TrimSpace(sql), args
+}
+
+// generate the sql of the aggregation,
+// fi is the aggregated field, which is nil for Count("*").
+func (t *dbTables) getAggregationSQL(a Aggregation) (string, *models.FieldInfo) {
+	if a.expr == "*" {
+		if a.fn != "COUNT" {
+			panic(fmt.Errorf("aggregation `%s` does not support `*`", a.fn))
+		}
+		return "COUNT(*)", nil
+	}
+
+	index, _, fi, suc := t.parseExprs(t.mi, strings.Split(a.expr, ExprSep))
+	if !suc {
+		panic(fmt.Errorf("unknown field/column name `%s`", a.expr))
+	}
+
+	Q := t.base.TableQuote()
+	col := fmt.Sprintf("%s.%s%s%s", index, Q, fi.Column, Q)
+	if a.distinct {
+		col = "DISTINCT " + col
+	}
+	return fmt.Sprintf("%s(%s)", a.fn, col), fi
+}
+
+// set the annotations, so the having and order can use the aliases of them
+func (t *dbTables) setAggregates(aggs []Aggregation) {
+	if len(aggs) == 0 {
+		return
+	}
+	t.aggregates = make(map[string]Aggregation, len(aggs))
+	for _, a := range aggs {
+		t.aggregates[a.Alias()] = a
+	}
+}
+
+// generate having sql.
+func (t *dbTables) getHavingSQL(cond *Condition, tz *time.Location) (having string, params []interface{}) {
+	having, params = t.getCondSQL(cond, true, tz)
+	if having != "" {
+		having = "HAVING " + having
+	}
+	return
+}
+
 // generate group sql.
 func (t *dbTables) getGroupSQL(groups []string) (groupSQL string) {
 	if len(groups) == 0 {
@@ -445,6 +680,12 @@ func (t *dbTables) getOrderSQL(orders []*order_clause.Order) (orderSQL string) {
 			} else {
 				panic(fmt.Errorf("unknown field/column name `%s`", strings.Join(clause, ExprSep)))
 			}
+		} else if a, ok := t.aggregates[column]; ok {
+			col, _ := t.getAggregationSQL(a)
+			orderSqls = append(orderSqls, fmt.Sprintf("%s %s", col, order.SortString()))
+		} else if index, fi, path, ok := t.parseJSONExprs(t.mi, clause); ok {
+			col := t.base.GenerateJSONPathCol(fmt.Sprintf("%s.%s%s%s", index, Q, fi.Column, Q), path, true)
+			orderSqls = append(orderSqls, fmt.Sprintf("%s %s", col, order.SortString()))
 		} else {
 			index, _, fi, suc := t.parseExprs(t.mi, clause)
 			if !suc {
@@ -15,6 +15,7 @@
 package orm
 
 import (
+	"context"
 	"errors"
 	"testing"
 	"time"
@@ -1427,7 +1428,7 @@ func TestDbBase_countSQL(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			res, args := tc.db.countSQL(tc.qs, mi, cond, tz)
+			res, args := tc.db.countSQL(context.Background(), tc.qs, mi, cond, tz)
 
 			assert.Equal(t, tc.wantRes, res)
 			assert.Equal(t, tc.wantArgs, args)
@@ -1457,3 +1458,139 @@ type testTab2 struct {
 	Age2   int64 `orm:"column(age_2)"`
 	Score2 int64 `orm:"column(score_2)"`
 }
+
+func TestDbBase_GenerateJSONSQL(t *testing.T) {
+	testCases := []struct {
+		name     string
+		db       dbBaser
+		path     []string
+		typed    bool
+		operator string
+		args     []interface{}
+
+		wantCol    string
+		wantSQL    string
+		wantParams []interface{}
+	}{
+		{
+			name:     "mysql jcontains",
+			db:       newdbBaseMysql(),
+			path:     []string{"address", "city"},
+			operator: "jcontains",
+			args:     []interface{}{map[string]string{"name": "Paris"}},
+
+			wantCol:    "JSON_UNQUOTE(JSON_EXTRACT(T0.`extra`, '$.\"address\".\"city\"'))",
+			wantSQL:    "JSON_CONTAINS(T0.`extra`, ?, '$.\"address\".\"city\"')",
+			wantParams: []interface{}{`{"name":"Paris"}`},
+		},
+		{
+			name:     "mysql has_key",
+			db:       newdbBaseMysql(),
+			path:     []string{"tags", "0"},
+			typed:    true,
+			operator: "has_key",
+			args:     []interface{}{"name"},
+
+			wantCol: "JSON_EXTRACT(T0.`extra`, '$.\"tags\"[0]')",
+			wantSQL: "JSON_CONTAINS_PATH(T0.`extra`, 'one', '$.\"tags\"[0].\"name\"')",
+		},
+		{
+			name:     "postgres jcontains",
+			db:       newdbBasePostgres(),
+			path:     []string{"address", "city"},
+			operator: "jcontains",
+			args:     []interface{}{`"Paris"`},
+
+			wantCol:    "(T0.`extra` #>> '{address,city}')",
+			wantSQL:    "(T0.`extra`::jsonb #> '{address,city}') @> ?::jsonb",
+			wantParams: []interface{}{`"Paris"`},
+		},
+		{
+			name:     "postgres has_key",
+			db:       newdbBasePostgres(),
+			path:     []string{"age"},
+			typed:    true,
+			operator: "has_key",
+			args:     []interface{}{"name"},
+
+			wantCol:    "(T0.`extra`::jsonb #> '{age}')",
+			wantSQL:    "jsonb_exists((T0.`extra`::jsonb #> '{age}'), ?)",
+			wantParams: []interface{}{"name"},
+		},
+		{
+			name:     "sqlite jcontains",
+			db:       newdbBaseSqlite(),
+			path:     []string{"address"},
+			operator: "jcontains",
+			args:     []interface{}{`{"city": "Paris", "tags": ["a"]}`},
+
+			wantCol:    "json_extract(T0.`extra`, '$.\"address\"')",
+			wantSQL:    "(json_extract(T0.`extra`, '$.\"address\".\"city\"') = ? AND EXISTS (SELECT 1 FROM json_each(T0.`extra`, '$.\"address\".\"tags\"') WHERE value = ?))",
+			wantParams: []interface{}{"Paris", "a"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			col := "T0.`extra`"
+			assert.Equal(t, tc.wantCol, tc.db.GenerateJSONPathCol(col, tc.path, tc.typed))
+			sql, params := tc.db.GenerateJSONOperatorSQL(col, tc.path, tc.operator, tc.args)
+			assert.Equal(t, tc.wantSQL, sql)
+			assert.Equal(t, tc.wantParams, params)
+		})
+	}
+}
+
+func TestDbTables_getArrayCondSQL(t *testing.T) {
+	mc := models.NewModelCacheHandler()
+	assert.Nil(t, mc.Register("", false, new(ExtendedDoc)))
+	mc.Bootstrap()
+	mi, ok := mc.GetByMd(new(ExtendedDoc))
+	assert.True(t, ok)
+
+	testCases := []struct {
+		name string
+		cond *Condition
+
+		wantRes  string
+		wantArgs []interface{}
+	}{
+		{
+			name:     "contains the elements",
+			cond:     NewCondition().And("tags__contains", "a", `b "c"`),
+			wantRes:  `WHERE T0."tags" @> ? `,
+			wantArgs: []interface{}{`{"a","b \"c\""}`},
+		},
+		{
+			name:     "contains the array",
+			cond:     NewCondition().And("tags__contains", []string{"a"}),
+			wantRes:  `WHERE T0."tags" @> ? `,
+			wantArgs: []interface{}{`{"a"}`},
+		},
+		{
+			name:     "overlap",
+			cond:     NewCondition().And("scores__overlap", IntArrayField{1, 2}),
+			wantRes:  `WHERE T0."scores" && ? `,
+			wantArgs: []interface{}{"{1,2}"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tables := newDbTables(mi, newdbBasePostgres())
+			res, args := tables.getCondSQL(tc.cond, false, time.Local)
+
+			assert.Equal(t, tc.wantRes, res)
+			assert.Equal(t, tc.wantArgs, args)
+		})
+	}
+
+	assert.Panics(t, func() {
+		tables := newDbTables(mi, newdbBaseSqlite())
+		tables.getCondSQL(NewCondition().And("tags__contains", "a"), false, time.Local)
+	})
+	assert.Panics(t, func() {
+		tables := newDbTables(mi, newdbBasePostgres())
+		tables.getCondSQL(NewCondition().And("scores__contains", "x"), false, time.Local)
+	})
+}
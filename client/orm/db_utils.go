@@ -15,8 +15,12 @@
 package orm
 
 import (
+	"encoding/json"
 	"fmt"
 	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/beego/beego/v2/client/orm/internal/utils"
@@ -32,10 +36,147 @@ func getDbAlias(name string) *alias {
 	panic(fmt.Errorf("unknown DataBase alias name %s", name))
 }
 
+// Get the columns of the field names or column names.
+func getColumns(mi *models.ModelInfo, names []string) ([]string, error) {
+	cols := make([]string, 0, len(names))
+	for _, name := range names {
+		fi, ok := mi.Fields.GetByAny(name)
+		if !ok || !fi.DBcol {
+			return nil, fmt.Errorf("wrong field/column name `%s`", name)
+		}
+		cols = append(cols, fi.Column)
+	}
+	return cols, nil
+}
+
 // Get pk column info.
 func getExistPk(mi *models.ModelInfo, ind reflect.Value) (column string, value interface{}, exist bool) {
 	fi := mi.Fields.Pk
+	value, exist = getPkValue(fi, ind)
+	column = fi.Column
+	return
+}
+
+// Get the columns and values of the pk fields, which are more than one for the composite primary key.
+func getExistPks(mi *models.ModelInfo, ind reflect.Value) (columns []string, values []interface{}, exist bool) {
+	fis := mi.Fields.PkFields()
+	if len(fis) == 0 {
+		return nil, nil, false
+	}
+	columns = make([]string, 0, len(fis))
+	values = make([]interface{}, 0, len(fis))
+	for _, fi := range fis {
+		value, ok := getPkValue(fi, ind)
+		if !ok {
+			return nil, nil, false
+		}
+		columns = append(columns, fi.Column)
+		values = append(values, value)
+	}
+	return columns, values, true
+}
+
+// Get the quoted pk columns with the prefix, such as T0.`id`.
+func getPkColumns(mi *models.ModelInfo, Q string, prefix string) []string {
+	fis := mi.Fields.PkFields()
+	cols := make([]string, 0, len(fis))
+	for _, fi := range fis {
+		cols = append(cols, prefix+Q+fi.Column+Q)
+	}
+	return cols
+}
+
+// Get the sql of the pk columns or marks, the composite primary key is wrapped as a row value, such as (`a`, `b`).
+func getPkRowSQL(cols []string) string {
+	if len(cols) == 1 {
+		return cols[0]
+	}
+	return "(" + strings.Join(cols, ", ") + ")"
+}
+
+// the keys of the json path are written to the sql, so only the word characters and - are allowed.
+var jsonPathKey = regexp.MustCompile(`^[\w-]+$`)
+
+func isJSONField(fi *models.FieldInfo) bool {
+	return fi.FieldType == TypeJSONField || fi.FieldType == TypeJsonbField
+}
+
+// getArrayArg Get the array literal of the array operator, the args are the elements,
+// or the only arg is the array, such as []string or StringArrayField.
+func getArrayArg(fi *models.FieldInfo, args []interface{}) interface{} {
+	var arg interface{} = args
+	if len(args) == 1 {
+		_, isFielder := args[0].(Fielder)
+		kind := reflect.Indirect(reflect.ValueOf(args[0])).Kind()
+		if isFielder || kind == reflect.Slice || kind == reflect.Array {
+			arg = args[0]
+		}
+	}
+	v, err := getFielderArg(fi, arg)
+	if err != nil {
+		panic(fmt.Errorf("wrong elements of the array field `%s`: %s", fi.FullName, err))
+	}
+	return v
+}
+
+// Check whether the first arg is a number, the json values are compared as numbers then.
+func isNumericArg(args []interface{}) bool {
+	if len(args) == 0 {
+		return false
+	}
+	switch args[0].(type) {
+	case int64, uint64, float64:
+		return true
+	}
+	return false
+}
 
+// Get the json path like $."address"."city" for MySQL and SQLite, the numeric keys are the array indexes.
+func getJSONPath(path []string) string {
+	var buf strings.Builder
+	buf.WriteString("$")
+	for _, key := range path {
+		if _, err := strconv.Atoi(key); err == nil {
+			buf.WriteString("[" + key + "]")
+		} else {
+			buf.WriteString(`."` + key + `"`)
+		}
+	}
+	return buf.String()
+}
+
+// Get the json text of the arg for the json operator, the string arg is used as json text already.
+func getJSONArg(operator string, args []interface{}) string {
+	if len(args) != 1 {
+		panic(fmt.Errorf("operator `%s` need 1 args not %d", operator, len(args)))
+	}
+	switch v := args[0].(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	}
+	data, err := json.Marshal(args[0])
+	if err != nil {
+		panic(fmt.Errorf("operator `%s` need a json value, %s", operator, err))
+	}
+	return string(data)
+}
+
+// Get the key arg for the json operator has_key.
+func getJSONKey(operator string, args []interface{}) string {
+	if len(args) != 1 {
+		panic(fmt.Errorf("operator `%s` need 1 args not %d", operator, len(args)))
+	}
+	key, ok := args[0].(string)
+	if !ok || !jsonPathKey.MatchString(key) {
+		panic(fmt.Errorf("operator `%s` need a json key not `%v`", operator, args[0]))
+	}
+	return key
+}
+
+// Get the value of the pk field fi, the value of rel field is the pk of the related model.
+func getPkValue(fi *models.FieldInfo, ind reflect.Value) (value interface{}, exist bool) {
 	v := ind.FieldByIndex(fi.FieldIndex)
 	if fi.FieldType&IsPositiveIntegerField > 0 {
 		vu := v.Uint()
@@ -46,17 +187,36 @@ func getExistPk(mi *models.ModelInfo, ind reflect.Value) (column string, value i
 		exist = true
 		value = vu
 	} else if fi.FieldType&IsRelField > 0 {
-		_, value, exist = getExistPk(fi.RelModelInfo, reflect.Indirect(v))
+		if rel := reflect.Indirect(v); rel.IsValid() {
+			_, value, exist = getExistPk(fi.RelModelInfo, rel)
+		}
 	} else {
 		vu := v.String()
 		exist = vu != ""
 		value = vu
 	}
-
-	column = fi.Column
 	return
 }
 
+// getVersion returns the value of the version field
+func getVersion(fi *models.FieldInfo, ind reflect.Value) int64 {
+	v := ind.FieldByIndex(fi.FieldIndex)
+	if fi.FieldType&IsPositiveIntegerField > 0 {
+		return int64(v.Uint())
+	}
+	return v.Int()
+}
+
+// setVersion sets the value of the version field
+func setVersion(fi *models.FieldInfo, ind reflect.Value, version int64) {
+	v := ind.FieldByIndex(fi.FieldIndex)
+	if fi.FieldType&IsPositiveIntegerField > 0 {
+		v.SetUint(uint64(version))
+	} else {
+		v.SetInt(version)
+	}
+}
+
 // Get Fields description as flatted string.
 func getFlatParams(fi *models.FieldInfo, args []interface{}, tz *time.Location) (params []interface{}) {
 outFor:
@@ -66,6 +226,14 @@ outFor:
 			continue
 		}
 
+		// the argument which can be set to the registered Fielder, such as the string of UUIDField
+		if fi != nil && isRegisteredFielder(fi) {
+			if v, err := getFielderArg(fi, arg); err == nil {
+				params = append(params, v)
+				continue
+			}
+		}
+
 		val := reflect.ValueOf(arg)
 		kind := val.Kind()
 		if kind == reflect.Ptr {
@@ -32,7 +32,7 @@ func getDbDropSQL(mc *imodels.ModelCache, al *alias) (queries []string, err erro
 	Q := al.DbBaser.TableQuote()
 
 	for _, mi := range mc.AllOrdered() {
-		queries = append(queries, fmt.Sprintf(`DROP TABLE IF EXISTS %s%s%s`, Q, mi.Table, Q))
+		queries = append(queries, fmt.Sprintf(`DROP TABLE IF EXISTS %s%s%s`, Q, al.DbBaser.TableName(mi), Q))
 	}
 	return queries, nil
 }
@@ -44,153 +44,184 @@ func getDbCreateSQL(mc *imodels.ModelCache, al *alias) (queries []string, tableI
 		return
 	}
 
-	Q := al.DbBaser.TableQuote()
-	T := al.DbBaser.DbTypes()
-	sep := fmt.Sprintf("%s, %s", Q, Q)
-
 	tableIndexes = make(map[string][]dbIndex)
 
 	for _, mi := range mc.AllOrdered() {
-		sql := fmt.Sprintf("-- %s\n", strings.Repeat("-", 50))
-		sql += fmt.Sprintf("--  Table Structure for `%s`\n", mi.FullName)
-		sql += fmt.Sprintf("-- %s\n", strings.Repeat("-", 50))
-
-		sql += fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s%s%s (\n", Q, mi.Table, Q)
-
-		columns := make([]string, 0, len(mi.Fields.FieldsDB))
-
-		sqlIndexes := [][]string{}
-		var commentIndexes []int // store comment indexes for postgres
-
-		for i, fi := range mi.Fields.FieldsDB {
-			column := fmt.Sprintf("    %s%s%s ", Q, fi.Column, Q)
-			col := getColumnTyp(al, fi)
-			if fi.DBType != "" {
-				column += fi.DBType
-			} else if fi.Auto {
-				switch al.Driver {
-				case DRSqlite, DRPostgres:
-					column += T["auto"]
-				default:
-					column += col + " " + T["auto"]
-				}
-			} else if fi.Pk {
-				column += col + " " + T["pk"]
-			} else {
-				column += col
+		sql, indexes := getTableCreateSQL(al, mi)
+		queries = append(queries, sql)
+		table := al.DbBaser.TableName(mi)
+		tableIndexes[table] = append(tableIndexes[table], indexes...)
+	}
 
-				if !fi.Null {
-					column += " " + "NOT NULL"
-				}
+	return
+}
 
-				// if fi.initial.String() != "" {
-				//	column += " DEFAULT " + fi.initial.String()
-				// }
+// getTableCreateSQL Get the creation sql query and the indexes of one table
+func getTableCreateSQL(al *alias, mi *imodels.ModelInfo) (string, []dbIndex) {
+	Q := al.DbBaser.TableQuote()
+	table := al.DbBaser.TableName(mi)
+	T := al.DbBaser.DbTypes()
+	sep := fmt.Sprintf("%s, %s", Q, Q)
 
-				// Append attribute DEFAULT
-				column += getColumnDefault(fi)
+	sql := fmt.Sprintf("-- %s\n", strings.Repeat("-", 50))
+	sql += fmt.Sprintf("--  Table Structure for `%s`\n", mi.FullName)
+	sql += fmt.Sprintf("-- %s\n", strings.Repeat("-", 50))
 
-				if fi.Unique {
-					column += " " + "UNIQUE"
-				}
+	sql += fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s%s%s (\n", Q, table, Q)
 
-				if fi.Index {
-					sqlIndexes = append(sqlIndexes, []string{fi.Column})
-				}
-			}
+	columns := make([]string, 0, len(mi.Fields.FieldsDB))
 
-			if strings.Contains(column, "%COL%") {
-				column = strings.Replace(column, "%COL%", fi.Column, -1)
+	sqlIndexes := [][]string{}
+	var commentIndexes []int // store comment indexes for postgres
+
+	for i, fi := range mi.Fields.FieldsDB {
+		column := fmt.Sprintf("    %s%s%s ", Q, fi.Column, Q)
+		col := getColumnTyp(al, fi)
+		if fi.DBType != "" {
+			column += fi.DBType
+		} else if fi.Auto {
+			switch al.Driver {
+			case DRSqlite, DRPostgres:
+				column += T["auto"]
+			default:
+				column += col + " " + T["auto"]
 			}
+		} else if fi.Pk {
+			column += col + " " + T["pk"]
+		} else {
+			column += col
 
-			if fi.Description != "" && al.Driver != DRSqlite {
-				if al.Driver == DRPostgres {
-					commentIndexes = append(commentIndexes, i)
-				} else {
-					column += " " + fmt.Sprintf("COMMENT '%s'", fi.Description)
-				}
+			if !fi.Null {
+				column += " " + "NOT NULL"
 			}
 
-			columns = append(columns, column)
-		}
+			// if fi.initial.String() != "" {
+			//	column += " DEFAULT " + fi.initial.String()
+			// }
 
-		if mi.Model != nil {
-			allnames := imodels.GetTableUnique(mi.AddrField)
-			if !mi.Manual && len(mi.Uniques) > 0 {
-				allnames = append(allnames, mi.Uniques)
+			// Append attribute DEFAULT
+			column += getColumnDefault(fi)
+
+			if fi.Unique {
+				column += " " + "UNIQUE"
 			}
-			for _, names := range allnames {
-				cols := make([]string, 0, len(names))
-				for _, name := range names {
-					if fi, ok := mi.Fields.GetByAny(name); ok && fi.DBcol {
-						cols = append(cols, fi.Column)
-					} else {
-						panic(fmt.Errorf("cannot found column `%s` when parse UNIQUE in `%s.TableUnique`", name, mi.FullName))
-					}
-				}
-				column := fmt.Sprintf("    UNIQUE (%s%s%s)", Q, strings.Join(cols, sep), Q)
-				columns = append(columns, column)
+
+			if fi.Index {
+				sqlIndexes = append(sqlIndexes, []string{fi.Column})
 			}
 		}
 
-		sql += strings.Join(columns, ",\n")
-		sql += "\n)"
-
-		if al.Driver == DRMySQL {
-			var engine string
-			if mi.Model != nil {
-				engine = imodels.GetTableEngine(mi.AddrField)
-			}
-			if engine == "" {
-				engine = al.Engine
-			}
-			sql += " ENGINE=" + engine
+		if strings.Contains(column, "%COL%") {
+			column = strings.Replace(column, "%COL%", fi.Column, -1)
 		}
 
-		sql += ";"
-		if al.Driver == DRPostgres && len(commentIndexes) > 0 {
-			// append comments for postgres only
-			for _, index := range commentIndexes {
-				sql += fmt.Sprintf("\nCOMMENT ON COLUMN %s%s%s.%s%s%s is '%s';",
-					Q,
-					mi.Table,
-					Q,
-					Q,
-					mi.Fields.FieldsDB[index].Column,
-					Q,
-					mi.Fields.FieldsDB[index].Description)
+		if fi.Description != "" && al.Driver != DRSqlite {
+			if al.Driver == DRPostgres {
+				commentIndexes = append(commentIndexes, i)
+			} else {
+				column += " " + fmt.Sprintf("COMMENT '%s'", fi.Description)
 			}
 		}
-		queries = append(queries, sql)
 
+		columns = append(columns, column)
+	}
+
+	if mi.Fields.CompositePk != nil {
+		column := fmt.Sprintf("    PRIMARY KEY (%s)", strings.Join(getPkColumns(mi, Q, ""), ", "))
+		columns = append(columns, column)
+	}
+
+	for _, cols := range getTableUniqueColumns(mi) {
+		column := fmt.Sprintf("    UNIQUE (%s%s%s)", Q, strings.Join(cols, sep), Q)
+		columns = append(columns, column)
+	}
+
+	sql += strings.Join(columns, ",\n")
+	sql += "\n)"
+
+	if al.Driver == DRMySQL {
+		var engine string
 		if mi.Model != nil {
-			for _, names := range imodels.GetTableIndex(mi.AddrField) {
-				cols := make([]string, 0, len(names))
-				for _, name := range names {
-					if fi, ok := mi.Fields.GetByAny(name); ok && fi.DBcol {
-						cols = append(cols, fi.Column)
-					} else {
-						panic(fmt.Errorf("cannot found column `%s` when parse INDEX in `%s.TableIndex`", name, mi.FullName))
-					}
-				}
-				sqlIndexes = append(sqlIndexes, cols)
-			}
+			engine = imodels.GetTableEngine(mi.AddrField)
+		}
+		if engine == "" {
+			engine = al.Engine
 		}
+		sql += " ENGINE=" + engine
+	}
 
-		for _, names := range sqlIndexes {
-			name := mi.Table + "_" + strings.Join(names, "_")
-			cols := strings.Join(names, sep)
-			sql := fmt.Sprintf("CREATE INDEX %s%s%s ON %s%s%s (%s%s%s);", Q, name, Q, Q, mi.Table, Q, Q, cols, Q)
+	sql += ";"
+	if al.Driver == DRPostgres && len(commentIndexes) > 0 {
+		// append comments for postgres only
+		for _, index := range commentIndexes {
+			sql += fmt.Sprintf("\nCOMMENT ON COLUMN %s%s%s.%s%s%s is '%s';",
+				Q,
+				table,
+				Q,
+				Q,
+				mi.Fields.FieldsDB[index].Column,
+				Q,
+				mi.Fields.FieldsDB[index].Description)
+		}
+	}
 
-			index := dbIndex{}
-			index.Table = mi.Table
-			index.Name = name
-			index.SQL = sql
+	sqlIndexes = append(sqlIndexes, getTableIndexColumns(mi)...)
 
-			tableIndexes[mi.Table] = append(tableIndexes[mi.Table], index)
-		}
+	indexes := make([]dbIndex, 0, len(sqlIndexes))
+	for _, names := range sqlIndexes {
+		name := table + "_" + strings.Join(names, "_")
+		cols := strings.Join(names, sep)
+		sql := fmt.Sprintf("CREATE INDEX %s%s%s ON %s%s%s (%s%s%s);", Q, name, Q, Q, table, Q, Q, cols, Q)
 
+		index := dbIndex{}
+		index.Table = table
+		index.Name = name
+		index.SQL = sql
+
+		indexes = append(indexes, index)
 	}
 
-	return
+	return sql, indexes
+}
+
+// getTableUniqueColumns Get the columns of the multi-column unique constraints of the table,
+// which are declared by TableUnique or the manual registration.
+func getTableUniqueColumns(mi *imodels.ModelInfo) [][]string {
+	if mi.Model == nil {
+		return nil
+	}
+	allnames := imodels.GetTableUnique(mi.AddrField)
+	if !mi.Manual && len(mi.Uniques) > 0 {
+		allnames = append(allnames, mi.Uniques)
+	}
+	uniques := make([][]string, 0, len(allnames))
+	for _, names := range allnames {
+		uniques = append(uniques, getColumnsByNames(mi, names, "UNIQUE", "TableUnique"))
+	}
+	return uniques
+}
+
+// getTableIndexColumns Get the columns of the indexes declared by TableIndex
+func getTableIndexColumns(mi *imodels.ModelInfo) [][]string {
+	if mi.Model == nil {
+		return nil
+	}
+	allnames := imodels.GetTableIndex(mi.AddrField)
+	indexes := make([][]string, 0, len(allnames))
+	for _, names := range allnames {
+		indexes = append(indexes, getColumnsByNames(mi, names, "INDEX", "TableIndex"))
+	}
+	return indexes
+}
+
+func getColumnsByNames(mi *imodels.ModelInfo, names []string, kind string, method string) []string {
+	cols := make([]string, 0, len(names))
+	for _, name := range names {
+		if fi, ok := mi.Fields.GetByAny(name); ok && fi.DBcol {
+			cols = append(cols, fi.Column)
+		} else {
+			panic(fmt.Errorf("cannot found column `%s` when parse %s in `%s.%s`", name, kind, mi.FullName, method))
+		}
+	}
+	return cols
 }
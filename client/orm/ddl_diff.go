@@ -0,0 +1,601 @@
+// Copyright 2023 beego. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package orm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	imodels "github.com/beego/beego/v2/client/orm/internal/models"
+)
+
+// SchemaChangeKind is the kind of SchemaChange
+type SchemaChangeKind string
+
+// the kinds of SchemaChange
+const (
+	SchemaCreateTable  SchemaChangeKind = "create_table"
+	SchemaRebuildTable SchemaChangeKind = "rebuild_table"
+	SchemaAddColumn    SchemaChangeKind = "add_column"
+	SchemaDropColumn   SchemaChangeKind = "drop_column"
+	SchemaRenameColumn SchemaChangeKind = "rename_column"
+	SchemaAlterColumn  SchemaChangeKind = "alter_column"
+	SchemaCreateIndex  SchemaChangeKind = "create_index"
+	SchemaDropIndex    SchemaChangeKind = "drop_index"
+)
+
+// SchemaChange is one change between the models and the database.
+// Up is the sql to apply the change and Down is the sql to revert it.
+type SchemaChange struct {
+	Kind  SchemaChangeKind
+	Table string
+	// Name is the column or index name, it's empty for the table changes
+	Name string
+	Up   []string
+	Down []string
+}
+
+// String return the description of the change, e.g. add_column user.age
+func (c *SchemaChange) String() string {
+	if c.Name == "" {
+		return fmt.Sprintf("%s %s", c.Kind, c.Table)
+	}
+	return fmt.Sprintf("%s %s.%s", c.Kind, c.Table, c.Name)
+}
+
+// SchemaDiff is the delta from the database to the registered models
+type SchemaDiff struct {
+	Changes []*SchemaChange
+}
+
+// IsEmpty check whether the database is up to date
+func (d *SchemaDiff) IsEmpty() bool {
+	return len(d.Changes) == 0
+}
+
+// UpSQL return the sql to migrate the database to the models
+func (d *SchemaDiff) UpSQL() []string {
+	var queries []string
+	for _, c := range d.Changes {
+		queries = append(queries, c.Up...)
+	}
+	return queries
+}
+
+// DownSQL return the sql to revert UpSQL, the changes are reverted in the reverse order
+func (d *SchemaDiff) DownSQL() []string {
+	var queries []string
+	for i := len(d.Changes) - 1; i >= 0; i-- {
+		queries = append(queries, d.Changes[i].Down...)
+	}
+	return queries
+}
+
+// SchemaDiffOption configures DiffSchema
+type SchemaDiffOption func(opts *schemaDiffOptions)
+
+type schemaDiffOptions struct {
+	// table -> old column -> new column
+	renames map[string]map[string]string
+}
+
+// WithRenamedColumn tells DiffSchema the column from of table is renamed to the column to.
+// Without it a renamed column is treated as one dropped column and one added column.
+func WithRenamedColumn(table, from, to string) SchemaDiffOption {
+	return func(opts *schemaDiffOptions) {
+		if opts.renames == nil {
+			opts.renames = make(map[string]map[string]string)
+		}
+		if opts.renames[table] == nil {
+			opts.renames[table] = make(map[string]string)
+		}
+		opts.renames[table][from] = to
+	}
+}
+
+// schemaColumn is a column of the database or a column expected by the models
+type schemaColumn struct {
+	Name string
+	Type string
+	Null bool
+	// Default is the sql expression of the default value
+	Default    string
+	HasDefault bool
+	// the columns declared by pk, auto or db_type are never altered
+	fixed bool
+}
+
+// schemaIndex is an index of the database or an index expected by the models
+type schemaIndex struct {
+	Name    string
+	Columns []string
+	Unique  bool
+	// Constraint means the index is created by a UNIQUE constraint
+	Constraint bool
+}
+
+func (idx *schemaIndex) key(renames map[string]string) string {
+	cols := make([]string, 0, len(idx.Columns))
+	for _, col := range idx.Columns {
+		if to, ok := renames[col]; ok {
+			col = to
+		}
+		cols = append(cols, col)
+	}
+	return fmt.Sprintf("%t:%s", idx.Unique, strings.Join(cols, ","))
+}
+
+// appendSchemaIndex add the column to the last index if it has the same name,
+// the rows of the index query must be ordered by the index name.
+func appendSchemaIndex(indexes []*schemaIndex, name string, unique bool, constraint bool, column string) []*schemaIndex {
+	if l := len(indexes); l > 0 && indexes[l-1].Name == name {
+		indexes[l-1].Columns = append(indexes[l-1].Columns, column)
+		return indexes
+	}
+	return append(indexes, &schemaIndex{Name: name, Columns: []string{column}, Unique: unique, Constraint: constraint})
+}
+
+// DiffSchema compare the registered models with the tables of the database alias,
+// and return the changes which make the database match the models,
+// including the created tables, the added, dropped, renamed and altered columns and the index changes.
+// Tables which are not registered are never dropped.
+// The tables of the tenant resolved from ctx are compared if there is one, see RegisterTenant.
+// MySQL, PostgreSQL and SQLite are supported.
+func DiffSchema(ctx context.Context, name string, opts ...SchemaDiffOption) (*SchemaDiff, error) {
+	BootStrap()
+	return diffSchema(ctx, defaultModelCache, tenantAlias(ctx, getDbAlias(name)), opts...)
+}
+
+func diffSchema(ctx context.Context, mc *imodels.ModelCache, al *alias, opts ...SchemaDiffOption) (*SchemaDiff, error) {
+	if mc.Empty() {
+		return nil, errors.New("no Model found, need Register your model")
+	}
+	switch al.Driver {
+	case DRMySQL, DRPostgres, DRSqlite:
+	default:
+		return nil, ErrNotImplement
+	}
+
+	options := &schemaDiffOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	db, release := tenantQuerier(al)
+	defer release()
+	tables, err := al.DbBaser.GetTables(db)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &SchemaDiff{}
+	for _, mi := range mc.AllOrdered() {
+		if !imodels.IsApplicableTableForDB(mi.AddrField, al.Name) {
+			continue
+		}
+		// the shards of the sharded model are diffed instead of its table
+		for _, tal := range shardAliases(al, mi) {
+			if !tables[tal.DbBaser.TableName(mi)] {
+				diff.Changes = append(diff.Changes, getCreateTableChange(tal, mi))
+				continue
+			}
+			changes, err := diffTable(ctx, db, tal, mi, options.renames[mi.Table])
+			if err != nil {
+				return nil, err
+			}
+			diff.Changes = append(diff.Changes, changes...)
+		}
+	}
+	return diff, nil
+}
+
+func getCreateTableChange(al *alias, mi *imodels.ModelInfo) *SchemaChange {
+	Q := al.DbBaser.TableQuote()
+	table := al.DbBaser.TableName(mi)
+	sql, indexes := getTableCreateSQL(al, mi)
+	up := []string{sql}
+	for _, idx := range indexes {
+		up = append(up, idx.SQL)
+	}
+	return &SchemaChange{
+		Kind:  SchemaCreateTable,
+		Table: table,
+		Up:    up,
+		Down:  []string{fmt.Sprintf("DROP TABLE %s%s%s;", Q, table, Q)},
+	}
+}
+
+// getModelColumns Get the columns expected by the model, in the same way as getTableCreateSQL
+func getModelColumns(al *alias, mi *imodels.ModelInfo) []*schemaColumn {
+	columns := make([]*schemaColumn, 0, len(mi.Fields.FieldsDB))
+	for _, fi := range mi.Fields.FieldsDB {
+		col := &schemaColumn{Name: fi.Column, Null: fi.Null}
+		if fi.DBType != "" || fi.Auto || fi.Pk {
+			col.fixed = true
+		} else {
+			col.Type = getColumnTyp(al, fi)
+			if v, quoted, ok := getColumnDefaultValue(fi); ok {
+				col.HasDefault = true
+				col.Default = v
+				if quoted {
+					col.Default = "'" + v + "'"
+				}
+			}
+		}
+		columns = append(columns, col)
+	}
+	return columns
+}
+
+// getModelIndexes Get the indexes expected by the model, in the same way as getTableCreateSQL
+func getModelIndexes(al *alias, mi *imodels.ModelInfo) []*schemaIndex {
+	table := al.DbBaser.TableName(mi)
+	var indexes []*schemaIndex
+	for _, fi := range mi.Fields.FieldsDB {
+		if fi.DBType != "" || fi.Auto || fi.Pk {
+			continue
+		}
+		if fi.Unique {
+			indexes = append(indexes, &schemaIndex{Name: table + "_" + fi.Column + "_uniq", Columns: []string{fi.Column}, Unique: true})
+		}
+		if fi.Index {
+			indexes = append(indexes, &schemaIndex{Name: table + "_" + fi.Column, Columns: []string{fi.Column}})
+		}
+	}
+	for _, cols := range getTableUniqueColumns(mi) {
+		indexes = append(indexes, &schemaIndex{Name: table + "_" + strings.Join(cols, "_") + "_uniq", Columns: cols, Unique: true})
+	}
+	for _, cols := range getTableIndexColumns(mi) {
+		indexes = append(indexes, &schemaIndex{Name: table + "_" + strings.Join(cols, "_"), Columns: cols})
+	}
+	return indexes
+}
+
+func diffTable(ctx context.Context, db dbQuerier, al *alias, mi *imodels.ModelInfo, renames map[string]string) ([]*SchemaChange, error) {
+	tableName := al.DbBaser.TableName(mi)
+	current, err := al.DbBaser.GetSchemaColumns(ctx, db, tableName)
+	if err != nil {
+		return nil, err
+	}
+	currentIndexes, err := al.DbBaser.GetSchemaIndexes(ctx, db, tableName)
+	if err != nil {
+		return nil, err
+	}
+	desired := getModelColumns(al, mi)
+
+	currentByName := make(map[string]*schemaColumn, len(current))
+	for _, col := range current {
+		currentByName[col.Name] = col
+	}
+	desiredByName := make(map[string]*schemaColumn, len(desired))
+	for _, col := range desired {
+		desiredByName[col.Name] = col
+	}
+
+	// only keep the renames which can be applied
+	applied := make(map[string]string)
+	renamedFrom := make(map[string]string)
+	for from, to := range renames {
+		_, hasFrom := currentByName[from]
+		_, hasTo := currentByName[to]
+		if _, ok := desiredByName[to]; ok && hasFrom && !hasTo {
+			applied[from] = to
+			renamedFrom[to] = from
+		}
+	}
+
+	var (
+		renamed, dropped, added []*schemaColumn
+		altered                 [][2]*schemaColumn
+	)
+	for _, col := range current {
+		if _, ok := applied[col.Name]; ok {
+			renamed = append(renamed, col)
+		} else if _, ok := desiredByName[col.Name]; !ok {
+			dropped = append(dropped, col)
+		}
+	}
+	for _, col := range desired {
+		from, ok := currentByName[col.Name]
+		if name, isRenamed := renamedFrom[col.Name]; isRenamed {
+			from, ok = currentByName[name], true
+		}
+		if !ok {
+			added = append(added, col)
+		} else if !col.fixed && isColumnChanged(al, from, col) {
+			altered = append(altered, [2]*schemaColumn{from, col})
+		}
+	}
+
+	desiredIndexes := getModelIndexes(al, mi)
+	desiredKeys := make(map[string]bool, len(desiredIndexes))
+	for _, idx := range desiredIndexes {
+		desiredKeys[idx.key(nil)] = true
+	}
+	currentKeys := make(map[string]bool, len(currentIndexes))
+	var droppedIndexes, addedIndexes []*schemaIndex
+	for _, idx := range currentIndexes {
+		key := idx.key(applied)
+		currentKeys[key] = true
+		if !desiredKeys[key] {
+			droppedIndexes = append(droppedIndexes, idx)
+		}
+	}
+	for _, idx := range desiredIndexes {
+		key := idx.key(nil)
+		if !currentKeys[key] {
+			addedIndexes = append(addedIndexes, idx)
+			// skip the duplicated declarations
+			currentKeys[key] = true
+		}
+	}
+
+	if al.Driver == DRSqlite && (len(altered) > 0 || hasConstraintIndex(droppedIndexes)) {
+		// sqlite can't alter columns or drop constraints, the table has to be rebuilt
+		change, err := getRebuildTableChange(ctx, db, al, mi, current, currentIndexes, renamedFrom)
+		if err != nil {
+			return nil, err
+		}
+		return []*SchemaChange{change}, nil
+	}
+
+	Q := al.DbBaser.TableQuote()
+	table := Q + tableName + Q
+	var changes []*SchemaChange
+	for _, idx := range droppedIndexes {
+		changes = append(changes, &SchemaChange{
+			Kind:  SchemaDropIndex,
+			Table: tableName,
+			Name:  idx.Name,
+			Up:    []string{getIndexDropSQL(al, tableName, idx)},
+			Down:  []string{getIndexCreateSQL(al, tableName, idx)},
+		})
+	}
+	for _, col := range renamed {
+		to := applied[col.Name]
+		changes = append(changes, &SchemaChange{
+			Kind:  SchemaRenameColumn,
+			Table: tableName,
+			Name:  to,
+			Up:    []string{fmt.Sprintf("ALTER TABLE %s RENAME COLUMN %s%s%s TO %s%s%s;", table, Q, col.Name, Q, Q, to, Q)},
+			Down:  []string{fmt.Sprintf("ALTER TABLE %s RENAME COLUMN %s%s%s TO %s%s%s;", table, Q, to, Q, Q, col.Name, Q)},
+		})
+	}
+	for _, col := range dropped {
+		changes = append(changes, &SchemaChange{
+			Kind:  SchemaDropColumn,
+			Table: tableName,
+			Name:  col.Name,
+			Up:    []string{fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s%s%s;", table, Q, col.Name, Q)},
+			Down:  []string{fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s%s%s %s;", table, Q, col.Name, Q, getColumnDefinition(col))},
+		})
+	}
+	for _, col := range added {
+		fi := mi.Fields.GetByColumn(col.Name)
+		changes = append(changes, &SchemaChange{
+			Kind:  SchemaAddColumn,
+			Table: tableName,
+			Name:  col.Name,
+			Up:    []string{strings.TrimSpace(getColumnAddQuery(al, fi)) + ";"},
+			Down:  []string{fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s%s%s;", table, Q, col.Name, Q)},
+		})
+	}
+	for _, cols := range altered {
+		changes = append(changes, &SchemaChange{
+			Kind:  SchemaAlterColumn,
+			Table: tableName,
+			Name:  cols[1].Name,
+			Up:    getColumnAlterSQL(al, tableName, cols[0], cols[1]),
+			Down:  getColumnAlterSQL(al, tableName, cols[1], cols[0]),
+		})
+	}
+	for _, idx := range addedIndexes {
+		changes = append(changes, &SchemaChange{
+			Kind:  SchemaCreateIndex,
+			Table: tableName,
+			Name:  idx.Name,
+			Up:    []string{getIndexCreateSQL(al, tableName, idx)},
+			Down:  []string{getIndexDropSQL(al, tableName, idx)},
+		})
+	}
+	return changes, nil
+}
+
+func hasConstraintIndex(indexes []*schemaIndex) bool {
+	for _, idx := range indexes {
+		if idx.Constraint {
+			return true
+		}
+	}
+	return false
+}
+
+// isColumnChanged compare the type, nullability and default value of the columns
+func isColumnChanged(al *alias, from, to *schemaColumn) bool {
+	return isColumnTypeChanged(al, from, to) || from.Null != to.Null || isColumnDefaultChanged(from, to)
+}
+
+func isColumnTypeChanged(al *alias, from, to *schemaColumn) bool {
+	return al.DbBaser.NormalizeColumnType(from.Type) != al.DbBaser.NormalizeColumnType(to.Type)
+}
+
+func isColumnDefaultChanged(from, to *schemaColumn) bool {
+	if from.HasDefault != to.HasDefault {
+		return true
+	}
+	return normalizeColumnDefault(from.Default) != normalizeColumnDefault(to.Default)
+}
+
+// normalizeColumnDefault turns the default expressions into comparable values,
+// e.g. 'abc'::character varying is abc, FALSE is 0.
+func normalizeColumnDefault(v string) string {
+	v = strings.TrimSpace(v)
+	if i := strings.Index(v, "::"); i > 0 {
+		v = v[:i]
+	}
+	if len(v) >= 2 && v[0] == '\'' && v[len(v)-1] == '\'' {
+		v = strings.ReplaceAll(v[1:len(v)-1], "''", "'")
+	}
+	switch strings.ToLower(v) {
+	case "false":
+		return "0"
+	case "true":
+		return "1"
+	}
+	return v
+}
+
+// getColumnDefinition Get the definition of column used by ADD COLUMN or MODIFY COLUMN
+func getColumnDefinition(col *schemaColumn) string {
+	def := col.Type
+	if !col.Null {
+		def += " NOT NULL"
+	}
+	if col.HasDefault {
+		def += " DEFAULT " + col.Default
+	}
+	return def
+}
+
+// getColumnAlterSQL Get the sql to alter the column from to the column to
+func getColumnAlterSQL(al *alias, table string, from, to *schemaColumn) []string {
+	Q := al.DbBaser.TableQuote()
+	if al.Driver != DRPostgres {
+		return []string{fmt.Sprintf("ALTER TABLE %s%s%s MODIFY COLUMN %s%s%s %s;", Q, table, Q, Q, to.Name, Q, getColumnDefinition(to))}
+	}
+
+	prefix := fmt.Sprintf("ALTER TABLE %s%s%s ALTER COLUMN %s%s%s", Q, table, Q, Q, to.Name, Q)
+	var queries []string
+	if isColumnTypeChanged(al, from, to) {
+		// the CHECK of the column type can't be used by ALTER COLUMN TYPE
+		typ := to.Type
+		if i := strings.Index(strings.ToLower(typ), " check"); i >= 0 {
+			typ = typ[:i]
+		}
+		queries = append(queries, fmt.Sprintf("%s TYPE %s USING %s%s%s::%s;", prefix, typ, Q, to.Name, Q, typ))
+	}
+	if from.Null != to.Null {
+		if to.Null {
+			queries = append(queries, prefix+" DROP NOT NULL;")
+		} else {
+			queries = append(queries, prefix+" SET NOT NULL;")
+		}
+	}
+	if isColumnDefaultChanged(from, to) {
+		if to.HasDefault {
+			queries = append(queries, fmt.Sprintf("%s SET DEFAULT %s;", prefix, to.Default))
+		} else {
+			queries = append(queries, prefix+" DROP DEFAULT;")
+		}
+	}
+	return queries
+}
+
+// getIndexCreateSQL Get the sql to create the index,
+// the postgresql constraints are restored as constraints.
+func getIndexCreateSQL(al *alias, table string, idx *schemaIndex) string {
+	Q := al.DbBaser.TableQuote()
+	cols := Q + strings.Join(idx.Columns, Q+", "+Q) + Q
+	if idx.Constraint && al.Driver == DRPostgres {
+		return fmt.Sprintf("ALTER TABLE %s%s%s ADD CONSTRAINT %s%s%s UNIQUE (%s);", Q, table, Q, Q, idx.Name, Q, cols)
+	}
+	unique := ""
+	if idx.Unique {
+		unique = "UNIQUE "
+	}
+	return fmt.Sprintf("CREATE %sINDEX %s%s%s ON %s%s%s (%s);", unique, Q, idx.Name, Q, Q, table, Q, cols)
+}
+
+// getIndexDropSQL Get the sql to drop the index
+func getIndexDropSQL(al *alias, table string, idx *schemaIndex) string {
+	Q := al.DbBaser.TableQuote()
+	switch {
+	case al.Driver == DRMySQL:
+		return fmt.Sprintf("DROP INDEX %s%s%s ON %s%s%s;", Q, idx.Name, Q, Q, table, Q)
+	case idx.Constraint && al.Driver == DRPostgres:
+		return fmt.Sprintf("ALTER TABLE %s%s%s DROP CONSTRAINT %s%s%s;", Q, table, Q, Q, idx.Name, Q)
+	default:
+		return fmt.Sprintf("DROP INDEX %s%s%s;", Q, idx.Name, Q)
+	}
+}
+
+// getRebuildTableChange Get the change which rebuilds the sqlite table:
+// create a new table, copy the rows, drop the old table and rename the new table.
+func getRebuildTableChange(ctx context.Context, db dbQuerier, al *alias, mi *imodels.ModelInfo,
+	current []*schemaColumn, currentIndexes []*schemaIndex, renamedFrom map[string]string,
+) (*SchemaChange, error) {
+	Q := al.DbBaser.TableQuote()
+	table := al.DbBaser.TableName(mi)
+
+	var origin string
+	row := db.QueryRowContext(ctx, "SELECT sql FROM sqlite_master WHERE type = 'table' AND name = ?", table)
+	if err := row.Scan(&origin); err != nil {
+		return nil, err
+	}
+
+	currentByName := make(map[string]bool, len(current))
+	for _, col := range current {
+		currentByName[col.Name] = true
+	}
+	// the columns to copy, from the old table to the new table
+	var fromCols, toCols []string
+	for _, fi := range mi.Fields.FieldsDB {
+		from := fi.Column
+		if name, ok := renamedFrom[fi.Column]; ok {
+			from = name
+		}
+		if currentByName[from] {
+			fromCols = append(fromCols, Q+from+Q)
+			toCols = append(toCols, Q+fi.Column+Q)
+		}
+	}
+
+	sql, indexes := getTableCreateSQL(al, mi)
+	tmp := table + "__new"
+	sql = strings.Replace(sql, fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s%s%s", Q, table, Q),
+		fmt.Sprintf("CREATE TABLE %s%s%s", Q, tmp, Q), 1)
+	up := append([]string{sql}, getRebuildCopySQL(al, table, tmp, fromCols, toCols)...)
+	for _, idx := range indexes {
+		up = append(up, idx.SQL)
+	}
+
+	tmp = table + "__old"
+	down := []string{fmt.Sprintf("CREATE TABLE %s%s%s %s;", Q, tmp, Q, origin[strings.Index(origin, "("):])}
+	down = append(down, getRebuildCopySQL(al, table, tmp, toCols, fromCols)...)
+	for _, idx := range currentIndexes {
+		if !idx.Constraint {
+			down = append(down, getIndexCreateSQL(al, table, idx))
+		}
+	}
+
+	return &SchemaChange{
+		Kind:  SchemaRebuildTable,
+		Table: table,
+		Up:    up,
+		Down:  down,
+	}, nil
+}
+
+func getRebuildCopySQL(al *alias, table, tmp string, fromCols, toCols []string) []string {
+	Q := al.DbBaser.TableQuote()
+	return []string{
+		fmt.Sprintf("INSERT INTO %s%s%s (%s) SELECT %s FROM %s%s%s;", Q, tmp, Q,
+			strings.Join(toCols, ", "), strings.Join(fromCols, ", "), Q, table, Q),
+		fmt.Sprintf("DROP TABLE %s%s%s;", Q, table, Q),
+		fmt.Sprintf("ALTER TABLE %s%s%s RENAME TO %s%s%s;", Q, tmp, Q, Q, table, Q),
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright 2023 beego. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package orm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/beego/beego/v2/client/orm/internal/models"
+)
+
+type SchemaDiffEntity struct {
+	ID    int    `orm:"column(id)"`
+	Name  string `orm:"size(30)"`
+	Email string `orm:"size(100)"`
+	Age   int    `orm:"index"`
+}
+
+type SchemaDiffAlteredEntity struct {
+	ID   int    `orm:"column(id)"`
+	Name string `orm:"size(50);null"`
+}
+
+func TestNormalizeColumnType(t *testing.T) {
+	mysql := newdbBaseMysql()
+	assert.Equal(t, "int", mysql.NormalizeColumnType("int(11)"))
+	assert.Equal(t, "int unsigned", mysql.NormalizeColumnType("integer unsigned"))
+	assert.Equal(t, "tinyint(1)", mysql.NormalizeColumnType("bool"))
+	assert.Equal(t, "decimal(10,2)", mysql.NormalizeColumnType("numeric(10, 2)"))
+	assert.Equal(t, "double", mysql.NormalizeColumnType("double precision"))
+
+	postgres := newdbBasePostgres()
+	assert.Equal(t, "character varying(30)", postgres.NormalizeColumnType("varchar(30)"))
+	assert.Equal(t, "boolean", postgres.NormalizeColumnType("bool"))
+	assert.Equal(t, "smallint", postgres.NormalizeColumnType(`smallint CHECK("a" >= 0 AND "a" <= 255)`))
+	assert.Equal(t, "numeric(10,2)", postgres.NormalizeColumnType("numeric(10, 2)"))
+
+	assert.Equal(t, "abc", normalizeColumnDefault("'abc'::character varying"))
+	assert.Equal(t, "0", normalizeColumnDefault("FALSE"))
+	assert.Equal(t, "it's", normalizeColumnDefault("'it''s'"))
+}
+
+func TestDiffSchema(t *testing.T) {
+	al := getDbAlias("default")
+	if al.Driver != DRSqlite {
+		t.Skip("the schema diff test only runs on sqlite")
+	}
+	ctx := context.Background()
+	exec := func(queries []string) {
+		for _, query := range queries {
+			_, err := al.DB.Exec(query)
+			require.NoError(t, err, query)
+		}
+	}
+	kinds := func(diff *SchemaDiff) []string {
+		var res []string
+		for _, c := range diff.Changes {
+			res = append(res, c.String())
+		}
+		return res
+	}
+
+	exec([]string{
+		"DROP TABLE IF EXISTS `schema_diff_entity`",
+		"CREATE TABLE `schema_diff_entity` (`id` integer NOT NULL PRIMARY KEY AUTOINCREMENT, " +
+			"`name` varchar(30) NOT NULL DEFAULT '', `old_email` varchar(100) NOT NULL DEFAULT '', `legacy` integer NOT NULL DEFAULT 0)",
+		"CREATE INDEX `schema_diff_entity_legacy` ON `schema_diff_entity` (`legacy`)",
+		"INSERT INTO `schema_diff_entity` (`name`, `old_email`, `legacy`) VALUES ('slene', 'slene@beego.vip', 1)",
+	})
+	defer al.DB.Exec("DROP TABLE IF EXISTS `schema_diff_entity`")
+
+	mc := models.NewModelCacheHandler()
+	require.NoError(t, mc.Register("", true, new(SchemaDiffEntity)))
+	mc.Bootstrap()
+
+	rename := WithRenamedColumn("schema_diff_entity", "old_email", "email")
+	diff, err := diffSchema(ctx, mc, al, rename)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"drop_index schema_diff_entity.schema_diff_entity_legacy",
+		"rename_column schema_diff_entity.email",
+		"drop_column schema_diff_entity.legacy",
+		"add_column schema_diff_entity.age",
+		"create_index schema_diff_entity.schema_diff_entity_age",
+	}, kinds(diff))
+
+	exec(diff.UpSQL())
+	upToDate, err := diffSchema(ctx, mc, al, rename)
+	require.NoError(t, err)
+	assert.True(t, upToDate.IsEmpty(), kinds(upToDate))
+
+	var email string
+	require.NoError(t, al.DB.QueryRow("SELECT `email` FROM `schema_diff_entity`").Scan(&email))
+	assert.Equal(t, "slene@beego.vip", email)
+
+	exec(diff.DownSQL())
+	reverted, err := diffSchema(ctx, mc, al, rename)
+	require.NoError(t, err)
+	assert.Equal(t, kinds(diff), kinds(reverted))
+
+	// sqlite can't alter the column, so the table is rebuilt
+	exec(diff.UpSQL())
+	mc = models.NewModelCacheHandler()
+	require.NoError(t, mc.Register("", true, new(SchemaDiffAlteredEntity)))
+	mc.Bootstrap()
+	al.DB.Exec("DROP TABLE IF EXISTS `schema_diff_altered_entity`")
+	exec([]string{"ALTER TABLE `schema_diff_entity` RENAME TO `schema_diff_altered_entity`"})
+	defer al.DB.Exec("DROP TABLE IF EXISTS `schema_diff_altered_entity`")
+
+	diff, err = diffSchema(ctx, mc, al)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"rebuild_table schema_diff_altered_entity"}, kinds(diff))
+
+	exec(diff.UpSQL())
+	upToDate, err = diffSchema(ctx, mc, al)
+	require.NoError(t, err)
+	assert.True(t, upToDate.IsEmpty(), kinds(upToDate))
+
+	var name string
+	require.NoError(t, al.DB.QueryRow("SELECT `name` FROM `schema_diff_altered_entity`").Scan(&name))
+	assert.Equal(t, "slene", name)
+
+	exec(diff.DownSQL())
+	columns, err := al.DbBaser.GetSchemaColumns(ctx, al.DB, "schema_diff_altered_entity")
+	require.NoError(t, err)
+	require.Equal(t, 4, len(columns))
+	assert.Equal(t, "varchar(30)", columns[1].Type)
+	assert.False(t, columns[1].Null)
+}
@@ -0,0 +1,599 @@
+// Copyright 2023 beego. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package orm
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/format"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	imodels "github.com/beego/beego/v2/client/orm/internal/models"
+)
+
+// schemaKeys are the primary key and the foreign keys of a table
+type schemaKeys struct {
+	Pk []string
+	// Auto is the auto increment column of the primary key
+	Auto    string
+	Foreign []*schemaForeignKey
+}
+
+// schemaForeignKey is a column of the foreign key constraint Name,
+// the composite foreign key has more than one column with the same Name.
+// RefColumn is empty if the constraint refers to the primary key implicitly.
+type schemaForeignKey struct {
+	Name      string
+	Column    string
+	RefTable  string
+	RefColumn string
+}
+
+// schemaTable is a table inspected from the database
+type schemaTable struct {
+	Name    string
+	Columns []*schemaColumn
+	Indexes []*schemaIndex
+	Keys    *schemaKeys
+}
+
+// foreignKeys groups the columns of the foreign keys by the constraints, in the order of the constraints
+func (t *schemaTable) foreignKeys() [][]*schemaForeignKey {
+	var groups [][]*schemaForeignKey
+	index := make(map[string]int)
+	for _, fk := range t.Keys.Foreign {
+		i, ok := index[fk.Name]
+		if !ok {
+			i = len(groups)
+			index[fk.Name] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], fk)
+	}
+	return groups
+}
+
+// inspectSchema reads the columns, the indexes and the keys of the tables,
+// all the tables except the migrations and the sqlite internal tables are read if names is empty.
+// The tables are ordered by name, and the referenced tables are before the tables referring to them.
+func inspectSchema(ctx context.Context, al *alias, names []string) ([]*schemaTable, error) {
+	switch al.Driver {
+	case DRMySQL, DRPostgres, DRSqlite:
+	default:
+		return nil, ErrNotImplement
+	}
+
+	db, release := tenantQuerier(al)
+	defer release()
+	exists, err := al.DbBaser.GetTables(db)
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		for name := range exists {
+			if name == "migrations" || strings.HasPrefix(name, "sqlite_") {
+				continue
+			}
+			names = append(names, name)
+		}
+	} else {
+		for _, name := range names {
+			if !exists[name] {
+				return nil, fmt.Errorf("table `%s` not found", name)
+			}
+		}
+	}
+	sort.Strings(names)
+
+	tables := make([]*schemaTable, 0, len(names))
+	for _, name := range names {
+		t := &schemaTable{Name: name}
+		if t.Columns, err = al.DbBaser.GetSchemaColumns(ctx, db, name); err != nil {
+			return nil, err
+		}
+		if t.Indexes, err = al.DbBaser.GetSchemaIndexes(ctx, db, name); err != nil {
+			return nil, err
+		}
+		if t.Keys, err = al.DbBaser.GetSchemaKeys(ctx, db, name); err != nil {
+			return nil, err
+		}
+		tables = append(tables, t)
+	}
+	return sortSchemaTables(tables), nil
+}
+
+// sortSchemaTables moves the referenced tables before the tables referring to them,
+// the tables in a reference cycle keep their order.
+func sortSchemaTables(tables []*schemaTable) []*schemaTable {
+	byName := make(map[string]*schemaTable, len(tables))
+	for _, t := range tables {
+		byName[t.Name] = t
+	}
+	visited := make(map[string]bool, len(tables))
+	sorted := make([]*schemaTable, 0, len(tables))
+	var visit func(t *schemaTable)
+	visit = func(t *schemaTable) {
+		visited[t.Name] = true
+		for _, fk := range t.Keys.Foreign {
+			if ref, ok := byName[fk.RefTable]; ok && !visited[ref.Name] {
+				visit(ref)
+			}
+		}
+		sorted = append(sorted, t)
+	}
+	for _, t := range tables {
+		if !visited[t.Name] {
+			visit(t)
+		}
+	}
+	return sorted
+}
+
+// DumpSchema reads the tables of the database alias and returns the changes creating them,
+// all the tables except the migrations are dumped if tables is empty.
+// It is the initial migration of the models generated by GenerateModels.
+func DumpSchema(ctx context.Context, name string, tables ...string) (*SchemaDiff, error) {
+	al := tenantAlias(ctx, getDbAlias(name))
+	inspected, err := inspectSchema(ctx, al, tables)
+	if err != nil {
+		return nil, err
+	}
+	diff := &SchemaDiff{}
+	for _, t := range inspected {
+		diff.Changes = append(diff.Changes, getTableDumpChange(al, t))
+	}
+	return diff, nil
+}
+
+// getTableDumpChange Get the change creating the inspected table and its indexes
+func getTableDumpChange(al *alias, t *schemaTable) *SchemaChange {
+	Q := al.DbBaser.TableQuote()
+	quote := func(names []string) string {
+		return Q + strings.Join(names, Q+", "+Q) + Q
+	}
+
+	var defs []string
+	inlinePk := false
+	for _, col := range t.Columns {
+		def := Q + col.Name + Q + " "
+		switch {
+		case col.Name == t.Keys.Auto && al.Driver == DRSqlite:
+			def += "integer NOT NULL PRIMARY KEY AUTOINCREMENT"
+			inlinePk = true
+		case col.Name == t.Keys.Auto && al.Driver == DRPostgres:
+			def += getPostgresSerialType(al.DbBaser.NormalizeColumnType(col.Type)) + " NOT NULL"
+		case col.Name == t.Keys.Auto:
+			def += col.Type + " NOT NULL AUTO_INCREMENT"
+		default:
+			def += getColumnDefinition(col)
+		}
+		defs = append(defs, def)
+	}
+	if len(t.Keys.Pk) > 0 && !inlinePk {
+		defs = append(defs, fmt.Sprintf("PRIMARY KEY (%s)", quote(t.Keys.Pk)))
+	}
+	for _, idx := range t.Indexes {
+		if idx.Constraint {
+			defs = append(defs, fmt.Sprintf("UNIQUE (%s)", quote(idx.Columns)))
+		}
+	}
+	for _, fks := range t.foreignKeys() {
+		var cols, refs []string
+		for _, fk := range fks {
+			cols = append(cols, fk.Column)
+			if fk.RefColumn != "" {
+				refs = append(refs, fk.RefColumn)
+			}
+		}
+		def := fmt.Sprintf("FOREIGN KEY (%s) REFERENCES %s%s%s", quote(cols), Q, fks[0].RefTable, Q)
+		if len(refs) > 0 {
+			def += fmt.Sprintf(" (%s)", quote(refs))
+		}
+		defs = append(defs, def)
+	}
+
+	create := fmt.Sprintf("CREATE TABLE %s%s%s (\n    %s\n)", Q, t.Name, Q, strings.Join(defs, ",\n    "))
+	if al.Driver == DRMySQL {
+		create += " ENGINE=INNODB"
+	}
+	up := []string{create + ";"}
+	for _, idx := range t.Indexes {
+		if !idx.Constraint {
+			up = append(up, getIndexCreateSQL(al, t.Name, idx))
+		}
+	}
+
+	return &SchemaChange{
+		Kind:  SchemaCreateTable,
+		Table: t.Name,
+		Up:    up,
+		Down:  []string{fmt.Sprintf("DROP TABLE %s%s%s;", Q, t.Name, Q)},
+	}
+}
+
+// getPostgresSerialType Get the serial type of the integer type of postgres
+func getPostgresSerialType(typ string) string {
+	switch typ {
+	case "bigint":
+		return "bigserial"
+	case "smallint":
+		return "smallserial"
+	default:
+		return "serial"
+	}
+}
+
+var modelsTpl = template.Must(template.New("models").Funcs(template.FuncMap{
+	"quote":   strconv.Quote,
+	"strings": getGoStringsLiteral,
+}).Parse(`// Code generated by "orm generate" from the database alias {{quote .Alias}}, edit it as needed.
+
+package {{.Package}}
+
+import (
+{{- if .Time}}
+	"time"
+{{end}}
+	"github.com/beego/beego/v2/client/orm"
+)
+
+func init() {
+	orm.RegisterModel({{range $i, $m := .Registered}}{{if $i}}, {{end}}new({{$m.Struct}}){{end}})
+}
+{{range .Models}}
+{{- if .NoPk}}
+// {{.Struct}} is not registered because the table {{.Table}} has no primary key
+{{- end}}
+type {{.Struct}} struct {
+{{- range .Fields}}
+	{{.Name}} {{.Type}}{{if .Tag}} ` + "`" + `orm:"{{.Tag}}"` + "`" + `{{end}}
+{{- end}}
+}
+
+func (m *{{.Struct}}) TableName() string {
+	return {{quote .Table}}
+}
+{{- if .PrimaryKey}}
+
+func (m *{{.Struct}}) TablePrimaryKey() []string {
+	return []string{{strings .PrimaryKey}}
+}
+{{- end}}
+{{- if .Indexes}}
+
+func (m *{{.Struct}}) TableIndex() [][]string {
+	return [][]string{
+{{- range .Indexes}}
+		{{strings .}},
+{{- end}}
+	}
+}
+{{- end}}
+{{- if .Uniques}}
+
+func (m *{{.Struct}}) TableUnique() [][]string {
+	return [][]string{
+{{- range .Uniques}}
+		{{strings .}},
+{{- end}}
+	}
+}
+{{- end}}
+{{end}}`))
+
+// getGoStringsLiteral Get the elements of the go literal of the strings, e.g. {"a", "b"}
+func getGoStringsLiteral(values []string) string {
+	quoted := make([]string, 0, len(values))
+	for _, v := range values {
+		quoted = append(quoted, strconv.Quote(v))
+	}
+	return "{" + strings.Join(quoted, ", ") + "}"
+}
+
+// generatedModel is the model of a table written by GenerateModels
+type generatedModel struct {
+	Struct string
+	Table  string
+	Fields []*generatedField
+	// PrimaryKey is the composite primary key
+	PrimaryKey []string
+	Indexes    [][]string
+	Uniques    [][]string
+	NoPk       bool
+}
+
+type generatedField struct {
+	Name string
+	Type string
+	Tag  string
+}
+
+// GenerateModels reads the tables of the database alias and writes the source of their models,
+// all the tables except the migrations are written if tables is empty.
+// The columns are mapped to the go types with the orm tags, the single column foreign keys to the written tables are
+// the rel(fk) fields, and the multiple column indexes and primary key are the TableIndex, TableUnique and TablePrimaryKey.
+func GenerateModels(ctx context.Context, w io.Writer, name string, pkg string, tables ...string) error {
+	al := tenantAlias(ctx, getDbAlias(name))
+	inspected, err := inspectSchema(ctx, al, tables)
+	if err != nil {
+		return err
+	}
+
+	structs := make(map[string]string, len(inspected))
+	pks := make(map[string][]string, len(inspected))
+	used := make(map[string]bool, len(inspected))
+	for _, t := range inspected {
+		s := getUniqueName(getGoName(t.Name), used)
+		structs[t.Name] = s
+		pks[t.Name] = t.Keys.Pk
+	}
+
+	hasTime := false
+	var models, registered []*generatedModel
+	for _, t := range inspected {
+		m := getGeneratedModel(al, t, structs, pks)
+		for _, f := range m.Fields {
+			hasTime = hasTime || f.Type == "time.Time"
+		}
+		models = append(models, m)
+		if !m.NoPk {
+			registered = append(registered, m)
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	err = modelsTpl.Execute(buf, map[string]interface{}{
+		"Alias":      al.Name,
+		"Package":    pkg,
+		"Time":       hasTime,
+		"Models":     models,
+		"Registered": registered,
+	})
+	if err != nil {
+		return err
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(src)
+	return err
+}
+
+// getGeneratedModel Get the model of the table,
+// structs and pks are the struct names and the primary keys of the generated tables.
+func getGeneratedModel(al *alias, t *schemaTable, structs map[string]string, pks map[string][]string) *generatedModel {
+	m := &generatedModel{Struct: structs[t.Name], Table: t.Name, NoPk: len(t.Keys.Pk) == 0}
+
+	// the single column foreign keys to the primary keys of the generated tables
+	rels := make(map[string]string)
+	for _, fks := range t.foreignKeys() {
+		fk := fks[0]
+		refPk := pks[fk.RefTable]
+		if len(fks) != 1 || len(refPk) != 1 || (fk.RefColumn != "" && fk.RefColumn != refPk[0]) {
+			continue
+		}
+		if len(t.Keys.Pk) == 1 && t.Keys.Pk[0] == fk.Column {
+			continue
+		}
+		rels[fk.Column] = fk.RefTable
+	}
+
+	singles := make(map[string]string)
+	for _, idx := range t.Indexes {
+		if len(idx.Columns) == 1 {
+			if idx.Unique {
+				singles[idx.Columns[0]] = "unique"
+			} else if singles[idx.Columns[0]] == "" {
+				singles[idx.Columns[0]] = "index"
+			}
+		}
+	}
+
+	fields := make(map[string]string, len(t.Columns))
+	used := make(map[string]bool, len(t.Columns))
+	for _, col := range t.Columns {
+		f := &generatedField{}
+		var tags []string
+		if ref, ok := rels[col.Name]; ok {
+			f.Name = getUniqueName(getGoName(strings.TrimSuffix(col.Name, "_id")), used)
+			f.Type = "*" + structs[ref]
+			tags = append(tags, "rel(fk)")
+			if getColumnName(f.Name)+"_id" != col.Name {
+				tags = append(tags, fmt.Sprintf("column(%s)", col.Name))
+			}
+		} else {
+			f.Name = getUniqueName(getGoName(col.Name), used)
+			if getColumnName(f.Name) != col.Name {
+				tags = append(tags, fmt.Sprintf("column(%s)", col.Name))
+			}
+			var typeTags []string
+			f.Type, typeTags = getGeneratedFieldType(al, col.Type)
+			switch {
+			case len(t.Keys.Pk) == 1 && col.Name == t.Keys.Auto && strings.Contains(f.Type, "int"):
+				tags = append(tags, "auto")
+			case len(t.Keys.Pk) == 1 && col.Name == t.Keys.Pk[0]:
+				tags = append(tags, "pk")
+			}
+			tags = append(tags, typeTags...)
+		}
+		isPk := len(t.Keys.Pk) == 1 && col.Name == t.Keys.Pk[0]
+		if col.Null && !isPk {
+			tags = append(tags, "null")
+		}
+		if tag := singles[col.Name]; tag != "" && !isPk {
+			tags = append(tags, tag)
+		}
+		f.Tag = strings.Join(tags, ";")
+		fields[col.Name] = f.Name
+		m.Fields = append(m.Fields, f)
+	}
+
+	toFields := func(columns []string) []string {
+		names := make([]string, 0, len(columns))
+		for _, col := range columns {
+			names = append(names, fields[col])
+		}
+		return names
+	}
+	if len(t.Keys.Pk) > 1 {
+		m.PrimaryKey = toFields(t.Keys.Pk)
+	}
+	for _, idx := range t.Indexes {
+		switch {
+		case len(idx.Columns) == 1:
+		case idx.Unique:
+			m.Uniques = append(m.Uniques, toFields(idx.Columns))
+		default:
+			m.Indexes = append(m.Indexes, toFields(idx.Columns))
+		}
+	}
+	return m
+}
+
+// getGeneratedFieldType Get the go type and the orm tags of the column type
+func getGeneratedFieldType(al *alias, typ string) (string, []string) {
+	// the values of the mysql enum are case-sensitive
+	if raw := strings.TrimSpace(typ); strings.HasPrefix(strings.ToLower(raw), "enum(") && strings.HasSuffix(raw, ")") {
+		return "string", []string{fmt.Sprintf("enum(%s)", strings.ReplaceAll(raw[len("enum("):len(raw)-1], "'", ""))}
+	}
+	typ = al.DbBaser.NormalizeColumnType(typ)
+	if elem, ok := strings.CutSuffix(typ, "[]"); ok {
+		switch {
+		case elem == "bigint" || elem == "integer" || elem == "smallint":
+			return "orm.IntArrayField", nil
+		case elem == "text" || strings.HasPrefix(elem, "character varying"):
+			return "orm.StringArrayField", nil
+		}
+	}
+	name, args := typ, ""
+	if i := strings.Index(typ, "("); i >= 0 {
+		name = typ[:i]
+		if j := strings.Index(typ[i:], ")"); j >= 0 {
+			args = typ[i+1 : i+j]
+		}
+	}
+	unsigned := strings.Contains(typ, "unsigned")
+	name = strings.TrimSpace(strings.Replace(strings.Replace(name, "unsigned", "", 1), "zerofill", "", 1))
+	integer := func(t string) string {
+		if unsigned {
+			return "u" + t
+		}
+		return t
+	}
+
+	switch name {
+	case "bool", "boolean":
+		return "bool", nil
+	case "tinyint":
+		if args == "1" {
+			return "bool", nil
+		}
+		return integer("int8"), nil
+	case "smallint", "int2":
+		return integer("int16"), nil
+	case "int", "integer", "mediumint", "int4":
+		return integer("int"), nil
+	case "bigint", "int8":
+		return integer("int64"), nil
+	case "float", "float4":
+		return "float32", nil
+	case "real":
+		if al.Driver == DRPostgres {
+			return "float32", nil
+		}
+		return "float64", nil
+	case "double", "double precision", "float8":
+		return "float64", nil
+	case "decimal", "numeric":
+		digits, decimals, ok := strings.Cut(args, ",")
+		if !ok || digits == "" {
+			return "float64", nil
+		}
+		return "float64", []string{fmt.Sprintf("digits(%s)", digits), fmt.Sprintf("decimals(%s)", decimals)}
+	case "varchar", "character varying", "nvarchar":
+		if args == "" {
+			return "string", []string{"type(text)"}
+		}
+		return "string", []string{fmt.Sprintf("size(%s)", args)}
+	case "char", "character", "nchar":
+		if args == "" {
+			args = "1"
+		}
+		return "string", []string{fmt.Sprintf("size(%s)", args), "type(char)"}
+	case "text", "tinytext", "mediumtext", "longtext", "clob":
+		return "string", []string{"type(text)"}
+	case "json", "jsonb":
+		return "string", []string{fmt.Sprintf("type(%s)", name)}
+	case "uuid":
+		return "orm.UUIDField", nil
+	case "inet":
+		return "orm.IPAddrField", nil
+	case "date":
+		return "time.Time", []string{"type(date)"}
+	case "time", "time without time zone":
+		return "time.Time", []string{"type(time)"}
+	case "datetime", "timestamp", "timestamp with time zone", "timestamp without time zone":
+		return "time.Time", []string{"type(datetime)"}
+	default:
+		return "string", nil
+	}
+}
+
+// getGoName Get the exported go name of the table or column
+func getGoName(name string) string {
+	b := strings.Builder{}
+	for _, r := range name {
+		if r == '_' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune('_')
+		}
+	}
+	goName := imodels.CamelString(b.String())
+	if goName == "" || goName[0] < 'A' || goName[0] > 'Z' {
+		goName = "X" + goName
+	}
+	return goName
+}
+
+// getUniqueName Get the name which is not used, the name with a number suffix if it is used
+func getUniqueName(name string, used map[string]bool) string {
+	unique := name
+	for i := 2; used[unique]; i++ {
+		unique = name + strconv.Itoa(i)
+	}
+	used[unique] = true
+	return unique
+}
+
+// getColumnName Get the column name of the field name, the same as the models without the column tag
+func getColumnName(name string) string {
+	return imodels.NameStrategyMap[imodels.NameStrategy](name)
+}
+
+// migrationWriter writes the migration file of the diff into dir and returns its path
+var migrationWriter func(dir string, name string, diff *SchemaDiff) (string, error)
+
+// RegisterMigrationWriter sets the writer of the migration files used by orm generate,
+// it is registered by importing the package migration.
+func RegisterMigrationWriter(w func(dir string, name string, diff *SchemaDiff) (string, error)) {
+	migrationWriter = w
+}
@@ -0,0 +1,153 @@
+// Copyright 2023 beego. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package orm
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateModels(t *testing.T) {
+	al := getDbAlias("default")
+	if al.Driver != DRSqlite {
+		t.Skip("the generate test only runs on sqlite")
+	}
+	ctx := context.Background()
+	exec := func(queries []string) {
+		for _, query := range queries {
+			_, err := al.DB.Exec(query)
+			require.NoError(t, err, query)
+		}
+	}
+	drop := []string{
+		"DROP TABLE IF EXISTS `gen_book_tag`",
+		"DROP TABLE IF EXISTS `gen_book`",
+		"DROP TABLE IF EXISTS `gen_author`",
+	}
+	tables := []string{"gen_book_tag", "gen_author", "gen_book"}
+
+	exec(drop)
+	exec([]string{
+		"CREATE TABLE `gen_author` (`id` integer NOT NULL PRIMARY KEY AUTOINCREMENT, `name` varchar(100) NOT NULL, " +
+			"`email` varchar(100) UNIQUE, `bio` text, `created` datetime NOT NULL)",
+		"CREATE TABLE `gen_book` (`id` integer NOT NULL PRIMARY KEY AUTOINCREMENT, " +
+			"`writer` integer NOT NULL REFERENCES `gen_author` (`id`), `title` varchar(200) NOT NULL, " +
+			"`price` decimal(10,2) NOT NULL DEFAULT 0, `published` date, `ISBN` char(13) NOT NULL)",
+		"CREATE INDEX `gen_book_title` ON `gen_book` (`title`)",
+		"CREATE INDEX `gen_book_writer_published` ON `gen_book` (`writer`, `published`)",
+		"CREATE TABLE `gen_book_tag` (`book_id` integer NOT NULL REFERENCES `gen_book` (`id`), `tag` varchar(30) NOT NULL, " +
+			"`rank` integer NOT NULL DEFAULT 0, PRIMARY KEY (`book_id`, `tag`))",
+		"CREATE UNIQUE INDEX `gen_book_tag_tag_rank` ON `gen_book_tag` (`tag`, `rank`)",
+	})
+	defer exec(drop)
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, GenerateModels(ctx, buf, "default", "models", tables...))
+	assert.Equal(t, `// Code generated by "orm generate" from the database alias "default", edit it as needed.
+
+package models
+
+import (
+	"time"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+func init() {
+	orm.RegisterModel(new(GenAuthor), new(GenBook), new(GenBookTag))
+}
+
+type GenAuthor struct {
+	Id      int       `+"`orm:\"auto\"`"+`
+	Name    string    `+"`orm:\"size(100)\"`"+`
+	Email   string    `+"`orm:\"size(100);null;unique\"`"+`
+	Bio     string    `+"`orm:\"type(text);null\"`"+`
+	Created time.Time `+"`orm:\"type(datetime)\"`"+`
+}
+
+func (m *GenAuthor) TableName() string {
+	return "gen_author"
+}
+
+type GenBook struct {
+	Id        int        `+"`orm:\"auto\"`"+`
+	Writer    *GenAuthor `+"`orm:\"rel(fk);column(writer)\"`"+`
+	Title     string     `+"`orm:\"size(200);index\"`"+`
+	Price     float64    `+"`orm:\"digits(10);decimals(2)\"`"+`
+	Published time.Time  `+"`orm:\"type(date);null\"`"+`
+	ISBN      string     `+"`orm:\"column(ISBN);size(13);type(char)\"`"+`
+}
+
+func (m *GenBook) TableName() string {
+	return "gen_book"
+}
+
+func (m *GenBook) TableIndex() [][]string {
+	return [][]string{
+		{"Writer", "Published"},
+	}
+}
+
+type GenBookTag struct {
+	Book *GenBook `+"`orm:\"rel(fk)\"`"+`
+	Tag  string   `+"`orm:\"size(30)\"`"+`
+	Rank int
+}
+
+func (m *GenBookTag) TableName() string {
+	return "gen_book_tag"
+}
+
+func (m *GenBookTag) TablePrimaryKey() []string {
+	return []string{"Book", "Tag"}
+}
+
+func (m *GenBookTag) TableUnique() [][]string {
+	return [][]string{
+		{"Tag", "Rank"},
+	}
+}
+`, buf.String())
+
+	diff, err := DumpSchema(ctx, "default", tables...)
+	require.NoError(t, err)
+	var kinds []string
+	for _, c := range diff.Changes {
+		kinds = append(kinds, c.String())
+	}
+	// the referenced tables are created first
+	assert.Equal(t, []string{"create_table gen_author", "create_table gen_book", "create_table gen_book_tag"}, kinds)
+	assert.Equal(t, []string{"CREATE TABLE `gen_author` (\n" +
+		"    `id` integer NOT NULL PRIMARY KEY AUTOINCREMENT,\n" +
+		"    `name` varchar(100) NOT NULL,\n" +
+		"    `email` varchar(100),\n" +
+		"    `bio` TEXT,\n" +
+		"    `created` datetime NOT NULL,\n" +
+		"    UNIQUE (`email`)\n);"}, diff.Changes[0].Up)
+	assert.Equal(t, []string{"DROP TABLE `gen_author`;"}, diff.Changes[0].Down)
+
+	exec(drop)
+	exec(diff.UpSQL())
+	dumped := &bytes.Buffer{}
+	require.NoError(t, GenerateModels(ctx, dumped, "default", "models", tables...))
+	assert.Equal(t, buf.String(), dumped.String())
+
+	_, err = DumpSchema(ctx, "default", "gen_unknown")
+	assert.NotNil(t, err)
+}
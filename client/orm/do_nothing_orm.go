@@ -107,6 +107,22 @@ func (d *DoNothingOrm) InsertMultiWithCtx(ctx context.Context, bulk int, mds int
 	return 0, nil
 }
 
+func (d *DoNothingOrm) InsertOrUpdateMulti(bulk int, mds interface{}, conflictCols []string, updateCols []string) (int64, error) {
+	return 0, nil
+}
+
+func (d *DoNothingOrm) InsertOrUpdateMultiWithCtx(ctx context.Context, bulk int, mds interface{}, conflictCols []string, updateCols []string) (int64, error) {
+	return 0, nil
+}
+
+func (d *DoNothingOrm) UpdateMulti(bulk int, mds interface{}, cols ...string) (int64, error) {
+	return 0, nil
+}
+
+func (d *DoNothingOrm) UpdateMultiWithCtx(ctx context.Context, bulk int, mds interface{}, cols ...string) (int64, error) {
+	return 0, nil
+}
+
 func (d *DoNothingOrm) Update(md interface{}, cols ...string) (int64, error) {
 	return 0, nil
 }
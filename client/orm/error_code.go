@@ -0,0 +1,28 @@
+// Copyright 2023 beego. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package orm
+
+import (
+	"github.com/beego/beego/v2/core/berror"
+)
+
+var StaleObject = berror.DefineCode(4003001, moduleName, "StaleObject", `
+The model has a version field, and Update found that the row was updated or deleted by others after you read it.
+Usually you should read the row again, apply your changes and update it again.
+You can check it by errors.Is(err, orm.ErrStaleObject).
+`)
+
+// ErrStaleObject is returned by Update when the version of the model is out of date
+var ErrStaleObject = berror.Error(StaleObject, "the model is updated or deleted by others")
@@ -0,0 +1,359 @@
+// Copyright 2023 beego
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+const (
+	ActionInsert = "insert"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+	// ActionUpsert is the row of InsertOrUpdateMulti without the primary key,
+	// which may be inserted or updated
+	ActionUpsert = "upsert"
+)
+
+// DefaultMaxRows is the max number of the rows changed by the Update or Delete of a QuerySeter, see WithMaxRows
+var DefaultMaxRows = 10000
+
+// ErrTooManyRows is returned when the QuerySeter changes more rows than the max rows,
+// the operation is not executed.
+var ErrTooManyRows = errors.New("audit: the operation changes too many rows")
+
+// pkChunk is the max number of the rows read by one query of the primary keys
+const pkChunk = 200
+
+// Record is the change of a row
+type Record struct {
+	Action string
+	Table  string
+	// PK is the value of the primary key, the values of the composite primary key are joined by ","
+	PK string
+	// Old is the row before the change, it is nil for insert
+	Old orm.Params
+	// New is the row after the change, it is nil for delete
+	New   orm.Params
+	Actor string
+	Time  time.Time
+}
+
+type actorKey struct{}
+
+// WithActor returns the context with the acting user, which is recorded by the filter
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext returns the acting user set by WithActor
+func ActorFromContext(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	return actor
+}
+
+type FilterChainOption func(builder *FilterChainBuilder)
+
+// WithMaxRows sets the max number of the rows changed by the Update or Delete of a QuerySeter,
+// the rows are read before the operation, and ErrTooManyRows is returned if there are more.
+// It defaults to DefaultMaxRows, and n <= 0 means no limit.
+func WithMaxRows(n int) FilterChainOption {
+	return func(builder *FilterChainBuilder) {
+		builder.maxRows = n
+	}
+}
+
+// WithActorFunc sets the function to get the acting user from the context, it defaults to ActorFromContext
+func WithActorFunc(actor func(ctx context.Context) string) FilterChainOption {
+	return func(builder *FilterChainBuilder) {
+		builder.actor = actor
+	}
+}
+
+// FilterChainBuilder provides a Filter which records the changes of the rows by Insert*, Update*, Delete*
+// and the Update and Delete of QuerySeter. The rows are read by the primary key before and after the operation,
+// and the records are written to the sink by the executor of the operation, so they are in the same transaction.
+// The rows inserted by InsertMulti and InsertOrUpdateMulti without the primary key are recorded by the model values.
+type FilterChainBuilder struct {
+	sink    Sink
+	actor   func(ctx context.Context) string
+	maxRows int
+}
+
+func NewFilterChainBuilder(sink Sink, options ...FilterChainOption) *FilterChainBuilder {
+	builder := &FilterChainBuilder{
+		sink:    sink,
+		actor:   ActorFromContext,
+		maxRows: DefaultMaxRows,
+	}
+	for _, o := range options {
+		o(builder)
+	}
+	return builder
+}
+
+// FilterChain records the changes of the operation if it succeeds,
+// and returns the error of the sink as the error of the operation.
+// Note that the operation outside a transaction is not rolled back if the sink fails.
+func (builder *FilterChainBuilder) FilterChain(next orm.Filter) orm.Filter {
+	return func(ctx context.Context, inv *orm.Invocation) []interface{} {
+		op := newOperation(inv)
+		if op == nil {
+			return next(ctx, inv)
+		}
+		op.maxRows = builder.maxRows
+
+		// read the rows from the primary database, the replicas may be behind
+		readCtx := orm.ForcePrimary(ctx)
+		old, err := op.readOld(readCtx)
+		if err != nil {
+			return []interface{}{int64(0), err}
+		}
+
+		res := next(ctx, inv)
+		if res[len(res)-1] != nil {
+			return res
+		}
+
+		rows, err := op.readNew(readCtx, old)
+		if err != nil {
+			res[len(res)-1] = err
+			return res
+		}
+		records := op.records(old, rows, builder.actor(ctx))
+		if len(records) == 0 {
+			return res
+		}
+		if err = builder.sink.Write(ctx, op.exec, records); err != nil {
+			res[len(res)-1] = err
+		}
+		return res
+	}
+}
+
+// operation is the insert, update or delete of the models or the rows of the QuerySeter
+type operation struct {
+	method  string
+	exec    orm.QueryExecutor
+	table   string
+	pks     []string
+	models  []reflect.Value
+	qs      orm.QuerySeter
+	maxRows int
+}
+
+func newOperation(inv *orm.Invocation) *operation {
+	op := &operation{
+		method: inv.Method,
+		exec:   inv.GetExecutor(),
+		table:  inv.GetTableName(),
+		pks:    inv.GetPkFieldNames(),
+	}
+	// the audit records are not audited
+	if op.exec == nil || op.table == "" || op.table == (&Log{}).TableName() || len(op.pks) == 0 {
+		return nil
+	}
+	switch inv.Method {
+	case "InsertWithCtx", "InsertOrUpdateWithCtx", "UpdateWithCtx", "DeleteWithCtx":
+		op.models = indirectModels(inv.Args[0])
+	case "InsertMultiWithCtx", "InsertOrUpdateMultiWithCtx", "UpdateMultiWithCtx":
+		op.models = indirectModels(inv.Args[1])
+	case "QuerySeterUpdateWithCtx", "QuerySeterDeleteWithCtx":
+		op.qs = inv.Args[0].(orm.QuerySeter)
+	default:
+		return nil
+	}
+	return op
+}
+
+// indirectModels returns the struct values of the model or the slice of models
+func indirectModels(md interface{}) []reflect.Value {
+	ind := reflect.Indirect(reflect.ValueOf(md))
+	if ind.Kind() != reflect.Slice {
+		return []reflect.Value{ind}
+	}
+	res := make([]reflect.Value, 0, ind.Len())
+	for i := 0; i < ind.Len(); i++ {
+		res = append(res, reflect.Indirect(ind.Index(i)))
+	}
+	return res
+}
+
+// readOld reads the rows before the operation
+func (op *operation) readOld(ctx context.Context) ([]orm.Params, error) {
+	switch op.method {
+	case "InsertWithCtx", "InsertMultiWithCtx":
+		return nil, nil
+	case "QuerySeterUpdateWithCtx", "QuerySeterDeleteWithCtx":
+		// read one more row to know whether there are too many
+		limit := -1
+		if op.maxRows > 0 {
+			limit = op.maxRows + 1
+		}
+		var rows []orm.Params
+		if _, err := op.qs.Limit(limit).ValuesWithCtx(ctx, &rows); err != nil {
+			return nil, err
+		}
+		if op.maxRows > 0 && len(rows) > op.maxRows {
+			return nil, fmt.Errorf("%w: more than %d rows of `%s`", ErrTooManyRows, op.maxRows, op.table)
+		}
+		return rows, nil
+	}
+	return op.readModels(ctx)
+}
+
+// readNew reads the rows after the operation, the rows of the QuerySeter are read by the primary keys of old,
+// because the update may change the filtered fields.
+func (op *operation) readNew(ctx context.Context, old []orm.Params) ([]orm.Params, error) {
+	switch op.method {
+	case "DeleteWithCtx", "QuerySeterDeleteWithCtx":
+		return nil, nil
+	case "QuerySeterUpdateWithCtx":
+		conds := make([]*orm.Condition, 0, len(old))
+		for _, row := range old {
+			c := orm.NewCondition()
+			for _, name := range op.pks {
+				c = c.And(name, row[name])
+			}
+			conds = append(conds, c)
+		}
+		return op.read(ctx, conds)
+	}
+	return op.readModels(ctx)
+}
+
+// readModels reads the rows of the models which have the primary key
+func (op *operation) readModels(ctx context.Context) ([]orm.Params, error) {
+	conds := make([]*orm.Condition, 0, len(op.models))
+	for _, ind := range op.models {
+		if c := op.pkCond(ind); c != nil {
+			conds = append(conds, c)
+		}
+	}
+	return op.read(ctx, conds)
+}
+
+func (op *operation) pkCond(ind reflect.Value) *orm.Condition {
+	if ind.Kind() != reflect.Struct {
+		return nil
+	}
+	cond := orm.NewCondition()
+	for _, name := range op.pks {
+		field := ind.FieldByName(name)
+		if !field.IsValid() || field.IsZero() {
+			return nil
+		}
+		cond = cond.And(name, field.Interface())
+	}
+	return cond
+}
+
+// read reads the rows matching any of the primary key conditions, pkChunk conditions in a query
+func (op *operation) read(ctx context.Context, conds []*orm.Condition) ([]orm.Params, error) {
+	var rows []orm.Params
+	for start := 0; start < len(conds); start += pkChunk {
+		cond := orm.NewCondition()
+		for _, c := range conds[start:min(start+pkChunk, len(conds))] {
+			cond = cond.OrCond(c)
+		}
+		var chunk []orm.Params
+		if _, err := op.exec.QueryTable(op.table).SetCond(cond).Limit(-1).ValuesWithCtx(ctx, &chunk); err != nil {
+			return nil, err
+		}
+		rows = append(rows, chunk...)
+	}
+	return rows, nil
+}
+
+// records matches the old and new rows by the primary key
+func (op *operation) records(old, rows []orm.Params, actor string) []*Record {
+	now := time.Now()
+	olds := make(map[string]orm.Params, len(old))
+	for _, row := range old {
+		olds[op.pkOf(row)] = row
+	}
+
+	records := make([]*Record, 0, len(old)+len(rows))
+	newRecord := func(action, pk string, old, row orm.Params) {
+		records = append(records, &Record{
+			Action: action,
+			Table:  op.table,
+			PK:     pk,
+			Old:    old,
+			New:    row,
+			Actor:  actor,
+			Time:   now,
+		})
+	}
+
+	seen := make(map[string]bool, len(rows))
+	for _, row := range rows {
+		pk := op.pkOf(row)
+		seen[pk] = true
+		if o, ok := olds[pk]; !ok {
+			newRecord(ActionInsert, pk, nil, row)
+		} else if !reflect.DeepEqual(o, row) {
+			newRecord(ActionUpdate, pk, o, row)
+		}
+	}
+	switch op.method {
+	case "DeleteWithCtx", "QuerySeterDeleteWithCtx":
+		for _, row := range old {
+			newRecord(ActionDelete, op.pkOf(row), row, nil)
+		}
+	case "InsertMultiWithCtx", "InsertOrUpdateMultiWithCtx":
+		// the primary keys are not set by the bulk insert, and the upserted rows may be inserted or updated
+		action := ActionInsert
+		if op.method == "InsertOrUpdateMultiWithCtx" {
+			action = ActionUpsert
+		}
+		for _, ind := range op.models {
+			if op.pkCond(ind) == nil && ind.Kind() == reflect.Struct {
+				newRecord(action, "", nil, modelValues(ind))
+			}
+		}
+	}
+	return records
+}
+
+func (op *operation) pkOf(row orm.Params) string {
+	values := make([]string, 0, len(op.pks))
+	for _, name := range op.pks {
+		values = append(values, fmt.Sprint(row[name]))
+	}
+	return strings.Join(values, ",")
+}
+
+// modelValues returns the values of the fields of the model, the relations are ignored
+func modelValues(ind reflect.Value) orm.Params {
+	typ := ind.Type()
+	values := make(orm.Params, typ.NumField())
+	for i := 0; i < typ.NumField(); i++ {
+		sf := typ.Field(i)
+		tag := sf.Tag.Get("orm")
+		if !sf.IsExported() || tag == "-" || strings.Contains(tag, "rel(") || strings.Contains(tag, "reverse(") {
+			continue
+		}
+		values[sf.Name] = ind.Field(i).Interface()
+	}
+	return values
+}
@@ -0,0 +1,159 @@
+// Copyright 2023 beego
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+type AuditUser struct {
+	Id   int
+	Name string `orm:"size(64)"`
+	Age  int
+}
+
+func newTestOrm(t *testing.T, sink Sink) orm.Ormer {
+	err := orm.RegisterDataBase("default", "sqlite3", filepath.Join(t.TempDir(), "audit.db"))
+	require.Nil(t, err)
+	orm.RegisterModel(new(AuditUser), new(Log))
+	require.Nil(t, orm.RunSyncdb("default", false, false))
+	return orm.NewFilterOrmDecorator(orm.NewOrm(), NewFilterChainBuilder(sink).FilterChain)
+}
+
+func TestFilterChainBuilder_FilterChain(t *testing.T) {
+	var records []*Record
+	o := newTestOrm(t, SinkFunc(func(ctx context.Context, executor orm.QueryExecutor, rs []*Record) error {
+		records = append(records, rs...)
+		return TableSink().Write(ctx, executor, rs)
+	}))
+	ctx := WithActor(context.Background(), "slene")
+
+	user := &AuditUser{Name: "slene", Age: 28}
+	_, err := o.InsertWithCtx(ctx, user)
+	require.Nil(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, ActionInsert, records[0].Action)
+	assert.Equal(t, "audit_user", records[0].Table)
+	assert.Equal(t, "1", records[0].PK)
+	assert.Nil(t, records[0].Old)
+	assert.Equal(t, "slene", records[0].New["Name"])
+	assert.Equal(t, "slene", records[0].Actor)
+
+	user.Age = 29
+	_, err = o.UpdateWithCtx(ctx, user)
+	require.Nil(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, ActionUpdate, records[1].Action)
+	assert.EqualValues(t, 28, records[1].Old["Age"])
+	assert.EqualValues(t, 29, records[1].New["Age"])
+
+	// nothing is changed
+	_, err = o.UpdateWithCtx(ctx, user)
+	require.Nil(t, err)
+	assert.Len(t, records, 2)
+
+	// the pks are not set by the bulk insert
+	_, err = o.InsertMultiWithCtx(ctx, 2, []*AuditUser{{Name: "astaxie", Age: 30}, {Name: "nobody", Age: 30}})
+	require.Nil(t, err)
+	require.Len(t, records, 4)
+	assert.Equal(t, ActionInsert, records[2].Action)
+	assert.Equal(t, "", records[2].PK)
+	assert.Equal(t, "astaxie", records[2].New["Name"])
+
+	num, err := o.QueryTable(new(AuditUser)).Filter("Age", 30).UpdateWithCtx(ctx, orm.Params{"Age": 31})
+	require.Nil(t, err)
+	assert.Equal(t, int64(2), num)
+	require.Len(t, records, 6)
+	for _, r := range records[4:] {
+		assert.Equal(t, ActionUpdate, r.Action)
+		assert.EqualValues(t, 30, r.Old["Age"])
+		assert.EqualValues(t, 31, r.New["Age"])
+	}
+
+	num, err = o.QueryTable(new(AuditUser)).Filter("Name", "nobody").DeleteWithCtx(ctx)
+	require.Nil(t, err)
+	assert.Equal(t, int64(1), num)
+	require.Len(t, records, 7)
+	assert.Equal(t, ActionDelete, records[6].Action)
+	assert.Equal(t, "3", records[6].PK)
+	assert.Nil(t, records[6].New)
+
+	_, err = o.DeleteWithCtx(ctx, user)
+	require.Nil(t, err)
+	require.Len(t, records, 8)
+	assert.Equal(t, ActionDelete, records[7].Action)
+	assert.Equal(t, "1", records[7].PK)
+	assert.Equal(t, "slene", records[7].Old["Name"])
+
+	// the records are written to the audit table
+	var logs []*Log
+	_, err = o.QueryTable(new(Log)).OrderBy("Id").All(&logs)
+	require.Nil(t, err)
+	require.Len(t, logs, 8)
+	assert.Equal(t, "audit_user", logs[1].Table)
+	assert.Equal(t, "slene", logs[1].Actor)
+	var values map[string]interface{}
+	require.Nil(t, json.Unmarshal([]byte(logs[1].NewValues), &values))
+	assert.EqualValues(t, 29, values["Age"])
+
+	// the records are rolled back with the transaction
+	err = o.DoTxWithCtx(ctx, func(ctx context.Context, txOrm orm.TxOrmer) error {
+		_, err := txOrm.InsertWithCtx(ctx, &AuditUser{Name: "rollback"})
+		require.Nil(t, err)
+		return errors.New("rollback")
+	})
+	assert.NotNil(t, err)
+	assert.Len(t, records, 9)
+	cnt, err := o.QueryTable(new(Log)).Count()
+	require.Nil(t, err)
+	assert.Equal(t, int64(8), cnt)
+
+	// the rows without the pk may be inserted or updated by the upsert
+	_, err = o.InsertOrUpdateMultiWithCtx(ctx, 2, []*AuditUser{{Name: "upsert", Age: 40}}, nil, nil)
+	require.Nil(t, err)
+	require.Len(t, records, 10)
+	assert.Equal(t, ActionUpsert, records[9].Action)
+	assert.Equal(t, "upsert", records[9].New["Name"])
+
+	// the operation is not executed if it changes too many rows
+	limited := orm.NewFilterOrmDecorator(orm.NewOrm(), NewFilterChainBuilder(TableSink(), WithMaxRows(1)).FilterChain)
+	_, err = limited.QueryTable(new(AuditUser)).UpdateWithCtx(ctx, orm.Params{"Age": 50})
+	assert.ErrorIs(t, err, ErrTooManyRows)
+	cnt, err = o.QueryTable(new(AuditUser)).Filter("Age", 50).Count()
+	require.Nil(t, err)
+	assert.Equal(t, int64(0), cnt)
+}
+
+func TestChanSink(t *testing.T) {
+	ch := make(chan *Record, 1)
+	r := &Record{Action: ActionInsert}
+	require.Nil(t, ChanSink(ch).Write(context.Background(), nil, []*Record{r}))
+	assert.Equal(t, r, <-ch)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	ch <- r
+	assert.Equal(t, context.Canceled, ChanSink(ch).Write(ctx, nil, []*Record{r}))
+}
@@ -0,0 +1,121 @@
+// Copyright 2023 beego
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/beego/beego/v2/client/orm"
+	"github.com/beego/beego/v2/core/logs"
+)
+
+// Sink writes the records of an operation,
+// executor runs the operation, which is the TxOrmer inside a transaction.
+type Sink interface {
+	Write(ctx context.Context, executor orm.QueryExecutor, records []*Record) error
+}
+
+// SinkFunc is an adapter to use the function as Sink
+type SinkFunc func(ctx context.Context, executor orm.QueryExecutor, records []*Record) error
+
+func (f SinkFunc) Write(ctx context.Context, executor orm.QueryExecutor, records []*Record) error {
+	return f(ctx, executor, records)
+}
+
+// Log is the row of the audit table written by TableSink,
+// register it by orm.RegisterModel(new(audit.Log)) to use TableSink
+type Log struct {
+	Id        int64
+	Action    string    `orm:"size(16)"`
+	Table     string    `orm:"size(128);column(table_name)"`
+	Pk        string    `orm:"size(255)"`
+	OldValues string    `orm:"type(text);null"`
+	NewValues string    `orm:"type(text);null"`
+	Actor     string    `orm:"size(128)"`
+	Created   time.Time `orm:"type(datetime)"`
+}
+
+func (l *Log) TableName() string {
+	return "audit_log"
+}
+
+// TableSink inserts the records into the audit table by the executor,
+// so they are rolled back with the transaction of the operation
+func TableSink() Sink {
+	return SinkFunc(func(ctx context.Context, executor orm.QueryExecutor, records []*Record) error {
+		logs := make([]*Log, 0, len(records))
+		for _, r := range records {
+			l := &Log{
+				Action:  r.Action,
+				Table:   r.Table,
+				Pk:      r.PK,
+				Actor:   r.Actor,
+				Created: r.Time,
+			}
+			var err error
+			if l.OldValues, err = marshalValues(r.Old); err != nil {
+				return err
+			}
+			if l.NewValues, err = marshalValues(r.New); err != nil {
+				return err
+			}
+			logs = append(logs, l)
+		}
+		_, err := executor.InsertMultiWithCtx(ctx, len(logs), logs)
+		return err
+	})
+}
+
+func marshalValues(values orm.Params) (string, error) {
+	if values == nil {
+		return "", nil
+	}
+	b, err := json.Marshal(values)
+	return string(b), err
+}
+
+// LogSink writes the records to the logger, logs.GetBeeLogger() is used if logger is nil
+func LogSink(logger *logs.BeeLogger) Sink {
+	if logger == nil {
+		logger = logs.GetBeeLogger()
+	}
+	return SinkFunc(func(ctx context.Context, executor orm.QueryExecutor, records []*Record) error {
+		for _, r := range records {
+			b, err := json.Marshal(r)
+			if err != nil {
+				return err
+			}
+			logger.Info("[AUDIT] %s", b)
+		}
+		return nil
+	})
+}
+
+// ChanSink sends the records to ch, it blocks the operation until ch receives them
+// or the context is done, so the receiver should not run the orm operations of the same transaction.
+func ChanSink(ch chan<- *Record) Sink {
+	return SinkFunc(func(ctx context.Context, executor orm.QueryExecutor, records []*Record) error {
+		for _, r := range records {
+			select {
+			case ch <- r:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,396 @@
+// Copyright 2023 beego
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slowquery
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"html/template"
+	"regexp"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/beego/beego/v2/client/orm"
+	"github.com/beego/beego/v2/core/admin"
+	"github.com/beego/beego/v2/core/logs"
+)
+
+const (
+	// the timeout of the EXPLAIN statement
+	explainTimeout = 3 * time.Second
+	// the max number of the EXPLAIN statements running at the same time,
+	// the EXPLAIN is skipped if there are too many
+	maxExplains = 4
+)
+
+// Entry is a slow statement
+type Entry struct {
+	Alias     string
+	Operation string
+	Query     string
+	// Args are redacted by the redactor of the Recorder
+	Args     []interface{}
+	Duration time.Duration
+	// Caller is the file and line which calls the orm
+	Caller string
+	// Explain is the result of EXPLAIN for the slow SELECT, one row per line
+	Explain string
+	Time    time.Time
+}
+
+// Stat is the statistics of the slow statements with the same fingerprint
+type Stat struct {
+	Fingerprint string
+	Count       int64
+	Total       time.Duration
+	Max         time.Duration
+	// Slowest is the slowest statement of the fingerprint,
+	// it is nil if all the statements take no time
+	Slowest *Entry
+}
+
+// Avg returns the average duration of the statements
+func (s *Stat) Avg() time.Duration {
+	if s.Count == 0 {
+		return 0
+	}
+	return s.Total / time.Duration(s.Count)
+}
+
+type Option func(r *Recorder)
+
+// WithExplain runs EXPLAIN for the slow SELECT statements on mysql, tidb, postgres and sqlite,
+// it only runs when the statement is the slowest of its fingerprint, and it runs in another goroutine,
+// so Entry.Explain is set a while later.
+func WithExplain(explain bool) Option {
+	return func(r *Recorder) {
+		r.explain = explain
+	}
+}
+
+// WithRedactor sets the function to redact the args before they are logged and recorded
+func WithRedactor(redactor func(query string, args []interface{}) []interface{}) Option {
+	return func(r *Recorder) {
+		r.redactor = redactor
+	}
+}
+
+// WithLogger sets the logger of the slow statements, it defaults to logs.GetBeeLogger(), and nil disables the log.
+func WithLogger(logger *logs.BeeLogger) Option {
+	return func(r *Recorder) {
+		r.logger = logger
+	}
+}
+
+// WithMaxFingerprints sets the max number of the recorded fingerprints, it defaults to 1000,
+// the fingerprint with the least total duration is removed if there are too many.
+func WithMaxFingerprints(n int) Option {
+	return func(r *Recorder) {
+		r.maxFingerprints = n
+	}
+}
+
+// RedactArgs replaces all the args with "?"
+func RedactArgs(query string, args []interface{}) []interface{} {
+	res := make([]interface{}, len(args))
+	for i := range res {
+		res[i] = "?"
+	}
+	return res
+}
+
+// Recorder records the statements which take longer than the threshold,
+// and aggregates them by the fingerprint which replaces the literals with "?".
+// Call Register to listen the statements of orm and show the top statements on the admin server.
+type Recorder struct {
+	threshold       time.Duration
+	explain         bool
+	redactor        func(query string, args []interface{}) []interface{}
+	logger          *logs.BeeLogger
+	maxFingerprints int
+
+	mu    sync.Mutex
+	stats map[string]*Stat
+	// explains limits the running EXPLAIN statements
+	explains chan struct{}
+}
+
+func NewRecorder(threshold time.Duration, options ...Option) *Recorder {
+	r := &Recorder{
+		threshold:       threshold,
+		logger:          logs.GetBeeLogger(),
+		maxFingerprints: 1000,
+		stats:           make(map[string]*Stat),
+		explains:        make(chan struct{}, maxExplains),
+	}
+	for _, o := range options {
+		o(r)
+	}
+	return r
+}
+
+// Register adds r as the query listener of orm, and the command "orm" "slowquery" of the admin server,
+// it must be called before the Ormer is created, see orm.AddQueryListener.
+func (r *Recorder) Register() {
+	orm.AddQueryListener(r.Observe)
+	admin.RegisterCommand("orm", "slowquery", r)
+}
+
+// Observe records the statement if it is slow
+func (r *Recorder) Observe(stat *orm.QueryStat) {
+	if stat.Duration < r.threshold {
+		return
+	}
+	entry := &Entry{
+		Alias:     stat.Alias,
+		Operation: stat.Operation,
+		Query:     stat.Query,
+		Args:      stat.Args,
+		Duration:  stat.Duration,
+		Caller:    caller(),
+		Time:      stat.Start,
+	}
+	if r.redactor != nil {
+		entry.Args = r.redactor(stat.Query, stat.Args)
+	}
+	fingerprint := Fingerprint(stat.Query)
+
+	r.mu.Lock()
+	s, ok := r.stats[fingerprint]
+	if !ok {
+		r.evict()
+		s = &Stat{Fingerprint: fingerprint}
+		r.stats[fingerprint] = s
+	}
+	s.Count++
+	s.Total += stat.Duration
+	slowest := stat.Duration > s.Max
+	if slowest {
+		s.Max = stat.Duration
+		s.Slowest = entry
+	}
+	r.mu.Unlock()
+
+	if slowest && r.explain && isSelect(stat) {
+		r.explainAsync(stat, entry)
+	}
+	if r.logger != nil {
+		r.logger.Warn("[ORM] slow query - [%s / %s / %.1fms] - [%s] - %v - %s",
+			entry.Alias, entry.Operation, float64(entry.Duration)/float64(time.Millisecond), entry.Query, entry.Args, entry.Caller)
+	}
+}
+
+// explainAsync runs EXPLAIN in another goroutine so the caller of orm is not blocked,
+// it skips the EXPLAIN if there are too many running
+func (r *Recorder) explainAsync(stat *orm.QueryStat, entry *Entry) {
+	select {
+	case r.explains <- struct{}{}:
+	default:
+		r.mu.Lock()
+		entry.Explain = "EXPLAIN skipped: too many running"
+		r.mu.Unlock()
+		return
+	}
+	go func() {
+		defer func() { <-r.explains }()
+		res, err := explain(stat)
+		if err != nil {
+			res = "EXPLAIN failed: " + err.Error()
+		}
+		r.mu.Lock()
+		entry.Explain = res
+		r.mu.Unlock()
+	}()
+}
+
+// evict removes the fingerprint with the least total duration if there are too many
+func (r *Recorder) evict() {
+	if r.maxFingerprints <= 0 || len(r.stats) < r.maxFingerprints {
+		return
+	}
+	var least *Stat
+	for _, s := range r.stats {
+		if least == nil || s.Total < least.Total {
+			least = s
+		}
+	}
+	delete(r.stats, least.Fingerprint)
+}
+
+// Top returns the copies of the top n stats ordered by the total duration, n <= 0 returns all of them
+func (r *Recorder) Top(n int) []Stat {
+	r.mu.Lock()
+	res := make([]Stat, 0, len(r.stats))
+	for _, s := range r.stats {
+		c := *s
+		if s.Slowest != nil {
+			e := *s.Slowest
+			c.Slowest = &e
+		}
+		res = append(res, c)
+	}
+	r.mu.Unlock()
+
+	slices.SortFunc(res, func(a, b Stat) int {
+		return cmp.Compare(b.Total, a.Total)
+	})
+	if n > 0 && len(res) > n {
+		res = res[:n]
+	}
+	return res
+}
+
+// Reset removes all the stats
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	r.stats = make(map[string]*Stat)
+	r.mu.Unlock()
+}
+
+// Execute returns the top stats for the admin server, the first param is the number of stats, it defaults to 20
+func (r *Recorder) Execute(params ...interface{}) *admin.Result {
+	n := 20
+	if len(params) > 0 {
+		if v, ok := params[0].(int); ok {
+			n = v
+		}
+	}
+	stats := r.Top(n)
+	resultList := make([][]string, 0, len(stats))
+	for _, s := range stats {
+		slowest := s.Slowest
+		if slowest == nil {
+			slowest = &Entry{}
+		}
+		resultList = append(resultList, []string{
+			template.HTMLEscapeString(s.Fingerprint),
+			fmt.Sprintf("%d", s.Count),
+			s.Total.String(),
+			s.Avg().String(),
+			s.Max.String(),
+			template.HTMLEscapeString(slowest.Caller),
+			template.HTMLEscapeString(fmt.Sprintf("%v", slowest.Args)),
+			template.HTMLEscapeString(slowest.Explain),
+		})
+	}
+	return &admin.Result{
+		Status:  200,
+		Content: resultList,
+	}
+}
+
+var (
+	fingerprintString = regexp.MustCompile(`'(?:[^']|'')*'`)
+	fingerprintNumber = regexp.MustCompile(`\$\d+|\b\d+(?:\.\d+)?\b`)
+	fingerprintIn     = regexp.MustCompile(`\(\s*\?(?:\s*,\s*\?)*\s*\)`)
+	fingerprintSpace  = regexp.MustCompile(`\s+`)
+)
+
+// Fingerprint normalizes the query by replacing the literals and the placeholders with "?",
+// and the lists of them with "(?+)", so the statements differing only in the values are the same
+func Fingerprint(query string) string {
+	query = fingerprintString.ReplaceAllString(query, "?")
+	query = fingerprintNumber.ReplaceAllString(query, "?")
+	query = fingerprintIn.ReplaceAllString(query, "(?+)")
+	return strings.TrimSpace(fingerprintSpace.ReplaceAllString(query, " "))
+}
+
+func isSelect(stat *orm.QueryStat) bool {
+	if !strings.HasSuffix(stat.Operation, ".Query") && !strings.HasSuffix(stat.Operation, ".QueryRow") {
+		return false
+	}
+	query := strings.TrimSpace(stat.Query)
+	return len(query) > 6 && strings.EqualFold(query[:6], "SELECT")
+}
+
+// explain runs EXPLAIN for the statement on the primary database of the alias
+func explain(stat *orm.QueryStat) (string, error) {
+	var prefix string
+	switch stat.Driver {
+	case orm.DRMySQL, orm.DRTiDB, orm.DRPostgres:
+		prefix = "EXPLAIN "
+	case orm.DRSqlite:
+		prefix = "EXPLAIN QUERY PLAN "
+	default:
+		return "", nil
+	}
+	db, err := orm.GetDB(stat.Alias)
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), explainTimeout)
+	defer cancel()
+	rows, err := db.QueryContext(ctx, prefix+stat.Query, stat.Args...)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return "", err
+	}
+	lines := []string{strings.Join(cols, " | ")}
+	values := make([]sql.NullString, len(cols))
+	refs := make([]interface{}, len(cols))
+	for i := range values {
+		refs[i] = &values[i]
+	}
+	for rows.Next() {
+		if err = rows.Scan(refs...); err != nil {
+			return "", err
+		}
+		line := make([]string, 0, len(cols))
+		for _, v := range values {
+			line = append(line, v.String)
+		}
+		lines = append(lines, strings.Join(line, " | "))
+	}
+	return strings.Join(lines, "\n"), rows.Err()
+}
+
+// caller returns the first frame outside the orm and database/sql
+func caller() string {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if !skipFrame(frame) {
+			return fmt.Sprintf("%s:%d", frame.File, frame.Line)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
+
+func skipFrame(frame runtime.Frame) bool {
+	if strings.HasSuffix(frame.File, "_test.go") {
+		return false
+	}
+	for _, prefix := range []string{"github.com/beego/beego/v2/client/orm", "database/sql.", "reflect.", "runtime."} {
+		if strings.HasPrefix(frame.Function, prefix) {
+			return true
+		}
+	}
+	return false
+}
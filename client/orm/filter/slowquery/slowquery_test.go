@@ -0,0 +1,135 @@
+// Copyright 2023 beego
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slowquery
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/beego/beego/v2/client/orm"
+	"github.com/beego/beego/v2/core/admin"
+)
+
+type SlowUser struct {
+	Id   int
+	Name string `orm:"size(64)"`
+}
+
+func TestFingerprint(t *testing.T) {
+	testCases := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{
+			name:  "placeholders",
+			query: "SELECT T0.`id` FROM `user` T0 WHERE T0.`id` IN (?, ?, ?) AND T0.`age` > ?",
+			want:  "SELECT T0.`id` FROM `user` T0 WHERE T0.`id` IN (?+) AND T0.`age` > ?",
+		},
+		{
+			name:  "literals",
+			query: "SELECT * FROM user\n\tWHERE name = 'it''s' AND age > 18 LIMIT 10",
+			want:  "SELECT * FROM user WHERE name = ? AND age > ? LIMIT ?",
+		},
+		{
+			name:  "postgres",
+			query: `UPDATE "user" SET "name" = $1 WHERE "id" IN ($2, $3)`,
+			want:  `UPDATE "user" SET "name" = ? WHERE "id" IN (?+)`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, Fingerprint(tc.query))
+		})
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	r := NewRecorder(0, WithExplain(true), WithRedactor(RedactArgs), WithLogger(nil))
+	r.Register()
+
+	err := orm.RegisterDataBase("default", "sqlite3", filepath.Join(t.TempDir(), "slowquery.db"))
+	require.Nil(t, err)
+	orm.RegisterModel(new(SlowUser))
+	require.Nil(t, orm.RunSyncdb("default", false, false))
+	r.Reset()
+
+	o := orm.NewOrm()
+	for _, name := range []string{"slene", "astaxie"} {
+		_, err = o.Insert(&SlowUser{Name: name})
+		require.Nil(t, err)
+	}
+	for _, name := range []string{"slene", "astaxie", "nobody"} {
+		var users []*SlowUser
+		_, err = o.QueryTable(new(SlowUser)).Filter("Name", name).All(&users)
+		require.Nil(t, err)
+	}
+
+	stats := r.Top(0)
+	var selects *Stat
+	for i, s := range stats {
+		if strings.HasPrefix(s.Fingerprint, "SELECT") {
+			selects = &stats[i]
+		}
+	}
+	require.NotNil(t, selects)
+	assert.Equal(t, int64(3), selects.Count)
+	assert.Equal(t, "default", selects.Slowest.Alias)
+	assert.Equal(t, []interface{}{"?"}, selects.Slowest.Args)
+	assert.Contains(t, selects.Slowest.Caller, "slowquery_test.go")
+	assert.True(t, selects.Max <= selects.Total)
+	// EXPLAIN runs in another goroutine
+	assert.Eventually(t, func() bool {
+		for _, s := range r.Top(0) {
+			if s.Fingerprint == selects.Fingerprint {
+				return strings.Contains(s.Slowest.Explain, "SCAN")
+			}
+		}
+		return false
+	}, 5*time.Second, 10*time.Millisecond)
+
+	assert.Len(t, r.Top(1), 1)
+
+	res := admin.GetCommand("orm", "slowquery").Execute(1)
+	assert.True(t, res.IsSuccess())
+	assert.Len(t, res.Content.([][]string), 1)
+}
+
+func TestRecorderThreshold(t *testing.T) {
+	r := NewRecorder(time.Second, WithLogger(nil), WithMaxFingerprints(1))
+	r.Observe(&orm.QueryStat{Query: "SELECT 1", Duration: time.Millisecond})
+	assert.Empty(t, r.Top(0))
+
+	r.Observe(&orm.QueryStat{Query: "SELECT 1", Duration: 2 * time.Second})
+	r.Observe(&orm.QueryStat{Query: "SELECT 2", Duration: 3 * time.Second})
+	r.Observe(&orm.QueryStat{Query: "DELETE FROM user", Duration: 2 * time.Second})
+	stats := r.Top(0)
+	require.Len(t, stats, 1)
+	assert.Equal(t, "DELETE FROM user", stats[0].Fingerprint)
+
+	// the statement without duration is not the slowest one
+	r = NewRecorder(0, WithLogger(nil))
+	r.Observe(&orm.QueryStat{Query: "SELECT 1"})
+	stats = r.Top(0)
+	require.Len(t, stats, 1)
+	assert.Nil(t, stats[0].Slowest)
+	assert.True(t, r.Execute().IsSuccess())
+}
@@ -33,8 +33,8 @@ const (
 )
 
 var (
-	_ Ormer   = new(filterOrmDecorator)
-	_ TxOrmer = new(filterOrmDecorator)
+	_ Ormer         = new(filterOrmDecorator)
+	_ NestedTxOrmer = new(filterOrmDecorator)
 )
 
 type filterOrmDecorator struct {
@@ -74,6 +74,10 @@ func NewFilterTxOrmDecorator(delegate TxOrmer, root Filter, txName string) TxOrm
 		txStartTime: time.Now(),
 		txName:      txName,
 	}
+	// the nested transactions are only supported by NestedTxOrmer
+	if b, ok := delegate.(TxBeginner); ok {
+		res.TxBeginner = b
+	}
 	return res
 }
 
@@ -90,6 +94,7 @@ func (f *filterOrmDecorator) ReadWithCtx(ctx context.Context, md interface{}, co
 		mi:          mi,
 		InsideTx:    f.insideTx,
 		TxStartTime: f.txStartTime,
+		executor:    f.ormer,
 		f: func(c context.Context) []interface{} {
 			err := f.ormer.ReadWithCtx(c, md, cols...)
 			return []interface{}{err}
@@ -112,6 +117,7 @@ func (f *filterOrmDecorator) ReadForUpdateWithCtx(ctx context.Context, md interf
 		mi:          mi,
 		InsideTx:    f.insideTx,
 		TxStartTime: f.txStartTime,
+		executor:    f.ormer,
 		f: func(c context.Context) []interface{} {
 			err := f.ormer.ReadForUpdateWithCtx(c, md, cols...)
 			return []interface{}{err}
@@ -134,6 +140,7 @@ func (f *filterOrmDecorator) ReadOrCreateWithCtx(ctx context.Context, md interfa
 		mi:          mi,
 		InsideTx:    f.insideTx,
 		TxStartTime: f.txStartTime,
+		executor:    f.ormer,
 		f: func(c context.Context) []interface{} {
 			ok, res, err := f.ormer.ReadOrCreateWithCtx(c, md, col1, cols...)
 			return []interface{}{ok, res, err}
@@ -156,6 +163,7 @@ func (f *filterOrmDecorator) LoadRelatedWithCtx(ctx context.Context, md interfac
 		mi:          mi,
 		InsideTx:    f.insideTx,
 		TxStartTime: f.txStartTime,
+		executor:    f.ormer,
 		f: func(c context.Context) []interface{} {
 			res, err := f.ormer.LoadRelatedWithCtx(c, md, name, args...)
 			return []interface{}{res, err}
@@ -174,6 +182,7 @@ func (f *filterOrmDecorator) QueryM2M(md interface{}, name string) QueryM2Mer {
 		mi:          mi,
 		InsideTx:    f.insideTx,
 		TxStartTime: f.txStartTime,
+		executor:    f.ormer,
 		f: func(c context.Context) []interface{} {
 			res := f.ormer.QueryM2M(md, name)
 			return []interface{}{res}
@@ -215,10 +224,15 @@ func (f *filterOrmDecorator) QueryTable(ptrStructOrTableName interface{}) QueryS
 		Args:        []interface{}{ptrStructOrTableName},
 		InsideTx:    f.insideTx,
 		TxStartTime: f.txStartTime,
+		executor:    f.ormer,
 		Md:          md,
 		mi:          mi,
 		f: func(c context.Context) []interface{} {
 			res := f.ormer.QueryTable(ptrStructOrTableName)
+			// the bulk update and delete of the QuerySeter go through the filters too
+			if qs, ok := res.(*querySet); ok {
+				qs.decorator = f
+			}
 			return []interface{}{res}
 		},
 	}
@@ -241,6 +255,7 @@ func (f *filterOrmDecorator) DBStats() *sql.DBStats {
 		Method:      "DBStats",
 		InsideTx:    f.insideTx,
 		TxStartTime: f.txStartTime,
+		executor:    f.ormer,
 		f: func(c context.Context) []interface{} {
 			res := f.ormer.DBStats()
 			return []interface{}{res}
@@ -268,6 +283,7 @@ func (f *filterOrmDecorator) InsertWithCtx(ctx context.Context, md interface{})
 		mi:          mi,
 		InsideTx:    f.insideTx,
 		TxStartTime: f.txStartTime,
+		executor:    f.ormer,
 		f: func(c context.Context) []interface{} {
 			res, err := f.ormer.InsertWithCtx(c, md)
 			return []interface{}{res, err}
@@ -290,6 +306,7 @@ func (f *filterOrmDecorator) InsertOrUpdateWithCtx(ctx context.Context, md inter
 		mi:          mi,
 		InsideTx:    f.insideTx,
 		TxStartTime: f.txStartTime,
+		executor:    f.ormer,
 		f: func(c context.Context) []interface{} {
 			res, err := f.ormer.InsertOrUpdateWithCtx(c, md, colConflitAndArgs...)
 			return []interface{}{res, err}
@@ -305,19 +322,7 @@ func (f *filterOrmDecorator) InsertMulti(bulk int, mds interface{}) (int64, erro
 
 // InsertMultiWithCtx uses the first element's model info
 func (f *filterOrmDecorator) InsertMultiWithCtx(ctx context.Context, bulk int, mds interface{}) (int64, error) {
-	var (
-		md interface{}
-		mi *models.ModelInfo
-	)
-
-	sind := reflect.Indirect(reflect.ValueOf(mds))
-
-	if (sind.Kind() == reflect.Array || sind.Kind() == reflect.Slice) && sind.Len() > 0 {
-		ind := reflect.Indirect(sind.Index(0))
-		md = ind.Interface()
-		mi, _ = defaultModelCache.GetByMd(md)
-	}
-
+	md, mi := f.firstModel(mds)
 	inv := &Invocation{
 		Method:      "InsertMultiWithCtx",
 		Args:        []interface{}{bulk, mds},
@@ -325,6 +330,7 @@ func (f *filterOrmDecorator) InsertMultiWithCtx(ctx context.Context, bulk int, m
 		mi:          mi,
 		InsideTx:    f.insideTx,
 		TxStartTime: f.txStartTime,
+		executor:    f.ormer,
 		f: func(c context.Context) []interface{} {
 			res, err := f.ormer.InsertMultiWithCtx(c, bulk, mds)
 			return []interface{}{res, err}
@@ -334,6 +340,41 @@ func (f *filterOrmDecorator) InsertMultiWithCtx(ctx context.Context, bulk int, m
 	return res[0].(int64), f.convertError(res[1])
 }
 
+func (f *filterOrmDecorator) InsertOrUpdateMulti(bulk int, mds interface{}, conflictCols []string, updateCols []string) (int64, error) {
+	return f.InsertOrUpdateMultiWithCtx(context.Background(), bulk, mds, conflictCols, updateCols)
+}
+
+// InsertOrUpdateMultiWithCtx uses the first element's model info
+func (f *filterOrmDecorator) InsertOrUpdateMultiWithCtx(ctx context.Context, bulk int, mds interface{}, conflictCols []string, updateCols []string) (int64, error) {
+	md, mi := f.firstModel(mds)
+	inv := &Invocation{
+		Method:      "InsertOrUpdateMultiWithCtx",
+		Args:        []interface{}{bulk, mds, conflictCols, updateCols},
+		Md:          md,
+		mi:          mi,
+		InsideTx:    f.insideTx,
+		TxStartTime: f.txStartTime,
+		executor:    f.ormer,
+		f: func(c context.Context) []interface{} {
+			res, err := f.ormer.InsertOrUpdateMultiWithCtx(c, bulk, mds, conflictCols, updateCols)
+			return []interface{}{res, err}
+		},
+	}
+	res := f.root(ctx, inv)
+	return res[0].(int64), f.convertError(res[1])
+}
+
+// firstModel return the first element of the models slice and its model info
+func (*filterOrmDecorator) firstModel(mds interface{}) (interface{}, *models.ModelInfo) {
+	sind := reflect.Indirect(reflect.ValueOf(mds))
+	if (sind.Kind() == reflect.Array || sind.Kind() == reflect.Slice) && sind.Len() > 0 {
+		md := reflect.Indirect(sind.Index(0)).Interface()
+		mi, _ := defaultModelCache.GetByMd(md)
+		return md, mi
+	}
+	return nil, nil
+}
+
 func (f *filterOrmDecorator) Update(md interface{}, cols ...string) (int64, error) {
 	return f.UpdateWithCtx(context.Background(), md, cols...)
 }
@@ -347,6 +388,7 @@ func (f *filterOrmDecorator) UpdateWithCtx(ctx context.Context, md interface{},
 		mi:          mi,
 		InsideTx:    f.insideTx,
 		TxStartTime: f.txStartTime,
+		executor:    f.ormer,
 		f: func(c context.Context) []interface{} {
 			res, err := f.ormer.UpdateWithCtx(c, md, cols...)
 			return []interface{}{res, err}
@@ -356,6 +398,30 @@ func (f *filterOrmDecorator) UpdateWithCtx(ctx context.Context, md interface{},
 	return res[0].(int64), f.convertError(res[1])
 }
 
+func (f *filterOrmDecorator) UpdateMulti(bulk int, mds interface{}, cols ...string) (int64, error) {
+	return f.UpdateMultiWithCtx(context.Background(), bulk, mds, cols...)
+}
+
+// UpdateMultiWithCtx uses the first element's model info
+func (f *filterOrmDecorator) UpdateMultiWithCtx(ctx context.Context, bulk int, mds interface{}, cols ...string) (int64, error) {
+	md, mi := f.firstModel(mds)
+	inv := &Invocation{
+		Method:      "UpdateMultiWithCtx",
+		Args:        []interface{}{bulk, mds, cols},
+		Md:          md,
+		mi:          mi,
+		InsideTx:    f.insideTx,
+		TxStartTime: f.txStartTime,
+		executor:    f.ormer,
+		f: func(c context.Context) []interface{} {
+			res, err := f.ormer.UpdateMultiWithCtx(c, bulk, mds, cols...)
+			return []interface{}{res, err}
+		},
+	}
+	res := f.root(ctx, inv)
+	return res[0].(int64), f.convertError(res[1])
+}
+
 func (f *filterOrmDecorator) Delete(md interface{}, cols ...string) (int64, error) {
 	return f.DeleteWithCtx(context.Background(), md, cols...)
 }
@@ -369,6 +435,7 @@ func (f *filterOrmDecorator) DeleteWithCtx(ctx context.Context, md interface{},
 		mi:          mi,
 		InsideTx:    f.insideTx,
 		TxStartTime: f.txStartTime,
+		executor:    f.ormer,
 		f: func(c context.Context) []interface{} {
 			res, err := f.ormer.DeleteWithCtx(c, md, cols...)
 			return []interface{}{res, err}
@@ -378,6 +445,45 @@ func (f *filterOrmDecorator) DeleteWithCtx(ctx context.Context, md interface{},
 	return res[0].(int64), f.convertError(res[1])
 }
 
+// querySetUpdate runs QuerySeter.Update through the filters,
+// the QuerySeter is the first arg of the invocation, which does not go through the filters again.
+func (f *filterOrmDecorator) querySetUpdate(ctx context.Context, qs querySet, values Params) (int64, error) {
+	qs.decorator = nil
+	inv := &Invocation{
+		Method:      "QuerySeterUpdateWithCtx",
+		Args:        []interface{}{&qs, values},
+		mi:          qs.mi,
+		InsideTx:    f.insideTx,
+		TxStartTime: f.txStartTime,
+		executor:    f.ormer,
+		f: func(c context.Context) []interface{} {
+			res, err := qs.UpdateWithCtx(c, values)
+			return []interface{}{res, err}
+		},
+	}
+	res := f.root(ctx, inv)
+	return res[0].(int64), f.convertError(res[1])
+}
+
+// querySetDelete runs QuerySeter.Delete through the filters, see querySetUpdate
+func (f *filterOrmDecorator) querySetDelete(ctx context.Context, qs querySet) (int64, error) {
+	qs.decorator = nil
+	inv := &Invocation{
+		Method:      "QuerySeterDeleteWithCtx",
+		Args:        []interface{}{&qs},
+		mi:          qs.mi,
+		InsideTx:    f.insideTx,
+		TxStartTime: f.txStartTime,
+		executor:    f.ormer,
+		f: func(c context.Context) []interface{} {
+			res, err := qs.DeleteWithCtx(c)
+			return []interface{}{res, err}
+		},
+	}
+	res := f.root(ctx, inv)
+	return res[0].(int64), f.convertError(res[1])
+}
+
 func (f *filterOrmDecorator) Raw(query string, args ...interface{}) RawSeter {
 	return f.RawWithCtx(context.Background(), query, args...)
 }
@@ -388,6 +494,7 @@ func (f *filterOrmDecorator) RawWithCtx(ctx context.Context, query string, args
 		Args:        []interface{}{query, args},
 		InsideTx:    f.insideTx,
 		TxStartTime: f.txStartTime,
+		executor:    f.ormer,
 		f: func(c context.Context) []interface{} {
 			res := f.ormer.RawWithCtx(c, query, args...)
 			return []interface{}{res}
@@ -406,6 +513,7 @@ func (f *filterOrmDecorator) Driver() Driver {
 		Method:      "Driver",
 		InsideTx:    f.insideTx,
 		TxStartTime: f.txStartTime,
+		executor:    f.ormer,
 		f: func(c context.Context) []interface{} {
 			res := f.ormer.Driver()
 			return []interface{}{res}
@@ -431,11 +539,15 @@ func (f *filterOrmDecorator) BeginWithOpts(opts *sql.TxOptions) (TxOrmer, error)
 }
 
 func (f *filterOrmDecorator) BeginWithCtxAndOpts(ctx context.Context, opts *sql.TxOptions) (TxOrmer, error) {
+	if f.TxBeginner == nil {
+		return nil, ErrNotImplement
+	}
 	inv := &Invocation{
 		Method:      "BeginWithCtxAndOpts",
 		Args:        []interface{}{opts},
 		InsideTx:    f.insideTx,
 		TxStartTime: f.txStartTime,
+		executor:    f.ormer,
 		f: func(c context.Context) []interface{} {
 			res, err := f.TxBeginner.BeginWithCtxAndOpts(c, opts)
 			res = NewFilterTxOrmDecorator(res, f.root, getTxNameFromCtx(c))
@@ -464,6 +576,7 @@ func (f *filterOrmDecorator) DoTxWithCtxAndOpts(ctx context.Context, opts *sql.T
 		Args:        []interface{}{opts, task},
 		InsideTx:    f.insideTx,
 		TxStartTime: f.txStartTime,
+		executor:    f.ormer,
 		TxName:      getTxNameFromCtx(ctx),
 		f: func(c context.Context) []interface{} {
 			err := doTxTemplate(c, f, opts, task)
@@ -480,6 +593,7 @@ func (f *filterOrmDecorator) Commit() error {
 		Args:        []interface{}{},
 		InsideTx:    f.insideTx,
 		TxStartTime: f.txStartTime,
+		executor:    f.ormer,
 		TxName:      f.txName,
 		f: func(c context.Context) []interface{} {
 			err := f.TxCommitter.Commit()
@@ -496,6 +610,7 @@ func (f *filterOrmDecorator) Rollback() error {
 		Args:        []interface{}{},
 		InsideTx:    f.insideTx,
 		TxStartTime: f.txStartTime,
+		executor:    f.ormer,
 		TxName:      f.txName,
 		f: func(c context.Context) []interface{} {
 			err := f.TxCommitter.Rollback()
@@ -512,6 +627,7 @@ func (f *filterOrmDecorator) RollbackUnlessCommit() error {
 		Args:        []interface{}{},
 		InsideTx:    f.insideTx,
 		TxStartTime: f.txStartTime,
+		executor:    f.ormer,
 		TxName:      f.txName,
 		f: func(c context.Context) []interface{} {
 			err := f.TxCommitter.RollbackUnlessCommit()
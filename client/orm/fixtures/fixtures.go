@@ -0,0 +1,489 @@
+// Copyright 2023 beego. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package fixtures loads the rows of the registered models from the YAML or JSON files for the tests.
+//
+// The files are keyed by the model name or the table name, and then by the labels of the rows.
+// The fields are the field names or the column names, the rel(fk) and rel(one) fields refer to the rows
+// by their labels or primary keys, and the rel(m2m) fields are the lists of them.
+//
+//	user:
+//	  slene:
+//	    Name: slene
+//	post:
+//	  hello:
+//	    Title: hello
+//	    User: slene
+//	    Tags: [go, orm]
+//
+// The tables are loaded in the order of their relations, so the referenced rows are inserted first.
+//
+//	f, err := fixtures.New("testdata/users.yml", "testdata/posts.yml")
+//	o := f.SetupTx(t, "default") // rolled back at the end of the test
+//	post := fixtures.Lookup[Post](f, "hello")
+package fixtures
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/beego/beego/v2/client/orm"
+	"github.com/beego/beego/v2/client/orm/internal/models"
+)
+
+// Fixtures are the rows parsed from the files, and the models loaded by Load
+type Fixtures struct {
+	// the tables in the order of loading
+	tables []*table
+	// model full name -> label -> the pointer of the loaded model
+	loaded map[string]map[string]reflect.Value
+}
+
+type table struct {
+	mi   *models.ModelInfo
+	rows []*row
+}
+
+type row struct {
+	label  string
+	fields []field
+}
+
+type field struct {
+	name  string
+	value interface{}
+}
+
+// New parses the fixture files after the models are registered.
+// The files are YAML, and JSON is accepted as YAML too.
+func New(files ...string) (*Fixtures, error) {
+	orm.BootStrap()
+
+	f := &Fixtures{}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if err = f.parse(file, data); err != nil {
+			return nil, err
+		}
+	}
+	f.sortTables()
+	return f, nil
+}
+
+// parse adds the rows of the file, the rows of the same model in different files are merged
+func (f *Fixtures) parse(file string, data []byte) error {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("fixtures %s: %w", file, err)
+	}
+	if len(doc.Content) == 0 {
+		return nil
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return fmt.Errorf("fixtures %s: the rows should be keyed by the model or table name", file)
+	}
+
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		name, labels := root.Content[i].Value, root.Content[i+1]
+		mi, ok := orm.LookupModel(name)
+		if !ok {
+			return fmt.Errorf("fixtures %s: unknown model or table `%s`", file, name)
+		}
+		if labels.Kind != yaml.MappingNode {
+			return fmt.Errorf("fixtures %s: the rows of `%s` should be keyed by the labels", file, name)
+		}
+		t := f.table(mi)
+		for j := 0; j+1 < len(labels.Content); j += 2 {
+			label, values := labels.Content[j].Value, labels.Content[j+1]
+			if t.row(label) != nil {
+				return fmt.Errorf("fixtures %s: duplicate label `%s` of `%s`", file, label, name)
+			}
+			if values.Kind != yaml.MappingNode {
+				return fmt.Errorf("fixtures %s: the row `%s` of `%s` should be the fields", file, label, name)
+			}
+			r := &row{label: label}
+			for k := 0; k+1 < len(values.Content); k += 2 {
+				var value interface{}
+				node := values.Content[k+1]
+				if node.Kind == yaml.ScalarNode && node.ShortTag() == "!!timestamp" {
+					// the time without the zone is in the local time zone, instead of UTC of yaml
+					value = node.Value
+				} else if err := node.Decode(&value); err != nil {
+					return fmt.Errorf("fixtures %s: %w", file, err)
+				}
+				r.fields = append(r.fields, field{name: values.Content[k].Value, value: value})
+			}
+			t.rows = append(t.rows, r)
+		}
+	}
+	return nil
+}
+
+func (f *Fixtures) table(mi *models.ModelInfo) *table {
+	for _, t := range f.tables {
+		if t.mi == mi {
+			return t
+		}
+	}
+	t := &table{mi: mi}
+	f.tables = append(f.tables, t)
+	return t
+}
+
+func (t *table) row(label string) *row {
+	for _, r := range t.rows {
+		if r.label == label {
+			return r
+		}
+	}
+	return nil
+}
+
+// sortTables moves the tables referred by the rel(fk) and rel(one) fields before the tables referring to them
+func (f *Fixtures) sortTables() {
+	byModel := make(map[*models.ModelInfo]*table, len(f.tables))
+	for _, t := range f.tables {
+		byModel[t.mi] = t
+	}
+	visited := make(map[*table]bool, len(f.tables))
+	sorted := make([]*table, 0, len(f.tables))
+	var visit func(t *table)
+	visit = func(t *table) {
+		visited[t] = true
+		for _, fi := range t.mi.Fields.FieldsByType[models.RelForeignKey] {
+			if ref, ok := byModel[fi.RelModelInfo]; ok && !visited[ref] {
+				visit(ref)
+			}
+		}
+		for _, fi := range t.mi.Fields.FieldsByType[models.RelOneToOne] {
+			if ref, ok := byModel[fi.RelModelInfo]; ok && !visited[ref] {
+				visit(ref)
+			}
+		}
+		sorted = append(sorted, t)
+	}
+	for _, t := range f.tables {
+		if !visited[t] {
+			visit(t)
+		}
+	}
+	f.tables = sorted
+}
+
+// m2m is the rel(m2m) field added after all the rows are inserted
+type m2m struct {
+	md     interface{}
+	fi     *models.FieldInfo
+	labels interface{}
+}
+
+// Load inserts the rows by o, the models of the previous Load are replaced
+func (f *Fixtures) Load(ctx context.Context, o orm.QueryExecutor) error {
+	f.loaded = make(map[string]map[string]reflect.Value, len(f.tables))
+	var m2ms []m2m
+	for _, t := range f.tables {
+		loaded := make(map[string]reflect.Value, len(t.rows))
+		f.loaded[t.mi.FullName] = loaded
+		for _, r := range t.rows {
+			md := reflect.New(t.mi.AddrField.Elem().Type())
+			ind := md.Elem()
+			for _, fd := range r.fields {
+				fi, ok := t.mi.Fields.GetByAny(fd.name)
+				if !ok {
+					return fmt.Errorf("fixtures: unknown field `%s` of `%s`", fd.name, t.mi.Name)
+				}
+				var err error
+				switch {
+				case fi.FieldType == models.RelManyToMany:
+					m2ms = append(m2ms, m2m{md: md.Interface(), fi: fi, labels: fd.value})
+				case fi.FieldType == models.RelForeignKey || fi.FieldType == models.RelOneToOne:
+					var rel reflect.Value
+					if rel, err = f.related(fi.RelModelInfo, fd.value); err == nil {
+						ind.FieldByIndex(fi.FieldIndex).Set(rel)
+					}
+				case fi.Reverse:
+					err = fmt.Errorf("the reverse field is not supported")
+				default:
+					err = setValue(ind.FieldByIndex(fi.FieldIndex), fd.value)
+				}
+				if err != nil {
+					return fmt.Errorf("fixtures: %s `%s` field `%s`: %w", t.mi.Name, r.label, fd.name, err)
+				}
+			}
+			if _, err := o.InsertWithCtx(ctx, md.Interface()); err != nil {
+				return fmt.Errorf("fixtures: insert %s `%s`: %w", t.mi.Name, r.label, err)
+			}
+			loaded[r.label] = md
+		}
+	}
+
+	for _, m := range m2ms {
+		values, ok := m.labels.([]interface{})
+		if !ok {
+			values = []interface{}{m.labels}
+		}
+		rels := make([]interface{}, 0, len(values))
+		for _, v := range values {
+			rel, err := f.related(m.fi.RelModelInfo, v)
+			if err != nil {
+				return fmt.Errorf("fixtures: %s field `%s`: %w", m.fi.Mi.Name, m.fi.Name, err)
+			}
+			rels = append(rels, rel.Interface())
+		}
+		if _, err := o.QueryM2M(m.md, m.fi.Name).AddWithCtx(ctx, rels...); err != nil {
+			return fmt.Errorf("fixtures: %s field `%s`: %w", m.fi.Mi.Name, m.fi.Name, err)
+		}
+	}
+	return nil
+}
+
+// related returns the pointer of the loaded model by the label,
+// or the new model with the primary key if the value isn't a label.
+func (f *Fixtures) related(mi *models.ModelInfo, value interface{}) (reflect.Value, error) {
+	if label, ok := value.(string); ok {
+		if md, ok := f.loaded[mi.FullName][label]; ok {
+			return md, nil
+		}
+	}
+	md := reflect.New(mi.AddrField.Elem().Type())
+	pk := mi.Fields.Pk
+	if pk == nil {
+		return md, fmt.Errorf("unknown label `%v` of `%s`", value, mi.Name)
+	}
+	if err := setValue(md.Elem().FieldByIndex(pk.FieldIndex), value); err != nil {
+		return md, fmt.Errorf("unknown label or invalid primary key `%v` of `%s`", value, mi.Name)
+	}
+	return md, nil
+}
+
+// the layouts of the time values in the strings
+var timeLayouts = []string{
+	time.RFC3339Nano, "2006-01-02T15:04:05.999999999", "2006-01-02 15:04:05.999999999", "2006-01-02", "15:04:05",
+}
+
+// setValue sets the value decoded from the file to the field
+func setValue(fv reflect.Value, value interface{}) error {
+	if value == nil {
+		fv.Set(reflect.Zero(fv.Type()))
+		return nil
+	}
+	if fielder, ok := fv.Addr().Interface().(orm.Fielder); ok {
+		return fielder.SetRaw(value)
+	}
+	if fv.Kind() == reflect.Ptr {
+		p := reflect.New(fv.Type().Elem())
+		if err := setValue(p.Elem(), value); err != nil {
+			return err
+		}
+		fv.Set(p)
+		return nil
+	}
+
+	if fv.Type() == reflect.TypeOf(time.Time{}) {
+		switch v := value.(type) {
+		case time.Time:
+			fv.Set(reflect.ValueOf(v))
+			return nil
+		case string:
+			for _, layout := range timeLayouts {
+				if t, err := time.ParseInLocation(layout, v, time.Local); err == nil {
+					fv.Set(reflect.ValueOf(t))
+					return nil
+				}
+			}
+		}
+		return fmt.Errorf("invalid time `%v`", value)
+	}
+
+	str := fmt.Sprint(value)
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(str)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(str)
+		if err != nil {
+			return err
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(str, 10, 64)
+		if err != nil || fv.OverflowInt(n) {
+			return fmt.Errorf("invalid integer `%v`", value)
+		}
+		fv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(str, 10, 64)
+		if err != nil || fv.OverflowUint(n) {
+			return fmt.Errorf("invalid unsigned integer `%v`", value)
+		}
+		fv.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(str, 64)
+		if err != nil {
+			return err
+		}
+		fv.SetFloat(n)
+	default:
+		// the structs, maps and slices of the json fields
+		data, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(data, fv.Addr().Interface())
+	}
+	return nil
+}
+
+// Get returns the pointer of the model loaded by the label, name is the model name or the table name.
+// It returns nil if the label isn't loaded.
+func (f *Fixtures) Get(name string, label string) interface{} {
+	mi, ok := orm.LookupModel(name)
+	if !ok {
+		return nil
+	}
+	if md, ok := f.loaded[mi.FullName][label]; ok {
+		return md.Interface()
+	}
+	return nil
+}
+
+// Lookup returns the model T loaded by the label, or nil if the label isn't loaded
+func Lookup[T any](f *Fixtures, label string) *T {
+	mi, ok := orm.LookupModel(new(T))
+	if !ok {
+		return nil
+	}
+	if md, ok := f.loaded[mi.FullName][label]; ok {
+		return md.Interface().(*T)
+	}
+	return nil
+}
+
+// Truncate deletes the rows of the tables of the fixtures and the m2m tables of them,
+// and resets the auto increment sequences.
+// The postgres tables are truncated by TRUNCATE ... RESTART IDENTITY CASCADE,
+// so the rows of the other tables referring to them are deleted too.
+func (f *Fixtures) Truncate(ctx context.Context, o orm.QueryExecutor) error {
+	var names []string
+	seen := make(map[string]bool)
+	add := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	// the tables referring to the others are deleted first,
+	// the tables have the prefix of the tenant resolved from ctx, and the sharded models have all their shards
+	alias := o.Driver().Name()
+	for i := len(f.tables) - 1; i >= 0; i-- {
+		for _, fi := range f.tables[i].mi.Fields.FieldsByType[models.RelManyToMany] {
+			for _, name := range orm.ModelTables(ctx, alias, fi.RelThroughModelInfo) {
+				add(name)
+			}
+		}
+		for _, name := range orm.ModelTables(ctx, alias, f.tables[i].mi) {
+			add(name)
+		}
+	}
+
+	exec := func(query string, args ...interface{}) error {
+		_, err := o.RawWithCtx(ctx, query, args...).Exec()
+		return err
+	}
+	switch o.Driver().Type() {
+	case orm.DRPostgres:
+		return exec(fmt.Sprintf(`TRUNCATE TABLE "%s" RESTART IDENTITY CASCADE`, strings.Join(names, `", "`)))
+	case orm.DRMySQL, orm.DRTiDB:
+		for _, name := range names {
+			if err := exec(fmt.Sprintf("DELETE FROM `%s`", name)); err != nil {
+				return err
+			}
+			if err := exec(fmt.Sprintf("ALTER TABLE `%s` AUTO_INCREMENT = 1", name)); err != nil {
+				return err
+			}
+		}
+	case orm.DRSqlite:
+		for _, name := range names {
+			if err := exec(fmt.Sprintf("DELETE FROM `%s`", name)); err != nil {
+				return err
+			}
+		}
+		// sqlite_sequence only exists after a table with AUTOINCREMENT is created
+		var n int
+		if o.RawWithCtx(ctx, "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'sqlite_sequence'").QueryRow(&n) == nil && n > 0 {
+			for _, name := range names {
+				if err := exec("DELETE FROM sqlite_sequence WHERE name = ?", name); err != nil {
+					return err
+				}
+			}
+		}
+	default:
+		for _, name := range names {
+			if err := exec(fmt.Sprintf(`DELETE FROM "%s"`, name)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Setup truncates the tables of the fixtures and loads them into the database alias for the test,
+// the test fails if any of them fails.
+func (f *Fixtures) Setup(t testing.TB, alias string) orm.Ormer {
+	t.Helper()
+	ctx := context.Background()
+	o := orm.NewOrmUsingDB(alias)
+	if err := f.Truncate(ctx, o); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Load(ctx, o); err != nil {
+		t.Fatal(err)
+	}
+	return o
+}
+
+// SetupTx truncates the tables of the fixtures and loads them in a transaction of the database alias,
+// which is rolled back when the test completes, so the changes of the test by it are discarded too.
+func (f *Fixtures) SetupTx(t testing.TB, alias string) orm.TxOrmer {
+	t.Helper()
+	ctx := context.Background()
+	o := orm.NewOrmUsingDB(alias)
+	if err := f.Truncate(ctx, o); err != nil {
+		t.Fatal(err)
+	}
+	tx, err := o.BeginWithCtx(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = tx.Rollback()
+	})
+	if err = f.Load(ctx, tx); err != nil {
+		t.Fatal(err)
+	}
+	return tx
+}
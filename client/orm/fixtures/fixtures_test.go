@@ -0,0 +1,131 @@
+// Copyright 2023 beego. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fixtures
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+type FixtureUser struct {
+	Id      int
+	Name    string `orm:"size(64)"`
+	Created time.Time
+}
+
+type FixtureTag struct {
+	Id   int
+	Name string `orm:"size(64)"`
+}
+
+type FixturePost struct {
+	Id    int
+	Title string        `orm:"size(64)"`
+	User  *FixtureUser  `orm:"rel(fk)"`
+	Tags  []*FixtureTag `orm:"rel(m2m)"`
+}
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "fixtures")
+	if err != nil {
+		panic(err)
+	}
+	if err = orm.RegisterDataBase("default", "sqlite3", filepath.Join(dir, "fixtures.db")); err != nil {
+		panic(err)
+	}
+	orm.RegisterModel(new(FixtureUser), new(FixtureTag), new(FixturePost))
+	if err = orm.RunSyncdb("default", false, false); err != nil {
+		panic(err)
+	}
+	code := m.Run()
+	// the directory is removed before os.Exit, which does not run the deferred functions
+	_ = os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func TestFixtures(t *testing.T) {
+	f, err := New("testdata/posts.json", "testdata/users.yml")
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		o := f.Setup(t, "default")
+
+		// the sequences are reset, so the ids are the same every time
+		hello := Lookup[FixturePost](f, "hello")
+		require.NotNil(t, hello)
+		assert.Equal(t, 1, hello.Id)
+		assert.Equal(t, Lookup[FixtureUser](f, "slene"), hello.User)
+		assert.Equal(t, time.Date(2023, 1, 2, 3, 4, 5, 0, time.Local), hello.User.Created)
+		assert.Equal(t, Lookup[FixtureUser](f, "astaxie"), f.Get("fixture_user", "astaxie"))
+		assert.Nil(t, f.Get("FixtureUser", "nobody"))
+
+		world := &FixturePost{Id: Lookup[FixturePost](f, "world").Id}
+		require.NoError(t, o.Read(world))
+		require.NoError(t, o.Read(world.User))
+		assert.Equal(t, "astaxie", world.User.Name)
+
+		num, err := o.QueryTable(new(FixturePost)).Filter("Tags__FixtureTag__Name", "orm").Count()
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), num)
+		num, err = o.QueryTable(new(FixtureUser)).Count()
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), num)
+	}
+
+	t.Run("tx", func(t *testing.T) {
+		tx := f.SetupTx(t, "default")
+		_, err := tx.Insert(&FixtureUser{Name: "nobody", Created: time.Now()})
+		require.NoError(t, err)
+		num, err := tx.QueryTable(new(FixtureUser)).Count()
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), num)
+	})
+	// the fixtures and the changes of the test are rolled back
+	num, err := orm.NewOrm().QueryTable(new(FixtureUser)).Count()
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), num)
+}
+
+func TestFixturesError(t *testing.T) {
+	dir := t.TempDir()
+	write := func(content string) string {
+		path := filepath.Join(dir, "fixtures.yml")
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+		return path
+	}
+
+	_, err := New(write("unknown:\n  a:\n    Name: a\n"))
+	assert.ErrorContains(t, err, "unknown model or table `unknown`")
+	_, err = New(write("FixtureTag:\n  a:\n    Name: a\n  a:\n    Name: b\n"))
+	assert.ErrorContains(t, err, "duplicate label `a`")
+
+	f, err := New(write("FixturePost:\n  a:\n    Title: a\n    User: nobody\n"))
+	require.NoError(t, err)
+	o := orm.NewOrm()
+	require.NoError(t, f.Truncate(t.Context(), o))
+	assert.ErrorContains(t, f.Load(t.Context(), o), "unknown label or invalid primary key `nobody`")
+
+	f, err = New(write("FixtureTag:\n  a:\n    Color: red\n"))
+	require.NoError(t, err)
+	assert.ErrorContains(t, f.Load(t.Context(), o), "unknown field `Color`")
+}
@@ -15,6 +15,8 @@
 package hints
 
 import (
+	"time"
+
 	"github.com/beego/beego/v2/core/utils"
 )
 
@@ -28,6 +30,7 @@ const (
 	KeyOffset
 	KeyOrderBy
 	KeyRelDepth
+	KeyCache
 )
 
 type Hint struct {
@@ -94,6 +97,11 @@ func OrderBy(s string) *Hint {
 	return NewHint(KeyOrderBy, s)
 }
 
+// Cache return a hint about caching the result for ttl
+func Cache(ttl time.Duration) *Hint {
+	return NewHint(KeyCache, ttl)
+}
+
 // NewHint return a hint
 func NewHint(key interface{}, value interface{}) *Hint {
 	return &Hint{
@@ -125,3 +125,9 @@ func TestOrderBy(t *testing.T) {
 	assert.Equal(t, hint.GetValue(), `-ID`)
 	assert.Equal(t, hint.GetKey(), KeyOrderBy)
 }
+
+func TestCache(t *testing.T) {
+	hint := Cache(time.Minute)
+	assert.Equal(t, hint.GetValue(), time.Minute)
+	assert.Equal(t, hint.GetKey(), KeyCache)
+}
@@ -129,6 +129,10 @@ func (mc *ModelCache) bootstrap() {
 					goto end
 				}
 				fi.RelModelInfo = mii
+				if fi.Rel && mii.Fields.CompositePk != nil {
+					err = fmt.Errorf("field `%s` can not refer to model `%s` with composite primary key", fi.FullName, mii.FullName)
+					goto end
+				}
 
 				switch fi.FieldType {
 				case RelManyToMany:
@@ -352,7 +356,14 @@ func (mc *ModelCache) Register(prefixOrSuffixStr string, prefixOrSuffix bool, mo
 		}
 
 		mi := NewModelInfo(val)
-		if mi.Fields.Pk == nil {
+		mi.TrackChanges = GetTableTrackChanges(val)
+		if names := GetTablePrimaryKey(val); len(names) > 0 {
+			if err = mi.SetPrimaryKey(names); err != nil {
+				err = fmt.Errorf("<orm.RegisterModel> model `%s` %s", name, err)
+				return
+			}
+		}
+		if mi.Fields.Pk == nil && mi.Fields.CompositePk == nil {
 		outFor:
 			for _, fi := range mi.Fields.FieldsDB {
 				if strings.ToLower(fi.Name) == "id" {
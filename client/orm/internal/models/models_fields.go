@@ -15,8 +15,13 @@
 package models
 
 import (
+	"encoding/hex"
 	"fmt"
+	"math"
+	"net/netip"
+	"regexp"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/beego/beego/v2/client/orm/internal/utils"
@@ -783,3 +788,523 @@ func (j *JsonbField) RawValue() interface{} {
 
 // verify JsonbField implement Fielder
 var _ Fielder = new(JsonbField)
+
+// DecimalField An exact decimal number, represented in go by its string such as "12.30".
+// required values tag: digits and decimals, eg: `orm:"digits(12);decimals(4)"`
+// The value is never converted to float64, so no precision is lost, and the empty value is NULL.
+type DecimalField string
+
+// decimalPattern matches the decimal strings such as "-12.30", ".5" and "1e-3", but not NaN and Infinity
+var decimalPattern = regexp.MustCompile(`^[+-]?(\d+(\.\d*)?|\.\d+)([eE][+-]?\d+)?$`)
+
+// Value return the DecimalField value
+func (e DecimalField) Value() string {
+	return string(e)
+}
+
+// Set the DecimalField value
+func (e *DecimalField) Set(d string) {
+	*e = DecimalField(d)
+}
+
+// String return the decimal string, the zero value is "0"
+func (e *DecimalField) String() string {
+	if *e == "" {
+		return "0"
+	}
+	return e.Value()
+}
+
+// FieldType return the enum type
+func (e *DecimalField) FieldType() int {
+	return TypeDecimalField
+}
+
+// SetRaw convert the decimal string, []byte, integer or float to DecimalField
+func (e *DecimalField) SetRaw(value interface{}) error {
+	switch d := value.(type) {
+	case nil:
+		e.Set("")
+	case string:
+		d = strings.TrimSpace(d)
+		if !decimalPattern.MatchString(d) {
+			return fmt.Errorf("<DecimalField.SetRaw> wrong decimal value `%s`", d)
+		}
+		e.Set(d)
+	case []byte:
+		return e.SetRaw(string(d))
+	case int64:
+		e.Set(strconv.FormatInt(d, 10))
+	case int:
+		e.Set(strconv.Itoa(d))
+	case float64:
+		if math.IsNaN(d) || math.IsInf(d, 0) {
+			return fmt.Errorf("<DecimalField.SetRaw> wrong decimal value `%v`", d)
+		}
+		e.Set(strconv.FormatFloat(d, 'f', -1, 64))
+	case float32:
+		if math.IsNaN(float64(d)) || math.IsInf(float64(d), 0) {
+			return fmt.Errorf("<DecimalField.SetRaw> wrong decimal value `%v`", d)
+		}
+		e.Set(strconv.FormatFloat(float64(d), 'f', -1, 32))
+	default:
+		return fmt.Errorf("<DecimalField.SetRaw> unknown value `%v`", value)
+	}
+	return nil
+}
+
+// RawValue return the decimal string, or nil if it is empty
+func (e *DecimalField) RawValue() interface{} {
+	if *e == "" {
+		return nil
+	}
+	return e.Value()
+}
+
+// verify DecimalField implement Fielder
+var _ Fielder = new(DecimalField)
+
+// UUIDField A UUID, uuid column of postgres and char(36) of the others.
+type UUIDField [16]byte
+
+// Value return the bytes of the UUID
+func (e UUIDField) Value() [16]byte {
+	return e
+}
+
+// Set the UUIDField value
+func (e *UUIDField) Set(d [16]byte) {
+	*e = d
+}
+
+// String return the canonical form of the UUID, such as 6ba7b810-9dad-11d1-80b4-00c04fd430c8
+func (e *UUIDField) String() string {
+	buf := make([]byte, 36)
+	hex.Encode(buf, e[:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], e[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], e[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], e[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], e[10:])
+	return string(buf)
+}
+
+// FieldType return the enum type
+func (e *UUIDField) FieldType() int {
+	return TypeCharField
+}
+
+// SetRaw convert the UUID string, 16 bytes or [16]byte to UUIDField,
+// the string can be with or without hyphens, braces and the urn:uuid: prefix.
+func (e *UUIDField) SetRaw(value interface{}) error {
+	switch d := value.(type) {
+	case nil:
+		e.Set([16]byte{})
+	case [16]byte:
+		e.Set(d)
+	case UUIDField:
+		*e = d
+	case []byte:
+		if len(d) == 16 {
+			copy(e[:], d)
+			return nil
+		}
+		return e.SetRaw(string(d))
+	case string:
+		s := strings.TrimPrefix(strings.ToLower(strings.TrimSpace(d)), "urn:uuid:")
+		s = strings.ReplaceAll(strings.Trim(s, "{}"), "-", "")
+		var u [16]byte
+		if len(s) != 32 {
+			return fmt.Errorf("<UUIDField.SetRaw> wrong uuid value `%s`", d)
+		}
+		if _, err := hex.Decode(u[:], []byte(s)); err != nil {
+			return fmt.Errorf("<UUIDField.SetRaw> wrong uuid value `%s`", d)
+		}
+		e.Set(u)
+	default:
+		return fmt.Errorf("<UUIDField.SetRaw> unknown value `%v`", value)
+	}
+	return nil
+}
+
+// RawValue return the canonical string of the UUID
+func (e *UUIDField) RawValue() interface{} {
+	return e.String()
+}
+
+// verify UUIDField implement Fielder
+var _ Fielder = new(UUIDField)
+
+// IPAddrField An IPv4 or IPv6 address represented in go by a netip.Addr,
+// inet column of postgres and varchar(45) of the others.
+type IPAddrField netip.Addr
+
+// Value return the netip.Addr value
+func (e IPAddrField) Value() netip.Addr {
+	return netip.Addr(e)
+}
+
+// Set the IPAddrField value
+func (e *IPAddrField) Set(d netip.Addr) {
+	*e = IPAddrField(d)
+}
+
+// String return the address string, it is empty if the address is invalid
+func (e *IPAddrField) String() string {
+	if !e.Value().IsValid() {
+		return ""
+	}
+	return e.Value().String()
+}
+
+// FieldType return the enum type
+func (e *IPAddrField) FieldType() int {
+	return TypeVarCharField
+}
+
+// SetRaw convert the netip.Addr or the address string to IPAddrField,
+// the mask of the string such as 10.0.0.1/24 returned by the inet column is ignored.
+func (e *IPAddrField) SetRaw(value interface{}) error {
+	switch d := value.(type) {
+	case nil:
+		e.Set(netip.Addr{})
+	case netip.Addr:
+		e.Set(d)
+	case []byte:
+		return e.SetRaw(string(d))
+	case string:
+		s, _, _ := strings.Cut(strings.TrimSpace(d), "/")
+		if s == "" {
+			e.Set(netip.Addr{})
+			return nil
+		}
+		v, err := netip.ParseAddr(s)
+		if err != nil {
+			return fmt.Errorf("<IPAddrField.SetRaw> %w", err)
+		}
+		e.Set(v)
+	default:
+		return fmt.Errorf("<IPAddrField.SetRaw> unknown value `%v`", value)
+	}
+	return nil
+}
+
+// RawValue return the address string, or nil if the address is invalid
+func (e *IPAddrField) RawValue() interface{} {
+	if !e.Value().IsValid() {
+		return nil
+	}
+	return e.String()
+}
+
+// verify IPAddrField implement Fielder
+var _ Fielder = new(IPAddrField)
+
+// the layouts of the times with zone returned by the databases
+var timeTZLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999Z07",
+	"2006-01-02 15:04:05.999999999 -0700 MST",
+}
+
+// DateTimeTZField A time with zone represented in go by a time.Time instance,
+// "timestamp with time zone" column of postgres, which keeps the instant of the time,
+// and the RFC 3339 text of the others, which keeps the offset of the time too.
+// Takes the same extra arguments as DateTimeField.
+type DateTimeTZField time.Time
+
+// Value return the time.Time value
+func (e DateTimeTZField) Value() time.Time {
+	return time.Time(e)
+}
+
+// Set the DateTimeTZField value
+func (e *DateTimeTZField) Set(d time.Time) {
+	*e = DateTimeTZField(d)
+}
+
+// String return the time in RFC 3339 format
+func (e *DateTimeTZField) String() string {
+	return e.Value().Format(time.RFC3339Nano)
+}
+
+// FieldType return the enum type
+func (e *DateTimeTZField) FieldType() int {
+	return TypeDateTimeField
+}
+
+// SetRaw convert the time.Time or the time string with zone to DateTimeTZField,
+// the string without zone is in the local zone.
+func (e *DateTimeTZField) SetRaw(value interface{}) error {
+	switch d := value.(type) {
+	case nil:
+		e.Set(time.Time{})
+	case time.Time:
+		e.Set(d)
+	case []byte:
+		return e.SetRaw(string(d))
+	case string:
+		for _, layout := range timeTZLayouts {
+			if v, err := time.Parse(layout, d); err == nil {
+				e.Set(v)
+				return nil
+			}
+		}
+		v, err := time.ParseInLocation(utils.FormatDateTime, d, time.Local)
+		if err != nil {
+			return fmt.Errorf("<DateTimeTZField.SetRaw> wrong time value `%s`", d)
+		}
+		e.Set(v)
+	default:
+		return fmt.Errorf("<DateTimeTZField.SetRaw> unknown value `%v`", value)
+	}
+	return nil
+}
+
+// RawValue return the time in RFC 3339 format, or nil if the time is zero
+func (e *DateTimeTZField) RawValue() interface{} {
+	if e.Value().IsZero() {
+		return nil
+	}
+	return e.String()
+}
+
+// verify DateTimeTZField implement Fielder
+var _ Fielder = new(DateTimeTZField)
+
+// ArrayFielder is the Fielder of the array columns, such as StringArrayField and IntArrayField,
+// whose RawValue is the array literal of postgres, such as {1,2,3}.
+// The fields of it support the filter operators contains and overlap on postgres.
+type ArrayFielder interface {
+	Fielder
+	// Elems return the elements of the array
+	Elems() []interface{}
+}
+
+// StringArrayField A text array of postgres, represented in go by a []string.
+// It is stored as the array literal in a text column of the others.
+type StringArrayField []string
+
+// Value return the []string value
+func (e StringArrayField) Value() []string {
+	return []string(e)
+}
+
+// Set the StringArrayField value
+func (e *StringArrayField) Set(d []string) {
+	*e = StringArrayField(d)
+}
+
+// String return the array literal, such as {"a","b"}
+func (e *StringArrayField) String() string {
+	elems := make([]string, 0, len(*e))
+	for _, s := range *e {
+		elems = append(elems, quoteArrayElem(s))
+	}
+	return "{" + strings.Join(elems, ",") + "}"
+}
+
+// FieldType return the enum type
+func (e *StringArrayField) FieldType() int {
+	return TypeTextField
+}
+
+// SetRaw convert the array literal, []string or []interface{} to StringArrayField
+func (e *StringArrayField) SetRaw(value interface{}) error {
+	switch d := value.(type) {
+	case nil:
+		e.Set(nil)
+	case []string:
+		e.Set(d)
+	case []interface{}:
+		v := make([]string, 0, len(d))
+		for _, elem := range d {
+			v = append(v, utils.ToStr(elem))
+		}
+		e.Set(v)
+	case []byte:
+		return e.SetRaw(string(d))
+	case string:
+		v, err := parseArrayLiteral(d)
+		if err != nil {
+			return fmt.Errorf("<StringArrayField.SetRaw> %w", err)
+		}
+		e.Set(v)
+	default:
+		return fmt.Errorf("<StringArrayField.SetRaw> unknown value `%v`", value)
+	}
+	return nil
+}
+
+// RawValue return the array literal
+func (e *StringArrayField) RawValue() interface{} {
+	return e.String()
+}
+
+// Elems return the strings of the array
+func (e *StringArrayField) Elems() []interface{} {
+	elems := make([]interface{}, 0, len(*e))
+	for _, s := range *e {
+		elems = append(elems, s)
+	}
+	return elems
+}
+
+// verify StringArrayField implement ArrayFielder
+var _ ArrayFielder = new(StringArrayField)
+
+// IntArrayField A bigint array of postgres, represented in go by a []int64.
+// It is stored as the array literal in a text column of the others.
+type IntArrayField []int64
+
+// Value return the []int64 value
+func (e IntArrayField) Value() []int64 {
+	return []int64(e)
+}
+
+// Set the IntArrayField value
+func (e *IntArrayField) Set(d []int64) {
+	*e = IntArrayField(d)
+}
+
+// String return the array literal, such as {1,2}
+func (e *IntArrayField) String() string {
+	elems := make([]string, 0, len(*e))
+	for _, i := range *e {
+		elems = append(elems, strconv.FormatInt(i, 10))
+	}
+	return "{" + strings.Join(elems, ",") + "}"
+}
+
+// FieldType return the enum type
+func (e *IntArrayField) FieldType() int {
+	return TypeTextField
+}
+
+// SetRaw convert the array literal, []int64, []int or []interface{} to IntArrayField
+func (e *IntArrayField) SetRaw(value interface{}) error {
+	switch d := value.(type) {
+	case nil:
+		e.Set(nil)
+	case []int64:
+		e.Set(d)
+	case []int:
+		v := make([]int64, 0, len(d))
+		for _, i := range d {
+			v = append(v, int64(i))
+		}
+		e.Set(v)
+	case []interface{}:
+		v := make([]int64, 0, len(d))
+		for _, elem := range d {
+			i, err := utils.StrTo(utils.ToStr(elem)).Int64()
+			if err != nil {
+				return fmt.Errorf("<IntArrayField.SetRaw> wrong integer `%v`", elem)
+			}
+			v = append(v, i)
+		}
+		e.Set(v)
+	case []byte:
+		return e.SetRaw(string(d))
+	case string:
+		elems, err := parseArrayLiteral(d)
+		if err != nil {
+			return fmt.Errorf("<IntArrayField.SetRaw> %w", err)
+		}
+		v := make([]int64, 0, len(elems))
+		for _, s := range elems {
+			i, err := strconv.ParseInt(s, 10, 64)
+			if err != nil {
+				return fmt.Errorf("<IntArrayField.SetRaw> wrong integer `%s`", s)
+			}
+			v = append(v, i)
+		}
+		e.Set(v)
+	default:
+		return fmt.Errorf("<IntArrayField.SetRaw> unknown value `%v`", value)
+	}
+	return nil
+}
+
+// RawValue return the array literal
+func (e *IntArrayField) RawValue() interface{} {
+	return e.String()
+}
+
+// Elems return the integers of the array
+func (e *IntArrayField) Elems() []interface{} {
+	elems := make([]interface{}, 0, len(*e))
+	for _, i := range *e {
+		elems = append(elems, i)
+	}
+	return elems
+}
+
+// verify IntArrayField implement ArrayFielder
+var _ ArrayFielder = new(IntArrayField)
+
+// quoteArrayElem quote the element of the array literal
+func quoteArrayElem(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// parseArrayLiteral parse the elements of the one-dimensional array literal of postgres, such as {a,"b c"}
+func parseArrayLiteral(s string) ([]string, error) {
+	s = strings.TrimSpace(s)
+	if len(s) < 2 || s[0] != '{' || s[len(s)-1] != '}' {
+		return nil, fmt.Errorf("wrong array literal `%s`", s)
+	}
+	body := s[1 : len(s)-1]
+	elems := make([]string, 0, strings.Count(body, ",")+1)
+	if strings.TrimSpace(body) == "" {
+		return elems, nil
+	}
+	for i := 0; i <= len(body); {
+		for i < len(body) && body[i] == ' ' {
+			i++
+		}
+		var elem strings.Builder
+		if i < len(body) && body[i] == '"' {
+			i++
+			for ; i < len(body) && body[i] != '"'; i++ {
+				if body[i] == '\\' {
+					i++
+				}
+				if i < len(body) {
+					elem.WriteByte(body[i])
+				}
+			}
+			if i >= len(body) {
+				return nil, fmt.Errorf("wrong array literal `%s`", s)
+			}
+			i++
+			for i < len(body) && body[i] == ' ' {
+				i++
+			}
+		} else {
+			end := strings.IndexByte(body[i:], ',')
+			if end < 0 {
+				end = len(body) - i
+			}
+			v := strings.TrimSpace(body[i : i+end])
+			switch {
+			case strings.ContainsAny(v, "{}"):
+				return nil, fmt.Errorf("multidimensional array literal `%s` is not supported", s)
+			case strings.EqualFold(v, "NULL"):
+				return nil, fmt.Errorf("the NULL element of array literal `%s` is not supported", s)
+			}
+			elem.WriteString(v)
+			i += end
+		}
+		elems = append(elems, elem.String())
+		if i < len(body) && body[i] != ',' {
+			return nil, fmt.Errorf("wrong array literal `%s`", s)
+		}
+		i++
+	}
+	return elems, nil
+}
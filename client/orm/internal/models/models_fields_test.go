@@ -0,0 +1,98 @@
+// Copyright 2023 beego. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"math"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseArrayLiteral(t *testing.T) {
+	testCases := []struct {
+		name    string
+		literal string
+		want    []string
+		wantErr bool
+	}{
+		{name: "empty", literal: "{}", want: []string{}},
+		{name: "unquoted", literal: "{a, b ,c}", want: []string{"a", "b", "c"}},
+		{name: "quoted", literal: `{"a,b","say \"hi\"","c\\d"}`, want: []string{"a,b", `say "hi"`, `c\d`}},
+		{name: "not array", literal: "a,b", wantErr: true},
+		{name: "unclosed quote", literal: `{"a}`, wantErr: true},
+		{name: "null", literal: "{a,NULL}", wantErr: true},
+		{name: "multidimensional", literal: "{{1,2},{3,4}}", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			elems, err := parseArrayLiteral(tc.literal)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, elems)
+		})
+	}
+
+	arr := StringArrayField{"a,b", `say "hi"`, `c\d`}
+	var parsed StringArrayField
+	assert.NoError(t, parsed.SetRaw([]byte(arr.String())))
+	assert.Equal(t, arr, parsed)
+}
+
+func TestExtendedFielders(t *testing.T) {
+	var u UUIDField
+	assert.NoError(t, u.SetRaw("{6BA7B810-9DAD-11D1-80B4-00C04FD430C8}"))
+	assert.Equal(t, "6ba7b810-9dad-11d1-80b4-00c04fd430c8", u.RawValue())
+	assert.NoError(t, u.SetRaw("urn:uuid:6ba7b8109dad11d180b400c04fd430c8"))
+	assert.Equal(t, "6ba7b810-9dad-11d1-80b4-00c04fd430c8", u.String())
+	assert.Error(t, u.SetRaw("6ba7b810"))
+
+	var d DecimalField
+	assert.Nil(t, d.RawValue())
+	assert.Equal(t, "0", d.String())
+	assert.NoError(t, d.SetRaw([]byte("12345678901234567890.123456")))
+	assert.Equal(t, "12345678901234567890.123456", d.RawValue())
+	assert.NoError(t, d.SetRaw(1.5))
+	assert.Equal(t, "1.5", d.Value())
+	assert.NoError(t, d.SetRaw(float32(0.1)))
+	assert.Equal(t, "0.1", d.Value())
+	assert.NoError(t, d.SetRaw("-.5e3"))
+	for _, v := range []interface{}{"1.2.3", "NaN", "Infinity", "inf", "0x1p-2", "1_000", "", math.NaN(), math.Inf(-1)} {
+		assert.Error(t, d.SetRaw(v), v)
+	}
+
+	var ip IPAddrField
+	assert.Nil(t, ip.RawValue())
+	assert.NoError(t, ip.SetRaw("10.0.0.1/24"))
+	assert.Equal(t, netip.MustParseAddr("10.0.0.1"), ip.Value())
+	assert.Error(t, ip.SetRaw("10.0.0"))
+
+	var tz DateTimeTZField
+	assert.Nil(t, tz.RawValue())
+	assert.NoError(t, tz.SetRaw("2023-04-05 06:07:08.5+08"))
+	assert.Equal(t, "2023-04-05T06:07:08.5+08:00", tz.RawValue())
+	assert.NoError(t, tz.SetRaw(time.Date(2023, 4, 5, 6, 7, 8, 0, time.UTC)))
+	assert.Equal(t, "2023-04-05T06:07:08Z", tz.RawValue())
+
+	var ints IntArrayField
+	assert.NoError(t, ints.SetRaw([]interface{}{1, "2"}))
+	assert.Equal(t, "{1,2}", ints.RawValue())
+	assert.Error(t, ints.SetRaw("{1,a}"))
+}
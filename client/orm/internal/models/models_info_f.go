@@ -28,6 +28,9 @@ var errSkipField = errors.New("skip field")
 // Fields field info collection
 type Fields struct {
 	Pk            *FieldInfo
+	CompositePk   []*FieldInfo
+	SoftDelete    *FieldInfo
+	Version       *FieldInfo
 	Columns       map[string]*FieldInfo
 	Fields        map[string]*FieldInfo
 	FieldsLow     map[string]*FieldInfo
@@ -40,6 +43,15 @@ type Fields struct {
 	DBcols        []string
 }
 
+// PkFields returns the fields of the primary key,
+// there are more than one fields for the composite primary key.
+func (f *Fields) PkFields() []*FieldInfo {
+	if f.Pk != nil {
+		return []*FieldInfo{f.Pk}
+	}
+	return f.CompositePk
+}
+
 // Add adds field info
 func (f *Fields) Add(fi *FieldInfo) (added bool) {
 	if f.Fields[fi.Name] == nil && f.Columns[fi.Column] == nil {
@@ -114,6 +126,8 @@ type FieldInfo struct {
 	ToText              bool
 	AutoNow             bool
 	AutoNowAdd          bool
+	SoftDelete          bool // set the deleted time instead of deleting the row
+	Version             bool // the version for optimistic locking
 	Rel                 bool // if type equal to RelForeignKey, RelOneToOne, RelManyToMany then true
 	Reverse             bool
 	IsFielder           bool // implement Fielder interface
@@ -141,6 +155,7 @@ type FieldInfo struct {
 	Description         string
 	TimePrecision       *int
 	DBType              string
+	Enum                []string // the values allowed by the enum tag
 }
 
 // NewFieldInfo new field info
@@ -417,6 +432,33 @@ checkType:
 		}
 	}
 
+	if v := tags["enum"]; v != "" {
+		if fieldType != TypeVarCharField && fieldType != TypeCharField || fi.IsFielder {
+			err = fmt.Errorf("enum only support string field")
+			goto end
+		}
+		for _, s := range strings.Split(v, ",") {
+			fi.Enum = append(fi.Enum, strings.TrimSpace(s))
+		}
+	}
+
+	if attrs["soft_delete"] {
+		if fieldType != TypeDateTimeField {
+			err = fmt.Errorf("soft_delete only support datetime field")
+			goto end
+		}
+		fi.SoftDelete = true
+		fi.Null = true
+	}
+
+	if attrs["version"] {
+		if fieldType&IsIntegerField == 0 || fi.IsFielder || field.Kind() == reflect.Ptr {
+			err = fmt.Errorf("version only support non-ptr integer field")
+			goto end
+		}
+		fi.Version = true
+	}
+
 	if fieldType&IsIntegerField == 0 {
 		if fi.Auto {
 			err = fmt.Errorf("non-integer type cannot set auto")
@@ -424,6 +466,11 @@ checkType:
 		}
 	}
 
+	if fi.Version && (fi.Auto || fi.Pk) {
+		err = fmt.Errorf("version can not be pk")
+		goto end
+	}
+
 	if fi.Auto || fi.Pk {
 		if fi.Auto {
 			switch addrField.Elem().Kind() {
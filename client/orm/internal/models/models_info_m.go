@@ -32,6 +32,8 @@ type ModelInfo struct {
 	Fields    *Fields
 	AddrField reflect.Value // store the original struct value
 	Uniques   []string
+	// save the snapshots of the read models, so Update writes the changed fields only
+	TrackChanges bool
 }
 
 // NewModelInfo new model info
@@ -46,6 +48,35 @@ func NewModelInfo(val reflect.Value) (mi *ModelInfo) {
 	return
 }
 
+// SetPrimaryKey set the fields of names as the primary key of the model,
+// more than one names make a composite primary key.
+func (mi *ModelInfo) SetPrimaryKey(names []string) error {
+	if mi.Fields.Pk != nil {
+		return fmt.Errorf("pk field `%s` conflicts with TablePrimaryKey", mi.Fields.Pk.Name)
+	}
+	fis := make([]*FieldInfo, 0, len(names))
+	for _, name := range names {
+		fi, ok := mi.Fields.GetByAny(name)
+		if !ok || !fi.DBcol {
+			return fmt.Errorf("wrong field/column name `%s` in TablePrimaryKey", name)
+		}
+		if fi.SoftDelete || fi.Version {
+			return fmt.Errorf("soft_delete or version field `%s` can not be pk", fi.Name)
+		}
+		fi.Null = false
+		fi.Unique = false
+		fis = append(fis, fi)
+	}
+	if len(fis) == 1 {
+		fis[0].Pk = true
+		fis[0].Index = false
+		mi.Fields.Pk = fis[0]
+		return nil
+	}
+	mi.Fields.CompositePk = fis
+	return nil
+}
+
 // AddModelFields index: FieldByIndex returns the nested field corresponding to index
 func AddModelFields(mi *ModelInfo, ind reflect.Value, mName string, index []int) {
 	var (
@@ -91,6 +122,20 @@ func AddModelFields(mi *ModelInfo, ind reflect.Value, mName string, index []int)
 				mi.Fields.Pk = fi
 			}
 		}
+		if fi.SoftDelete {
+			if mi.Fields.SoftDelete != nil {
+				err = fmt.Errorf("one model must have one soft_delete field only")
+				break
+			}
+			mi.Fields.SoftDelete = fi
+		}
+		if fi.Version {
+			if mi.Fields.Version != nil {
+				err = fmt.Errorf("one model must have one version field only")
+				break
+			}
+			mi.Fields.Version = fi
+		}
 	}
 
 	if err != nil {
@@ -47,3 +47,35 @@ func TestDbBase_GetTables(t *testing.T) {
 	indexes := GetTableIndex(mi.AddrField)
 	assert.Equal(t, [][]string{{"index1"}, {"index2"}}, indexes)
 }
+
+type CompositePk struct {
+	TenantId int
+	Code     string
+	Name     string
+}
+
+func (c *CompositePk) TablePrimaryKey() []string {
+	return []string{"TenantId", "code"}
+}
+
+type WrongCompositePk struct {
+	Id   int `orm:"pk"`
+	Code string
+}
+
+func (w *WrongCompositePk) TablePrimaryKey() []string {
+	return []string{"Id", "Code"}
+}
+
+func TestModelCache_RegisterCompositePk(t *testing.T) {
+	c := NewModelCacheHandler()
+	err := c.Register("", true, &CompositePk{})
+	assert.Nil(t, err)
+	mi, ok := c.Get("composite_pk")
+	assert.True(t, ok)
+	assert.Nil(t, mi.Fields.Pk)
+	assert.Equal(t, []*FieldInfo{mi.Fields.GetByName("TenantId"), mi.Fields.GetByName("Code")}, mi.Fields.PkFields())
+
+	err = c.Register("", true, &WrongCompositePk{})
+	assert.NotNil(t, err)
+}
@@ -33,6 +33,8 @@ var supportTag = map[string]int{
 	"auto":         1,
 	"auto_now":     1,
 	"auto_now_add": 1,
+	"soft_delete":  1,
+	"version":      1,
 	"size":         2,
 	"column":       2,
 	"default":      2,
@@ -47,6 +49,7 @@ var supportTag = map[string]int{
 	"description":  2,
 	"precision":    2,
 	"db_type":      2,
+	"enum":         2,
 }
 
 type fn func(string) string
@@ -122,6 +125,34 @@ func GetTableUnique(val reflect.Value) [][]string {
 	return nil
 }
 
+// GetTablePrimaryKey get the fields of the table primary key from method
+func GetTablePrimaryKey(val reflect.Value) []string {
+	fun := val.MethodByName("TablePrimaryKey")
+	if fun.IsValid() {
+		vals := fun.Call([]reflect.Value{})
+		if len(vals) > 0 && vals[0].CanInterface() {
+			if d, ok := vals[0].Interface().([]string); ok {
+				return d
+			}
+		}
+	}
+	return nil
+}
+
+// GetTableTrackChanges get whether the model tracks changes from method
+func GetTableTrackChanges(val reflect.Value) bool {
+	fun := val.MethodByName("TableTrackChanges")
+	if fun.IsValid() {
+		vals := fun.Call([]reflect.Value{})
+		if len(vals) > 0 && vals[0].CanInterface() {
+			if d, ok := vals[0].Interface().(bool); ok {
+				return d
+			}
+		}
+	}
+	return false
+}
+
 // IsApplicableTableForDB get whether the table needs to be created for the database alias
 func IsApplicableTableForDB(val reflect.Value, db string) bool {
 	if !val.IsValid() {
@@ -30,6 +30,8 @@ type Invocation struct {
 	Args []interface{}
 
 	mi *models.ModelInfo
+	// executor runs the Orm operation without the filters
+	executor QueryExecutor
 	// f is the Orm operation
 	f func(ctx context.Context) []interface{}
 
@@ -46,6 +48,12 @@ func (inv *Invocation) GetTableName() string {
 	return ""
 }
 
+// GetExecutor return the QueryExecutor which runs the operation,
+// it is the TxOrmer if the operation is inside a transaction, and the queries by it skip the filters
+func (inv *Invocation) GetExecutor() QueryExecutor {
+	return inv.executor
+}
+
 func (inv *Invocation) execute(ctx context.Context) []interface{} {
 	return inv.f(ctx)
 }
@@ -58,3 +66,17 @@ func (inv *Invocation) GetPkFieldName() string {
 	}
 	return ""
 }
+
+// GetPkFieldNames return the fields of the primary key,
+// there are more than one fields for the composite primary key
+func (inv *Invocation) GetPkFieldNames() []string {
+	if inv.mi == nil {
+		return nil
+	}
+	fis := inv.mi.Fields.PkFields()
+	names := make([]string, 0, len(fis))
+	for _, fi := range fis {
+		names = append(names, fi.Name)
+	}
+	return names
+}
@@ -0,0 +1,130 @@
+// Copyright 2023 beego. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migration
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/beego/beego/v2/client/orm"
+	"github.com/beego/beego/v2/core/logs"
+)
+
+// ErrChecksumMismatch means an applied migration is modified after it was applied
+var ErrChecksumMismatch = errors.New("migration: checksum mismatch")
+
+// statementer is implemented by Migration, the checksum and the dry run depend on it
+type statementer interface {
+	Statements() []string
+}
+
+// checksum return the sha256 of the sql of a migration
+func checksum(sqls []string) string {
+	sum := sha256.Sum256([]byte(strings.Join(sqls, "; ")))
+	return hex.EncodeToString(sum[:])
+}
+
+// ensureChecksumColumn add the checksum column to the migrations table created by the old version
+func (o *options) ensureChecksumColumn() error {
+	ok, err := o.hasChecksumColumn()
+	if err != nil || ok {
+		return err
+	}
+	logs.Info("add column checksum to table migrations")
+	_, err = o.ormer().Raw("alter table migrations add column checksum varchar(64)").Exec()
+	return err
+}
+
+// hasChecksumColumn return whether the migrations table has the checksum column
+func (o *options) hasChecksumColumn() (bool, error) {
+	db, err := orm.GetDB(o.alias)
+	if err != nil {
+		return false, err
+	}
+	rows, err := db.Query("select * from migrations where 1 = 0")
+	if err != nil {
+		return false, err
+	}
+	columns, err := rows.Columns()
+	rows.Close()
+	if err != nil {
+		return false, err
+	}
+	for _, column := range columns {
+		if strings.EqualFold(column, "checksum") {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// getAppliedChecksums return the checksums of the applied migrations by name,
+// the migrations applied by the old version have no checksum.
+func (o *options) getAppliedChecksums() (map[string]string, error) {
+	var maps []orm.Params
+	_, err := o.ormer().Raw("select name, status, checksum from migrations order by id_migration desc").Values(&maps)
+	if err != nil {
+		return nil, err
+	}
+	checksums := make(map[string]string, len(maps))
+	for _, v := range maps {
+		name, _ := v["name"].(string)
+		if _, ok := checksums[name]; ok {
+			// only the latest record counts
+			continue
+		}
+		status, _ := v["status"].(string)
+		sum, _ := v["checksum"].(string)
+		if status != "update" {
+			sum = ""
+		}
+		checksums[name] = sum
+	}
+	return checksums, nil
+}
+
+// verifyChecksums regenerate the sql of the applied migrations and compare them with the stored checksums
+func (o *options) verifyChecksums(sm dataSlice) error {
+	if o.dryRun != nil {
+		// the dry run doesn't add the checksum column, the table without it has no checksum to verify
+		if ok, err := o.hasChecksumColumn(); err != nil || !ok {
+			return err
+		}
+	}
+	checksums, err := o.getAppliedChecksums()
+	if err != nil {
+		return fmt.Errorf("migration: read the checksums of the applied migrations: %w", err)
+	}
+	var modified []string
+	for _, v := range sm {
+		sum := checksums[v.name]
+		s, ok := v.m.(statementer)
+		if sum == "" || !ok {
+			continue
+		}
+		v.m.Reset()
+		v.m.Up()
+		if checksum(s.Statements()) != sum {
+			modified = append(modified, v.name)
+		}
+	}
+	if len(modified) > 0 {
+		return fmt.Errorf("%w: %s", ErrChecksumMismatch, strings.Join(modified, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+// Copyright 2023 beego. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migration
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+func init() {
+	orm.RegisterCommand("migrate", &commandMigrate{out: os.Stdout})
+}
+
+// migrate commander of orm.RunCommand:
+//
+//	orm migrate up|down|status|redo [-db default] [-n 1] [-dry-run]
+//	orm migrate to <name> [-db default] [-dry-run]
+type commandMigrate struct {
+	action string
+	target string
+	steps  int
+	opts   []Option
+	out    io.Writer
+}
+
+// Parse orm command line arguments.
+func (d *commandMigrate) Parse(args []string) {
+	var (
+		alias  string
+		dryRun bool
+	)
+	if len(args) > 0 {
+		d.action, args = args[0], args[1:]
+	}
+
+	flagSet := flag.NewFlagSet("orm command: migrate "+d.action, flag.ExitOnError)
+	flagSet.StringVar(&alias, "db", "default", "DataBase alias name")
+	flagSet.IntVar(&d.steps, "n", 1, "number of the migrations to roll back by down and redo")
+	flagSet.BoolVar(&dryRun, "dry-run", false, "print sql of the migrations instead of executing them")
+	flagSet.Parse(args)
+	// the flags after the migration name of to
+	if d.action == "to" && flagSet.NArg() > 0 {
+		d.target = flagSet.Arg(0)
+		flagSet.Parse(flagSet.Args()[1:])
+	}
+
+	d.opts = []Option{WithAlias(alias)}
+	if dryRun {
+		d.opts = append(d.opts, WithDryRun(d.out))
+	}
+}
+
+// Run orm line command.
+func (d *commandMigrate) Run() error {
+	switch d.action {
+	case "up":
+		return Upgrade(0, d.opts...)
+	case "down":
+		return RollbackSteps(d.steps, d.opts...)
+	case "redo":
+		return Redo(d.steps, d.opts...)
+	case "to":
+		if d.target == "" {
+			return errors.New("the migration name is required, e.g. orm migrate to CreateUser_20230102_030405")
+		}
+		return MigrateTo(d.target, d.opts...)
+	case "status":
+		states, err := Status(d.opts...)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(d.out, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "Migration\tStatus\tApplied At")
+		for _, s := range states {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", s.Name, s.Status, s.AppliedAt)
+		}
+		return w.Flush()
+	default:
+		return fmt.Errorf("unknown migrate action `%s`, it should be up, down, status, redo or to", d.action)
+	}
+}
@@ -29,4 +29,11 @@
 // //Foreign Columns, single columns are only supported, SetOnDelete & SetOnUpdate are available, call appropriately.
 // //Supports standard column methods, automatic reverse.
 // m.ForeignCol("local_col","foreign_col","foreign_table")
+//
+// //Generate a migration file from the difference between the registered models and the database
+// migration.GenerateMigration(ctx, "database/migrations", "AddUserAge", "default")
+//
+// //Run the migrations from the command line of orm.RunCommand after importing this package
+// go run main.go orm migrate up|down|status|redo [-db default] [-n 1] [-dry-run]
+// go run main.go orm migrate to AddUserAge_20230102_030405
 package migration
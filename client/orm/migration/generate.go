@@ -0,0 +1,154 @@
+// Copyright 2023 beego. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migration
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/format"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"text/template"
+	"time"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+func init() {
+	orm.RegisterMigrationWriter(writeMigrationFile)
+}
+
+var migrationTpl = template.Must(template.New("migration").Funcs(template.FuncMap{
+	"quote": strconv.Quote,
+}).Parse(`package {{.Package}}
+
+import (
+	"github.com/beego/beego/v2/client/orm/migration"
+)
+
+// DO NOT MODIFY
+type {{.Struct}} struct {
+	migration.Migration
+}
+
+// DO NOT MODIFY
+func init() {
+	m := &{{.Struct}}{}
+	m.Created = {{quote .Created}}
+
+	migration.Register({{quote .Struct}}, m)
+}
+
+// Run the migrations
+func (m *{{.Struct}}) Up() {
+{{- range .Changes}}
+	// {{.}}
+{{- range .Up}}
+	m.SQL({{quote .}})
+{{- end}}
+{{- end}}
+}
+
+// Reverse the migrations
+func (m *{{.Struct}}) Down() {
+{{- range .Reversed}}
+	// {{.}}
+{{- range .Down}}
+	m.SQL({{quote .}})
+{{- end}}
+{{- end}}
+}
+`))
+
+// WriteMigration write the source of a Migration which applies the diff in Up and reverts it in Down.
+// The migration is registered as name_created, in the same layout as the migrations generated by bee.
+func WriteMigration(w io.Writer, pkg string, name string, created time.Time, diff *orm.SchemaDiff) error {
+	reversed := make([]*orm.SchemaChange, 0, len(diff.Changes))
+	for i := len(diff.Changes) - 1; i >= 0; i-- {
+		reversed = append(reversed, diff.Changes[i])
+	}
+	createdStr := created.Format(DateFormat)
+
+	buf := &bytes.Buffer{}
+	err := migrationTpl.Execute(buf, map[string]interface{}{
+		"Package":  pkg,
+		"Struct":   name + "_" + createdStr,
+		"Created":  createdStr,
+		"Changes":  diff.Changes,
+		"Reversed": reversed,
+	})
+	if err != nil {
+		return err
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(src)
+	return err
+}
+
+// GenerateMigration compare the registered models with the database alias by orm.DiffSchema,
+// and write a migration file of package main into dir for the delta.
+// It returns the path of the file, or an empty path if the database is up to date.
+func GenerateMigration(ctx context.Context, dir string, name string, alias string, opts ...orm.SchemaDiffOption) (string, error) {
+	diff, err := orm.DiffSchema(ctx, alias, opts...)
+	if err != nil {
+		return "", err
+	}
+	if diff.IsEmpty() {
+		return "", nil
+	}
+
+	return writeMigrationFile(dir, name, diff)
+}
+
+// GenerateInitialMigration dump the tables of the database alias by orm.DumpSchema,
+// and write a migration file of package main into dir which creates them, all the tables are dumped if tables is empty.
+func GenerateInitialMigration(ctx context.Context, dir string, name string, alias string, tables ...string) (string, error) {
+	diff, err := orm.DumpSchema(ctx, alias, tables...)
+	if err != nil {
+		return "", err
+	}
+	return writeMigrationFile(dir, name, diff)
+}
+
+// writeMigrationFile write the migration of diff into a new file of dir, the file is named by the time and name.
+// The file is removed if it cannot be written completely.
+func writeMigrationFile(dir string, name string, diff *orm.SchemaDiff) (string, error) {
+	created := time.Now()
+	buf := &bytes.Buffer{}
+	if err := WriteMigration(buf, "main", name, created, diff); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("%s_%s.go", created.Format(DateFormat), name))
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return "", err
+	}
+	_, err = f.Write(buf.Bytes())
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(path)
+		return "", err
+	}
+	return path, nil
+}
@@ -0,0 +1,84 @@
+// Copyright 2023 beego. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migration
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+func TestWriteMigration(t *testing.T) {
+	diff := &orm.SchemaDiff{Changes: []*orm.SchemaChange{
+		{
+			Kind:  orm.SchemaAddColumn,
+			Table: "user",
+			Name:  "age",
+			Up:    []string{"ALTER TABLE `user` ADD COLUMN `age` integer NOT NULL DEFAULT 0;"},
+			Down:  []string{"ALTER TABLE `user` DROP COLUMN `age`;"},
+		},
+		{
+			Kind:  orm.SchemaCreateIndex,
+			Table: "user",
+			Name:  "user_age",
+			Up:    []string{"CREATE INDEX `user_age` ON `user` (`age`);"},
+			Down:  []string{"DROP INDEX `user_age`;"},
+		},
+	}}
+	created := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	buf := &bytes.Buffer{}
+	err := WriteMigration(buf, "main", "AddUserAge", created, diff)
+	assert.Nil(t, err)
+	assert.Equal(t, `package main
+
+import (
+	"github.com/beego/beego/v2/client/orm/migration"
+)
+
+// DO NOT MODIFY
+type AddUserAge_20230102_030405 struct {
+	migration.Migration
+}
+
+// DO NOT MODIFY
+func init() {
+	m := &AddUserAge_20230102_030405{}
+	m.Created = "20230102_030405"
+
+	migration.Register("AddUserAge_20230102_030405", m)
+}
+
+// Run the migrations
+func (m *AddUserAge_20230102_030405) Up() {
+	// add_column user.age
+	m.SQL("ALTER TABLE `+"`user`"+` ADD COLUMN `+"`age`"+` integer NOT NULL DEFAULT 0;")
+	// create_index user.user_age
+	m.SQL("CREATE INDEX `+"`user_age`"+` ON `+"`user`"+` (`+"`age`"+`);")
+}
+
+// Reverse the migrations
+func (m *AddUserAge_20230102_030405) Down() {
+	// create_index user.user_age
+	m.SQL("DROP INDEX `+"`user_age`"+`;")
+	// add_column user.age
+	m.SQL("ALTER TABLE `+"`user`"+` DROP COLUMN `+"`age`"+`;")
+}
+`, buf.String())
+}
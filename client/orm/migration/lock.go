@@ -0,0 +1,286 @@
+// Copyright 2023 beego. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migration
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/beego/beego/v2/client/orm"
+	"github.com/beego/beego/v2/core/logs"
+)
+
+// ErrLockTimeout is returned when the migration lock is held by others until the lock timeout
+var ErrLockTimeout = errors.New("migration: acquire the migration lock timeout")
+
+// lockName is the name of the advisory lock shared by all the instances
+const lockName = "beego_migrations"
+
+// lockRetryInterval is the interval of trying the locks which can't block
+var lockRetryInterval = 200 * time.Millisecond
+
+// locker is the advisory lock which protects the migrations from running concurrently
+type locker interface {
+	lock(ctx context.Context) error
+	unlock() error
+}
+
+func newLocker(db *sql.DB, driver orm.DriverType) locker {
+	switch driver {
+	case orm.DRMySQL, orm.DRTiDB:
+		return &mysqlLocker{db: db}
+	case orm.DRPostgres:
+		h := fnv.New64a()
+		h.Write([]byte(lockName))
+		return &postgresLocker{db: db, key: int64(h.Sum64())}
+	case orm.DRSqlite:
+		return &sqliteLocker{db: db}
+	default:
+		logs.Warn("migration lock is not supported by the driver, migrations are not protected from running concurrently")
+		return noopLocker{}
+	}
+}
+
+// withLock run the task while holding the migration lock of the database alias
+func withLock(alias string, timeout time.Duration, task func() error) error {
+	db, err := orm.GetDB(alias)
+	if err != nil {
+		return err
+	}
+	l := newLocker(db, orm.NewOrmUsingDB(alias).Driver().Type())
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := l.lock(ctx); err != nil {
+		return err
+	}
+	defer func() {
+		if err := l.unlock(); err != nil {
+			logs.Error("release migration lock error:", err)
+		}
+	}()
+	return task()
+}
+
+// retryLock call try until it succeeds or the ctx is done
+func retryLock(ctx context.Context, try func() (bool, error)) error {
+	for {
+		ok, err := try()
+		if err != nil || ok {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ErrLockTimeout
+		case <-time.After(lockRetryInterval):
+		}
+	}
+}
+
+// mysqlLocker uses GET_LOCK, the lock is held by the connection
+type mysqlLocker struct {
+	db   *sql.DB
+	conn *sql.Conn
+}
+
+func (l *mysqlLocker) lock(ctx context.Context) error {
+	conn, err := l.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	timeout := 0
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = int(time.Until(deadline).Seconds())
+	}
+	var res sql.NullInt64
+	if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", lockName, timeout).Scan(&res); err != nil {
+		conn.Close()
+		return err
+	}
+	if !res.Valid || res.Int64 != 1 {
+		conn.Close()
+		return ErrLockTimeout
+	}
+	l.conn = conn
+	return nil
+}
+
+func (l *mysqlLocker) unlock() error {
+	defer l.conn.Close()
+	_, err := l.conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", lockName)
+	return err
+}
+
+// postgresLocker uses the session level advisory lock, the lock is held by the connection
+type postgresLocker struct {
+	db   *sql.DB
+	conn *sql.Conn
+	key  int64
+}
+
+func (l *postgresLocker) lock(ctx context.Context) error {
+	conn, err := l.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	err = retryLock(ctx, func() (bool, error) {
+		var ok bool
+		err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", l.key).Scan(&ok)
+		return ok, err
+	})
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	l.conn = conn
+	return nil
+}
+
+func (l *postgresLocker) unlock() error {
+	defer l.conn.Close()
+	_, err := l.conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", l.key)
+	return err
+}
+
+// staleLockAge is the age of the lock file which is left by a crashed process,
+// the holder touches its lock file much more often than it.
+var staleLockAge = time.Minute
+
+// sqliteLocker creates a lock file beside the database file, which contains the pid and the time of the holder.
+// The holder keeps touching the lock file, and the lock file not touched for staleLockAge is broken.
+type sqliteLocker struct {
+	db   *sql.DB
+	path string
+	done chan struct{}
+}
+
+func (l *sqliteLocker) lock(ctx context.Context) error {
+	var (
+		seq        int
+		name, file string
+	)
+	if err := l.db.QueryRowContext(ctx, "PRAGMA database_list").Scan(&seq, &name, &file); err != nil {
+		return err
+	}
+	if file == "" {
+		// the memory database can't be shared with other processes
+		return nil
+	}
+	l.path = file + "-migration.lock"
+	err := retryLock(ctx, func() (bool, error) {
+		f, err := os.OpenFile(l.path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if os.IsExist(err) {
+			l.breakStale()
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		_, err = fmt.Fprintf(f, "%d %s\n", os.Getpid(), time.Now().Format(time.RFC3339))
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			_ = os.Remove(l.path)
+			return false, err
+		}
+		return true, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	l.done = make(chan struct{})
+	go l.touch(l.done)
+	return nil
+}
+
+// touch keeps the lock file fresh until done is closed
+func (l *sqliteLocker) touch(done chan struct{}) {
+	ticker := time.NewTicker(staleLockAge / 4)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case now := <-ticker.C:
+			_ = os.Chtimes(l.path, now, now)
+		}
+	}
+}
+
+// breakStale removes the lock file left by a crashed process.
+// The waiters break it one by one while holding the break file, and check it again before removing it,
+// so the fresh lock file created after the stale one is broken by another waiter is kept.
+func (l *sqliteLocker) breakStale() {
+	info, err := os.Stat(l.path)
+	if err != nil || time.Since(info.ModTime()) < staleLockAge {
+		return
+	}
+	holder, err := os.ReadFile(l.path)
+	if err != nil {
+		return
+	}
+
+	breaking := l.path + ".break"
+	f, err := os.OpenFile(breaking, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if os.IsExist(err) {
+		// the break file is only held for a moment, the one left by a crashed waiter is removed
+		if info, err := os.Stat(breaking); err == nil && time.Since(info.ModTime()) >= staleLockAge {
+			_ = os.Remove(breaking)
+		}
+		return
+	}
+	if err != nil {
+		return
+	}
+	_ = f.Close()
+	defer os.Remove(breaking)
+
+	again, err := os.Stat(l.path)
+	if err != nil || !again.ModTime().Equal(info.ModTime()) {
+		return
+	}
+	if current, err := os.ReadFile(l.path); err != nil || !bytes.Equal(current, holder) {
+		return
+	}
+	logs.Warn("break the stale migration lock", l.path, "held by", strings.TrimSpace(string(holder)))
+	_ = os.Remove(l.path)
+}
+
+func (l *sqliteLocker) unlock() error {
+	if l.path == "" {
+		return nil
+	}
+	close(l.done)
+	return os.Remove(l.path)
+}
+
+type noopLocker struct{}
+
+func (noopLocker) lock(context.Context) error {
+	return nil
+}
+
+func (noopLocker) unlock() error {
+	return nil
+}
@@ -23,12 +23,15 @@
 //		`statements` longtext COMMENT 'SQL statements for this migration',
 //		`rollback_statements` longtext,
 //		`status` enum('update','rollback') DEFAULT NULL COMMENT 'update indicates it is a normal migration while rollback means this migration is rolled back',
+//		`checksum` varchar(64) DEFAULT NULL COMMENT 'sha256 of the statements, added automatically by Upgrade if missing',
 //		PRIMARY KEY (`id_migration`)
 //	) ENGINE=InnoDB DEFAULT CHARSET=utf8;
 package migration
 
 import (
 	"errors"
+	"fmt"
+	"io"
 	"sort"
 	"strings"
 	"time"
@@ -70,6 +73,8 @@ type Migration struct {
 	RemoveIndexes  []*Index
 	RemoveUniques  []*Unique
 	RemoveForeigns []*Foreign
+	// alias is the database alias which the sql is executed on
+	alias string
 }
 
 var migrationMap map[string]Migrationer
@@ -111,14 +116,32 @@ func (m *Migration) SQL(sql string) {
 	m.sqls = append(m.sqls, sql)
 }
 
+// Statements return the sql added by Up or Down, which will be executed by Exec
+func (m *Migration) Statements() []string {
+	return m.sqls
+}
+
 // Reset the sqls
 func (m *Migration) Reset() {
 	m.sqls = make([]string, 0)
 }
 
+// setAlias set the database alias of Exec, it's called before Exec
+func (m *Migration) setAlias(alias string) {
+	m.alias = alias
+}
+
+// ormer return the Ormer of the database alias, the default alias if it's not set
+func (m *Migration) ormer() orm.Ormer {
+	if m.alias == "" {
+		return orm.NewOrm()
+	}
+	return orm.NewOrmUsingDB(m.alias)
+}
+
 // Exec execute the sql already add in the sql
 func (m *Migration) Exec(name, status string) error {
-	o := orm.NewOrm()
+	o := m.ormer()
 	for _, s := range m.sqls {
 		logs.Info("exec sql:", s)
 		r := o.Raw(s)
@@ -131,7 +154,7 @@ func (m *Migration) Exec(name, status string) error {
 }
 
 func (m *Migration) addOrUpdateRecord(name, status string) error {
-	o := orm.NewOrm()
+	o := m.ormer()
 	if status == "down" {
 		status = "rollback"
 		p, err := o.Raw("update migrations set status = ?, rollback_statements = ?, created_at = ? where name = ?").Prepare()
@@ -142,11 +165,11 @@ func (m *Migration) addOrUpdateRecord(name, status string) error {
 		return err
 	}
 	status = "update"
-	p, err := o.Raw("insert into migrations(name, created_at, statements, status) values(?,?,?,?)").Prepare()
+	p, err := o.Raw("insert into migrations(name, created_at, statements, status, checksum) values(?,?,?,?,?)").Prepare()
 	if err != nil {
 		return err
 	}
-	_, err = p.Exec(name, time.Now().Format(DBDateFormat), strings.Join(m.sqls, "; "), status)
+	_, err = p.Exec(name, time.Now().Format(DBDateFormat), strings.Join(m.sqls, "; "), status, checksum(m.sqls))
 	return err
 }
 
@@ -168,91 +191,360 @@ func Register(name string, m Migrationer) error {
 	return nil
 }
 
-// Upgrade upgrade the migration from lasttime
-func Upgrade(lasttime int64) error {
+// Option configures Upgrade, Rollback, Reset and Refresh
+type Option func(opts *options)
+
+type options struct {
+	alias       string
+	dryRun      io.Writer
+	lockTimeout time.Duration
+	pause       time.Duration
+}
+
+var (
+	// DefaultLockTimeout is the default time to wait for the migration lock held by other instances
+	DefaultLockTimeout = 10 * time.Minute
+	// DefaultPause is the default unit of the pauses after running the migrations, which leave time to read the logs
+	DefaultPause = time.Second
+)
+
+// WithDryRun prints the sql of the migrations to w instead of executing them,
+// the database is not changed and the migration lock is not acquired.
+func WithDryRun(w io.Writer) Option {
+	return func(opts *options) {
+		opts.dryRun = w
+	}
+}
+
+// WithAlias runs the migrations on the database alias, it defaults to "default".
+// The migrations table and the migration lock are in the database of the alias too.
+func WithAlias(alias string) Option {
+	return func(opts *options) {
+		opts.alias = alias
+	}
+}
+
+// WithLockTimeout sets the time to wait for the migration lock
+func WithLockTimeout(timeout time.Duration) Option {
+	return func(opts *options) {
+		opts.lockTimeout = timeout
+	}
+}
+
+// WithPause sets the unit of the pauses after running the migrations, 0 means no pause
+func WithPause(pause time.Duration) Option {
+	return func(opts *options) {
+		opts.pause = pause
+	}
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{alias: "default", lockTimeout: DefaultLockTimeout, pause: DefaultPause}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// run the task with the migration lock, unless it's a dry run
+func (o *options) run(task func() error) error {
+	if o.dryRun != nil {
+		return task()
+	}
+	return withLock(o.alias, o.lockTimeout, task)
+}
+
+// ormer return the Ormer of the database alias
+func (o *options) ormer() orm.Ormer {
+	return orm.NewOrmUsingDB(o.alias)
+}
+
+// aliasSetter is implemented by Migration, the migrations without it are executed on the default alias
+type aliasSetter interface {
+	setAlias(alias string)
+}
+
+// exec executes the sql of the migration, or prints them in dry run mode
+func (o *options) exec(m Migrationer, name, status string) error {
+	if o.dryRun == nil {
+		if a, ok := m.(aliasSetter); ok {
+			a.setAlias(o.alias)
+		}
+		return m.Exec(name, status)
+	}
+	fmt.Fprintf(o.dryRun, "-- %s %s\n", status, name)
+	if s, ok := m.(statementer); ok {
+		for _, sql := range s.Statements() {
+			fmt.Fprintln(o.dryRun, sql)
+		}
+	} else {
+		fmt.Fprintln(o.dryRun, "-- the sql of the migration is unknown")
+	}
+	return nil
+}
+
+// Upgrade upgrade the migration from lasttime.
+// The migration lock is held during upgrading, and the applied migrations are checked
+// against their checksums, ErrChecksumMismatch is returned if any of them is modified.
+func Upgrade(lasttime int64, opts ...Option) error {
+	o := newOptions(opts)
+	return o.run(func() error {
+		return upgrade(o, false, "")
+	})
+}
+
+// upgrade apply the migrations which are not in the migrations table, or all the migrations if all is true.
+// If target is not empty, the migrations after it are not applied,
+// and the rolled back migrations up to it are applied again, the others are only applied again by Redo.
+func upgrade(o *options, all bool, target string) error {
+	if o.dryRun == nil {
+		if err := o.ensureChecksumColumn(); err != nil {
+			return err
+		}
+	}
 	sm := sortMap(migrationMap)
+	if err := o.verifyChecksums(sm); err != nil {
+		return err
+	}
 	i := 0
-	migs, _ := getAllMigrations()
+	migs, _ := o.getAllMigrations()
 	for _, v := range sm {
-		if _, ok := migs[v.name]; !ok {
+		status, ok := migs[v.name]
+		if !ok || all || target != "" && status != "update" {
 			logs.Info("start upgrade", v.name)
 			v.m.Reset()
 			v.m.Up()
-			err := v.m.Exec(v.name, "up")
+			err := o.exec(v.m, v.name, "up")
 			if err != nil {
 				logs.Error("execute error:", err)
-				time.Sleep(2 * time.Second)
+				o.sleep(2)
 				return err
 			}
 			logs.Info("end upgrade:", v.name)
 			i++
 		}
+		if v.name == target {
+			break
+		}
 	}
 	logs.Info("total success upgrade:", i, " migration")
-	time.Sleep(2 * time.Second)
+	o.sleep(2)
 	return nil
 }
 
 // Rollback rollback the migration by the name
-func Rollback(name string) error {
+func Rollback(name string, opts ...Option) error {
+	o := newOptions(opts)
+	return o.run(func() error {
+		return rollback(o, name)
+	})
+}
+
+func rollback(o *options, name string) error {
 	if v, ok := migrationMap[name]; ok {
 		logs.Info("start rollback")
 		v.Reset()
 		v.Down()
-		err := v.Exec(name, "down")
+		err := o.exec(v, name, "down")
 		if err != nil {
 			logs.Error("execute error:", err)
-			time.Sleep(2 * time.Second)
+			o.sleep(2)
 			return err
 		}
 		logs.Info("end rollback")
-		time.Sleep(2 * time.Second)
+		o.sleep(2)
 		return nil
 	}
 	logs.Error("not exist the migrationMap name:" + name)
-	time.Sleep(2 * time.Second)
+	o.sleep(2)
 	return errors.New("not exist the migrationMap name:" + name)
 }
 
 // Reset reset all migration
 // run all migration's down function
-func Reset() error {
+func Reset(opts ...Option) error {
+	o := newOptions(opts)
+	return o.run(func() error {
+		return reset(o)
+	})
+}
+
+func reset(o *options) error {
 	sm := sortMap(migrationMap)
 	i := 0
 	for j := len(sm) - 1; j >= 0; j-- {
 		v := sm[j]
-		if isRollBack(v.name) {
+		if o.isRollBack(v.name) {
 			logs.Info("skip the", v.name)
-			time.Sleep(1 * time.Second)
+			o.sleep(1)
 			continue
 		}
 		logs.Info("start reset:", v.name)
 		v.m.Reset()
 		v.m.Down()
-		err := v.m.Exec(v.name, "down")
+		err := o.exec(v.m, v.name, "down")
 		if err != nil {
 			logs.Error("execute error:", err)
-			time.Sleep(2 * time.Second)
+			o.sleep(2)
 			return err
 		}
 		i++
 		logs.Info("end reset:", v.name)
 	}
 	logs.Info("total success reset:", i, " migration")
-	time.Sleep(2 * time.Second)
+	o.sleep(2)
 	return nil
 }
 
 // Refresh first Reset, then Upgrade
-func Refresh() error {
-	err := Reset()
-	if err != nil {
-		logs.Error("execute error:", err)
-		time.Sleep(2 * time.Second)
+func Refresh(opts ...Option) error {
+	o := newOptions(opts)
+	return o.run(func() error {
+		err := reset(o)
+		if err != nil {
+			logs.Error("execute error:", err)
+			o.sleep(2)
+			return err
+		}
+		// in dry run mode the reset is not executed, so all the migrations are printed
+		return upgrade(o, o.dryRun != nil, "")
+	})
+}
+
+// RollbackSteps rollback the last steps applied migrations in the reverse order
+func RollbackSteps(steps int, opts ...Option) error {
+	o := newOptions(opts)
+	return o.run(func() error {
+		_, err := rollbackSteps(o, steps, "")
 		return err
+	})
+}
+
+// rollbackSteps rollback at most steps applied migrations after target in the reverse order,
+// the steps are not limited if it's negative. It returns the rolled back migrations in the order of them.
+func rollbackSteps(o *options, steps int, target string) (dataSlice, error) {
+	migs, err := o.getAllMigrations()
+	if err != nil {
+		return nil, err
+	}
+	sm := sortMap(migrationMap)
+	var done dataSlice
+	for j := len(sm) - 1; j >= 0 && steps != 0; j-- {
+		v := sm[j]
+		if v.name == target {
+			break
+		}
+		if migs[v.name] != "update" {
+			continue
+		}
+		logs.Info("start rollback:", v.name)
+		v.m.Reset()
+		v.m.Down()
+		if err := o.exec(v.m, v.name, "down"); err != nil {
+			logs.Error("execute error:", err)
+			return nil, err
+		}
+		logs.Info("end rollback:", v.name)
+		done = append(dataSlice{v}, done...)
+		steps--
+	}
+	return done, nil
+}
+
+// Redo rollback the last steps applied migrations and apply them again,
+// the checksums are not checked, so the modified migrations can be applied again.
+func Redo(steps int, opts ...Option) error {
+	o := newOptions(opts)
+	return o.run(func() error {
+		done, err := rollbackSteps(o, steps, "")
+		if err != nil {
+			return err
+		}
+		for _, v := range done {
+			logs.Info("start upgrade", v.name)
+			v.m.Reset()
+			v.m.Up()
+			if err := o.exec(v.m, v.name, "up"); err != nil {
+				logs.Error("execute error:", err)
+				return err
+			}
+			logs.Info("end upgrade:", v.name)
+		}
+		return nil
+	})
+}
+
+// MigrateTo rollback the applied migrations after the migration name,
+// and apply the migrations up to it which are not applied.
+func MigrateTo(name string, opts ...Option) error {
+	if _, ok := migrationMap[name]; !ok {
+		return errors.New("not exist the migrationMap name:" + name)
+	}
+	o := newOptions(opts)
+	return o.run(func() error {
+		if _, err := rollbackSteps(o, -1, name); err != nil {
+			return err
+		}
+		return upgrade(o, false, name)
+	})
+}
+
+// State is the state of a migration, the migrations are applied, pending or rolled back,
+// and the missing ones are recorded in the migrations table but not registered.
+type State struct {
+	Name   string
+	Status string
+	// AppliedAt is the time of applying or rolling back the migration
+	AppliedAt string
+}
+
+// the statuses of State
+const (
+	StatusApplied    = "applied"
+	StatusPending    = "pending"
+	StatusRolledBack = "rolled back"
+	StatusMissing    = "missing"
+)
+
+// Status return the states of the registered migrations in order, and then the missing migrations
+func Status(opts ...Option) ([]State, error) {
+	o := newOptions(opts)
+	records, err := o.getRecords()
+	if err != nil {
+		return nil, err
+	}
+	sm := sortMap(migrationMap)
+	states := make([]State, 0, len(sm))
+	for _, v := range sm {
+		r, ok := records[v.name]
+		state := State{Name: v.name, Status: StatusPending, AppliedAt: r.createdAt}
+		switch {
+		case !ok:
+		case r.status == "update":
+			state.Status = StatusApplied
+		case r.status == "rollback":
+			state.Status = StatusRolledBack
+		}
+		states = append(states, state)
+	}
+	var missing []string
+	for name := range records {
+		if _, ok := migrationMap[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	sort.Strings(missing)
+	for _, name := range missing {
+		states = append(states, State{Name: name, Status: StatusMissing, AppliedAt: records[name].createdAt})
+	}
+	return states, nil
+}
+
+// sleep pauses n units of the pause, it is skipped in dry run mode
+func (o *options) sleep(n int) {
+	if o.dryRun == nil && o.pause > 0 {
+		time.Sleep(time.Duration(n) * o.pause)
 	}
-	err = Upgrade(0)
-	return err
 }
 
 type dataSlice []data
@@ -291,37 +583,61 @@ func sortMap(m map[string]Migrationer) dataSlice {
 	return s
 }
 
-func isRollBack(name string) bool {
-	o := orm.NewOrm()
+func (o *options) isRollBack(name string) bool {
+	return o.getStatus(name) == "rollback"
+}
+
+// getStatus return the status of the latest record of the migration, it's empty if the migration is never applied
+func (o *options) getStatus(name string) string {
 	var maps []orm.Params
-	num, err := o.Raw("select * from migrations where `name` = ? order by id_migration desc", name).Values(&maps)
+	num, err := o.ormer().Raw("select * from migrations where `name` = ? order by id_migration desc", name).Values(&maps)
 	if err != nil {
 		logs.Info("get name has error", err)
-		return false
+		return ""
 	}
 	if num <= 0 {
-		return false
+		return ""
 	}
-	if maps[0]["status"] == "rollback" {
-		return true
+	status, _ := maps[0]["status"].(string)
+	return status
+}
+
+// getAllMigrations return the status of the latest record of each migration by name
+func (o *options) getAllMigrations() (map[string]string, error) {
+	records, err := o.getRecords()
+	migs := make(map[string]string, len(records))
+	for name, r := range records {
+		migs[name] = r.status
 	}
-	return false
+	return migs, err
 }
 
-func getAllMigrations() (map[string]string, error) {
-	o := orm.NewOrm()
+// record is the latest record of a migration in the migrations table
+type record struct {
+	status    string
+	createdAt string
+}
+
+// getRecords return the latest record of each migration by name
+func (o *options) getRecords() (map[string]record, error) {
 	var maps []orm.Params
-	migs := make(map[string]string)
-	num, err := o.Raw("select * from migrations order by id_migration desc").Values(&maps)
+	records := make(map[string]record)
+	_, err := o.ormer().Raw("select name, status, created_at from migrations order by id_migration desc").Values(&maps)
 	if err != nil {
 		logs.Info("get name has error", err)
-		return migs, err
+		return records, err
 	}
-	if num > 0 {
-		for _, v := range maps {
-			name := v["name"].(string)
-			migs[name] = v["status"].(string)
+	for _, v := range maps {
+		name, _ := v["name"].(string)
+		if _, ok := records[name]; ok {
+			continue
+		}
+		r := record{}
+		r.status, _ = v["status"].(string)
+		if v["created_at"] != nil {
+			r.createdAt = fmt.Sprint(v["created_at"])
 		}
+		records[name] = r
 	}
-	return migs, nil
+	return records, nil
 }
@@ -0,0 +1,230 @@
+// Copyright 2023 beego. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migration
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+func init() {
+	// no pause after the migrations in the tests
+	DefaultPause = 0
+}
+
+type testMigration struct {
+	Migration
+	up string
+}
+
+func (m *testMigration) Up() {
+	m.SQL(m.up)
+}
+
+func (m *testMigration) Down() {
+	m.SQL("DROP TABLE migration_test_entity")
+}
+
+func TestMigrationChecksumAndDryRun(t *testing.T) {
+	require.NoError(t, orm.RegisterDataBase("default", "sqlite3", filepath.Join(t.TempDir(), "migration.db")))
+	_, err := orm.NewOrm().Raw("CREATE TABLE migrations (id_migration integer NOT NULL PRIMARY KEY AUTOINCREMENT, " +
+		"name varchar(255), created_at timestamp, statements text, rollback_statements text, status varchar(10))").Exec()
+	require.NoError(t, err)
+	migrationMap = make(map[string]Migrationer)
+	defer func() {
+		migrationMap = make(map[string]Migrationer)
+	}()
+
+	m := &testMigration{up: "CREATE TABLE migration_test_entity (id integer)"}
+	m.Created = "20230102_030405"
+	require.NoError(t, Register("CreateEntity_20230102_030405", m))
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, Upgrade(0, WithDryRun(buf)))
+	assert.Equal(t, "-- up CreateEntity_20230102_030405\nCREATE TABLE migration_test_entity (id integer)\n", buf.String())
+	migs, err := newOptions(nil).getAllMigrations()
+	require.NoError(t, err)
+	assert.Empty(t, migs)
+
+	require.NoError(t, Upgrade(0))
+	checksums, err := newOptions(nil).getAppliedChecksums()
+	require.NoError(t, err)
+	assert.Equal(t, checksum([]string{m.up}), checksums["CreateEntity_20230102_030405"])
+
+	buf.Reset()
+	require.NoError(t, Rollback("CreateEntity_20230102_030405", WithDryRun(buf)))
+	assert.Equal(t, "-- down CreateEntity_20230102_030405\nDROP TABLE migration_test_entity\n", buf.String())
+
+	m.up = "CREATE TABLE migration_test_entity (id integer, name text)"
+	err = Upgrade(0)
+	assert.True(t, errors.Is(err, ErrChecksumMismatch), err)
+
+	// the checksums can't be verified if the migrations table is broken
+	_, err = orm.NewOrm().Raw("ALTER TABLE migrations RENAME COLUMN status TO state").Exec()
+	require.NoError(t, err)
+	err = Upgrade(0)
+	assert.NotNil(t, err)
+	assert.False(t, errors.Is(err, ErrChecksumMismatch), err)
+}
+
+func TestSqliteLocker(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lock.db")
+	db, err := sql.Open("sqlite3", path)
+	require.NoError(t, err)
+	defer db.Close()
+	require.NoError(t, db.Ping())
+
+	l1 := newLocker(db, orm.DRSqlite)
+	require.NoError(t, l1.lock(context.Background()))
+	_, err = os.Stat(path + "-migration.lock")
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	l2 := newLocker(db, orm.DRSqlite)
+	assert.Equal(t, ErrLockTimeout, l2.lock(ctx))
+
+	require.NoError(t, l1.unlock())
+	require.NoError(t, l2.lock(context.Background()))
+	require.NoError(t, l2.unlock())
+
+	// the lock file left by a crashed process is broken
+	require.NoError(t, os.WriteFile(path+"-migration.lock", []byte("1 2023-01-02T03:04:05Z\n"), 0o600))
+	stale := time.Now().Add(-2 * staleLockAge)
+	require.NoError(t, os.Chtimes(path+"-migration.lock", stale, stale))
+	l3 := newLocker(db, orm.DRSqlite)
+	require.NoError(t, l3.lock(context.Background()))
+
+	// the fresh lock file which replaced the stale one is kept
+	sl := &sqliteLocker{path: path + "-migration.lock"}
+	sl.breakStale()
+	_, err = os.Stat(path + "-migration.lock")
+	assert.NoError(t, err)
+	require.NoError(t, l3.unlock())
+
+	// the break file left by a crashed waiter is removed
+	require.NoError(t, os.WriteFile(path+"-migration.lock", []byte("1 2023-01-02T03:04:05Z\n"), 0o600))
+	require.NoError(t, os.Chtimes(path+"-migration.lock", stale, stale))
+	require.NoError(t, os.WriteFile(path+"-migration.lock.break", nil, 0o600))
+	require.NoError(t, os.Chtimes(path+"-migration.lock.break", stale, stale))
+	l4 := newLocker(db, orm.DRSqlite)
+	require.NoError(t, l4.lock(context.Background()))
+	require.NoError(t, l4.unlock())
+	_, err = os.Stat(path + "-migration.lock.break")
+	assert.True(t, os.IsNotExist(err))
+}
+
+type stepMigration struct {
+	Migration
+	table string
+}
+
+func (m *stepMigration) Up() {
+	m.SQL("CREATE TABLE " + m.table + " (id integer)")
+}
+
+func (m *stepMigration) Down() {
+	m.SQL("DROP TABLE " + m.table)
+}
+
+func TestMigrateCommand(t *testing.T) {
+	require.NoError(t, orm.RegisterDataBase("migrate", "sqlite3", filepath.Join(t.TempDir(), "migrate.db")))
+	o := orm.NewOrmUsingDB("migrate")
+	_, err := o.Raw("CREATE TABLE migrations (id_migration integer NOT NULL PRIMARY KEY AUTOINCREMENT, " +
+		"name varchar(255), created_at timestamp, statements text, rollback_statements text, status varchar(10))").Exec()
+	require.NoError(t, err)
+	migrationMap = make(map[string]Migrationer)
+	defer func() {
+		migrationMap = make(map[string]Migrationer)
+	}()
+
+	names := []string{"CreateA_20230101_000000", "CreateB_20230102_000000", "CreateC_20230103_000000"}
+	for i, table := range []string{"step_a", "step_b", "step_c"} {
+		m := &stepMigration{table: table}
+		m.Created = names[i][len(names[i])-len(DateFormat):]
+		require.NoError(t, Register(names[i], m))
+	}
+	run := func(args ...string) string {
+		buf := &bytes.Buffer{}
+		cmd := &commandMigrate{out: buf}
+		cmd.Parse(args)
+		require.NoError(t, cmd.Run())
+		return buf.String()
+	}
+	statuses := func() []string {
+		states, err := Status(WithAlias("migrate"))
+		require.NoError(t, err)
+		var res []string
+		for _, s := range states {
+			res = append(res, s.Status)
+		}
+		return res
+	}
+	tables := func() []string {
+		var res []string
+		_, err := o.Raw("SELECT name FROM sqlite_master WHERE type = 'table' AND name LIKE 'step_%' ORDER BY name").QueryRows(&res)
+		require.NoError(t, err)
+		return res
+	}
+
+	assert.Equal(t, []string{StatusPending, StatusPending, StatusPending}, statuses())
+	run("up", "-db", "migrate")
+	assert.Equal(t, []string{StatusApplied, StatusApplied, StatusApplied}, statuses())
+	assert.Equal(t, []string{"step_a", "step_b", "step_c"}, tables())
+
+	run("down", "-db", "migrate", "-n", "2")
+	assert.Equal(t, []string{StatusApplied, StatusRolledBack, StatusRolledBack}, statuses())
+	assert.Equal(t, []string{"step_a"}, tables())
+
+	assert.Equal(t, "-- up CreateB_20230102_000000\nCREATE TABLE step_b (id integer)\n",
+		run("to", names[1], "-db", "migrate", "-dry-run"))
+	run("to", names[1], "-db", "migrate")
+	assert.Equal(t, []string{StatusApplied, StatusApplied, StatusRolledBack}, statuses())
+	assert.Equal(t, []string{"step_a", "step_b"}, tables())
+
+	run("redo", "-db", "migrate")
+	assert.Equal(t, []string{StatusApplied, StatusApplied, StatusRolledBack}, statuses())
+	assert.Equal(t, []string{"step_a", "step_b"}, tables())
+
+	run("to", names[0], "-db", "migrate")
+	assert.Equal(t, []string{StatusApplied, StatusRolledBack, StatusRolledBack}, statuses())
+
+	// up skips the rolled back migrations
+	run("up", "-db", "migrate")
+	assert.Equal(t, []string{StatusApplied, StatusRolledBack, StatusRolledBack}, statuses())
+	assert.Equal(t, []string{"step_a"}, tables())
+
+	delete(migrationMap, names[2])
+	out := run("status", "-db", "migrate")
+	assert.Contains(t, out, "CreateA_20230101_000000  applied")
+	assert.Contains(t, out, "CreateB_20230102_000000  rolled back")
+	assert.Contains(t, out, "CreateC_20230103_000000  missing")
+
+	cmd := &commandMigrate{out: &bytes.Buffer{}}
+	cmd.Parse([]string{"sideways"})
+	assert.NotNil(t, cmd.Run())
+}
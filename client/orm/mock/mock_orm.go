@@ -92,6 +92,16 @@ func MockInsertOrUpdateWithCtx(tableName string, id int64, err error) *Mock {
 	return NewMock(NewSimpleCondition(tableName, "InsertOrUpdateWithCtx"), []interface{}{id, err}, nil)
 }
 
+// MockInsertOrUpdateMultiWithCtx support InsertOrUpdateMulti and InsertOrUpdateMultiWithCtx
+func MockInsertOrUpdateMultiWithCtx(tableName string, cnt int64, err error) *Mock {
+	return NewMock(NewSimpleCondition(tableName, "InsertOrUpdateMultiWithCtx"), []interface{}{cnt, err}, nil)
+}
+
+// MockUpdateMultiWithCtx support UpdateMulti and UpdateMultiWithCtx
+func MockUpdateMultiWithCtx(tableName string, affectedRow int64, err error) *Mock {
+	return NewMock(NewSimpleCondition(tableName, "UpdateMultiWithCtx"), []interface{}{affectedRow, err}, nil)
+}
+
 // MockUpdateWithCtx support UpdateWithCtx and Update
 func MockUpdateWithCtx(tableName string, affectedRow int64, err error) *Mock {
 	return NewMock(NewSimpleCondition(tableName, "UpdateWithCtx"), []interface{}{affectedRow, err}, nil)
@@ -120,6 +120,28 @@ func TestMockInsertMultiWithCtx(t *testing.T) {
 	assert.Equal(t, mock, err)
 }
 
+func TestMockInsertOrUpdateMultiWithCtx(t *testing.T) {
+	s := StartMock()
+	defer s.Clear()
+	mock := errors.New(mockErrorMsg)
+	s.Mock(MockInsertOrUpdateMultiWithCtx((&User{}).TableName(), 12, mock))
+	o := orm.NewOrm()
+	res, err := o.InsertOrUpdateMulti(11, []interface{}{&User{}}, nil, nil)
+	assert.Equal(t, int64(12), res)
+	assert.Equal(t, mock, err)
+}
+
+func TestMockUpdateMultiWithCtx(t *testing.T) {
+	s := StartMock()
+	defer s.Clear()
+	mock := errors.New(mockErrorMsg)
+	s.Mock(MockUpdateMultiWithCtx((&User{}).TableName(), 12, mock))
+	o := orm.NewOrm()
+	res, err := o.UpdateMulti(11, []interface{}{&User{}})
+	assert.Equal(t, int64(12), res)
+	assert.Equal(t, mock, err)
+}
+
 func TestMockInsertWithCtx(t *testing.T) {
 	s := StartMock()
 	defer s.Clear()
@@ -16,6 +16,7 @@ package mock
 
 import (
 	"context"
+	"time"
 
 	"github.com/beego/beego/v2/client/orm"
 	"github.com/beego/beego/v2/client/orm/clauses/order_clause"
@@ -57,6 +58,30 @@ func (d *DoNothingQuerySetter) OneWithCtx(ctx context.Context, container interfa
 	return nil
 }
 
+func (d *DoNothingQuerySetter) Prefetch(params ...interface{}) orm.QuerySeter {
+	return d
+}
+
+func (d *DoNothingQuerySetter) Cache(ttl time.Duration) orm.QuerySeter {
+	return d
+}
+
+func (d *DoNothingQuerySetter) Iterate(fn func(md interface{}) error, cols ...string) (int64, error) {
+	return 0, nil
+}
+
+func (d *DoNothingQuerySetter) IterateWithCtx(ctx context.Context, fn func(md interface{}) error, cols ...string) (int64, error) {
+	return 0, nil
+}
+
+func (d *DoNothingQuerySetter) Chunk(size int, fn func(container interface{}) error) error {
+	return nil
+}
+
+func (d *DoNothingQuerySetter) ChunkWithCtx(ctx context.Context, size int, fn func(container interface{}) error) error {
+	return nil
+}
+
 func (d *DoNothingQuerySetter) ValuesWithCtx(ctx context.Context, results *[]orm.Params, exprs ...string) (int64, error) {
 	return 0, nil
 }
@@ -73,6 +98,14 @@ func (d *DoNothingQuerySetter) Aggregate(s string) orm.QuerySeter {
 	return d
 }
 
+func (d *DoNothingQuerySetter) Annotate(aggs ...orm.Aggregation) orm.QuerySeter {
+	return d
+}
+
+func (d *DoNothingQuerySetter) Having(expr string, args ...interface{}) orm.QuerySeter {
+	return d
+}
+
 func (d *DoNothingQuerySetter) Filter(s string, i ...interface{}) orm.QuerySeter {
 	return d
 }
@@ -133,6 +166,18 @@ func (d *DoNothingQuerySetter) ForUpdate() orm.QuerySeter {
 	return d
 }
 
+func (d *DoNothingQuerySetter) WithDeleted() orm.QuerySeter {
+	return d
+}
+
+func (d *DoNothingQuerySetter) OnlyDeleted() orm.QuerySeter {
+	return d
+}
+
+func (d *DoNothingQuerySetter) HardDelete() orm.QuerySeter {
+	return d
+}
+
 func (d *DoNothingQuerySetter) Count() (int64, error) {
 	return 0, nil
 }
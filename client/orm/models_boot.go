@@ -15,6 +15,7 @@
 package orm
 
 import (
+	"context"
 	"fmt"
 	"runtime/debug"
 
@@ -42,6 +43,38 @@ func RegisterModelWithSuffix(suffix string, models ...interface{}) {
 	}
 }
 
+// LookupModel returns the registered model by the table name, the full name or the name of the struct,
+// or by the model itself if md is not a string. It is used by the packages of orm, such as fixtures.
+func LookupModel(md interface{}) (*imodels.ModelInfo, bool) {
+	name, ok := md.(string)
+	if !ok {
+		return defaultModelCache.GetByMd(md)
+	}
+	if mi, ok := defaultModelCache.Get(name); ok {
+		return mi, true
+	}
+	if mi, ok := defaultModelCache.GetByFullName(name); ok {
+		return mi, true
+	}
+	for _, mi := range defaultModelCache.AllOrdered() {
+		if mi.Name == name {
+			return mi, true
+		}
+	}
+	return nil, false
+}
+
+// ModelTables returns the tables of the model in the database alias for the tenant resolved from ctx,
+// which are the tables of the shards in the alias if the model is sharded.
+func ModelTables(ctx context.Context, aliasName string, mi *imodels.ModelInfo) []string {
+	al := tenantAlias(ctx, getDbAlias(aliasName))
+	var tables []string
+	for _, sal := range shardAliases(al, mi) {
+		tables = append(tables, sal.DbBaser.TableName(mi))
+	}
+	return tables
+}
+
 // BootStrap Bootstrap models.
 // make All model parsed and can not add more models
 func BootStrap() {
@@ -15,6 +15,9 @@
 package orm
 
 import (
+	"fmt"
+	"reflect"
+
 	"github.com/beego/beego/v2/client/orm/internal/models"
 )
 
@@ -171,3 +174,139 @@ type JsonbField = models.JsonbField
 
 // verify JsonbField implement Fielder
 var _ models.Fielder = new(JsonbField)
+
+// DecimalField An exact decimal number, represented in go by its string such as "12.30".
+// required values tag: digits and decimals, eg: `orm:"digits(12);decimals(4)"`
+// The empty value is NULL, so the field should have the null tag if it may be empty.
+type DecimalField = models.DecimalField
+
+// verify DecimalField implement Fielder
+var _ Fielder = new(DecimalField)
+
+// UUIDField A UUID, uuid column of postgres and char(36) of the others.
+type UUIDField = models.UUIDField
+
+// verify UUIDField implement Fielder
+var _ Fielder = new(UUIDField)
+
+// IPAddrField An IPv4 or IPv6 address represented in go by a netip.Addr,
+// inet column of postgres and varchar(45) of the others.
+type IPAddrField = models.IPAddrField
+
+// verify IPAddrField implement Fielder
+var _ Fielder = new(IPAddrField)
+
+// DateTimeTZField A time with zone represented in go by a time.Time instance,
+// "timestamp with time zone" column of postgres, which keeps the instant of the time,
+// and the RFC 3339 text of the others, which keeps the offset of the time too.
+type DateTimeTZField = models.DateTimeTZField
+
+// verify DateTimeTZField implement Fielder
+var _ Fielder = new(DateTimeTZField)
+
+// ArrayFielder is the Fielder of the array columns, whose RawValue is the array literal of postgres.
+// The fields of the registered ArrayFielder support the filter operators contains and overlap on postgres.
+type ArrayFielder = models.ArrayFielder
+
+// StringArrayField A text array of postgres, represented in go by a []string.
+// It is stored as the array literal in a text column of the others.
+type StringArrayField = models.StringArrayField
+
+// verify StringArrayField implement ArrayFielder
+var _ ArrayFielder = new(StringArrayField)
+
+// IntArrayField A bigint array of postgres, represented in go by a []int64.
+// It is stored as the array literal in a text column of the others.
+type IntArrayField = models.IntArrayField
+
+// verify IntArrayField implement ArrayFielder
+var _ ArrayFielder = new(IntArrayField)
+
+func init() {
+	RegisterFieldType(new(DecimalField), nil)
+	RegisterFieldType(new(UUIDField), map[DriverType]string{
+		DRPostgres: "uuid",
+		DRMySQL:    "char(36)",
+		DRTiDB:     "char(36)",
+		DRSqlite:   "char(36)",
+	})
+	RegisterFieldType(new(IPAddrField), map[DriverType]string{
+		DRPostgres: "inet",
+		DRMySQL:    "varchar(45)",
+		DRTiDB:     "varchar(45)",
+		DRSqlite:   "varchar(45)",
+	})
+	RegisterFieldType(new(DateTimeTZField), map[DriverType]string{
+		DRPostgres: "timestamp with time zone",
+		DRMySQL:    "varchar(40)",
+		DRTiDB:     "varchar(40)",
+		DRSqlite:   "varchar(40)",
+	})
+	RegisterFieldType(new(StringArrayField), map[DriverType]string{DRPostgres: "text[]"})
+	RegisterFieldType(new(IntArrayField), map[DriverType]string{DRPostgres: "bigint[]"})
+}
+
+// the column types of the registered Fielder types in the drivers
+var fieldTypes = map[reflect.Type]map[DriverType]string{}
+
+// RegisterFieldType registers the Fielder type with its column types in the drivers, eg:
+//
+//	orm.RegisterFieldType(new(Money), map[orm.DriverType]string{orm.DRPostgres: "money"})
+//
+// The column types of the other drivers are decided by the FieldType of the Fielder,
+// and %COL% in the column types is replaced by the column name.
+// The values of the database are set to the registered Fielder by SetRaw as they are returned by the driver,
+// so it should accept []byte, string, int64, float64, time.Time and nil,
+// and the filter arguments of its fields are converted to the RawValue by SetRaw.
+func RegisterFieldType(field Fielder, dbTypes map[DriverType]string) {
+	typ := reflect.TypeOf(field)
+	if typ == nil || typ.Kind() != reflect.Ptr {
+		panic(fmt.Errorf("<orm.RegisterFieldType> the Fielder must be a pointer, such as new(%T)", field))
+	}
+	types := make(map[DriverType]string, len(dbTypes))
+	for driver, dbType := range dbTypes {
+		types[driver] = dbType
+	}
+	fieldTypes[typ] = types
+}
+
+// isRegisteredFielder checks whether the field is a Fielder registered by RegisterFieldType
+func isRegisteredFielder(fi *models.FieldInfo) bool {
+	if !fi.IsFielder {
+		return false
+	}
+	_, ok := fieldTypes[fi.AddrValue.Type()]
+	return ok
+}
+
+// getFielderDbType returns the column type of the registered Fielder in the driver of the alias
+func getFielderDbType(al *alias, fi *models.FieldInfo) string {
+	if !fi.IsFielder {
+		return ""
+	}
+	return fieldTypes[fi.AddrValue.Type()][al.Driver]
+}
+
+// isArrayField checks whether the field is a registered ArrayFielder
+func isArrayField(fi *models.FieldInfo) bool {
+	if !isRegisteredFielder(fi) {
+		return false
+	}
+	_, ok := fi.AddrValue.Interface().(ArrayFielder)
+	return ok
+}
+
+// getFielderArg converts the filter argument of the registered Fielder field to the RawValue of the Fielder
+func getFielderArg(fi *models.FieldInfo, arg interface{}) (interface{}, error) {
+	if f, ok := arg.(Fielder); ok {
+		return f.RawValue(), nil
+	}
+	typ := fi.AddrValue.Type().Elem()
+	f := reflect.New(typ)
+	if val := reflect.ValueOf(arg); val.IsValid() && val.Type() == typ {
+		f.Elem().Set(val)
+	} else if err := f.Interface().(Fielder).SetRaw(arg); err != nil {
+		return nil, err
+	}
+	return f.Interface().(Fielder).RawValue(), nil
+}
@@ -15,8 +15,10 @@
 package orm
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"strings"
@@ -473,6 +475,134 @@ type StrPk struct {
 	Value string
 }
 
+type SoftDeleteUser struct {
+	Id        int
+	Name      string
+	DeletedAt time.Time         `orm:"null;soft_delete"`
+	Posts     []*SoftDeletePost `orm:"reverse(many)"`
+}
+
+type VersionedPost struct {
+	Id      int
+	Title   string
+	Version uint `orm:"version"`
+}
+
+type SoftDeletePost struct {
+	Id        int
+	Title     string
+	User      *SoftDeleteUser `orm:"rel(fk)"`
+	DeletedAt *time.Time      `orm:"null;soft_delete"`
+}
+
+type HookedPost struct {
+	Id    int
+	Title string
+	Calls []string `orm:"-"`
+}
+
+func (p *HookedPost) BeforeInsert(ctx context.Context, o QueryExecutor) error {
+	if p.Title == "" {
+		return errors.New("title is required")
+	}
+	p.Calls = append(p.Calls, "BeforeInsert")
+	return nil
+}
+
+func (p *HookedPost) AfterInsert(ctx context.Context, o QueryExecutor) error {
+	p.Calls = append(p.Calls, "AfterInsert")
+	_, err := o.InsertWithCtx(ctx, &HookLog{Post: p.Id, Action: "insert"})
+	return err
+}
+
+func (p *HookedPost) BeforeUpdate(ctx context.Context, o QueryExecutor) error {
+	p.Calls = append(p.Calls, "BeforeUpdate")
+	return nil
+}
+
+func (p *HookedPost) AfterUpdate(ctx context.Context, o QueryExecutor) error {
+	p.Calls = append(p.Calls, "AfterUpdate")
+	return nil
+}
+
+func (p *HookedPost) BeforeDelete(ctx context.Context, o QueryExecutor) error {
+	p.Calls = append(p.Calls, "BeforeDelete")
+	return nil
+}
+
+func (p *HookedPost) AfterDelete(ctx context.Context, o QueryExecutor) error {
+	p.Calls = append(p.Calls, "AfterDelete")
+	_, err := o.InsertWithCtx(ctx, &HookLog{Post: p.Id, Action: "delete"})
+	return err
+}
+
+func (p *HookedPost) AfterRead(ctx context.Context, o QueryExecutor) error {
+	p.Calls = append(p.Calls, "AfterRead")
+	return nil
+}
+
+type Product struct {
+	Id      int
+	Code    string `orm:"size(32);unique"`
+	Name    string
+	Stock   int
+	Updated time.Time `orm:"auto_now;type(datetime)"`
+}
+
+// GroupMember is the join table of users and groups with payload,
+// the primary key is composed of the user and the group.
+type GroupMember struct {
+	User   *User     `orm:"rel(fk)"`
+	Group  *Group    `orm:"rel(fk)"`
+	Role   string    `orm:"size(20)"`
+	Joined time.Time `orm:"auto_now_add;type(datetime)"`
+}
+
+func (m *GroupMember) TablePrimaryKey() []string {
+	return []string{"User", "Group"}
+}
+
+type JSONDoc struct {
+	Id    int
+	Name  string
+	Attrs string `orm:"type(jsonb);null"`
+}
+
+type ExtendedDoc struct {
+	Id      int
+	Uid     UUIDField        `orm:"unique"`
+	Status  string           `orm:"size(20);enum(draft,published)"`
+	Amount  DecimalField     `orm:"digits(20);decimals(6)"`
+	IP      IPAddrField      `orm:"column(ip);null"`
+	Created DateTimeTZField  `orm:"null"`
+	Tags    StringArrayField `orm:"null"`
+	Scores  IntArrayField    `orm:"null"`
+}
+
+type ShardOrder struct {
+	Id     int64 `orm:"pk"`
+	UserId int64 `orm:"index"`
+	Amount int
+}
+
+type TrackedProfile struct {
+	Id      int
+	Name    string
+	Email   string
+	Age     int
+	Updated time.Time `orm:"auto_now;type(datetime)"`
+}
+
+func (p *TrackedProfile) TableTrackChanges() bool {
+	return true
+}
+
+type HookLog struct {
+	Id     int
+	Post   int
+	Action string
+}
+
 var DBARGS = struct {
 	Driver string
 	Source string
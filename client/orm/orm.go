@@ -85,6 +85,8 @@ var (
 	ErrStmtClosed    = errors.New("<QuerySeter> stmt already closed")
 	ErrArgs          = errors.New("<Ormer> args error may be empty")
 	ErrNotImplement  = errors.New("have not implement")
+	ErrNestedTxOpts  = errors.New("<TxOrmer.Begin> nested transaction can't change the TxOptions")
+	ErrShardFanOut   = errors.New("<QuerySeter> the query cannot run on more than one shard, filter it by the sharding key")
 
 	ErrLastInsertIdUnavailable = errors.New("<Ormer> last insert id is unavailable")
 )
@@ -98,6 +100,8 @@ type ParamsList []interface{}
 type ormBase struct {
 	alias *alias
 	db    dbQuerier
+	// the ormBase of a shard keeps its alias, see RegisterSharding
+	sharded bool
 }
 
 var (
@@ -150,8 +154,17 @@ func (o *ormBase) Read(md interface{}, cols ...string) error {
 }
 
 func (o *ormBase) ReadWithCtx(ctx context.Context, md interface{}, cols ...string) error {
+	o, release := o.forTenant(ctx)
+	defer release()
 	mi, ind := o.getPtrMiInd(md)
-	return o.alias.DbBaser.Read(ctx, o.db, mi, ind, o.alias.TZ, cols, false)
+	o, err := o.forShard(mi, ind)
+	if err != nil {
+		return err
+	}
+	if err := o.readCached(ctx, mi, ind, cols); err != nil {
+		return err
+	}
+	return afterRead(ctx, o, mi, md)
 }
 
 // read data to model, like Read(), but use "SELECT FOR UPDATE" form
@@ -160,8 +173,18 @@ func (o *ormBase) ReadForUpdate(md interface{}, cols ...string) error {
 }
 
 func (o *ormBase) ReadForUpdateWithCtx(ctx context.Context, md interface{}, cols ...string) error {
+	o, release := o.forTenant(ctx)
+	defer release()
+	ctx = ForcePrimary(ctx)
 	mi, ind := o.getPtrMiInd(md)
-	return o.alias.DbBaser.Read(ctx, o.db, mi, ind, o.alias.TZ, cols, true)
+	o, err := o.forShard(mi, ind)
+	if err != nil {
+		return err
+	}
+	if err := o.alias.DbBaser.Read(ctx, o.db, mi, ind, o.alias.TZ, cols, true); err != nil {
+		return err
+	}
+	return afterRead(ctx, o, mi, md)
 }
 
 // Try to read a row from the database, or insert one if it doesn't exist
@@ -170,14 +193,27 @@ func (o *ormBase) ReadOrCreate(md interface{}, col1 string, cols ...string) (boo
 }
 
 func (o *ormBase) ReadOrCreateWithCtx(ctx context.Context, md interface{}, col1 string, cols ...string) (bool, int64, error) {
+	o, release := o.forTenant(ctx)
+	defer release()
+	ctx = ForcePrimary(ctx)
 	cols = append([]string{col1}, cols...)
 	mi, ind := o.getPtrMiInd(md)
-	err := o.alias.DbBaser.Read(ctx, o.db, mi, ind, o.alias.TZ, cols, false)
+	o, err := o.forShard(mi, ind)
+	if err != nil {
+		return false, 0, err
+	}
+	err = o.alias.DbBaser.Read(ctx, o.db, mi, ind, o.alias.TZ, cols, false)
 	if err == ErrNoRows {
 		// Create
 		id, err := o.InsertWithCtx(ctx, md)
 		return err == nil, id, err
 	}
+	if err == nil {
+		err = afterRead(ctx, o, mi, md)
+	}
+	if mi.Fields.Pk == nil {
+		return false, 0, err
+	}
 
 	id, vid := int64(0), ind.FieldByIndex(mi.Fields.Pk.FieldIndex)
 	if mi.Fields.Pk.FieldType&IsPositiveIntegerField > 0 {
@@ -197,15 +233,50 @@ func (o *ormBase) Insert(md interface{}) (int64, error) {
 }
 
 func (o *ormBase) InsertWithCtx(ctx context.Context, md interface{}) (int64, error) {
+	o, release := o.forTenant(ctx)
+	defer release()
+	ctx = ForcePrimary(ctx)
 	mi, ind := o.getPtrMiInd(md)
+	if err := o.beforeInsert(ctx, md); err != nil {
+		return 0, err
+	}
+	o, err := o.forShard(mi, ind)
+	if err != nil {
+		return 0, err
+	}
 	id, err := o.alias.DbBaser.Insert(ctx, o.db, mi, ind, o.alias.TZ)
 	if err != nil {
 		return id, err
 	}
 
 	o.setPk(mi, ind, id)
+	saveSnapshot(mi, ind)
 
-	return id, nil
+	return id, o.afterInsert(ctx, md)
+}
+
+func (o *ormBase) beforeInsert(ctx context.Context, md interface{}) error {
+	return callHook(md, func(h BeforeInserter) error {
+		return h.BeforeInsert(ctx, o)
+	})
+}
+
+func (o *ormBase) afterInsert(ctx context.Context, md interface{}) error {
+	return callHook(md, func(h AfterInserter) error {
+		return h.AfterInsert(ctx, o)
+	})
+}
+
+func (o *ormBase) beforeUpdate(ctx context.Context, md interface{}) error {
+	return callHook(md, func(h BeforeUpdater) error {
+		return h.BeforeUpdate(ctx, o)
+	})
+}
+
+func (o *ormBase) afterUpdate(ctx context.Context, md interface{}) error {
+	return callHook(md, func(h AfterUpdater) error {
+		return h.AfterUpdate(ctx, o)
+	})
 }
 
 // Set auto pk field
@@ -225,24 +296,29 @@ func (o *ormBase) InsertMulti(bulk int, mds interface{}) (int64, error) {
 }
 
 func (o *ormBase) InsertMultiWithCtx(ctx context.Context, bulk int, mds interface{}) (int64, error) {
+	o, release := o.forTenant(ctx)
+	defer release()
+	ctx = ForcePrimary(ctx)
 	var cnt int64
 
-	sind := reflect.Indirect(reflect.ValueOf(mds))
-
-	switch sind.Kind() {
-	case reflect.Array, reflect.Slice:
-		if sind.Len() == 0 {
-			return cnt, ErrArgs
-		}
-	default:
-		return cnt, ErrArgs
+	sind, err := o.getMultiInd(mds)
+	if err != nil {
+		return cnt, err
 	}
 
 	if bulk <= 1 {
 		for i := 0; i < sind.Len(); i++ {
 			ind := reflect.Indirect(sind.Index(i))
 			mi := o.getMi(ind.Interface())
-			id, err := o.alias.DbBaser.Insert(ctx, o.db, mi, ind, o.alias.TZ)
+			md := hookModel(sind.Index(i))
+			if err := o.beforeInsert(ctx, md); err != nil {
+				return cnt, err
+			}
+			ob, err := o.forShard(mi, ind)
+			if err != nil {
+				return cnt, err
+			}
+			id, err := ob.alias.DbBaser.Insert(ctx, ob.db, mi, ind, ob.alias.TZ)
 			if err != nil {
 				return cnt, err
 			}
@@ -250,21 +326,110 @@ func (o *ormBase) InsertMultiWithCtx(ctx context.Context, bulk int, mds interfac
 			o.setPk(mi, ind, id)
 
 			cnt++
+			if err := o.afterInsert(ctx, md); err != nil {
+				return cnt, err
+			}
 		}
 	} else {
+		for i := 0; i < sind.Len(); i++ {
+			if err := o.beforeInsert(ctx, hookModel(sind.Index(i))); err != nil {
+				return cnt, err
+			}
+		}
 		mi := o.getMi(sind.Index(0).Interface())
-		return o.alias.DbBaser.InsertMulti(ctx, o.db, mi, sind, bulk, o.alias.TZ)
+		orms, groups, err := o.shardModels(mi, sind)
+		if err != nil {
+			return cnt, err
+		}
+		for i, ob := range orms {
+			num, err := ob.alias.DbBaser.InsertMulti(ctx, ob.db, mi, groups[i], bulk, ob.alias.TZ)
+			cnt += num
+			if err != nil {
+				return cnt, err
+			}
+		}
+		// the pk of the models are not set in bulk insert
+		for i := 0; i < sind.Len(); i++ {
+			if err := o.afterInsert(ctx, hookModel(sind.Index(i))); err != nil {
+				return cnt, err
+			}
+		}
+		return cnt, nil
 	}
 	return cnt, nil
 }
 
+// InsertOrUpdateMulti insert some models or update them if conflict
+func (o *ormBase) InsertOrUpdateMulti(bulk int, mds interface{}, conflictCols []string, updateCols []string) (int64, error) {
+	return o.InsertOrUpdateMultiWithCtx(context.Background(), bulk, mds, conflictCols, updateCols)
+}
+
+func (o *ormBase) InsertOrUpdateMultiWithCtx(ctx context.Context, bulk int, mds interface{}, conflictCols []string, updateCols []string) (int64, error) {
+	o, release := o.forTenant(ctx)
+	defer release()
+	ctx = ForcePrimary(ctx)
+	sind, err := o.getMultiInd(mds)
+	if err != nil {
+		return 0, err
+	}
+	if bulk <= 0 {
+		bulk = sind.Len()
+	}
+	// the same hooks as UpdateMulti
+	for i := 0; i < sind.Len(); i++ {
+		if err := o.beforeUpdate(ctx, hookModel(sind.Index(i))); err != nil {
+			return 0, err
+		}
+	}
+	mi := o.getMi(sind.Index(0).Interface())
+	orms, groups, err := o.shardModels(mi, sind)
+	if err != nil {
+		return 0, err
+	}
+	var cnt int64
+	for i, ob := range orms {
+		num, err := ob.alias.DbBaser.InsertOrUpdateMulti(ctx, ob.db, mi, groups[i], bulk, ob.alias, conflictCols, updateCols)
+		cnt += num
+		if err != nil {
+			return cnt, err
+		}
+	}
+	for i := 0; i < sind.Len(); i++ {
+		if err := o.afterUpdate(ctx, hookModel(sind.Index(i))); err != nil {
+			return cnt, err
+		}
+	}
+	return cnt, nil
+}
+
+// getMultiInd return the value of the models slice, the slice must not be empty
+func (*ormBase) getMultiInd(mds interface{}) (reflect.Value, error) {
+	sind := reflect.Indirect(reflect.ValueOf(mds))
+	switch sind.Kind() {
+	case reflect.Array, reflect.Slice:
+		if sind.Len() == 0 {
+			return sind, ErrArgs
+		}
+	default:
+		return sind, ErrArgs
+	}
+	return sind, nil
+}
+
 // InsertOrUpdate data to database
 func (o *ormBase) InsertOrUpdate(md interface{}, colConflictAndArgs ...string) (int64, error) {
 	return o.InsertOrUpdateWithCtx(context.Background(), md, colConflictAndArgs...)
 }
 
 func (o *ormBase) InsertOrUpdateWithCtx(ctx context.Context, md interface{}, colConflitAndArgs ...string) (int64, error) {
+	o, release := o.forTenant(ctx)
+	defer release()
+	ctx = ForcePrimary(ctx)
 	mi, ind := o.getPtrMiInd(md)
+	o, err := o.forShard(mi, ind)
+	if err != nil {
+		return 0, err
+	}
 	id, err := o.alias.DbBaser.InsertOrUpdate(ctx, o.db, mi, ind, o.alias, colConflitAndArgs...)
 	if err != nil {
 		return id, err
@@ -282,8 +447,76 @@ func (o *ormBase) Update(md interface{}, cols ...string) (int64, error) {
 }
 
 func (o *ormBase) UpdateWithCtx(ctx context.Context, md interface{}, cols ...string) (int64, error) {
+	o, release := o.forTenant(ctx)
+	defer release()
+	ctx = ForcePrimary(ctx)
 	mi, ind := o.getPtrMiInd(md)
-	return o.alias.DbBaser.Update(ctx, o.db, mi, ind, o.alias.TZ, cols)
+	o, err := o.forShard(mi, ind)
+	if err != nil {
+		return 0, err
+	}
+	err = callHook(md, func(h BeforeUpdater) error {
+		return h.BeforeUpdate(ctx, o)
+	})
+	if err != nil {
+		return 0, err
+	}
+	// write the changed fields only if the model tracks changes
+	if len(cols) == 0 {
+		if changed, ok := getChangedFields(mi, ind); ok {
+			if len(changed) == 0 {
+				return 0, nil
+			}
+			cols = changed
+		}
+	}
+	num, err := o.alias.DbBaser.Update(ctx, o.db, mi, ind, o.alias.TZ, cols)
+	if err != nil {
+		return num, err
+	}
+	saveSnapshot(mi, ind)
+	return num, callHook(md, func(h AfterUpdater) error {
+		return h.AfterUpdate(ctx, o)
+	})
+}
+
+// UpdateMulti update the cols of some models by pk
+func (o *ormBase) UpdateMulti(bulk int, mds interface{}, cols ...string) (int64, error) {
+	return o.UpdateMultiWithCtx(context.Background(), bulk, mds, cols...)
+}
+
+func (o *ormBase) UpdateMultiWithCtx(ctx context.Context, bulk int, mds interface{}, cols ...string) (int64, error) {
+	o, release := o.forTenant(ctx)
+	defer release()
+	ctx = ForcePrimary(ctx)
+	sind, err := o.getMultiInd(mds)
+	if err != nil {
+		return 0, err
+	}
+	for i := 0; i < sind.Len(); i++ {
+		if err := o.beforeUpdate(ctx, hookModel(sind.Index(i))); err != nil {
+			return 0, err
+		}
+	}
+	mi := o.getMi(sind.Index(0).Interface())
+	orms, groups, err := o.shardModels(mi, sind)
+	if err != nil {
+		return 0, err
+	}
+	var num int64
+	for i, ob := range orms {
+		n, err := ob.alias.DbBaser.UpdateMulti(ctx, ob.db, mi, groups[i], bulk, ob.alias.TZ, cols)
+		num += n
+		if err != nil {
+			return num, err
+		}
+	}
+	for i := 0; i < sind.Len(); i++ {
+		if err := o.afterUpdate(ctx, hookModel(sind.Index(i))); err != nil {
+			return num, err
+		}
+	}
+	return num, nil
 }
 
 // delete model in database
@@ -293,9 +526,28 @@ func (o *ormBase) Delete(md interface{}, cols ...string) (int64, error) {
 }
 
 func (o *ormBase) DeleteWithCtx(ctx context.Context, md interface{}, cols ...string) (int64, error) {
+	o, release := o.forTenant(ctx)
+	defer release()
+	ctx = ForcePrimary(ctx)
 	mi, ind := o.getPtrMiInd(md)
+	o, err := o.forShard(mi, ind)
+	if err != nil {
+		return 0, err
+	}
+	err = callHook(md, func(h BeforeDeleter) error {
+		return h.BeforeDelete(ctx, o)
+	})
+	if err != nil {
+		return 0, err
+	}
 	num, err := o.alias.DbBaser.Delete(ctx, o.db, mi, ind, o.alias.TZ, cols)
-	return num, err
+	if err != nil {
+		return num, err
+	}
+	deleteSnapshot(mi, ind)
+	return num, callHook(md, func(h AfterDeleter) error {
+		return h.AfterDelete(ctx, o)
+	})
 }
 
 // create a models to models queryer
@@ -332,7 +584,9 @@ func (o *ormBase) LoadRelated(md interface{}, name string, args ...utils.KV) (in
 	return o.LoadRelatedWithCtx(context.Background(), md, name, args...)
 }
 
-func (o *ormBase) LoadRelatedWithCtx(_ context.Context, md interface{}, name string, args ...utils.KV) (int64, error) {
+func (o *ormBase) LoadRelatedWithCtx(ctx context.Context, md interface{}, name string, args ...utils.KV) (int64, error) {
+	o, release := o.forTenant(ctx)
+	defer release()
 	_, fi, ind, qs := o.queryRelated(md, name)
 
 	var relDepth int
@@ -384,13 +638,13 @@ func (o *ormBase) LoadRelatedWithCtx(_ context.Context, md interface{}, name str
 	case RelOneToOne, RelForeignKey, RelReverseOne:
 		val := reflect.New(find.Type().Elem())
 		container := val.Interface()
-		err = qs.One(container)
+		err = qs.OneWithCtx(ctx, container)
 		if err == nil {
 			find.Set(val)
 			nums = 1
 		}
 	default:
-		nums, err = qs.All(find.Addr().Interface())
+		nums, err = qs.AllWithCtx(ctx, find.Addr().Interface())
 	}
 
 	return nums, err
@@ -401,7 +655,7 @@ func (o *ormBase) queryRelated(md interface{}, name string) (*models.ModelInfo,
 	mi, ind := o.getPtrMiInd(md)
 	fi := o.getFieldInfo(mi, name)
 
-	_, _, exist := getExistPk(mi, ind)
+	_, _, exist := getExistPks(mi, ind)
 	if !exist {
 		panic(ErrMissPK)
 	}
@@ -502,8 +756,8 @@ func (o *ormBase) Raw(query string, args ...interface{}) RawSeter {
 	return o.RawWithCtx(context.Background(), query, args...)
 }
 
-func (o *ormBase) RawWithCtx(_ context.Context, query string, args ...interface{}) RawSeter {
-	return newRawSet(o, query, args)
+func (o *ormBase) RawWithCtx(ctx context.Context, query string, args ...interface{}) RawSeter {
+	return newRawSet(ctx, o, query, args)
 }
 
 // Driver return current using database Driver
@@ -539,20 +793,33 @@ func (o *orm) BeginWithOpts(opts *sql.TxOptions) (TxOrmer, error) {
 }
 
 func (o *orm) BeginWithCtxAndOpts(ctx context.Context, opts *sql.TxOptions) (TxOrmer, error) {
-	tx, err := o.db.(txer).BeginTx(ctx, opts)
+	b := &o.ormBase
+	if al := tenantAlias(ctx, o.alias); al != o.alias {
+		base := newOrmBase(al)
+		b = &base
+	}
+	tx, err := b.db.(txer).BeginTx(ctx, opts)
 	if err != nil {
 		return nil, err
 	}
+	if b.alias.Schema != "" {
+		if err = setLocalSearchPath(ctx, b.alias, tx); err != nil {
+			_ = tx.Rollback()
+			return nil, err
+		}
+	}
 
 	_txOrm := &txOrm{
 		ormBase: ormBase{
-			alias: o.alias,
+			alias: b.alias,
 			db:    &TxDB{tx: tx},
 		},
+		savepoints: new(int),
+		finished:   new(bool),
 	}
 
-	if Debug {
-		_txOrm.db = newDbQueryLog(o.alias, _txOrm.db)
+	if logQueries() {
+		_txOrm.db = newDbQueryLog(b.alias, _txOrm.db)
 	}
 
 	var taskTxOrm TxOrmer = _txOrm
@@ -603,20 +870,113 @@ func doTxTemplate(ctx context.Context, o TxBeginner, opts *sql.TxOptions,
 
 type txOrm struct {
 	ormBase
+
+	// savepoints counts the savepoints created in the transaction
+	savepoints *int
+	// savepoint is not empty if it is a nested transaction
+	savepoint string
+	done      bool
+	// finished is set when the outermost transaction commits or rolls back
+	finished *bool
+}
+
+var _ NestedTxOrmer = new(txOrm)
+
+func (t *txOrm) Begin() (TxOrmer, error) {
+	return t.BeginWithCtx(context.Background())
+}
+
+func (t *txOrm) BeginWithCtx(ctx context.Context) (TxOrmer, error) {
+	return t.BeginWithCtxAndOpts(ctx, nil)
+}
+
+func (t *txOrm) BeginWithOpts(opts *sql.TxOptions) (TxOrmer, error) {
+	return t.BeginWithCtxAndOpts(context.Background(), opts)
 }
 
-var _ TxOrmer = new(txOrm)
+// BeginWithCtxAndOpts begin a nested transaction by SAVEPOINT,
+// the opts must be empty since the nested transaction shares the outer one.
+func (t *txOrm) BeginWithCtxAndOpts(ctx context.Context, opts *sql.TxOptions) (TxOrmer, error) {
+	if opts != nil && (opts.Isolation != sql.LevelDefault || opts.ReadOnly) {
+		return nil, ErrNestedTxOpts
+	}
+	switch t.alias.Driver {
+	case DRMySQL, DRPostgres, DRSqlite, DRTiDB:
+	default:
+		return nil, ErrNotImplement
+	}
+	if t.done || *t.finished {
+		return nil, ErrTxDone
+	}
+
+	*t.savepoints++
+	name := fmt.Sprintf("beego_sp_%d", *t.savepoints)
+	if _, err := t.db.ExecContext(ctx, "SAVEPOINT "+name); err != nil {
+		return nil, err
+	}
+
+	var taskTxOrm TxOrmer = &txOrm{
+		ormBase:    t.ormBase,
+		savepoints: t.savepoints,
+		savepoint:  name,
+		finished:   t.finished,
+	}
+	return taskTxOrm, nil
+}
+
+func (t *txOrm) DoTx(task func(ctx context.Context, txOrm TxOrmer) error) error {
+	return t.DoTxWithCtx(context.Background(), task)
+}
+
+func (t *txOrm) DoTxWithCtx(ctx context.Context, task func(ctx context.Context, txOrm TxOrmer) error) error {
+	return t.DoTxWithCtxAndOpts(ctx, nil, task)
+}
+
+func (t *txOrm) DoTxWithOpts(opts *sql.TxOptions, task func(ctx context.Context, txOrm TxOrmer) error) error {
+	return t.DoTxWithCtxAndOpts(context.Background(), opts, task)
+}
+
+// DoTxWithCtxAndOpts run the task in a nested transaction,
+// only the changes of the task are rolled back if it returns error or panics.
+func (t *txOrm) DoTxWithCtxAndOpts(ctx context.Context, opts *sql.TxOptions, task func(ctx context.Context, txOrm TxOrmer) error) error {
+	return doTxTemplate(ctx, t, opts, task)
+}
 
 func (t *txOrm) Commit() error {
-	return t.db.(txEnder).Commit()
+	if t.savepoint == "" {
+		*t.finished = true
+		return t.db.(txEnder).Commit()
+	}
+	if t.done || *t.finished {
+		return ErrTxDone
+	}
+	t.done = true
+	_, err := t.db.Exec("RELEASE SAVEPOINT " + t.savepoint)
+	return err
 }
 
 func (t *txOrm) Rollback() error {
-	return t.db.(txEnder).Rollback()
+	if t.savepoint == "" {
+		*t.finished = true
+		return t.db.(txEnder).Rollback()
+	}
+	if t.done || *t.finished {
+		return ErrTxDone
+	}
+	t.done = true
+	_, err := t.db.Exec("ROLLBACK TO SAVEPOINT " + t.savepoint)
+	return err
 }
 
 func (t *txOrm) RollbackUnlessCommit() error {
-	return t.db.(txEnder).RollbackUnlessCommit()
+	if t.savepoint == "" {
+		*t.finished = true
+		return t.db.(txEnder).RollbackUnlessCommit()
+	}
+	if t.done || *t.finished {
+		return nil
+	}
+	return t.Rollback()
 }
 
 // NewOrm create new orm
@@ -645,17 +1005,20 @@ func NewOrmWithDB(driverName, aliasName string, db *sql.DB, params ...DBOption)
 func newDBWithAlias(al *alias) Ormer {
 	BootStrapWithAlias(al.Name) // execute only once
 
-	o := new(orm)
-	o.alias = al
-
-	if Debug {
-		o.db = newDbQueryLog(al, al.DB)
-	} else {
-		o.db = al.DB
-	}
+	o := &orm{ormBase: newOrmBase(al)}
 
 	if len(globalFilterChains) > 0 {
 		return NewFilterOrmDecorator(o, globalFilterChains...)
 	}
 	return o
 }
+
+func newOrmBase(al *alias) ormBase {
+	o := ormBase{alias: al}
+	if logQueries() {
+		o.db = newDbQueryLog(al, al.querier())
+	} else {
+		o.db = al.querier()
+	}
+	return o
+}
@@ -0,0 +1,188 @@
+// Copyright 2023 beego. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package orm
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/beego/beego/v2/client/orm/internal/models"
+	"github.com/beego/beego/v2/client/orm/internal/utils"
+)
+
+// the aliases of the aggregations are written to the sql
+var aggregationAlias = regexp.MustCompile(`^\w+$`)
+
+// Aggregation is an aggregate function of a field, which is selected by QuerySeter.Annotate
+type Aggregation struct {
+	fn       string
+	expr     string
+	alias    string
+	distinct bool
+}
+
+// Count counts the rows which the field is not null, or all the rows by Count("*")
+func Count(expr string) Aggregation {
+	return Aggregation{fn: "COUNT", expr: expr}
+}
+
+// Sum sums the values of the field
+func Sum(expr string) Aggregation {
+	return Aggregation{fn: "SUM", expr: expr}
+}
+
+// Avg averages the values of the field
+func Avg(expr string) Aggregation {
+	return Aggregation{fn: "AVG", expr: expr}
+}
+
+// Max gets the max value of the field
+func Max(expr string) Aggregation {
+	return Aggregation{fn: "MAX", expr: expr}
+}
+
+// Min gets the min value of the field
+func Min(expr string) Aggregation {
+	return Aggregation{fn: "MIN", expr: expr}
+}
+
+// As set the alias of the result, which is the key of orm.Params,
+// and the name to match the struct field.
+func (a Aggregation) As(alias string) Aggregation {
+	if !aggregationAlias.MatchString(alias) {
+		panic(fmt.Errorf("<orm.Aggregation> alias `%s` can only contain word characters", alias))
+	}
+	a.alias = alias
+	return a
+}
+
+// Distinct aggregates the distinct values only, such as COUNT(DISTINCT ...)
+func (a Aggregation) Distinct() Aggregation {
+	a.distinct = true
+	return a
+}
+
+// Alias returns the alias of the result,
+// it defaults to the lower function name and the snake field name, such as sum_salary.
+func (a Aggregation) Alias() string {
+	if a.alias != "" {
+		return a.alias
+	}
+	if a.expr == "*" {
+		return strings.ToLower(a.fn)
+	}
+	return strings.ToLower(a.fn) + "_" + models.SnakeString(strings.ReplaceAll(a.expr, ExprSep, ""))
+}
+
+// OuterRef refers to the field of the outer query in the filter of a subquery, for example:
+//
+//	sub := o.QueryTable("post").Filter("User", orm.OuterRef("Id"))
+//	o.QueryTable("user").SetCond(orm.Exists(sub)).All(&users)
+type OuterRef string
+
+// Exists returns the condition that the subquery qs has any rows
+func Exists(qs QuerySeter) *Condition {
+	sub, ok := qs.(*querySet)
+	if !ok {
+		panic(fmt.Errorf("<orm.Exists> unsupported QuerySeter `%T`", qs))
+	}
+	return &Condition{params: []condValue{{sub: sub}}}
+}
+
+// convert the value of the aggregation a from db, it is the same as convertValueFromDB if a is nil,
+// fi is the aggregated field, which is nil for Count("*").
+func (d *dbBase) convertAggregationValue(a *Aggregation, fi *models.FieldInfo, val interface{}, tz *time.Location) (interface{}, error) {
+	if a == nil {
+		return d.convertValueFromDB(fi, val, tz)
+	}
+	if val == nil {
+		return nil, nil
+	}
+	if b, ok := val.([]byte); ok {
+		val = string(b)
+	}
+	switch a.fn {
+	case "COUNT":
+		return utils.StrTo(utils.ToStr(val)).Int64()
+	case "AVG":
+		return utils.StrTo(utils.ToStr(val)).Float64()
+	case "SUM":
+		if fi != nil && fi.FieldType&IsIntegerField > 0 {
+			if v, err := utils.StrTo(utils.ToStr(val)).Int64(); err == nil {
+				return v, nil
+			}
+		}
+		return utils.StrTo(utils.ToStr(val)).Float64()
+	}
+	return d.convertValueFromDB(fi, val, tz)
+}
+
+// setAnnotated sets the results of the annotated query to the struct slice container,
+// the keys of the results match the struct fields by the column tag, the name or the snake name.
+func (o querySet) setAnnotated(container interface{}, maps []Params) {
+	val := reflect.ValueOf(container)
+	ind := reflect.Indirect(val)
+	if val.Kind() != reflect.Ptr || ind.Kind() != reflect.Slice {
+		panic(fmt.Errorf("<QuerySeter.Annotate> container must be a ptr slice but found `%T`", container))
+	}
+	etyp := ind.Type().Elem()
+	typ := etyp
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		panic(fmt.Errorf("<QuerySeter.Annotate> container must be a slice of struct but found `%T`", container))
+	}
+
+	// the field index of the normalized names
+	fields := make(map[string]int, typ.NumField())
+	for i := 0; i < typ.NumField(); i++ {
+		sf := typ.Field(i)
+		if sf.PkgPath != "" {
+			continue
+		}
+		_, tags := models.ParseStructTag(sf.Tag.Get(models.DefaultStructTagName))
+		if col := tags["column"]; col != "" {
+			fields[normalizeAnnotatedName(col)] = i
+		} else {
+			fields[normalizeAnnotatedName(sf.Name)] = i
+		}
+	}
+
+	raw := &rawSet{orm: o.orm}
+	slice := reflect.MakeSlice(ind.Type(), 0, len(maps))
+	for _, params := range maps {
+		elm := reflect.New(typ).Elem()
+		for key, value := range params {
+			if i, ok := fields[normalizeAnnotatedName(key)]; ok {
+				raw.setFieldValue(elm.Field(i), value)
+			}
+		}
+		if etyp.Kind() == reflect.Ptr {
+			slice = reflect.Append(slice, elm.Addr())
+		} else {
+			slice = reflect.Append(slice, elm)
+		}
+	}
+	ind.Set(slice)
+}
+
+// normalizeAnnotatedName makes the names like DeptName, dept_name and Dept__Name equal
+func normalizeAnnotatedName(name string) string {
+	return strings.ToLower(strings.ReplaceAll(name, "_", ""))
+}
@@ -0,0 +1,353 @@
+// Copyright 2023 beego. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package orm
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/beego/beego/v2/client/cache"
+	"github.com/beego/beego/v2/client/orm/hints"
+	"github.com/beego/beego/v2/client/orm/internal/models"
+	"github.com/beego/beego/v2/core/utils"
+)
+
+const (
+	queryCachePrefix = "orm:cache:"
+	// the versions of the tables should live longer than the results
+	queryCacheVersionTimeout = 30 * 24 * time.Hour
+)
+
+func init() {
+	// the values of Params and ParamsList
+	gob.Register(time.Time{})
+}
+
+// queryCache caches the results of the queries in the cache.Cache,
+// the keys contain the versions of the tables, which are changed by the DML on the tables.
+type queryCache struct {
+	cache cache.Cache
+	// the SingleflightCache of each ttl
+	flights sync.Map
+	// the tables written by the transactions, which are invalidated again after commit
+	txTables sync.Map
+}
+
+var defaultQueryCache *queryCache
+
+// RegisterQueryCache sets the cache of QuerySeter.Cache and the hint hints.Cache for Read,
+// and adds the global filter chain which invalidates the results of the tables written by the Ormer.
+// Like AddGlobalFilterChain, it only works for the Ormer created after it.
+func RegisterQueryCache(c cache.Cache) {
+	defaultQueryCache = &queryCache{cache: c}
+	AddGlobalFilterChain(defaultQueryCache.FilterChain)
+}
+
+// WithHints returns the context with the hints of Read, such as hints.Cache
+func WithHints(ctx context.Context, hs ...utils.KV) context.Context {
+	return context.WithValue(ctx, hintsKey{}, hs)
+}
+
+type hintsKey struct{}
+
+// getCacheHint returns the ttl of hints.Cache in the context
+func getCacheHint(ctx context.Context) time.Duration {
+	hs, _ := ctx.Value(hintsKey{}).([]utils.KV)
+	var ttl time.Duration
+	for _, h := range hs {
+		if h.GetKey() == hints.KeyCache {
+			ttl, _ = h.GetValue().(time.Duration)
+		}
+	}
+	return ttl
+}
+
+type queryCacheLoaderKey struct{}
+
+// flight returns the SingleflightCache of the ttl, which loads the result by the loader in the context
+func (c *queryCache) flight(ttl time.Duration) cache.Cache {
+	if f, ok := c.flights.Load(ttl); ok {
+		return f.(cache.Cache)
+	}
+	f, _ := cache.NewSingleflightCache(c.cache, ttl, func(ctx context.Context, key string) (any, error) {
+		return ctx.Value(queryCacheLoaderKey{}).(func(ctx context.Context) (any, error))(ctx)
+	})
+	actual, _ := c.flights.LoadOrStore(ttl, f)
+	return actual.(cache.Cache)
+}
+
+func (c *queryCache) versionKey(table string) string {
+	return queryCachePrefix + "version:" + table
+}
+
+func newQueryCacheVersion() string {
+	return strconv.FormatInt(time.Now().UnixNano(), 10)
+}
+
+// version returns the version of the table, it seeds a new one if the version is missing or evicted,
+// so the results cached with the old versions are never used again
+func (c *queryCache) version(ctx context.Context, table string) string {
+	v, _ := c.cache.Get(ctx, c.versionKey(table))
+	if version := cache.GetString(v); version != "" {
+		return version
+	}
+	version := newQueryCacheVersion()
+	_ = c.cache.Put(ctx, c.versionKey(table), version, queryCacheVersionTimeout)
+	return version
+}
+
+// key returns the key of the query, which contains the versions of the tables and the tenant of the alias
+func (c *queryCache) key(ctx context.Context, al *alias, query string, tables []string) string {
+	h := sha256.New()
+	for _, table := range tables {
+		_, _ = fmt.Fprintf(h, "%s@%s;", table, c.version(ctx, table))
+	}
+	_, _ = h.Write([]byte(query))
+	name := al.Name
+	if al.Tenant != "" {
+		name += "@" + al.Tenant
+	}
+	return queryCachePrefix + name + ":" + hex.EncodeToString(h.Sum(nil))
+}
+
+// invalidate changes the versions of the tables
+func (c *queryCache) invalidate(ctx context.Context, tables ...string) {
+	version := newQueryCacheVersion()
+	for _, table := range tables {
+		_ = c.cache.Put(ctx, c.versionKey(table), version, queryCacheVersionTimeout)
+	}
+}
+
+// load sets the cached result to the pointer result, or loads it by load and caches it.
+// load fills the new pointer of the same type as result, so the result can be shared by the same queries.
+// It falls back to load result directly if the cache fails.
+func (c *queryCache) load(ctx context.Context, ttl time.Duration, key string, result interface{},
+	load func(ctx context.Context, result interface{}) (int64, error),
+) (int64, error) {
+	typ := reflect.TypeOf(result).Elem()
+	// the result loaded by this call, which is returned directly without decoding,
+	// so the query is not run again if it cannot be cached
+	var (
+		loaded  bool
+		loadNum int64
+		loadErr error
+		loadVal reflect.Value
+	)
+	ctx = context.WithValue(ctx, queryCacheLoaderKey{}, func(ctx context.Context) (any, error) {
+		fresh := reflect.New(typ)
+		num, err := load(ctx, fresh.Interface())
+		loaded, loadNum, loadErr, loadVal = true, num, err, fresh
+		if err != nil {
+			return nil, err
+		}
+		buf := bytes.Buffer{}
+		enc := gob.NewEncoder(&buf)
+		if err = enc.Encode(num); err == nil {
+			err = enc.EncodeValue(fresh.Elem())
+		}
+		if err != nil {
+			DebugLog.Println(fmt.Sprintf("[WARN] cannot cache the result `%s`: %s", typ, err))
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	})
+
+	val, err := c.flight(ttl).Get(ctx, key)
+	if loaded {
+		if loadErr != nil {
+			return 0, loadErr
+		}
+		reflect.ValueOf(result).Elem().Set(loadVal.Elem())
+		return loadNum, nil
+	}
+	var data []byte
+	switch v := val.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	}
+	if data == nil && err != nil {
+		return load(ctx, result)
+	}
+
+	dec := gob.NewDecoder(bytes.NewReader(data))
+	var num int64
+	fresh := reflect.New(typ)
+	if err = dec.Decode(&num); err == nil {
+		err = dec.DecodeValue(fresh)
+	}
+	if err != nil {
+		return load(ctx, result)
+	}
+	reflect.ValueOf(result).Elem().Set(fresh.Elem())
+	return num, nil
+}
+
+// FilterChain invalidates the results of the tables written by the invocation,
+// the tables written in a transaction are invalidated again after the outermost transaction commits,
+// because the results may be cached by the other queries before commit.
+func (c *queryCache) FilterChain(next Filter) Filter {
+	return func(ctx context.Context, inv *Invocation) []interface{} {
+		res := next(ctx, inv)
+		switch inv.Method {
+		case "InsertWithCtx", "InsertOrUpdateWithCtx", "InsertMultiWithCtx", "InsertOrUpdateMultiWithCtx",
+			"UpdateWithCtx", "UpdateMultiWithCtx", "DeleteWithCtx", "ReadOrCreateWithCtx",
+			"QuerySeterUpdateWithCtx", "QuerySeterDeleteWithCtx":
+			table := inv.GetTableName()
+			c.invalidate(ctx, table)
+			if inv.InsideTx {
+				key, _ := txRoot(inv.GetExecutor())
+				tables, _ := c.txTables.LoadOrStore(key, &sync.Map{})
+				tables.(*sync.Map).Store(table, true)
+			}
+		case "Commit", "Rollback", "RollbackUnlessCommit":
+			key, nested := txRoot(inv.GetExecutor())
+			if nested {
+				// the savepoint is released or rolled back, the tables wait for the outermost transaction
+				break
+			}
+			tables, ok := c.txTables.LoadAndDelete(key)
+			if ok && inv.Method == "Commit" {
+				tables.(*sync.Map).Range(func(table, _ any) bool {
+					c.invalidate(ctx, table.(string))
+					return true
+				})
+			}
+		}
+		return res
+	}
+}
+
+// txRoot returns the key of the outermost transaction of the executor, which is shared by the nested transactions,
+// and whether the executor is a nested transaction.
+func txRoot(executor QueryExecutor) (interface{}, bool) {
+	if t, ok := executor.(*txOrm); ok {
+		return t.finished, t.savepoint != ""
+	}
+	return executor, false
+}
+
+// insideTx checks whether the queries of the ormBase are inside a transaction
+func (o *ormBase) insideTx() bool {
+	db := o.db
+	if l, ok := db.(*dbQueryLog); ok {
+		db = l.db
+	}
+	_, ok := db.(*TxDB)
+	return ok
+}
+
+// readCached reads the model with the query cache if the hint hints.Cache is in the context
+func (o *ormBase) readCached(ctx context.Context, mi *models.ModelInfo, ind reflect.Value, cols []string) error {
+	ttl := getCacheHint(ctx)
+	if ttl <= 0 || defaultQueryCache == nil || o.insideTx() {
+		return o.alias.DbBaser.Read(ctx, o.db, mi, ind, o.alias.TZ, cols, false)
+	}
+
+	whereCols := cols
+	if len(whereCols) == 0 {
+		whereCols = make([]string, 0, 1)
+		for _, fi := range mi.Fields.PkFields() {
+			whereCols = append(whereCols, fi.Name)
+		}
+	}
+	query := strings.Builder{}
+	query.WriteString("Read:" + mi.FullName)
+	for _, col := range whereCols {
+		if fi, ok := mi.Fields.GetByAny(col); ok {
+			_, _ = fmt.Fprintf(&query, ";%s=%#v", fi.Column, getSnapshotValue(fi, ind))
+		}
+	}
+
+	key := defaultQueryCache.key(ctx, o.alias, query.String(), []string{mi.Table})
+	_, err := defaultQueryCache.load(ctx, ttl, key, ind.Addr().Interface(), func(ctx context.Context, result interface{}) (int64, error) {
+		fresh := reflect.ValueOf(result).Elem()
+		fresh.Set(ind)
+		return 1, o.alias.DbBaser.Read(ctx, o.db, mi, fresh, o.alias.TZ, cols, false)
+	})
+	return err
+}
+
+// cached runs load with the query cache if the QuerySeter is cached by Cache,
+// method and cols distinguish the results of the same query.
+func (o querySet) cached(ctx context.Context, method string, cols []string, result interface{},
+	load func(ctx context.Context, result interface{}) (int64, error),
+) (int64, error) {
+	if o.cacheTTL <= 0 || defaultQueryCache == nil || o.forUpdate || o.orm.insideTx() {
+		return load(ctx, result)
+	}
+
+	d := &dbBase{ins: o.orm.alias.DbBaser}
+	tables := newDbTables(o.mi, d.ins)
+	tables.ctx = ctx
+	tables.parseRelated(o.related, o.relDepth)
+	tables.withDeleted = isWithDeleted(ctx) || o.withDeleted
+	cond := getSoftDeleteCond(ctx, &o, o.mi, o.cond)
+	query, args := d.readBatchSQL(tables, cols, cond, o, o.mi, o.orm.alias.TZ)
+	query = fmt.Sprintf("%s:%s:%s - %#v", method, reflect.TypeOf(result), query, args)
+
+	// the tables joined by the exprs of Values, ValuesList and ValuesFlat, the annotations and the groups
+	exprs := append(append([]string{}, cols...), o.groups...)
+	for _, a := range o.annotations {
+		exprs = append(exprs, a.expr)
+	}
+	for _, ex := range exprs {
+		tables.parseExprs(o.mi, strings.Split(ex, ExprSep))
+	}
+
+	names := []string{o.mi.Table}
+	for _, t := range tables.tables {
+		names = append(names, t.mi.Table)
+	}
+	names = append(names, getCondTables(o.cond)...)
+
+	key := defaultQueryCache.key(ctx, o.orm.alias, query, names)
+	return defaultQueryCache.load(ctx, o.cacheTTL, key, result, load)
+}
+
+// getCondTables returns the tables of the subqueries in the condition
+func getCondTables(cond *Condition) []string {
+	if cond == nil {
+		return nil
+	}
+	var names []string
+	for _, p := range cond.params {
+		switch {
+		case p.isCond:
+			names = append(names, getCondTables(p.cond)...)
+		case p.sub != nil:
+			names = append(names, p.sub.mi.Table)
+			names = append(names, getCondTables(p.sub.cond)...)
+		default:
+			for _, arg := range p.args {
+				if sub, ok := arg.(*querySet); ok {
+					names = append(names, sub.mi.Table)
+					names = append(names, getCondTables(sub.cond)...)
+				}
+			}
+		}
+	}
+	return names
+}
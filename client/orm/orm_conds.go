@@ -35,6 +35,7 @@ type condValue struct {
 	isCond bool
 	isRaw  bool
 	sql    string
+	sub    *querySet
 }
 
 // Condition struct.
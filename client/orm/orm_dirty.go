@@ -0,0 +1,130 @@
+// Copyright 2023 beego. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package orm
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"runtime"
+	"slices"
+	"sync"
+	"weak"
+
+	"github.com/beego/beego/v2/client/orm/internal/models"
+)
+
+// the snapshots of the models which track changes, keyed by the weak pointers of the models,
+// so the snapshot is removed after the model is collected or deleted.
+// the key is the address of the model, so the models in a []Model must not be copied or moved,
+// for example by append, after they are read, or the snapshots are lost.
+var snapshots sync.Map
+
+// snapshotPtr returns the address of the model value,
+// the type of it does not matter because the weak pointer refers to the memory only.
+func snapshotPtr(ind reflect.Value) *byte {
+	return (*byte)(ind.Addr().UnsafePointer())
+}
+
+// saveSnapshot saves the values of the model ind if the model tracks changes
+func saveSnapshot(mi *models.ModelInfo, ind reflect.Value) {
+	if !mi.TrackChanges || !ind.CanAddr() || ind.Type() != mi.AddrField.Elem().Type() {
+		return
+	}
+	values := make(map[string]interface{}, len(mi.Fields.FieldsDB))
+	for _, fi := range mi.Fields.FieldsDB {
+		values[fi.Name] = getSnapshotValue(fi, ind)
+	}
+
+	ptr := snapshotPtr(ind)
+	key := weak.Make(ptr)
+	if _, loaded := snapshots.Swap(key, values); !loaded {
+		runtime.AddCleanup(ptr, func(key weak.Pointer[byte]) {
+			snapshots.Delete(key)
+		}, key)
+	}
+}
+
+// deleteSnapshot removes the snapshot of the deleted model
+func deleteSnapshot(mi *models.ModelInfo, ind reflect.Value) {
+	if !mi.TrackChanges || !ind.CanAddr() {
+		return
+	}
+	snapshots.Delete(weak.Make(snapshotPtr(ind)))
+}
+
+// getSnapshotValue returns the value of the field to compare,
+// the related model is compared by its pk, and the pointer is compared by the value of it.
+func getSnapshotValue(fi *models.FieldInfo, ind reflect.Value) interface{} {
+	field := ind.FieldByIndex(fi.FieldIndex)
+	if fi.Rel {
+		rel := reflect.Indirect(field)
+		if !rel.IsValid() {
+			return nil
+		}
+		value, _ := getPkValue(fi.RelModelInfo.Fields.Pk, rel)
+		return value
+	}
+	if f, ok := field.Addr().Interface().(Fielder); ok {
+		return f.RawValue()
+	}
+	field = reflect.Indirect(field)
+	if !field.IsValid() {
+		return nil
+	}
+	value := field.Interface()
+	if b, ok := value.([]byte); ok {
+		return bytes.Clone(b)
+	}
+	return value
+}
+
+// getChangedFields returns the names of the fields changed from the snapshot,
+// ok is false if there is no snapshot of the model.
+// the pk, version and auto_now fields are ignored, because Update writes them by itself.
+func getChangedFields(mi *models.ModelInfo, ind reflect.Value) (names []string, ok bool) {
+	if !mi.TrackChanges || !ind.CanAddr() {
+		return nil, false
+	}
+	v, ok := snapshots.Load(weak.Make(snapshotPtr(ind)))
+	if !ok {
+		return nil, false
+	}
+	values := v.(map[string]interface{})
+
+	pks := mi.Fields.PkFields()
+	names = make([]string, 0, len(values))
+	for _, fi := range mi.Fields.FieldsDB {
+		if fi.AutoNow || fi.AutoNowAdd || fi == mi.Fields.Version || slices.Contains(pks, fi) {
+			continue
+		}
+		if !reflect.DeepEqual(values[fi.Name], getSnapshotValue(fi, ind)) {
+			names = append(names, fi.Name)
+		}
+	}
+	return names, true
+}
+
+// Changed returns the names of the fields changed after the model md is read or saved,
+// it returns nil if the model does not track changes or is not read, see TableTrackChangesI.
+func Changed(md interface{}) []string {
+	val := reflect.ValueOf(md)
+	if val.Kind() != reflect.Ptr {
+		panic(fmt.Errorf("<orm.Changed> cannot use non-ptr model struct `%T`", md))
+	}
+	ind := reflect.Indirect(val)
+	names, _ := getChangedFields(getTypeMi(ind.Type()), ind)
+	return names
+}
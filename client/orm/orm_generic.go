@@ -0,0 +1,374 @@
+// Copyright 2023 beego. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package orm
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/beego/beego/v2/client/orm/clauses/order_clause"
+)
+
+// Field is a typed reference to the field of model T whose go type is V.
+// The name of the field is resolved by the orm model cache at query time,
+// so a Field can be declared before the model is registered.
+// usage:
+//
+//	var userName = orm.FieldOf(func(u *User) *string { return &u.UserName })
+//	users, err := orm.NewQuery[User](o).Where(userName.Eq("slene")).All()
+type Field[T any, V any] struct {
+	name string
+}
+
+// FieldOf derives a Field from a selector which returns the address of one of T's fields.
+// It panics if the returned pointer is not a field of T, which is a programming error.
+func FieldOf[T any, V any](sel func(*T) *V) Field[T, V] {
+	md := new(T)
+	ptr := sel(md)
+	if ptr == nil {
+		panic(fmt.Errorf("<orm.FieldOf> selector must return the address of a field of `%T`", md))
+	}
+	name, ok := lookupFieldName(reflect.ValueOf(md).Elem(), reflect.ValueOf(ptr))
+	if !ok {
+		panic(fmt.Errorf("<orm.FieldOf> selector must return the address of a field of `%T`", md))
+	}
+	return Field[T, V]{name: name}
+}
+
+// NewField return a Field with the expression, such as "UserName" or "Profile__Age".
+// The expression is not checked until the query is executed.
+func NewField[T any, V any](expr string) Field[T, V] {
+	return Field[T, V]{name: expr}
+}
+
+// Related return the Field f of the related model R reached through rel,
+// e.g. Related(userProfile, profileAge) is the expression "Profile__Age" of User.
+func Related[T any, R any, V any](rel Field[T, *R], f Field[R, V]) Field[T, V] {
+	return Field[T, V]{name: rel.name + ExprSep + f.name}
+}
+
+// lookupFieldName find the struct field of v whose address is ptr.
+// anonymous struct fields are flattened just like the model cache does.
+func lookupFieldName(v reflect.Value, ptr reflect.Value) (string, bool) {
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		sf := v.Type().Field(i)
+		if sf.Anonymous && field.Kind() == reflect.Struct {
+			if name, ok := lookupFieldName(field, ptr); ok {
+				return name, true
+			}
+			continue
+		}
+		if field.Addr().Pointer() == ptr.Pointer() && sf.Type == ptr.Type().Elem() {
+			return sf.Name, true
+		}
+	}
+	return "", false
+}
+
+// Name return the filter expression of the field
+func (f Field[T, V]) Name() string {
+	return f.name
+}
+
+func (f Field[T, V]) cond(operator string, args ...interface{}) *Condition {
+	expr := f.name
+	if operator != "" {
+		expr += ExprSep + operator
+	}
+	return NewCondition().And(expr, args...)
+}
+
+// Eq return the condition field = value
+func (f Field[T, V]) Eq(value V) *Condition {
+	return f.cond("", value)
+}
+
+// Ne return the condition NOT field = value
+func (f Field[T, V]) Ne(value V) *Condition {
+	return NewCondition().AndNot(f.name, value)
+}
+
+// Gt return the condition field > value
+func (f Field[T, V]) Gt(value V) *Condition {
+	return f.cond("gt", value)
+}
+
+// Gte return the condition field >= value
+func (f Field[T, V]) Gte(value V) *Condition {
+	return f.cond("gte", value)
+}
+
+// Lt return the condition field < value
+func (f Field[T, V]) Lt(value V) *Condition {
+	return f.cond("lt", value)
+}
+
+// Lte return the condition field <= value
+func (f Field[T, V]) Lte(value V) *Condition {
+	return f.cond("lte", value)
+}
+
+// In return the condition field IN (values...)
+func (f Field[T, V]) In(values ...V) *Condition {
+	args := make([]interface{}, 0, len(values))
+	for _, value := range values {
+		args = append(args, value)
+	}
+	return f.cond("in", args...)
+}
+
+// Between return the condition field BETWEEN from AND to
+func (f Field[T, V]) Between(from, to V) *Condition {
+	return f.cond("between", from, to)
+}
+
+// IsNull return the condition field IS NULL, or IS NOT NULL if isNull is false
+func (f Field[T, V]) IsNull(isNull bool) *Condition {
+	return f.cond("isnull", isNull)
+}
+
+// Contains return the condition field LIKE %value%
+func (f Field[T, V]) Contains(value V) *Condition {
+	return f.cond("contains", value)
+}
+
+// IContains return the case-insensitive condition field LIKE %value%
+func (f Field[T, V]) IContains(value V) *Condition {
+	return f.cond("icontains", value)
+}
+
+// StartsWith return the condition field LIKE value%
+func (f Field[T, V]) StartsWith(value V) *Condition {
+	return f.cond("startswith", value)
+}
+
+// EndsWith return the condition field LIKE %value
+func (f Field[T, V]) EndsWith(value V) *Condition {
+	return f.cond("endswith", value)
+}
+
+// Asc return the ascending order clause of the field
+func (f Field[T, V]) Asc() *order_clause.Order {
+	return order_clause.Clause(order_clause.Column(f.name), order_clause.SortAscending())
+}
+
+// Desc return the descending order clause of the field
+func (f Field[T, V]) Desc() *order_clause.Order {
+	return order_clause.Clause(order_clause.Column(f.name), order_clause.SortDescending())
+}
+
+// Query is the type-safe QuerySeter of model T.
+// It is immutable like QuerySeter, every method returns a new Query.
+type Query[T any] struct {
+	qs QuerySeter
+}
+
+// NewQuery return a Query of model T by using QueryTable of the executor,
+// so the filter chains registered to the Ormer still work.
+// T must be a registered model struct, not a pointer.
+func NewQuery[T any](o QueryExecutor) *Query[T] {
+	return &Query[T]{qs: o.QueryTable(new(T))}
+}
+
+// QuerySeter return the underlying QuerySeter
+func (q *Query[T]) QuerySeter() QuerySeter {
+	return q.qs
+}
+
+func (q *Query[T]) with(qs QuerySeter) *Query[T] {
+	return &Query[T]{qs: qs}
+}
+
+// Where add the conditions to the query, they are combined by AND
+func (q *Query[T]) Where(conds ...*Condition) *Query[T] {
+	cond := q.qs.GetCond()
+	if cond == nil {
+		cond = NewCondition()
+	}
+	for _, c := range conds {
+		cond = cond.AndCond(c)
+	}
+	return q.with(q.qs.SetCond(cond))
+}
+
+// Filter add condition expression, see QuerySeter.Filter
+func (q *Query[T]) Filter(expr string, args ...interface{}) *Query[T] {
+	return q.with(q.qs.Filter(expr, args...))
+}
+
+// Exclude add NOT condition expression, see QuerySeter.Exclude
+func (q *Query[T]) Exclude(expr string, args ...interface{}) *Query[T] {
+	return q.with(q.qs.Exclude(expr, args...))
+}
+
+// Limit add LIMIT value, see QuerySeter.Limit
+func (q *Query[T]) Limit(limit interface{}, args ...interface{}) *Query[T] {
+	return q.with(q.qs.Limit(limit, args...))
+}
+
+// Offset add OFFSET value, see QuerySeter.Offset
+func (q *Query[T]) Offset(offset interface{}) *Query[T] {
+	return q.with(q.qs.Offset(offset))
+}
+
+// OrderBy add ORDER BY clauses, usually built by Field.Asc and Field.Desc
+func (q *Query[T]) OrderBy(orders ...*order_clause.Order) *Query[T] {
+	return q.with(q.qs.OrderClauses(orders...))
+}
+
+// ForceIndex see QuerySeter.ForceIndex
+func (q *Query[T]) ForceIndex(indexes ...string) *Query[T] {
+	return q.with(q.qs.ForceIndex(indexes...))
+}
+
+// UseIndex see QuerySeter.UseIndex
+func (q *Query[T]) UseIndex(indexes ...string) *Query[T] {
+	return q.with(q.qs.UseIndex(indexes...))
+}
+
+// IgnoreIndex see QuerySeter.IgnoreIndex
+func (q *Query[T]) IgnoreIndex(indexes ...string) *Query[T] {
+	return q.with(q.qs.IgnoreIndex(indexes...))
+}
+
+// RelatedSel see QuerySeter.RelatedSel
+func (q *Query[T]) RelatedSel(params ...interface{}) *Query[T] {
+	return q.with(q.qs.RelatedSel(params...))
+}
+
+// Prefetch see QuerySeter.Prefetch
+func (q *Query[T]) Prefetch(params ...interface{}) *Query[T] {
+	return q.with(q.qs.Prefetch(params...))
+}
+
+// Distinct see QuerySeter.Distinct
+func (q *Query[T]) Distinct() *Query[T] {
+	return q.with(q.qs.Distinct())
+}
+
+// ForUpdate see QuerySeter.ForUpdate
+func (q *Query[T]) ForUpdate() *Query[T] {
+	return q.with(q.qs.ForUpdate())
+}
+
+// WithDeleted see QuerySeter.WithDeleted
+func (q *Query[T]) WithDeleted() *Query[T] {
+	return q.with(q.qs.WithDeleted())
+}
+
+// OnlyDeleted see QuerySeter.OnlyDeleted
+func (q *Query[T]) OnlyDeleted() *Query[T] {
+	return q.with(q.qs.OnlyDeleted())
+}
+
+// HardDelete see QuerySeter.HardDelete
+func (q *Query[T]) HardDelete() *Query[T] {
+	return q.with(q.qs.HardDelete())
+}
+
+// All return all the records matched by the query
+func (q *Query[T]) All(cols ...string) ([]*T, error) {
+	return q.AllWithCtx(context.Background(), cols...)
+}
+
+// AllWithCtx return all the records matched by the query
+func (q *Query[T]) AllWithCtx(ctx context.Context, cols ...string) ([]*T, error) {
+	var container []*T
+	if _, err := q.qs.AllWithCtx(ctx, &container, cols...); err != nil {
+		return nil, err
+	}
+	return container, nil
+}
+
+// One return the only record matched by the query.
+// it returns ErrNoRows if there is no record and ErrMultiRows if more than one records are matched.
+func (q *Query[T]) One(cols ...string) (*T, error) {
+	return q.OneWithCtx(context.Background(), cols...)
+}
+
+// OneWithCtx return the only record matched by the query
+func (q *Query[T]) OneWithCtx(ctx context.Context, cols ...string) (*T, error) {
+	container := new(T)
+	if err := q.qs.OneWithCtx(ctx, container, cols...); err != nil {
+		return nil, err
+	}
+	return container, nil
+}
+
+// Iterate call fn with the records matched by the query one by one, see QuerySeter.Iterate
+func (q *Query[T]) Iterate(fn func(*T) error, cols ...string) (int64, error) {
+	return q.IterateWithCtx(context.Background(), fn, cols...)
+}
+
+// IterateWithCtx call fn with the records matched by the query one by one
+func (q *Query[T]) IterateWithCtx(ctx context.Context, fn func(*T) error, cols ...string) (int64, error) {
+	return q.qs.IterateWithCtx(ctx, func(md interface{}) error {
+		return fn(md.(*T))
+	}, cols...)
+}
+
+// Chunk call fn with the records matched by the query by pages of the primary key, see QuerySeter.Chunk
+func (q *Query[T]) Chunk(size int, fn func([]*T) error) error {
+	return q.ChunkWithCtx(context.Background(), size, fn)
+}
+
+// ChunkWithCtx call fn with the records matched by the query by pages of the primary key
+func (q *Query[T]) ChunkWithCtx(ctx context.Context, size int, fn func([]*T) error) error {
+	return q.qs.ChunkWithCtx(ctx, size, func(container interface{}) error {
+		return fn(container.([]*T))
+	})
+}
+
+// Count return the number of records matched by the query
+func (q *Query[T]) Count() (int64, error) {
+	return q.qs.Count()
+}
+
+// CountWithCtx return the number of records matched by the query
+func (q *Query[T]) CountWithCtx(ctx context.Context) (int64, error) {
+	return q.qs.CountWithCtx(ctx)
+}
+
+// Exist check whether any record is matched by the query
+func (q *Query[T]) Exist() bool {
+	return q.qs.Exist()
+}
+
+// ExistWithCtx check whether any record is matched by the query
+func (q *Query[T]) ExistWithCtx(ctx context.Context) bool {
+	return q.qs.ExistWithCtx(ctx)
+}
+
+// Update update the records matched by the query, see QuerySeter.Update
+func (q *Query[T]) Update(values Params) (int64, error) {
+	return q.qs.Update(values)
+}
+
+// UpdateWithCtx update the records matched by the query, see QuerySeter.UpdateWithCtx
+func (q *Query[T]) UpdateWithCtx(ctx context.Context, values Params) (int64, error) {
+	return q.qs.UpdateWithCtx(ctx, values)
+}
+
+// Delete delete the records matched by the query
+func (q *Query[T]) Delete() (int64, error) {
+	return q.qs.Delete()
+}
+
+// DeleteWithCtx delete the records matched by the query
+func (q *Query[T]) DeleteWithCtx(ctx context.Context) (int64, error) {
+	return q.qs.DeleteWithCtx(ctx)
+}
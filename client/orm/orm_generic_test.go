@@ -0,0 +1,83 @@
+// Copyright 2023 beego. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package orm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/beego/beego/v2/client/orm/clauses/order_clause"
+)
+
+type genericTestBase struct {
+	Created int64
+}
+
+type genericTestEntity struct {
+	genericTestBase
+	ID   int
+	Name string
+}
+
+func TestFieldOf(t *testing.T) {
+	id := FieldOf(func(e *genericTestEntity) *int { return &e.ID })
+	assert.Equal(t, "ID", id.Name())
+
+	name := FieldOf(func(e *genericTestEntity) *string { return &e.Name })
+	assert.Equal(t, "Name", name.Name())
+
+	created := FieldOf(func(e *genericTestEntity) *int64 { return &e.Created })
+	assert.Equal(t, "Created", created.Name())
+
+	assert.Panics(t, func() {
+		other := 0
+		FieldOf(func(e *genericTestEntity) *int { return &other })
+	})
+	assert.Panics(t, func() {
+		FieldOf(func(e *genericTestEntity) *int { return nil })
+	})
+
+	profile := FieldOf(func(u *User) **Profile { return &u.Profile })
+	age := FieldOf(func(p *Profile) *int16 { return &p.Age })
+	assert.Equal(t, "Profile__Age", Related(profile, age).Name())
+	assert.Equal(t, "Profile__Age", NewField[User, int16]("Profile__Age").Name())
+}
+
+func TestFieldCondition(t *testing.T) {
+	name := NewField[genericTestEntity, string]("Name")
+
+	cond := name.Eq("a")
+	assert.Equal(t, []string{"Name"}, cond.params[0].exprs)
+	assert.Equal(t, []interface{}{"a"}, cond.params[0].args)
+
+	cond = name.Ne("a")
+	assert.True(t, cond.params[0].isNot)
+
+	cond = name.In("a", "b")
+	assert.Equal(t, []string{"Name", "in"}, cond.params[0].exprs)
+	assert.Equal(t, []interface{}{"a", "b"}, cond.params[0].args)
+
+	cond = name.Between("a", "b")
+	assert.Equal(t, []string{"Name", "between"}, cond.params[0].exprs)
+
+	cond = name.IsNull(false)
+	assert.Equal(t, []string{"Name", "isnull"}, cond.params[0].exprs)
+	assert.Equal(t, []interface{}{false}, cond.params[0].args)
+
+	order := name.Desc()
+	assert.Equal(t, "Name", order.GetColumn())
+	assert.Equal(t, order_clause.Descending, order.GetSort())
+}
@@ -0,0 +1,77 @@
+// Copyright 2023 beego. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package orm
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/beego/beego/v2/client/orm/internal/models"
+)
+
+var afterReaderType = reflect.TypeOf((*AfterReader)(nil)).Elem()
+
+// callHook calls the hook if the model implements it
+func callHook[H any](md interface{}, call func(h H) error) error {
+	if h, ok := md.(H); ok {
+		return call(h)
+	}
+	return nil
+}
+
+// hookModel returns the pointer of the model, the hooks usually have pointer receivers
+func hookModel(v reflect.Value) interface{} {
+	if v.Kind() != reflect.Ptr && v.CanAddr() {
+		return v.Addr().Interface()
+	}
+	return v.Interface()
+}
+
+// afterRead calls AfterRead on the models in the container,
+// and saves the snapshots of them if the model tracks changes.
+// the container is a model or a slice of models.
+func afterRead(ctx context.Context, o QueryExecutor, mi *models.ModelInfo, container interface{}) error {
+	val := reflect.Indirect(reflect.ValueOf(container))
+	if val.Kind() != reflect.Slice {
+		err := callHook(container, func(h AfterReader) error {
+			return h.AfterRead(ctx, o)
+		})
+		if err == nil {
+			saveSnapshot(mi, val)
+		}
+		return err
+	}
+
+	typ := val.Type().Elem()
+	if typ.Kind() != reflect.Ptr {
+		typ = reflect.PointerTo(typ)
+	}
+	if typ.Implements(afterReaderType) {
+		for i := 0; i < val.Len(); i++ {
+			err := callHook(hookModel(val.Index(i)), func(h AfterReader) error {
+				return h.AfterRead(ctx, o)
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if mi.TrackChanges {
+		for i := 0; i < val.Len(); i++ {
+			saveSnapshot(mi, reflect.Indirect(val.Index(i)))
+		}
+	}
+	return nil
+}
@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"io"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/beego/beego/v2/client/orm/internal/logs"
@@ -35,7 +36,65 @@ func NewLog(out io.Writer) *logs.Log {
 // LogFunc costomer log func
 var LogFunc func(query map[string]interface{})
 
+// QueryStat is the statement executed by the orm, which is passed to the query listeners
+type QueryStat struct {
+	Alias     string
+	Driver    DriverType
+	Operation string
+	Query     string
+	Args      []interface{}
+	Start     time.Time
+	Duration  time.Duration
+	Err       error
+}
+
+var (
+	queryListenersMu sync.RWMutex
+	queryListeners   []func(stat *QueryStat)
+)
+
+// AddQueryListener adds the listener called after each statement in the goroutine of the caller.
+// like Debug, it only works for the Ormer created after it. It is safe for concurrent use.
+func AddQueryListener(listener func(stat *QueryStat)) {
+	queryListenersMu.Lock()
+	defer queryListenersMu.Unlock()
+	// copy on write, so the statements running now keep iterating the old listeners
+	listeners := make([]func(stat *QueryStat), len(queryListeners), len(queryListeners)+1)
+	copy(listeners, queryListeners)
+	queryListeners = append(listeners, listener)
+}
+
+func getQueryListeners() []func(stat *QueryStat) {
+	queryListenersMu.RLock()
+	defer queryListenersMu.RUnlock()
+	return queryListeners
+}
+
+// logQueries checks whether the queries are logged by Debug or the query listeners
+func logQueries() bool {
+	return Debug || len(getQueryListeners()) > 0
+}
+
 func debugLogQueies(alias *alias, operation, query string, t time.Time, err error, args ...interface{}) {
+	if listeners := getQueryListeners(); len(listeners) > 0 {
+		stat := &QueryStat{
+			Alias:     alias.Name,
+			Driver:    alias.Driver,
+			Operation: operation,
+			Query:     query,
+			Args:      args,
+			Start:     t,
+			Duration:  time.Since(t),
+			Err:       err,
+		}
+		for _, listener := range listeners {
+			listener(stat)
+		}
+	}
+	if !Debug {
+		return
+	}
+
 	logMap := make(map[string]interface{})
 	sub := time.Since(t) / 1e5
 	elsp := float64(int(sub)) / 10.0
@@ -85,7 +85,7 @@ func newInsertSet(ctx context.Context, orm *ormBase, mi *models.ModelInfo) (Inse
 	if err != nil {
 		return nil, err
 	}
-	if Debug {
+	if logQueries() {
 		bi.stmt = newStmtQueryLog(orm.alias, st, query)
 	} else {
 		bi.stmt = st
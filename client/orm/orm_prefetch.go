@@ -0,0 +1,374 @@
+// Copyright 2023 beego. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package orm
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/beego/beego/v2/client/orm/clauses/order_clause"
+	"github.com/beego/beego/v2/client/orm/hints"
+	"github.com/beego/beego/v2/client/orm/internal/models"
+	"github.com/beego/beego/v2/core/utils"
+)
+
+// prefetchChunk is the max number of the pks in the IN clause of a prefetch query,
+// which keeps the query under the bind variable limit of the drivers.
+const prefetchChunk = 500
+
+// prefetch is a relation path loaded by QuerySeter.Prefetch,
+// the limit, offset and order are applied to the related models of each parent.
+type prefetch struct {
+	path   string
+	limit  int64
+	offset int64
+	order  string
+}
+
+// parsePrefetches appends the relation names in params to a copy of prefetches,
+// the hints in params are applied to the preceding relation name.
+func parsePrefetches(prefetches []*prefetch, params []interface{}) []*prefetch {
+	res := make([]*prefetch, len(prefetches), len(prefetches)+len(params))
+	copy(res, prefetches)
+	var last *prefetch
+	for _, p := range params {
+		switch val := p.(type) {
+		case string:
+			last = &prefetch{path: val}
+			res = append(res, last)
+		case utils.KV:
+			if last == nil {
+				panic(fmt.Errorf("<QuerySeter.Prefetch> hint must follow a relation name"))
+			}
+			last.setHint(val)
+		default:
+			panic(fmt.Errorf("<QuerySeter.Prefetch> wrong param kind: %v", val))
+		}
+	}
+	return res
+}
+
+func (p *prefetch) setHint(kv utils.KV) {
+	switch kv.GetKey() {
+	case hints.KeyLimit:
+		if v, ok := kv.GetValue().(int64); ok {
+			p.limit = v
+		}
+	case hints.KeyOffset:
+		if v, ok := kv.GetValue().(int64); ok {
+			p.offset = v
+		}
+	case hints.KeyOrderBy:
+		if v, ok := kv.GetValue().(string); ok {
+			p.order = v
+		}
+	default:
+		panic(fmt.Errorf("<QuerySeter.Prefetch> unsupported hint `%v`", kv.GetKey()))
+	}
+}
+
+// prefetchRelated loads the relations of the prefetches for the models in container,
+// one query for each level of the relation paths.
+func (o querySet) prefetchRelated(ctx context.Context, container interface{}) error {
+	parents := prefetchParents(container)
+	if len(parents) == 0 {
+		return nil
+	}
+
+	configs := make(map[string]*prefetch, len(o.prefetches))
+	for _, p := range o.prefetches {
+		configs[p.path] = p
+	}
+
+	type level struct {
+		mi   *models.ModelInfo
+		inds []reflect.Value
+	}
+	loaded := make(map[string]level)
+
+	for _, p := range o.prefetches {
+		mi, inds := o.mi, parents
+		path := ""
+		for _, name := range strings.Split(p.path, ExprSep) {
+			if path != "" {
+				path += ExprSep
+			}
+			path += name
+			if l, ok := loaded[path]; ok {
+				mi, inds = l.mi, l.inds
+				continue
+			}
+			conf := configs[path]
+			if conf == nil {
+				conf = &prefetch{path: path}
+			}
+			var err error
+			mi, inds, err = o.prefetchField(ctx, mi, inds, name, conf)
+			if err != nil {
+				return err
+			}
+			loaded[path] = level{mi: mi, inds: inds}
+		}
+	}
+	return nil
+}
+
+// prefetchParents returns the model values in the container
+func prefetchParents(container interface{}) []reflect.Value {
+	ind := reflect.Indirect(reflect.ValueOf(container))
+	if ind.Kind() != reflect.Slice {
+		return []reflect.Value{ind}
+	}
+	inds := make([]reflect.Value, 0, ind.Len())
+	for i := 0; i < ind.Len(); i++ {
+		if elm := reflect.Indirect(ind.Index(i)); elm.IsValid() {
+			inds = append(inds, elm)
+		}
+	}
+	return inds
+}
+
+// prefetchField loads the relation name of the parents,
+// it returns the model info and the values of the loaded models for the next level.
+func (o querySet) prefetchField(ctx context.Context, mi *models.ModelInfo, parents []reflect.Value, name string, p *prefetch) (*models.ModelInfo, []reflect.Value, error) {
+	fi, ok := mi.Fields.GetByAny(name)
+	if !ok || fi.RelModelInfo == nil {
+		return nil, nil, fmt.Errorf("<QuerySeter.Prefetch> name `%s` for model `%s` is not an available rel/reverse field", name, mi.FullName)
+	}
+
+	var children []reflect.Value
+	var err error
+	switch {
+	case fi.FieldType == RelForeignKey || fi.FieldType == RelOneToOne:
+		children, err = o.prefetchRel(ctx, fi, parents)
+	case fi.FieldType == RelManyToMany ||
+		fi.FieldType == RelReverseMany && fi.ReverseFieldInfo.Mi.IsThrough:
+		children, err = o.prefetchM2M(ctx, mi, fi, parents, p)
+	default:
+		children, err = o.prefetchReverse(ctx, mi, fi, parents, p)
+	}
+	return fi.RelModelInfo, children, err
+}
+
+// prefetchRel loads the related models of the foreign key or one to one field by their pk
+func (o querySet) prefetchRel(ctx context.Context, fi *models.FieldInfo, parents []reflect.Value) ([]reflect.Value, error) {
+	rmi := fi.RelModelInfo
+	if rmi.Fields.Pk == nil {
+		return nil, errPrefetchPk(rmi)
+	}
+	pks := make([]interface{}, 0, len(parents))
+	seen := make(map[interface{}]bool, len(parents))
+	for _, parent := range parents {
+		rel := reflect.Indirect(parent.FieldByIndex(fi.FieldIndex))
+		if !rel.IsValid() {
+			continue
+		}
+		if _, pk, ok := getExistPk(rmi, rel); ok && !seen[pk] {
+			seen[pk] = true
+			pks = append(pks, pk)
+		}
+	}
+	if len(pks) == 0 {
+		return nil, nil
+	}
+
+	qs := newQuerySet(o.orm, rmi).(*querySet)
+	rels, err := qs.prefetchIn(ctx, rmi.Fields.Pk.Name, pks, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	byPk := make(map[interface{}]reflect.Value, len(rels))
+	for _, rel := range rels {
+		_, pk, _ := getExistPk(rmi, rel)
+		byPk[pk] = rel
+	}
+	for _, parent := range parents {
+		field := parent.FieldByIndex(fi.FieldIndex)
+		rel := reflect.Indirect(field)
+		if !rel.IsValid() {
+			continue
+		}
+		_, pk, _ := getExistPk(rmi, rel)
+		if v, ok := byPk[pk]; ok {
+			field.Set(v.Addr())
+		}
+	}
+	return rels, nil
+}
+
+// prefetchReverse loads the models whose foreign key or one to one field refers to the parents
+func (o querySet) prefetchReverse(ctx context.Context, mi *models.ModelInfo, fi *models.FieldInfo, parents []reflect.Value, p *prefetch) ([]reflect.Value, error) {
+	rfi := fi.ReverseFieldInfo
+	byPk, pks, err := prefetchParentPks(mi, parents)
+	if err != nil || len(pks) == 0 {
+		return nil, err
+	}
+
+	qs := newQuerySet(o.orm, fi.RelModelInfo).(*querySet)
+	if p.order != "" {
+		qs.orders = order_clause.ParseOrder(p.order)
+	}
+	children, err := qs.prefetchIn(ctx, rfi.Name, pks, p)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make(map[interface{}][]reflect.Value, len(parents))
+	for _, child := range children {
+		rel := reflect.Indirect(child.FieldByIndex(rfi.FieldIndex))
+		if !rel.IsValid() {
+			continue
+		}
+		_, pk, _ := getExistPk(mi, rel)
+		groups[pk] = append(groups[pk], child)
+	}
+	setPrefetched(fi, byPk, groups)
+	return children, nil
+}
+
+// prefetchM2M loads the related models through the m2m table,
+// the m2m table is joined with the related table in one query.
+func (o querySet) prefetchM2M(ctx context.Context, mi *models.ModelInfo, fi *models.FieldInfo, parents []reflect.Value, p *prefetch) ([]reflect.Value, error) {
+	// the fields of the through model referring to the parent and the related model
+	pfi, rfi := fi.ReverseFieldInfo, fi.ReverseFieldInfoTwo
+	byPk, pks, err := prefetchParentPks(mi, parents)
+	if err != nil || len(pks) == 0 {
+		return nil, err
+	}
+
+	qs := newQuerySet(o.orm, fi.RelThroughModelInfo).(*querySet)
+	if sfi := fi.RelModelInfo.Fields.SoftDelete; sfi != nil && !isWithDeleted(ctx) {
+		qs.cond = NewCondition().And(rfi.Name+ExprSep+sfi.Name+ExprSep+"isnull", true)
+	}
+	qs.related = []string{rfi.Name}
+	if p.order != "" {
+		order := p.order
+		if strings.HasPrefix(order, "-") {
+			order = "-" + rfi.Name + ExprSep + order[1:]
+		} else {
+			order = rfi.Name + ExprSep + order
+		}
+		qs.orders = order_clause.ParseOrder(order)
+	}
+	rows, err := qs.prefetchIn(ctx, pfi.Name, pks, p)
+	if err != nil {
+		return nil, err
+	}
+
+	children := make([]reflect.Value, 0, len(rows))
+	groups := make(map[interface{}][]reflect.Value, len(parents))
+	for _, row := range rows {
+		parent := reflect.Indirect(row.FieldByIndex(pfi.FieldIndex))
+		child := reflect.Indirect(row.FieldByIndex(rfi.FieldIndex))
+		if !parent.IsValid() || !child.IsValid() {
+			continue
+		}
+		_, pk, _ := getExistPk(mi, parent)
+		groups[pk] = append(groups[pk], child)
+		children = append(children, child)
+	}
+	setPrefetched(fi, byPk, groups)
+	return children, nil
+}
+
+// prefetchIn reads the models of the query whose field name is in pks, the pks are queried in chunks.
+// If the prefetch has limit or offset, the pks are queried one by one to apply them to each parent by the database.
+func (o querySet) prefetchIn(ctx context.Context, name string, pks []interface{}, p *prefetch) ([]reflect.Value, error) {
+	size := prefetchChunk
+	if p != nil && (p.limit > 0 || p.offset > 0) {
+		size = 1
+		o.limit, o.offset = p.limit, p.offset
+	}
+	cond := o.cond
+	var res []reflect.Value
+	for start := 0; start < len(pks); start += size {
+		end := min(start+size, len(pks))
+		o.cond = NewCondition().And(name+ExprSep+"in", pks[start:end]...)
+		if cond != nil && !cond.IsEmpty() {
+			o.cond = o.cond.AndCond(cond)
+		}
+		rows, err := o.prefetchAll(ctx)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, rows...)
+	}
+	return res, nil
+}
+
+// prefetchAll reads all the models of the query, and returns the values of them
+func (o querySet) prefetchAll(ctx context.Context) ([]reflect.Value, error) {
+	container := reflect.New(reflect.SliceOf(o.mi.AddrField.Type()))
+	if _, err := o.AllWithCtx(ctx, container.Interface()); err != nil {
+		return nil, err
+	}
+	return prefetchParents(container.Interface()), nil
+}
+
+func prefetchParentPks(mi *models.ModelInfo, parents []reflect.Value) (map[interface{}][]reflect.Value, []interface{}, error) {
+	if mi.Fields.Pk == nil {
+		return nil, nil, errPrefetchPk(mi)
+	}
+	byPk := make(map[interface{}][]reflect.Value, len(parents))
+	pks := make([]interface{}, 0, len(parents))
+	for _, parent := range parents {
+		_, pk, ok := getExistPk(mi, parent)
+		if !ok {
+			continue
+		}
+		if _, ok := byPk[pk]; !ok {
+			pks = append(pks, pk)
+		}
+		byPk[pk] = append(byPk[pk], parent)
+	}
+	return byPk, pks, nil
+}
+
+// errPrefetchPk is returned when the relation of the model is prefetched by the single pk, but it has the composite primary key
+func errPrefetchPk(mi *models.ModelInfo) error {
+	return fmt.Errorf("<QuerySeter.Prefetch> model `%s` has no single pk", mi.FullName)
+}
+
+// setPrefetched sets the related models grouped by the pk of the parents to the field fi
+func setPrefetched(fi *models.FieldInfo, byPk map[interface{}][]reflect.Value, groups map[interface{}][]reflect.Value) {
+	for pk, parents := range byPk {
+		children := groups[pk]
+
+		for _, parent := range parents {
+			field := parent.FieldByIndex(fi.FieldIndex)
+			if field.Kind() != reflect.Slice {
+				// reverse one
+				if len(children) > 0 {
+					field.Set(children[0].Addr())
+				} else {
+					field.Set(reflect.Zero(field.Type()))
+				}
+				continue
+			}
+			slice := reflect.MakeSlice(field.Type(), 0, len(children))
+			for _, child := range children {
+				if field.Type().Elem().Kind() == reflect.Ptr {
+					slice = reflect.Append(slice, child.Addr())
+				} else {
+					slice = reflect.Append(slice, child)
+				}
+			}
+			field.Set(slice)
+		}
+	}
+}
@@ -16,7 +16,9 @@ package orm
 
 import (
 	"context"
+	"fmt"
 	"reflect"
+	"slices"
 
 	"github.com/beego/beego/v2/client/orm/internal/models"
 )
@@ -43,21 +45,29 @@ func (o *queryM2M) Add(mds ...interface{}) (int64, error) {
 }
 
 func (o *queryM2M) AddWithCtx(ctx context.Context, mds ...interface{}) (int64, error) {
+	ctx = ForcePrimary(ctx)
 	fi := o.fi
 	mi := fi.RelThroughModelInfo
 	mfi := fi.ReverseFieldInfo
 	rfi := fi.ReverseFieldInfoTwo
 
-	orm := o.qs.orm
+	orm, release := o.qs.orm.forTenant(ctx)
+	defer release()
 	dbase := orm.alias.DbBaser
 
 	var models []interface{}
 	var otherValues []interface{}
 	var otherNames []string
 
+	if o.mi.Fields.Pk == nil || fi.RelModelInfo.Fields.Pk == nil {
+		return 0, fmt.Errorf("<QueryM2M.Add> the models of m2m field `%s` must have the single pk", fi.FullName)
+	}
+
+	// the columns of the through model except the relations and the pk are set by the non-struct values
+	pkFields := mi.Fields.PkFields()
 	for _, colname := range mi.Fields.DBcols {
 		if colname != mfi.Column && colname != rfi.Column && colname != fi.Mi.Fields.Pk.Column &&
-			mi.Fields.Columns[colname] != mi.Fields.Pk {
+			!slices.Contains(pkFields, mi.Fields.Columns[colname]) {
 			otherNames = append(otherNames, colname)
 		}
 	}
@@ -128,8 +138,7 @@ func (o *queryM2M) Exist(md interface{}) bool {
 
 func (o *queryM2M) ExistWithCtx(ctx context.Context, md interface{}) bool {
 	fi := o.fi
-	return o.qs.Filter(fi.ReverseFieldInfo.Name, o.md).
-		Filter(fi.ReverseFieldInfoTwo.Name, md).ExistWithCtx(ctx)
+	return o.related(ctx).Filter(fi.ReverseFieldInfoTwo.Name, md).ExistWithCtx(ctx)
 }
 
 // Clean All models in related of origin model
@@ -148,8 +157,18 @@ func (o *queryM2M) Count() (int64, error) {
 }
 
 func (o *queryM2M) CountWithCtx(ctx context.Context) (int64, error) {
+	return o.related(ctx).CountWithCtx(ctx)
+}
+
+// related returns the relationships of origin model, the soft deleted related models are excluded
+func (o *queryM2M) related(ctx context.Context) QuerySeter {
 	fi := o.fi
-	return o.qs.Filter(fi.ReverseFieldInfo.Name, o.md).CountWithCtx(ctx)
+	qs := o.qs.Filter(fi.ReverseFieldInfo.Name, o.md)
+	rfi := fi.ReverseFieldInfoTwo
+	if sfi := rfi.RelModelInfo.Fields.SoftDelete; sfi != nil && !isWithDeleted(ctx) {
+		qs = qs.Filter(rfi.Name+ExprSep+sfi.Name+ExprSep+"isnull", true)
+	}
+	return qs
 }
 
 var _ QueryM2Mer = new(queryM2M)
@@ -17,6 +17,9 @@ package orm
 import (
 	"context"
 	"fmt"
+	"reflect"
+	"slices"
+	"time"
 
 	"github.com/beego/beego/v2/client/orm/internal/utils"
 
@@ -70,20 +73,33 @@ func ColValue(opt operator, value interface{}) interface{} {
 
 // real query struct
 type querySet struct {
-	mi        *models.ModelInfo
-	cond      *Condition
-	related   []string
-	relDepth  int
-	limit     int64
-	offset    int64
-	groups    []string
-	orders    []*order_clause.Order
-	distinct  bool
-	forUpdate bool
-	useIndex  int
-	indexes   []string
-	orm       *ormBase
-	aggregate string
+	mi         *models.ModelInfo
+	cond       *Condition
+	related    []string
+	prefetches []*prefetch
+	relDepth   int
+	limit      int64
+	offset     int64
+	groups     []string
+	orders     []*order_clause.Order
+	distinct   bool
+	forUpdate  bool
+	useIndex   int
+	indexes    []string
+	orm        *ormBase
+	aggregate  string
+
+	annotations []Aggregation
+	having      *Condition
+
+	// the filters of the Ormer which creates the QuerySeter
+	decorator *filterOrmDecorator
+	// the ttl of the results in the query cache
+	cacheTTL time.Duration
+
+	withDeleted bool
+	onlyDeleted bool
+	hardDelete  bool
 }
 
 var _ QuerySeter = new(querySet)
@@ -173,6 +189,32 @@ func (o querySet) ForUpdate() QuerySeter {
 	return &o
 }
 
+// include the soft deleted rows
+func (o querySet) WithDeleted() QuerySeter {
+	o.withDeleted = true
+	return &o
+}
+
+// query the soft deleted rows only
+func (o querySet) OnlyDeleted() QuerySeter {
+	o.onlyDeleted = true
+	return &o
+}
+
+// remove the rows instead of soft deleting them
+func (o querySet) HardDelete() QuerySeter {
+	o.hardDelete = true
+	return &o
+}
+
+// readCtx sends the SELECT FOR UPDATE to the primary
+func (o querySet) readCtx(ctx context.Context) context.Context {
+	if o.forUpdate {
+		return ForcePrimary(ctx)
+	}
+	return ctx
+}
+
 // ForceIndex force index for query
 func (o querySet) ForceIndex(indexes ...string) QuerySeter {
 	o.useIndex = hints.KeyForceIndex
@@ -214,6 +256,18 @@ func (o querySet) RelatedSel(params ...interface{}) QuerySeter {
 	return &o
 }
 
+// Cache caches the results in the cache registered by RegisterQueryCache, see QuerySeter.Cache
+func (o querySet) Cache(ttl time.Duration) QuerySeter {
+	o.cacheTTL = ttl
+	return &o
+}
+
+// Prefetch loads the relations after All or One, see QuerySeter.Prefetch
+func (o querySet) Prefetch(params ...interface{}) QuerySeter {
+	o.prefetches = parsePrefetches(o.prefetches, params)
+	return &o
+}
+
 // Set condition to QuerySeter.
 func (o querySet) SetCond(cond *Condition) QuerySeter {
 	o.cond = cond
@@ -231,7 +285,22 @@ func (o querySet) Count() (int64, error) {
 }
 
 func (o querySet) CountWithCtx(ctx context.Context) (int64, error) {
-	return o.orm.alias.DbBaser.Count(ctx, o.orm.db, o, o.mi, o.cond, o.orm.alias.TZ)
+	defer o.forTenant(ctx)()
+	o, shards, err := o.routeShards()
+	if err != nil {
+		return 0, err
+	}
+	if len(shards) > 0 {
+		return sumShards(shards, func(qs querySet) (int64, error) {
+			return qs.CountWithCtx(ctx)
+		})
+	}
+	var cnt int64
+	return o.cached(ctx, "Count", nil, &cnt, func(ctx context.Context, result interface{}) (int64, error) {
+		cnt, err := o.orm.alias.DbBaser.Count(o.readCtx(ctx), o.orm.db, o, o.mi, o.cond, o.orm.alias.TZ)
+		*result.(*int64) = cnt
+		return cnt, err
+	})
 }
 
 // check result empty or not after QuerySeter executed
@@ -240,7 +309,20 @@ func (o querySet) Exist() bool {
 }
 
 func (o querySet) ExistWithCtx(ctx context.Context) bool {
-	cnt, _ := o.orm.alias.DbBaser.Count(ctx, o.orm.db, o, o.mi, o.cond, o.orm.alias.TZ)
+	defer o.forTenant(ctx)()
+	o, shards, err := o.routeShards()
+	if err != nil {
+		return false
+	}
+	for _, qs := range shards {
+		if qs.ExistWithCtx(ctx) {
+			return true
+		}
+	}
+	if len(shards) > 0 {
+		return false
+	}
+	cnt, _ := o.orm.alias.DbBaser.Count(o.readCtx(ctx), o.orm.db, o, o.mi, o.cond, o.orm.alias.TZ)
 	return cnt > 0
 }
 
@@ -250,6 +332,20 @@ func (o querySet) Update(values Params) (int64, error) {
 }
 
 func (o querySet) UpdateWithCtx(ctx context.Context, values Params) (int64, error) {
+	defer o.forTenant(ctx)()
+	if o.decorator != nil {
+		return o.decorator.querySetUpdate(ctx, o, values)
+	}
+	o, shards, err := o.routeShards()
+	if err != nil {
+		return 0, err
+	}
+	if len(shards) > 0 {
+		return sumShards(shards, func(qs querySet) (int64, error) {
+			return qs.UpdateWithCtx(ctx, values)
+		})
+	}
+	ctx = ForcePrimary(ctx)
 	return o.orm.alias.DbBaser.UpdateBatch(ctx, o.orm.db, &o, o.mi, o.cond, values, o.orm.alias.TZ)
 }
 
@@ -259,6 +355,20 @@ func (o querySet) Delete() (int64, error) {
 }
 
 func (o querySet) DeleteWithCtx(ctx context.Context) (int64, error) {
+	defer o.forTenant(ctx)()
+	if o.decorator != nil {
+		return o.decorator.querySetDelete(ctx, o)
+	}
+	o, shards, err := o.routeShards()
+	if err != nil {
+		return 0, err
+	}
+	if len(shards) > 0 {
+		return sumShards(shards, func(qs querySet) (int64, error) {
+			return qs.DeleteWithCtx(ctx)
+		})
+	}
+	ctx = ForcePrimary(ctx)
 	return o.orm.alias.DbBaser.DeleteBatch(ctx, o.orm.db, &o, o.mi, o.cond, o.orm.alias.TZ)
 }
 
@@ -273,6 +383,11 @@ func (o querySet) PrepareInsert() (Inserter, error) {
 }
 
 func (o querySet) PrepareInsertWithCtx(ctx context.Context) (Inserter, error) {
+	defer o.forTenant(ctx)()
+	if s, _ := getSharding(o.mi); s != nil {
+		return nil, fmt.Errorf("<QuerySeter.PrepareInsert> model `%s` is sharded, use Insert instead", o.mi.FullName)
+	}
+	ctx = ForcePrimary(ctx)
 	return newInsertSet(ctx, o.orm, o.mi)
 }
 
@@ -284,7 +399,112 @@ func (o querySet) All(container interface{}, cols ...string) (int64, error) {
 
 // AllWithCtx see All
 func (o querySet) AllWithCtx(ctx context.Context, container interface{}, cols ...string) (int64, error) {
-	return o.orm.alias.DbBaser.ReadBatch(ctx, o.orm.db, o, o.mi, o.cond, container, o.orm.alias.TZ, cols)
+	defer o.forTenant(ctx)()
+	o, shards, err := o.routeShards()
+	if err != nil {
+		return 0, err
+	}
+	if len(shards) > 0 {
+		return o.allShards(ctx, shards, container, cols)
+	}
+	if len(o.annotations) > 0 {
+		if maps, ok := container.(*[]Params); ok {
+			return o.ValuesWithCtx(ctx, maps, cols...)
+		}
+		var maps []Params
+		num, err := o.ValuesWithCtx(ctx, &maps, cols...)
+		if err != nil {
+			return num, err
+		}
+		o.setAnnotated(container, maps)
+		return num, nil
+	}
+	num, err := o.cached(ctx, "All", cols, container, func(ctx context.Context, container interface{}) (int64, error) {
+		return o.orm.alias.DbBaser.ReadBatch(o.readCtx(ctx), o.orm.db, o, o.mi, o.cond, container, o.orm.alias.TZ, cols)
+	})
+	if err != nil {
+		return num, err
+	}
+	if num > 0 && len(o.prefetches) > 0 {
+		if err = o.prefetchRelated(ctx, container); err != nil {
+			return num, err
+		}
+	}
+	return num, afterRead(ctx, o.orm, o.mi, container)
+}
+
+// Iterate query the data and call fn with each model, see QuerySeter.Iterate
+func (o querySet) Iterate(fn func(md interface{}) error, cols ...string) (int64, error) {
+	return o.IterateWithCtx(context.Background(), fn, cols...)
+}
+
+// IterateWithCtx see Iterate
+func (o querySet) IterateWithCtx(ctx context.Context, fn func(md interface{}) error, cols ...string) (int64, error) {
+	defer o.forTenant(ctx)()
+	o, shards, err := o.routeShards()
+	if err != nil {
+		return 0, err
+	}
+	if len(shards) > 0 {
+		// the rows of the shards are not merged
+		if len(o.orders) > 0 || o.limit != 0 || o.offset != 0 {
+			return 0, ErrShardFanOut
+		}
+		return sumShards(shards, func(qs querySet) (int64, error) {
+			return qs.IterateWithCtx(ctx, fn, cols...)
+		})
+	}
+	return o.orm.alias.DbBaser.IterateBatch(o.readCtx(ctx), o.orm.db, o, o.mi, o.cond, o.orm.alias.TZ, cols, func(ind reflect.Value) error {
+		md := ind.Addr().Interface()
+		if err := afterRead(ctx, o.orm, o.mi, md); err != nil {
+			return err
+		}
+		return fn(md)
+	})
+}
+
+// Chunk query the data by pages of the primary key, see QuerySeter.Chunk
+func (o querySet) Chunk(size int, fn func(container interface{}) error) error {
+	return o.ChunkWithCtx(context.Background(), size, fn)
+}
+
+// ChunkWithCtx see Chunk
+func (o querySet) ChunkWithCtx(ctx context.Context, size int, fn func(container interface{}) error) error {
+	defer o.forTenant(ctx)()
+	if size <= 0 {
+		return ErrArgs
+	}
+	pk := o.mi.Fields.Pk
+	if pk == nil {
+		return fmt.Errorf("<QuerySeter.Chunk> model `%s` has no single pk", o.mi.FullName)
+	}
+	typ := reflect.SliceOf(o.mi.AddrField.Type())
+
+	o.offset = 0
+	qs := o.OrderBy(pk.Name).Limit(size)
+	var last interface{}
+	for {
+		page := qs
+		if last != nil {
+			page = qs.Filter(pk.Name+ExprSep+"gt", last)
+		}
+		container := reflect.New(typ)
+		num, err := page.AllWithCtx(ctx, container.Interface())
+		if err != nil {
+			return err
+		}
+		if num == 0 {
+			return nil
+		}
+		slice := container.Elem()
+		if err = fn(slice.Interface()); err != nil {
+			return err
+		}
+		if num < int64(size) {
+			return nil
+		}
+		_, last, _ = getExistPk(o.mi, slice.Index(slice.Len()-1).Elem())
+	}
 }
 
 // One query one row data and map to containers.
@@ -295,8 +515,18 @@ func (o querySet) One(container interface{}, cols ...string) error {
 
 // OneWithCtx check One
 func (o querySet) OneWithCtx(ctx context.Context, container interface{}, cols ...string) error {
+	defer o.forTenant(ctx)()
+	o, shards, err := o.routeShards()
+	if err != nil {
+		return err
+	}
+	if len(shards) > 0 {
+		return o.oneShards(ctx, shards, container, cols)
+	}
 	o.limit = 1
-	num, err := o.orm.alias.DbBaser.ReadBatch(ctx, o.orm.db, o, o.mi, o.cond, container, o.orm.alias.TZ, cols)
+	num, err := o.cached(ctx, "One", cols, container, func(ctx context.Context, container interface{}) (int64, error) {
+		return o.orm.alias.DbBaser.ReadBatch(o.readCtx(ctx), o.orm.db, o, o.mi, o.cond, container, o.orm.alias.TZ, cols)
+	})
 	if err != nil {
 		return err
 	}
@@ -307,7 +537,12 @@ func (o querySet) OneWithCtx(ctx context.Context, container interface{}, cols ..
 	if num > 1 {
 		return ErrMultiRows
 	}
-	return nil
+	if len(o.prefetches) > 0 {
+		if err = o.prefetchRelated(ctx, container); err != nil {
+			return err
+		}
+	}
+	return afterRead(ctx, o.orm, o.mi, container)
 }
 
 // Values query All data and map to []map[string]interface.
@@ -319,7 +554,18 @@ func (o querySet) Values(results *[]Params, exprs ...string) (int64, error) {
 
 // ValuesWithCtx see Values
 func (o querySet) ValuesWithCtx(ctx context.Context, results *[]Params, exprs ...string) (int64, error) {
-	return o.orm.alias.DbBaser.ReadValues(ctx, o.orm.db, o, o.mi, o.cond, exprs, results, o.orm.alias.TZ)
+	defer o.forTenant(ctx)()
+	o, shards, err := o.routeShards()
+	if err != nil {
+		return 0, err
+	}
+	if len(shards) > 0 {
+		return o.readShards(shards, results, func(qs querySet, container interface{}) error {
+			_, err := qs.ValuesWithCtx(ctx, container.(*[]Params), exprs...)
+			return err
+		})
+	}
+	return o.cached(ctx, "Values", exprs, results, o.readValues(exprs))
 }
 
 // ValuesList query data and map to [][]interface
@@ -329,7 +575,18 @@ func (o querySet) ValuesList(results *[]ParamsList, exprs ...string) (int64, err
 }
 
 func (o querySet) ValuesListWithCtx(ctx context.Context, results *[]ParamsList, exprs ...string) (int64, error) {
-	return o.orm.alias.DbBaser.ReadValues(ctx, o.orm.db, o, o.mi, o.cond, exprs, results, o.orm.alias.TZ)
+	defer o.forTenant(ctx)()
+	o, shards, err := o.routeShards()
+	if err != nil {
+		return 0, err
+	}
+	if len(shards) > 0 {
+		return o.readShards(shards, results, func(qs querySet, container interface{}) error {
+			_, err := qs.ValuesListWithCtx(ctx, container.(*[]ParamsList), exprs...)
+			return err
+		})
+	}
+	return o.cached(ctx, "ValuesList", exprs, results, o.readValues(exprs))
 }
 
 // ValuesFlat query all data and map to []interface.
@@ -340,7 +597,25 @@ func (o querySet) ValuesFlat(result *ParamsList, expr string) (int64, error) {
 
 // ValuesFlatWithCtx see ValuesFlat
 func (o querySet) ValuesFlatWithCtx(ctx context.Context, result *ParamsList, expr string) (int64, error) {
-	return o.orm.alias.DbBaser.ReadValues(ctx, o.orm.db, o, o.mi, o.cond, []string{expr}, result, o.orm.alias.TZ)
+	defer o.forTenant(ctx)()
+	o, shards, err := o.routeShards()
+	if err != nil {
+		return 0, err
+	}
+	if len(shards) > 0 {
+		return o.readShards(shards, result, func(qs querySet, container interface{}) error {
+			_, err := qs.ValuesFlatWithCtx(ctx, container.(*ParamsList), expr)
+			return err
+		})
+	}
+	return o.cached(ctx, "ValuesFlat", []string{expr}, result, o.readValues([]string{expr}))
+}
+
+// readValues returns the function to read the values of exprs to the container
+func (o querySet) readValues(exprs []string) func(ctx context.Context, container interface{}) (int64, error) {
+	return func(ctx context.Context, container interface{}) (int64, error) {
+		return o.orm.alias.DbBaser.ReadValues(o.readCtx(ctx), o.orm.db, o, o.mi, o.cond, exprs, container, o.orm.alias.TZ)
+	}
 }
 
 // RowsToMap query rows into map[string]interface with specify key and value column name.
@@ -386,3 +661,18 @@ func (o querySet) Aggregate(s string) QuerySeter {
 	o.aggregate = s
 	return &o
 }
+
+// add the aggregations to the selected columns, see QuerySeter.Annotate
+func (o querySet) Annotate(aggs ...Aggregation) QuerySeter {
+	o.annotations = slices.Concat(o.annotations, aggs)
+	return &o
+}
+
+// add HAVING condition expression, see QuerySeter.Having
+func (o querySet) Having(expr string, args ...interface{}) QuerySeter {
+	if o.having == nil {
+		o.having = NewCondition()
+	}
+	o.having = o.having.And(expr, args...)
+	return &o
+}
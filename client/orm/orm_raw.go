@@ -15,6 +15,7 @@
 package orm
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
@@ -27,9 +28,10 @@ import (
 
 // raw sql string prepared statement
 type rawPrepare struct {
-	rs     *rawSet
-	stmt   stmtQuerier
-	closed bool
+	rs      *rawSet
+	stmt    stmtQuerier
+	closed  bool
+	release func()
 }
 
 func (o *rawPrepare) Exec(args ...interface{}) (sql.Result, error) {
@@ -42,21 +44,25 @@ func (o *rawPrepare) Exec(args ...interface{}) (sql.Result, error) {
 
 func (o *rawPrepare) Close() error {
 	o.closed = true
+	defer o.release()
 	return o.stmt.Close()
 }
 
 func newRawPreparer(rs *rawSet) (RawPreparer, error) {
 	o := new(rawPrepare)
-	o.rs = rs
+	// the connection of the tenant schema is released after the statement is closed
+	o.rs, o.release = rs.forTenant()
+	rs = o.rs
 
 	query := rs.query
 	rs.orm.alias.DbBaser.ReplaceMarks(&query)
 
 	st, err := rs.orm.db.Prepare(query)
 	if err != nil {
+		o.release()
 		return nil, err
 	}
-	if Debug {
+	if logQueries() {
 		o.stmt = newStmtQueryLog(rs.orm.alias, st, query)
 	} else {
 		o.stmt = st
@@ -66,6 +72,7 @@ func newRawPreparer(rs *rawSet) (RawPreparer, error) {
 
 // raw query seter
 type rawSet struct {
+	ctx   context.Context
 	query string
 	args  []interface{}
 	orm   *ormBase
@@ -81,11 +88,14 @@ func (o rawSet) SetArgs(args ...interface{}) RawSeter {
 
 // execute raw sql and return sql.Result
 func (o *rawSet) Exec() (sql.Result, error) {
+	o, release := o.forTenant()
+	defer release()
+
 	query := o.query
 	o.orm.alias.DbBaser.ReplaceMarks(&query)
 
 	args := getFlatParams(nil, o.args, o.orm.alias.TZ)
-	return o.orm.db.Exec(query, args...)
+	return o.orm.db.ExecContext(o.ctx, query, args...)
 }
 
 // Set field value to row container
@@ -285,6 +295,9 @@ func (o *rawSet) loopSetRefs(refs []interface{}, sInds []reflect.Value, nIndsPtr
 
 // query data and map to container
 func (o *rawSet) QueryRow(containers ...interface{}) error {
+	o, release := o.forTenant()
+	defer release()
+
 	var (
 		refs  = make([]interface{}, 0, len(containers))
 		sInds []reflect.Value
@@ -329,7 +342,7 @@ func (o *rawSet) QueryRow(containers ...interface{}) error {
 	o.orm.alias.DbBaser.ReplaceMarks(&query)
 
 	args := getFlatParams(nil, o.args, o.orm.alias.TZ)
-	rows, err := o.orm.db.Query(query, args...)
+	rows, err := o.orm.db.QueryContext(o.ctx, query, args...)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return ErrNoRows
@@ -447,6 +460,9 @@ func (o *rawSet) QueryRow(containers ...interface{}) error {
 
 // QueryRows query data rows and map to container
 func (o *rawSet) QueryRows(containers ...interface{}) (int64, error) {
+	o, release := o.forTenant()
+	defer release()
+
 	var (
 		refs  = make([]interface{}, 0, len(containers))
 		sInds []reflect.Value
@@ -490,7 +506,7 @@ func (o *rawSet) QueryRows(containers ...interface{}) (int64, error) {
 	o.orm.alias.DbBaser.ReplaceMarks(&query)
 
 	args := getFlatParams(nil, o.args, o.orm.alias.TZ)
-	rows, err := o.orm.db.Query(query, args...)
+	rows, err := o.orm.db.QueryContext(o.ctx, query, args...)
 	if err != nil {
 		return 0, err
 	}
@@ -621,6 +637,9 @@ func (o *rawSet) QueryRows(containers ...interface{}) (int64, error) {
 }
 
 func (o *rawSet) readValues(container interface{}, needCols []string) (int64, error) {
+	o, release := o.forTenant()
+	defer release()
+
 	var (
 		maps  []Params
 		lists []ParamsList
@@ -645,7 +664,7 @@ func (o *rawSet) readValues(container interface{}, needCols []string) (int64, er
 	args := getFlatParams(nil, o.args, o.orm.alias.TZ)
 
 	var rs *sql.Rows
-	rs, err := o.orm.db.Query(query, args...)
+	rs, err := o.orm.db.QueryContext(o.ctx, query, args...)
 	if err != nil {
 		return 0, err
 	}
@@ -750,6 +769,9 @@ func (o *rawSet) readValues(container interface{}, needCols []string) (int64, er
 }
 
 func (o *rawSet) queryRowsTo(container interface{}, keyCol, valueCol string) (int64, error) {
+	o, release := o.forTenant()
+	defer release()
+
 	var (
 		maps Params
 		ind  *reflect.Value
@@ -775,7 +797,7 @@ func (o *rawSet) queryRowsTo(container interface{}, keyCol, valueCol string) (in
 
 	args := getFlatParams(nil, o.args, o.orm.alias.TZ)
 
-	rs, err := o.orm.db.Query(query, args...)
+	rs, err := o.orm.db.QueryContext(o.ctx, query, args...)
 	if err != nil {
 		return 0, err
 	}
@@ -913,8 +935,12 @@ func (o *rawSet) Prepare() (RawPreparer, error) {
 	return newRawPreparer(o)
 }
 
-func newRawSet(orm *ormBase, query string, args []interface{}) RawSeter {
+func newRawSet(ctx context.Context, orm *ormBase, query string, args []interface{}) RawSeter {
+	if ctx == nil {
+		ctx = context.Background()
+	}
 	o := new(rawSet)
+	o.ctx = ctx
 	o.query = query
 	o.args = args
 	o.orm = orm
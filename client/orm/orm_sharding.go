@@ -0,0 +1,655 @@
+// Copyright 2023 beego. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package orm
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/beego/beego/v2/client/orm/clauses/order_clause"
+	"github.com/beego/beego/v2/client/orm/internal/models"
+)
+
+// Shard is a table of the sharded model, in the database alias or in the alias of the Ormer if Alias is empty
+type Shard struct {
+	Alias string
+	Table string
+}
+
+// ShardingRule decides the shards of the table of the sharded model
+type ShardingRule interface {
+	// Shards returns all the shards of the table
+	Shards(table string) []Shard
+	// Shard returns the shard of the row whose sharding key is value
+	Shard(table string, value interface{}) (Shard, error)
+}
+
+type hashSharding struct {
+	count   int
+	aliases []string
+}
+
+// HashSharding returns the rule which shards the table into count tables by the hash of the sharding key.
+// The tables are named by the zero padded index, such as order_00 to order_63,
+// the integer keys are taken modulo count, the time keys by their unix nanoseconds, and the string keys are hashed by fnv,
+// the other keys such as the floats are not supported.
+// The shards are split into the aliases in order, such as order_00 to order_31 in the first one of two aliases.
+func HashSharding(count int, aliases ...string) ShardingRule {
+	if count <= 0 {
+		panic(fmt.Errorf("<orm.HashSharding> the count of shards should be positive but got %d", count))
+	}
+	return &hashSharding{count: count, aliases: aliases}
+}
+
+func (r *hashSharding) Shards(table string) []Shard {
+	shards := make([]Shard, 0, r.count)
+	for i := 0; i < r.count; i++ {
+		shards = append(shards, r.shard(table, i))
+	}
+	return shards
+}
+
+func (r *hashSharding) Shard(table string, value interface{}) (Shard, error) {
+	n := int64(r.count)
+	switch v := shardValue(value).(type) {
+	case nil:
+		return Shard{}, errors.New("the sharding key is nil")
+	case int64:
+		return r.shard(table, int((v%n+n)%n)), nil
+	case uint64:
+		return r.shard(table, int(v%uint64(n))), nil
+	case string:
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(v))
+		return r.shard(table, int(h.Sum32()%uint32(n))), nil
+	case time.Time:
+		// the same instant goes to the same shard whatever its location and monotonic clock are
+		return r.Shard(table, v.UTC().UnixNano())
+	default:
+		return Shard{}, fmt.Errorf("the sharding key of type `%T` cannot be hashed", value)
+	}
+}
+
+func (r *hashSharding) shard(table string, i int) Shard {
+	width := len(strconv.Itoa(r.count - 1))
+	if width < 2 {
+		width = 2
+	}
+	shard := Shard{Table: fmt.Sprintf("%s_%0*d", table, width, i)}
+	if len(r.aliases) > 0 {
+		shard.Alias = r.aliases[i*len(r.aliases)/r.count]
+	}
+	return shard
+}
+
+// RangeShard is the shard of the sharding keys less than Upper, the nil Upper has no bound
+type RangeShard struct {
+	Shard
+	Upper interface{}
+}
+
+type rangeSharding struct {
+	shards []RangeShard
+}
+
+// RangeSharding returns the rule which shards the table by the ranges of the sharding key,
+// the row goes to the first shard whose Upper is greater than its key, so the shards should be ordered by Upper.
+// The tables of the shards are named by Table.
+func RangeSharding(shards ...RangeShard) ShardingRule {
+	if len(shards) == 0 {
+		panic(errors.New("<orm.RangeSharding> no shards"))
+	}
+	return &rangeSharding{shards: shards}
+}
+
+func (r *rangeSharding) Shards(string) []Shard {
+	shards := make([]Shard, 0, len(r.shards))
+	for _, s := range r.shards {
+		shards = append(shards, s.Shard)
+	}
+	return shards
+}
+
+func (r *rangeSharding) Shard(_ string, value interface{}) (Shard, error) {
+	if shardValue(value) == nil {
+		return Shard{}, errors.New("the sharding key is nil")
+	}
+	for _, s := range r.shards {
+		if s.Upper == nil || compareShardValues(value, s.Upper) < 0 {
+			return s.Shard, nil
+		}
+	}
+	return Shard{}, fmt.Errorf("no shard for the sharding key `%v`", value)
+}
+
+// sharding is the rule and the sharding key of a sharded model
+type sharding struct {
+	key  string
+	rule ShardingRule
+}
+
+var (
+	shardingsMux sync.RWMutex
+	shardings    = make(map[string]*sharding)
+)
+
+// RegisterSharding shards the table of the model by the rule of the sharding key field, after the model is registered.
+// Read, Insert, Update and Delete of Ormer use the shard of the key of the model, they return the error if the key is the zero value.
+// QuerySeter uses the shards of the key filtered by exact or in, or runs on all the shards and merges the results,
+// which supports the orders of the fields of the model, and the limit and the offset.
+// The merged query reads limit+offset rows from every shard, so the deep pages of many shards are expensive.
+// syncdb creates all the shards instead of the table of the model.
+func RegisterSharding(model interface{}, key string, rule ShardingRule) error {
+	if rule == nil {
+		return errors.New("<orm.RegisterSharding> the sharding rule is nil")
+	}
+	mi := getTypeMi(reflect.Indirect(reflect.ValueOf(model)).Type())
+	if _, ok := mi.Fields.GetByAny(key); !ok {
+		return fmt.Errorf("<orm.RegisterSharding> cannot find the sharding key `%s` of model `%s`", key, mi.FullName)
+	}
+
+	shardingsMux.Lock()
+	defer shardingsMux.Unlock()
+	if _, ok := shardings[mi.FullName]; ok {
+		return fmt.Errorf("<orm.RegisterSharding> model `%s` already sharded", mi.FullName)
+	}
+	shardings[mi.FullName] = &sharding{key: key, rule: rule}
+	return nil
+}
+
+// getSharding returns the sharding and the sharding key field of the model, or nil if it is not sharded
+func getSharding(mi *models.ModelInfo) (*sharding, *models.FieldInfo) {
+	shardingsMux.RLock()
+	s, ok := shardings[mi.FullName]
+	shardingsMux.RUnlock()
+	if !ok {
+		return nil, nil
+	}
+	fi, _ := mi.Fields.GetByAny(s.key)
+	return s, fi
+}
+
+// shardAliases returns the copies of al which use the tables of the shards of the model in al,
+// or al if the model is not sharded.
+func shardAliases(al *alias, mi *models.ModelInfo) []*alias {
+	s, _ := getSharding(mi)
+	if s == nil {
+		return []*alias{al}
+	}
+	var als []*alias
+	for _, shard := range s.rule.Shards(mi.Table) {
+		if shard.Alias == "" || shard.Alias == al.Name {
+			c := *al
+			c.DbBaser = newdbBaserWithShard(al.Driver, al.DbBaser, mi.Table, shard.Table)
+			als = append(als, &c)
+		}
+	}
+	return als
+}
+
+// forShard returns the ormBase of the shard of the model, or o if the model is not sharded.
+// The sharding key of the model should be set, the zero key cannot tell the shard of the row.
+func (o *ormBase) forShard(mi *models.ModelInfo, ind reflect.Value) (*ormBase, error) {
+	s, fi := getSharding(mi)
+	if s == nil {
+		return o, nil
+	}
+	value := getSnapshotValue(fi, ind)
+	if value == nil || reflect.ValueOf(value).IsZero() {
+		return nil, fmt.Errorf("<Ormer> model `%s`, the sharding key `%s` is not set", mi.FullName, fi.Name)
+	}
+	shard, err := s.rule.Shard(mi.Table, value)
+	if err != nil {
+		return nil, fmt.Errorf("<Ormer> model `%s`, %s", mi.FullName, err.Error())
+	}
+	return o.withShard(mi, shard)
+}
+
+// withShard returns the ormBase which uses the alias and the table of the shard,
+// it keeps the connections or the transaction of o if the shard is in the alias of o.
+func (o *ormBase) withShard(mi *models.ModelInfo, shard Shard) (*ormBase, error) {
+	al := o.alias
+	if shard.Alias != "" && shard.Alias != al.Name {
+		if o.insideTx() {
+			return nil, fmt.Errorf("<Ormer> the shard `%s` is not in the database `%s` of the transaction", shard.Table, al.Name)
+		}
+		var ok bool
+		if al, ok = dataBaseCache.get(shard.Alias); !ok {
+			return nil, fmt.Errorf("<Ormer> unknown DataBase alias name `%s` of shard `%s`", shard.Alias, shard.Table)
+		}
+	}
+	c := *al
+	c.DbBaser = newdbBaserWithShard(al.Driver, al.DbBaser, mi.Table, shard.Table)
+	if al == o.alias {
+		return &ormBase{alias: &c, db: o.db, sharded: true}, nil
+	}
+	b := newOrmBase(&c)
+	b.sharded = true
+	return &b, nil
+}
+
+// shardModels splits the models of sind by their shards, the models which are not sharded are in one group of o
+func (o *ormBase) shardModels(mi *models.ModelInfo, sind reflect.Value) ([]*ormBase, []reflect.Value, error) {
+	if s, _ := getSharding(mi); s == nil {
+		return []*ormBase{o}, []reflect.Value{sind}, nil
+	}
+	var (
+		orms   []*ormBase
+		groups []reflect.Value
+		index  = make(map[string]int)
+	)
+	for i := 0; i < sind.Len(); i++ {
+		ob, err := o.forShard(mi, reflect.Indirect(sind.Index(i)))
+		if err != nil {
+			return nil, nil, err
+		}
+		key := ob.alias.Name + "." + ob.alias.DbBaser.TableName(mi)
+		j, ok := index[key]
+		if !ok {
+			j = len(orms)
+			index[key] = j
+			orms = append(orms, ob)
+			groups = append(groups, reflect.MakeSlice(reflect.SliceOf(sind.Type().Elem()), 0, 1))
+		}
+		groups[j] = reflect.Append(groups[j], sind.Index(i))
+	}
+	return orms, groups, nil
+}
+
+// shards returns the querySets of the shards of the query, or nil if the model is not sharded or the query is routed.
+// The query uses the shards of the sharding key filtered by exact or in, or all the shards.
+func (o querySet) shards() ([]querySet, error) {
+	s, fi := getSharding(o.mi)
+	if s == nil {
+		return nil, nil
+	}
+	if _, tables := o.orm.alias.DbBaser.(tableNamer).tableNames(); tables[o.mi.Table] != "" {
+		return nil, nil
+	}
+	var shards []Shard
+	if values := getShardKeyArgs(o.cond, fi); len(values) > 0 {
+		seen := make(map[Shard]bool, len(values))
+		for _, value := range values {
+			shard, err := s.rule.Shard(o.mi.Table, value)
+			if err != nil {
+				return nil, fmt.Errorf("<QuerySeter> model `%s`, %s", o.mi.FullName, err.Error())
+			}
+			if !seen[shard] {
+				seen[shard] = true
+				shards = append(shards, shard)
+			}
+		}
+	} else {
+		shards = s.rule.Shards(o.mi.Table)
+	}
+	if len(shards) == 0 {
+		return nil, fmt.Errorf("<QuerySeter> model `%s` has no shards", o.mi.FullName)
+	}
+
+	qss := make([]querySet, 0, len(shards))
+	for _, shard := range shards {
+		ob, err := o.orm.withShard(o.mi, shard)
+		if err != nil {
+			return nil, err
+		}
+		qs := o
+		qs.orm = ob
+		qss = append(qss, qs)
+	}
+	return qss, nil
+}
+
+// routeShards returns the querySet of the only shard of the query,
+// or the querySets of the shards if the query runs on more than one shard.
+func (o querySet) routeShards() (querySet, []querySet, error) {
+	qss, err := o.shards()
+	if err != nil || len(qss) == 0 {
+		return o, nil, err
+	}
+	if len(qss) == 1 {
+		return qss[0], nil, nil
+	}
+	return o, qss, nil
+}
+
+// getShardKeyArgs returns the values of the sharding key filtered by exact or in, or nil if the condition cannot decide the shards.
+// The params joined by OR are split into the groups like the where clause, every group should filter the sharding key,
+// and the shards of the query are the shards of the values of all the groups. The conditions with NOT are ignored.
+func getShardKeyArgs(cond *Condition, fi *models.FieldInfo) []interface{} {
+	if cond == nil || cond.IsEmpty() {
+		return nil
+	}
+	var values []interface{}
+	start := 0
+	for i := 1; i <= len(cond.params); i++ {
+		if i < len(cond.params) && !cond.params[i].isOr {
+			continue
+		}
+		group := getShardKeyGroupArgs(cond.params[start:i], fi)
+		if len(group) == 0 {
+			return nil
+		}
+		values = append(values, group...)
+		start = i
+	}
+	return values
+}
+
+// getShardKeyGroupArgs returns the values of the sharding key filtered by the params joined by AND
+func getShardKeyGroupArgs(params []condValue, fi *models.FieldInfo) []interface{} {
+	for _, p := range params {
+		if p.isNot || p.isRaw || p.sub != nil {
+			continue
+		}
+		if p.isCond {
+			if values := getShardKeyArgs(p.cond, fi); len(values) > 0 {
+				return values
+			}
+			continue
+		}
+		exprs := p.exprs
+		if len(exprs) == 2 && (exprs[1] == "exact" || exprs[1] == "eq" || exprs[1] == "in") {
+			exprs = exprs[:1]
+		}
+		if len(exprs) != 1 {
+			continue
+		}
+		if f, ok := fi.Mi.Fields.GetByAny(exprs[0]); !ok || f != fi {
+			continue
+		}
+		var values []interface{}
+		for _, arg := range p.args {
+			val := reflect.ValueOf(arg)
+			if (val.Kind() == reflect.Slice || val.Kind() == reflect.Array) && val.Type().Elem().Kind() != reflect.Uint8 {
+				for i := 0; i < val.Len(); i++ {
+					values = append(values, getShardKeyArg(fi, val.Index(i).Interface()))
+				}
+				continue
+			}
+			values = append(values, getShardKeyArg(fi, arg))
+		}
+		return values
+	}
+	return nil
+}
+
+// getShardKeyArg converts the string argument of the integer sharding key to the integer
+func getShardKeyArg(fi *models.FieldInfo, arg interface{}) interface{} {
+	s, ok := arg.(string)
+	if !ok || fi.FieldType&IsIntegerField == 0 {
+		return arg
+	}
+	if fi.FieldType&IsPositiveIntegerField > 0 {
+		if v, err := strconv.ParseUint(s, 10, 64); err == nil {
+			return v
+		}
+	} else if v, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return v
+	}
+	return arg
+}
+
+// checkFanOut checks whether the results of the query can be merged from the shards
+func (o querySet) checkFanOut() error {
+	if len(o.groups) > 0 || len(o.annotations) > 0 || o.aggregate != "" || o.distinct {
+		return ErrShardFanOut
+	}
+	return nil
+}
+
+// sumShards sums the numbers of the shards
+func sumShards(qss []querySet, fn func(qs querySet) (int64, error)) (int64, error) {
+	var sum int64
+	for _, qs := range qss {
+		num, err := fn(qs)
+		sum += num
+		if err != nil {
+			return sum, err
+		}
+	}
+	return sum, nil
+}
+
+// readShards reads the rows of the shards into the pointer of the slice container by read,
+// the rows are sorted by the orders of o, and limited by the limit and the offset of o.
+// Every shard reads limit+offset rows since the rows of the offset may be in any shard,
+// so the deep pages read and sort (limit+offset)*shards rows in memory, and the query without the limit reads all the rows.
+func (o querySet) readShards(qss []querySet, container interface{}, read func(qs querySet, container interface{}) error) (int64, error) {
+	if err := o.checkFanOut(); err != nil {
+		return 0, err
+	}
+	val := reflect.ValueOf(container)
+	ind := reflect.Indirect(val)
+	if val.Kind() != reflect.Ptr || ind.Kind() != reflect.Slice {
+		return 0, fmt.Errorf("<QuerySeter> the container of the shards should be the pointer of slice but got `%s`", val.Type())
+	}
+
+	limit := o.limit
+	if limit == 0 {
+		limit = int64(DefaultRowsLimit)
+	}
+	rows := reflect.MakeSlice(ind.Type(), 0, 0)
+	for _, qs := range qss {
+		qs.offset = 0
+		qs.limit = -1
+		if limit > 0 {
+			qs.limit = limit + o.offset
+		}
+		part := reflect.New(ind.Type())
+		if err := read(qs, part.Interface()); err != nil {
+			return 0, err
+		}
+		rows = reflect.AppendSlice(rows, part.Elem())
+	}
+
+	if len(o.orders) > 0 {
+		if err := o.sortShardRows(rows); err != nil {
+			return 0, err
+		}
+	}
+	start, end := int(o.offset), rows.Len()
+	if start > end {
+		start = end
+	}
+	if limit > 0 && start+int(limit) < end {
+		end = start + int(limit)
+	}
+	ind.Set(rows.Slice(start, end))
+	return int64(end - start), nil
+}
+
+// sortShardRows sorts the models or the Params of the shards by the orders of the fields
+func (o querySet) sortShardRows(rows reflect.Value) error {
+	fis := make([]*models.FieldInfo, 0, len(o.orders))
+	for _, order := range o.orders {
+		fi, ok := o.mi.Fields.GetByAny(order.GetColumn())
+		if order.IsRaw() || !ok {
+			return fmt.Errorf("<QuerySeter> the results of the shards can only be ordered by the fields of model `%s`", o.mi.FullName)
+		}
+		fis = append(fis, fi)
+	}
+
+	values := make([][]interface{}, rows.Len())
+	for i := range values {
+		row := reflect.Indirect(rows.Index(i))
+		values[i] = make([]interface{}, len(fis))
+		for j, fi := range fis {
+			switch {
+			case row.Type() == o.mi.AddrField.Type().Elem():
+				values[i][j] = getSnapshotValue(fi, row)
+			case row.Type() == reflect.TypeOf(Params{}):
+				p := row.Interface().(Params)
+				v, ok := p[fi.Name]
+				if !ok {
+					v, ok = p[fi.Column]
+				}
+				if !ok {
+					v, ok = p[o.orders[j].GetColumn()]
+				}
+				if !ok {
+					return fmt.Errorf("<QuerySeter> the results of the shards are ordered by `%s` which is not in the values", o.orders[j].GetColumn())
+				}
+				values[i][j] = v
+			default:
+				return fmt.Errorf("<QuerySeter> the results `%s` of the shards cannot be ordered", rows.Type())
+			}
+		}
+	}
+
+	index := make([]int, rows.Len())
+	for i := range index {
+		index[i] = i
+	}
+	sort.SliceStable(index, func(a, b int) bool {
+		for j, order := range o.orders {
+			c := compareShardValues(values[index[a]][j], values[index[b]][j])
+			if order.GetSort() == order_clause.Descending {
+				c = -c
+			}
+			if c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	})
+	sorted := reflect.MakeSlice(rows.Type(), rows.Len(), rows.Len())
+	for i, j := range index {
+		sorted.Index(i).Set(rows.Index(j))
+	}
+	reflect.Copy(rows, sorted)
+	return nil
+}
+
+// allShards reads the models of the shards into container, see readShards
+func (o querySet) allShards(ctx context.Context, qss []querySet, container interface{}, cols []string) (int64, error) {
+	return o.readShards(qss, container, func(qs querySet, container interface{}) error {
+		_, err := qs.AllWithCtx(ctx, container, cols...)
+		return err
+	})
+}
+
+// oneShards reads the first model of the shards into the pointer of the model container
+func (o querySet) oneShards(ctx context.Context, qss []querySet, container interface{}, cols []string) error {
+	val := reflect.ValueOf(container)
+	if val.Kind() != reflect.Ptr || val.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("<QuerySeter> the container of the shards should be the pointer of struct but got `%s`", val.Type())
+	}
+	rows := reflect.New(reflect.SliceOf(val.Type()))
+	o.limit = 1
+	num, err := o.allShards(ctx, qss, rows.Interface(), cols)
+	if err != nil {
+		return err
+	}
+	if num == 0 {
+		return ErrNoRows
+	}
+	val.Elem().Set(rows.Elem().Index(0).Elem())
+	return nil
+}
+
+// shardValue returns the comparable value of the sharding key or the ordered field,
+// the integers are int64 or uint64, and the floats are float64.
+func shardValue(v interface{}) interface{} {
+	switch x := v.(type) {
+	case nil:
+		return nil
+	case Fielder:
+		return shardValue(x.RawValue())
+	case time.Time:
+		return x
+	case []byte:
+		return string(x)
+	}
+	val := reflect.ValueOf(v)
+	switch val.Kind() {
+	case reflect.Ptr:
+		if val.IsNil() {
+			return nil
+		}
+		return shardValue(val.Elem().Interface())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return val.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return val.Uint()
+	case reflect.Float32, reflect.Float64:
+		return val.Float()
+	case reflect.String:
+		return val.String()
+	case reflect.Bool:
+		if val.Bool() {
+			return int64(1)
+		}
+		return int64(0)
+	}
+	return v
+}
+
+// compareShardValues compares the values like the database, the nil is the smallest
+func compareShardValues(a, b interface{}) int {
+	a, b = shardValue(a), shardValue(b)
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	}
+	switch x := a.(type) {
+	case int64:
+		if y, ok := b.(int64); ok {
+			return cmp.Compare(x, y)
+		}
+	case uint64:
+		if y, ok := b.(uint64); ok {
+			return cmp.Compare(x, y)
+		}
+	case string:
+		if y, ok := b.(string); ok {
+			return strings.Compare(x, y)
+		}
+	case time.Time:
+		if y, ok := b.(time.Time); ok {
+			return x.Compare(y)
+		}
+	}
+	if x, ok := shardFloat(a); ok {
+		if y, ok := shardFloat(b); ok {
+			return cmp.Compare(x, y)
+		}
+	}
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+func shardFloat(v interface{}) (float64, bool) {
+	switch x := v.(type) {
+	case int64:
+		return float64(x), true
+	case uint64:
+		return float64(x), true
+	case float64:
+		return x, true
+	}
+	return 0, false
+}
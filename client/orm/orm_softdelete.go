@@ -0,0 +1,104 @@
+// Copyright 2023 beego. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package orm
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"github.com/beego/beego/v2/client/orm/internal/models"
+)
+
+// The model which has a datetime field with the soft_delete tag is soft deleted:
+//
+//	type User struct {
+//		Id        int
+//		DeletedAt time.Time `orm:"null;soft_delete"`
+//	}
+//
+// Delete sets the field to now instead of deleting the row,
+// and the queries exclude the rows whose field is not null.
+
+type withDeletedKey struct{}
+
+type hardDeleteKey struct{}
+
+// WithDeleted returns a context which makes Read, LoadRelated and QueryM2M include the soft deleted rows.
+// It's the same as QuerySeter.WithDeleted for the queries executed with the context.
+func WithDeleted(ctx context.Context) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, withDeletedKey{}, true)
+}
+
+// HardDelete returns a context which makes Delete remove the rows of the soft deleted models
+func HardDelete(ctx context.Context) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, hardDeleteKey{}, true)
+}
+
+func isWithDeleted(ctx context.Context) bool {
+	v, _ := ctx.Value(withDeletedKey{}).(bool)
+	return v
+}
+
+func isHardDelete(ctx context.Context, qs *querySet) bool {
+	if qs != nil && qs.hardDelete {
+		return true
+	}
+	v, _ := ctx.Value(hardDeleteKey{}).(bool)
+	return v
+}
+
+// getSoftDeleteCond returns the cond which excludes the soft deleted rows,
+// or selects them only after QuerySeter.OnlyDeleted
+func getSoftDeleteCond(ctx context.Context, qs *querySet, mi *models.ModelInfo, cond *Condition) *Condition {
+	fi := mi.Fields.SoftDelete
+	if fi == nil {
+		return cond
+	}
+
+	onlyDeleted := qs != nil && qs.onlyDeleted
+	if !onlyDeleted && (isWithDeleted(ctx) || qs != nil && qs.withDeleted) {
+		return cond
+	}
+
+	softCond := NewCondition().And(fi.Name+ExprSep+"isnull", !onlyDeleted)
+	if cond == nil || cond.IsEmpty() {
+		return softCond
+	}
+	return softCond.AndCond(cond)
+}
+
+// setSoftDeleted sets the soft_delete field of the model, zero t means not deleted
+func setSoftDeleted(fi *models.FieldInfo, ind reflect.Value, t time.Time) {
+	field := ind.FieldByIndex(fi.FieldIndex)
+	switch {
+	case fi.IsFielder:
+		_ = field.Addr().Interface().(models.Fielder).SetRaw(t)
+	case field.Kind() == reflect.Ptr:
+		if t.IsZero() {
+			field.Set(reflect.Zero(field.Type()))
+		} else {
+			field.Set(reflect.ValueOf(&t))
+		}
+	default:
+		field.Set(reflect.ValueOf(t))
+	}
+}
@@ -0,0 +1,368 @@
+// Copyright 2023 beego. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package orm
+
+import (
+	"context"
+	"database/sql"
+	sqldriver "database/sql/driver"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// tenant is the database, the schema or the table prefix of a tenant
+type tenant struct {
+	name        string
+	alias       string
+	schema      string
+	tablePrefix string
+
+	// al is the alias used by the queries of the tenant
+	al *alias
+}
+
+type TenantOption func(t *tenant)
+
+// TenantAlias sets the registered database alias of the tenant, it defaults to "default"
+func TenantAlias(name string) TenantOption {
+	return func(t *tenant) {
+		t.alias = name
+	}
+}
+
+// TenantSchema sets the schema of the tenant, only postgres is supported, and the schema must not contain `"`.
+// The tenant shares the connections of the alias, each method of Ormer, QuerySeter and RawSeter takes a connection
+// of the primary database and sets the search_path of the schema, which is reset when the method returns,
+// and the transaction sets the search_path by SET LOCAL.
+func TenantSchema(schema string) TenantOption {
+	return func(t *tenant) {
+		t.schema = schema
+	}
+}
+
+// TenantTablePrefix sets the prefix of the table names of the tenant,
+// it is added to the table names of the models, and the raw sql is not changed.
+func TenantTablePrefix(prefix string) TenantOption {
+	return func(t *tenant) {
+		t.tablePrefix = prefix
+	}
+}
+
+var (
+	tenantsMux     sync.RWMutex
+	tenants        = make(map[string]*tenant)
+	tenantResolver = TenantFromContext
+)
+
+type tenantKey struct{}
+
+// WithTenant returns the context with the tenant, which is used by the default tenant resolver
+func WithTenant(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, name)
+}
+
+// TenantFromContext returns the tenant set by WithTenant
+func TenantFromContext(ctx context.Context) string {
+	name, _ := ctx.Value(tenantKey{}).(string)
+	return name
+}
+
+// RegisterTenantResolver sets the function which returns the tenant of the context, it defaults to TenantFromContext.
+// The empty tenant means the queries use the alias of the Ormer.
+func RegisterTenantResolver(resolver func(ctx context.Context) string) {
+	tenantResolver = resolver
+}
+
+// RegisterTenant registers the tenant after its database alias is registered.
+// The methods with ctx of Ormer and QuerySeter use the alias, the schema and the table prefix of the tenant
+// resolved from ctx, and the transaction uses the tenant resolved when it begins.
+// They panic if the resolved tenant is not registered, like the unknown alias.
+func RegisterTenant(name string, opts ...TenantOption) error {
+	t := &tenant{name: name, alias: "default"}
+	for _, opt := range opts {
+		opt(t)
+	}
+
+	tenantsMux.Lock()
+	defer tenantsMux.Unlock()
+	if _, ok := tenants[name]; ok {
+		return fmt.Errorf("tenant `%s` already registered", name)
+	}
+
+	base, ok := dataBaseCache.get(t.alias)
+	if !ok {
+		return fmt.Errorf("unknown DataBase alias name `%s` of tenant `%s`", t.alias, name)
+	}
+	if t.schema != "" {
+		if err := checkTenantSchema(base, t.schema); err != nil {
+			return fmt.Errorf("register tenant `%s`, %s", name, err.Error())
+		}
+	}
+	al := base
+	if t.schema != "" || t.tablePrefix != "" {
+		c := *base
+		c.DbBaser = newdbBaserOfTenant(base.Driver, t.tablePrefix, t.schema)
+		c.Schema = t.schema
+		c.Tenant = name
+		al = &c
+	}
+	t.al = al
+	tenants[name] = t
+	return nil
+}
+
+// Tenants returns the names of the registered tenants
+func Tenants() []string {
+	tenantsMux.RLock()
+	defer tenantsMux.RUnlock()
+	names := make([]string, 0, len(tenants))
+	for name := range tenants {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func getTenant(name string) (*tenant, bool) {
+	tenantsMux.RLock()
+	defer tenantsMux.RUnlock()
+	t, ok := tenants[name]
+	return t, ok
+}
+
+// tenantAlias returns the alias of the tenant resolved from ctx, or al if there is no tenant
+func tenantAlias(ctx context.Context, al *alias) *alias {
+	name := tenantResolver(ctx)
+	if name == "" {
+		return al
+	}
+	t, ok := getTenant(name)
+	if !ok {
+		panic(fmt.Errorf("<Ormer> unknown tenant `%s`", name))
+	}
+	return t.al
+}
+
+func noRelease() {}
+
+// forTenant returns the ormBase of the tenant resolved from ctx and the function releasing it,
+// the queries inside a transaction keep the tenant of the transaction.
+// The ormBase of the tenant schema uses a pinned connection until it is released.
+func (o *ormBase) forTenant(ctx context.Context) (*ormBase, func()) {
+	if o.insideTx() || o.sharded {
+		return o, noRelease
+	}
+	al := tenantAlias(ctx, o.alias)
+	if al == o.alias {
+		return o, noRelease
+	}
+	b := newOrmBase(al)
+	if al.Schema == "" {
+		return &b, noRelease
+	}
+	conn := &schemaConn{al: al}
+	b.db = conn
+	if logQueries() {
+		b.db = newDbQueryLog(al, conn)
+	}
+	return &b, conn.release
+}
+
+// forTenant sets the ormBase of the tenant resolved from ctx, and returns the function releasing it
+func (o *querySet) forTenant(ctx context.Context) func() {
+	b, release := o.orm.forTenant(ctx)
+	o.orm = b
+	return release
+}
+
+// forTenant returns the copy of the rawSet using the ormBase of the tenant resolved from its ctx,
+// and the function releasing it
+func (o *rawSet) forTenant() (*rawSet, func()) {
+	b, release := o.orm.forTenant(o.ctx)
+	r := *o
+	r.orm = b
+	return &r, release
+}
+
+// tenantQuerier returns the querier of the schema inspection and the DDL of the alias,
+// which is a pinned connection if the alias is of a tenant schema.
+func tenantQuerier(al *alias) (dbQuerier, func()) {
+	if al.Schema == "" {
+		return al.DB, noRelease
+	}
+	conn := &schemaConn{al: al}
+	return conn, conn.release
+}
+
+func checkTenantSchema(base *alias, schema string) error {
+	if schema == "" || strings.Contains(schema, `"`) {
+		return fmt.Errorf("invalid schema `%s`", schema)
+	}
+	if base.Driver != DRPostgres {
+		return errors.New("the schema is only supported by the postgres alias")
+	}
+	return nil
+}
+
+// quoteSchema returns the quoted schema of the tenant alias, which does not contain the quote
+func quoteSchema(al *alias) string {
+	Q := al.DbBaser.TableQuote()
+	return Q + al.Schema + Q
+}
+
+// setLocalSearchPath sets the search_path of the tenant schema in the transaction
+func setLocalSearchPath(ctx context.Context, al *alias, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, "SET LOCAL search_path TO "+quoteSchema(al))
+	return err
+}
+
+// schemaConn is the connection of the primary database of the alias with the search_path of the tenant schema,
+// it is taken from the pool by the first statement, and reset and put back to the pool by release.
+// Like the connection, it is not safe for concurrent use.
+type schemaConn struct {
+	al   *alias
+	conn *sql.Conn
+}
+
+var _ dbQuerier = new(schemaConn)
+
+func (c *schemaConn) get(ctx context.Context) (*sql.Conn, error) {
+	if c.conn != nil {
+		return c.conn, nil
+	}
+	conn, err := c.al.DB.DB.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = conn.ExecContext(ctx, "SET search_path TO "+quoteSchema(c.al)); err != nil {
+		c.discard(conn)
+		return nil, err
+	}
+	c.conn = conn
+	return conn, nil
+}
+
+// release resets the search_path and puts the connection back to the pool
+func (c *schemaConn) release() {
+	if c.conn == nil {
+		return
+	}
+	if _, err := c.conn.ExecContext(context.Background(), "RESET search_path"); err != nil {
+		c.discard(c.conn)
+	} else {
+		_ = c.conn.Close()
+	}
+	c.conn = nil
+}
+
+// discard closes the connection instead of putting it back to the pool,
+// because its search_path may not be reset
+func (c *schemaConn) discard(conn *sql.Conn) {
+	_ = conn.Raw(func(interface{}) error {
+		return sqldriver.ErrBadConn
+	})
+	_ = conn.Close()
+}
+
+func (c *schemaConn) Prepare(query string) (*sql.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *schemaConn) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	conn, err := c.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return conn.PrepareContext(ctx, query)
+}
+
+func (c *schemaConn) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return c.ExecContext(context.Background(), query, args...)
+}
+
+func (c *schemaConn) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	conn, err := c.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return conn.ExecContext(ctx, query, args...)
+}
+
+func (c *schemaConn) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return c.QueryContext(context.Background(), query, args...)
+}
+
+func (c *schemaConn) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	conn, err := c.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return conn.QueryContext(ctx, query, args...)
+}
+
+func (c *schemaConn) QueryRow(query string, args ...interface{}) *sql.Row {
+	return c.QueryRowContext(context.Background(), query, args...)
+}
+
+func (c *schemaConn) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	conn, err := c.get(ctx)
+	if err != nil {
+		// sql.Row with the error cannot be created, so it is the row of the canceled query
+		DebugLog.Println(fmt.Sprintf("[WARN] cannot set the search_path of tenant `%s`: %s", c.al.Tenant, err))
+		canceled, cancel := context.WithCancel(ctx)
+		cancel()
+		return c.al.DB.DB.QueryRowContext(canceled, query, args...)
+	}
+	return conn.QueryRowContext(ctx, query, args...)
+}
+
+// RunSyncdbTenants runs syncdb for all the registered tenants,
+// and creates the schemas of the tenants if they do not exist.
+func RunSyncdbTenants(force bool, verbose bool) error {
+	BootStrap()
+
+	cmd := new(commandSyncDb)
+	cmd.tenants = true
+	cmd.force = force
+	cmd.noInfo = !verbose
+	cmd.verbose = verbose
+	cmd.rtOnError = true
+	return cmd.Run()
+}
+
+// runTenants runs the command for the alias of each registered tenant
+func (d *commandSyncDb) runTenants() error {
+	for _, name := range Tenants() {
+		t, _ := getTenant(name)
+		if !d.noInfo {
+			fmt.Printf("sync tenant `%s`\n", name)
+		}
+		if t.schema != "" {
+			if _, err := t.al.DB.Exec("CREATE SCHEMA IF NOT EXISTS " + quoteSchema(t.al)); err != nil {
+				return fmt.Errorf("sync tenant `%s`, %s", name, err.Error())
+			}
+		}
+		cmd := *d
+		cmd.al = t.al
+		cmd.tenants = false
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("sync tenant `%s`, %s", name, err.Error())
+		}
+	}
+	return nil
+}
@@ -18,8 +18,10 @@ import (
 	"bytes"
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"math"
+	"net/netip"
 	"os"
 	"path/filepath"
 	"reflect"
@@ -34,8 +36,10 @@ import (
 
 	"github.com/stretchr/testify/assert"
 
+	"github.com/beego/beego/v2/client/cache"
 	"github.com/beego/beego/v2/client/orm/clauses/order_clause"
 	"github.com/beego/beego/v2/client/orm/hints"
+	"github.com/beego/beego/v2/core/berror"
 )
 
 var _ = os.PathSeparator
@@ -211,10 +215,29 @@ func TestSyncDb(t *testing.T) {
 	RegisterModel(new(StrPk))
 	RegisterModel(new(TM))
 	RegisterModel(new(DeptInfo))
+	RegisterModel(new(SoftDeleteUser), new(SoftDeletePost))
+	RegisterModel(new(VersionedPost))
+	RegisterModel(new(HookedPost), new(HookLog))
+	RegisterModel(new(Product))
+	RegisterModel(new(GroupMember))
+	RegisterModel(new(JSONDoc))
+	RegisterModel(new(ExtendedDoc))
+	RegisterModel(new(ShardOrder))
+	RegisterModel(new(TrackedProfile))
 
 	err := RunSyncdb("default", true, Debug)
 	throwFail(t, err)
 
+	// the tables created by syncdb should match the models
+	switch getDbAlias("default").Driver {
+	case DRMySQL, DRPostgres, DRSqlite:
+		diff, err := DiffSchema(context.Background(), "default")
+		throwFail(t, err)
+		for _, c := range diff.Changes {
+			t.Errorf("unexpected schema change %s: %v", c, c.Up)
+		}
+	}
+
 	defaultModelCache.Clean()
 }
 
@@ -240,6 +263,15 @@ func TestRegisterModels(_ *testing.T) {
 	RegisterModel(new(StrPk))
 	RegisterModel(new(TM))
 	RegisterModel(new(DeptInfo))
+	RegisterModel(new(SoftDeleteUser), new(SoftDeletePost))
+	RegisterModel(new(VersionedPost))
+	RegisterModel(new(HookedPost), new(HookLog))
+	RegisterModel(new(Product))
+	RegisterModel(new(GroupMember))
+	RegisterModel(new(JSONDoc))
+	RegisterModel(new(ExtendedDoc))
+	RegisterModel(new(ShardOrder))
+	RegisterModel(new(TrackedProfile))
 
 	BootStrap()
 
@@ -1302,6 +1334,94 @@ func TestOne(t *testing.T) {
 	throwFail(t, AssertIs(err, ErrNoRows))
 }
 
+func TestIterate(t *testing.T) {
+	var names []string
+	qs := dORM.QueryTable("user")
+	num, err := qs.OrderBy("Id").RelatedSel().Iterate(func(md interface{}) error {
+		user := md.(*User)
+		names = append(names, user.UserName)
+		if user.UserName == "slene" {
+			throwFail(t, AssertIs(user.Profile.Age, 28))
+		}
+		return nil
+	})
+	throwFailNow(t, err)
+	throwFail(t, AssertIs(num, 3))
+	throwFail(t, AssertIs(strings.Join(names, ","), "slene,astaxie,nobody"))
+
+	stop := errors.New("stop")
+	names = nil
+	num, err = NewQuery[User](dORM).OrderBy(NewField[User, int]("Id").Desc()).Iterate(func(user *User) error {
+		names = append(names, user.UserName)
+		return stop
+	}, "UserName")
+	throwFail(t, AssertIs(err, stop))
+	throwFail(t, AssertIs(num, 0))
+	throwFail(t, AssertIs(strings.Join(names, ","), "nobody"))
+}
+
+func TestChunk(t *testing.T) {
+	var pages [][]string
+	qs := dORM.QueryTable("user").OrderBy("-Id").Limit(1)
+	err := qs.Chunk(2, func(container interface{}) error {
+		var page []string
+		for _, user := range container.([]*User) {
+			page = append(page, user.UserName)
+		}
+		pages = append(pages, page)
+		return nil
+	})
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(len(pages), 2))
+	throwFail(t, AssertIs(strings.Join(pages[0], ","), "slene,astaxie"))
+	throwFail(t, AssertIs(strings.Join(pages[1], ","), "nobody"))
+
+	var cnt int
+	err = NewQuery[User](dORM).Filter("user_name__in", "slene", "nobody").Chunk(1, func(users []*User) error {
+		cnt += len(users)
+		return nil
+	})
+	throwFail(t, err)
+	throwFail(t, AssertIs(cnt, 2))
+
+	throwFail(t, AssertIs(qs.Chunk(0, func(interface{}) error { return nil }), ErrArgs))
+}
+
+func TestGenericQuery(t *testing.T) {
+	id := FieldOf(func(u *User) *int { return &u.ID })
+	userName := FieldOf(func(u *User) *string { return &u.UserName })
+	isStaff := FieldOf(func(u *User) *bool { return &u.IsStaff })
+	profile := FieldOf(func(u *User) **Profile { return &u.Profile })
+	age := FieldOf(func(p *Profile) *int16 { return &p.Age })
+
+	users, err := NewQuery[User](dORM).OrderBy(id.Asc()).All()
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(len(users), 3))
+	throwFail(t, AssertIs(users[0].UserName, "slene"))
+	throwFail(t, AssertIs(users[2].UserName, "nobody"))
+
+	users, err = NewQuery[User](dORM).Where(userName.In("slene", "nobody")).OrderBy(id.Desc()).All()
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(len(users), 2))
+	throwFail(t, AssertIs(users[0].UserName, "nobody"))
+
+	user, err := NewQuery[User](dORM).Where(isStaff.Eq(true)).One()
+	throwFailNow(t, err)
+	throwFail(t, AssertIs(user.UserName, "astaxie"))
+
+	user, err = NewQuery[User](dORM).Where(Related(profile, age).Eq(28)).RelatedSel().One()
+	throwFailNow(t, err)
+	throwFail(t, AssertIs(user.UserName, "slene"))
+	throwFail(t, AssertIs(user.Profile.Age, 28))
+
+	num, err := NewQuery[User](dORM).Filter("Status__gte", 2).Where(isStaff.Eq(true).OrCond(userName.Eq("slene"))).Count()
+	throwFail(t, err)
+	throwFail(t, AssertIs(num, 1))
+
+	_, err = NewQuery[User](dORM).Where(userName.Eq("nothing")).One()
+	throwFail(t, AssertIs(err, ErrNoRows))
+}
+
 func TestValues(t *testing.T) {
 	var maps []Params
 	qs := dORM.QueryTable("user")
@@ -1653,6 +1773,76 @@ func TestLoadRelated(t *testing.T) {
 	throwFailNow(t, AssertIs(tag.Posts[0].User.UserName, "slene"))
 }
 
+func TestPrefetch(t *testing.T) {
+	var users []*User
+	num, err := dORM.QueryTable("user").OrderBy("Id").
+		Prefetch("Posts", hints.OrderBy("-Id"), "Posts__Tags", "Profile").All(&users)
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(num, 3))
+
+	for _, user := range users {
+		expected := &User{ID: user.ID}
+		throwFailNow(t, dORM.Read(expected))
+		_, err = dORM.LoadRelated(expected, "Posts", hints.OrderBy("-Id"))
+		throwFailNow(t, err)
+		throwFailNow(t, AssertIs(len(user.Posts), len(expected.Posts)))
+		for i, post := range user.Posts {
+			throwFail(t, AssertIs(post.ID, expected.Posts[i].ID))
+			throwFail(t, AssertIs(post.Title, expected.Posts[i].Title))
+
+			tags := &Post{ID: post.ID}
+			_, err = dORM.LoadRelated(tags, "Tags")
+			throwFailNow(t, err)
+			throwFail(t, AssertIs(len(post.Tags), len(tags.Tags)))
+		}
+
+		if expected.Profile == nil {
+			throwFail(t, AssertIs(user.Profile == nil, true))
+		} else {
+			_, err = dORM.LoadRelated(expected, "Profile")
+			throwFailNow(t, err)
+			throwFail(t, AssertIs(user.Profile.Age, expected.Profile.Age))
+		}
+	}
+
+	var tags []Tag
+	_, err = dORM.QueryTable("tag").OrderBy("Id").Prefetch("Posts", hints.OrderBy("Id"), hints.Limit(1)).All(&tags)
+	throwFailNow(t, err)
+	for _, tag := range tags {
+		expected := &Tag{ID: tag.ID}
+		num, err = dORM.LoadRelated(expected, "Posts", hints.OrderBy("Id"), hints.Limit(int64(1)))
+		throwFailNow(t, err)
+		throwFailNow(t, AssertIs(len(tag.Posts), int(num)))
+		if num > 0 {
+			throwFail(t, AssertIs(tag.Posts[0].ID, expected.Posts[0].ID))
+		}
+	}
+
+	user, err := NewQuery[User](dORM).Filter("Id", 3).Prefetch("Posts").One()
+	throwFailNow(t, err)
+	throwFail(t, AssertIs(len(user.Posts), 2))
+
+	// the limit and offset are applied to each parent by the database
+	_, err = dORM.QueryTable("user").OrderBy("Id").Prefetch("Posts", hints.OrderBy("Id"), hints.Limit(1), hints.Offset(1)).All(&users)
+	throwFailNow(t, err)
+	for _, user := range users {
+		expected := &User{ID: user.ID}
+		num, err = dORM.LoadRelated(expected, "Posts", hints.OrderBy("Id"), hints.Limit(1), hints.Offset(1))
+		throwFailNow(t, err)
+		throwFailNow(t, AssertIs(len(user.Posts), int(num)))
+		if num > 0 {
+			throwFail(t, AssertIs(user.Posts[0].ID, expected.Posts[0].ID))
+		}
+	}
+
+	mi, _ := defaultModelCache.GetByFullName(models.GetFullName(reflect.TypeOf(GroupMember{})))
+	_, _, err = prefetchParentPks(mi, nil)
+	throwFail(t, AssertIs(err != nil, true))
+
+	_, err = dORM.QueryTable("user").Prefetch("Nothing").All(&users)
+	throwFail(t, AssertIs(err != nil, true))
+}
+
 func TestQueryM2M(t *testing.T) {
 	post := Post{ID: 4}
 	m2m := dORM.QueryM2M(&post, "Tags")
@@ -2368,6 +2558,1086 @@ func TestTxOrmRollbackUnlessCommit(t *testing.T) {
 	assert.Equal(t, int64(1), num)
 }
 
+func TestNestedTransaction(t *testing.T) {
+	o := NewOrm()
+	errInner := errors.New("inner")
+
+	err := o.DoTx(func(ctx context.Context, to TxOrmer) error {
+		txOrm, ok := to.(NestedTxOrmer)
+		assert.True(t, ok)
+		_, err := txOrm.Insert(&Tag{Name: "nested outer"})
+		throwFail(t, err)
+
+		err = txOrm.DoTxWithCtx(ctx, func(ctx context.Context, txOrm TxOrmer) error {
+			_, err := txOrm.Insert(&Tag{Name: "nested committed"})
+			throwFail(t, err)
+			return nil
+		})
+		throwFail(t, err)
+
+		err = txOrm.DoTxWithCtx(ctx, func(ctx context.Context, txOrm TxOrmer) error {
+			_, err := txOrm.Insert(&Tag{Name: "nested rollback"})
+			throwFail(t, err)
+			return errInner
+		})
+		assert.Equal(t, errInner, err)
+
+		assert.Panics(t, func() {
+			_ = txOrm.DoTxWithCtx(ctx, func(ctx context.Context, txOrm TxOrmer) error {
+				_, err := txOrm.Insert(&Tag{Name: "nested panic"})
+				throwFail(t, err)
+				panic("inner panic")
+			})
+		})
+
+		inner, err := txOrm.Begin()
+		throwFail(t, err)
+		_, err = inner.Insert(&Tag{Name: "nested rollback"})
+		throwFail(t, err)
+		throwFail(t, inner.Rollback())
+		assert.Equal(t, ErrTxDone, inner.Commit())
+		assert.Nil(t, inner.RollbackUnlessCommit())
+
+		_, err = txOrm.BeginWithOpts(&sql.TxOptions{ReadOnly: true})
+		assert.Equal(t, ErrNestedTxOpts, err)
+		return nil
+	})
+	throwFail(t, err)
+
+	var names ParamsList
+	_, err = o.QueryTable("tag").Filter("name__startswith", "nested").OrderBy("id").ValuesFlat(&names, "name")
+	throwFail(t, err)
+	assert.Equal(t, ParamsList{"nested outer", "nested committed"}, names)
+
+	to, err := o.Begin()
+	throwFail(t, err)
+	txOrm := to.(NestedTxOrmer)
+	inner, err := txOrm.Begin()
+	throwFail(t, err)
+	throwFail(t, txOrm.Rollback())
+	_, err = txOrm.Begin()
+	assert.Equal(t, ErrTxDone, err)
+	assert.Equal(t, ErrTxDone, inner.Commit())
+
+	num, err := o.QueryTable("tag").Filter("name__startswith", "nested").Delete()
+	throwFail(t, err)
+	assert.Equal(t, int64(2), num)
+}
+
+func TestSoftDelete(t *testing.T) {
+	o := NewOrm()
+	ctx := context.Background()
+
+	user := &SoftDeleteUser{Name: "soft"}
+	_, err := o.Insert(user)
+	throwFail(t, err)
+	for _, title := range []string{"first", "second", "third"} {
+		_, err = o.Insert(&SoftDeletePost{Title: title, User: user})
+		throwFail(t, err)
+	}
+
+	post := &SoftDeletePost{Title: "first"}
+	throwFail(t, o.Read(post, "Title"))
+	num, err := o.Delete(post)
+	throwFail(t, err)
+	assert.Equal(t, int64(1), num)
+	assert.NotNil(t, post.DeletedAt)
+	num, err = o.Delete(post)
+	throwFail(t, err)
+	assert.Equal(t, int64(0), num)
+
+	assert.Equal(t, ErrNoRows, o.Read(&SoftDeletePost{Id: post.Id}))
+	deleted := &SoftDeletePost{Id: post.Id}
+	throwFail(t, o.ReadWithCtx(WithDeleted(ctx), deleted))
+	assert.Equal(t, "first", deleted.Title)
+	assert.NotNil(t, deleted.DeletedAt)
+
+	qs := o.QueryTable(new(SoftDeletePost))
+	cnt, err := qs.Count()
+	throwFail(t, err)
+	assert.Equal(t, int64(2), cnt)
+	cnt, err = qs.Filter("title", "first").Count()
+	throwFail(t, err)
+	assert.Equal(t, int64(0), cnt)
+	cnt, err = qs.SetCond(NewCondition().And("title", "second").Or("title", "first")).Count()
+	throwFail(t, err)
+	assert.Equal(t, int64(1), cnt)
+	cnt, err = qs.WithDeleted().Count()
+	throwFail(t, err)
+	assert.Equal(t, int64(3), cnt)
+	var posts []*SoftDeletePost
+	_, err = qs.OnlyDeleted().All(&posts)
+	throwFail(t, err)
+	assert.Len(t, posts, 1)
+	assert.Equal(t, post.Id, posts[0].Id)
+
+	// the subqueries use the context of the query
+	users := o.QueryTable(new(SoftDeleteUser)).Filter("Id__in", o.QueryTable(new(SoftDeletePost)).Filter("Title", "first").GroupBy("User"))
+	cnt, err = users.Count()
+	throwFail(t, err)
+	assert.Equal(t, int64(0), cnt)
+	cnt, err = users.CountWithCtx(WithDeleted(ctx))
+	throwFail(t, err)
+	assert.Equal(t, int64(1), cnt)
+
+	num, err = o.LoadRelated(user, "Posts")
+	throwFail(t, err)
+	assert.Equal(t, int64(2), num)
+	num, err = o.LoadRelatedWithCtx(WithDeleted(ctx), user, "Posts")
+	throwFail(t, err)
+	assert.Equal(t, int64(3), num)
+
+	num, err = qs.Filter("title", "second").Delete()
+	throwFail(t, err)
+	assert.Equal(t, int64(1), num)
+	cnt, err = qs.Count()
+	throwFail(t, err)
+	assert.Equal(t, int64(1), cnt)
+
+	// the soft delete doesn't cascade, the hard delete does
+	num, err = o.Delete(user)
+	throwFail(t, err)
+	assert.Equal(t, int64(1), num)
+	cnt, err = qs.OnlyDeleted().Count()
+	throwFail(t, err)
+	assert.Equal(t, int64(2), cnt)
+
+	// the soft deleted related model isn't selected by RelatedSel
+	var third SoftDeletePost
+	throwFail(t, qs.Filter("title", "third").RelatedSel().One(&third))
+	assert.Equal(t, 0, third.User.Id)
+	throwFail(t, qs.Filter("title", "third").RelatedSel().WithDeleted().One(&third))
+	assert.Equal(t, user.Id, third.User.Id)
+
+	// the rows selected by OnlyDeleted are not deleted again
+	deleted = &SoftDeletePost{Id: post.Id}
+	throwFail(t, o.ReadWithCtx(WithDeleted(ctx), deleted))
+	num, err = qs.OnlyDeleted().Filter("title", "first").Delete()
+	throwFail(t, err)
+	assert.Equal(t, int64(0), num)
+	again := &SoftDeletePost{Id: post.Id}
+	throwFail(t, o.ReadWithCtx(WithDeleted(ctx), again))
+	assert.Equal(t, deleted.DeletedAt, again.DeletedAt)
+
+	num, err = qs.OnlyDeleted().HardDelete().Filter("title", "first").Delete()
+	throwFail(t, err)
+	assert.Equal(t, int64(1), num)
+	num, err = o.DeleteWithCtx(HardDelete(ctx), &SoftDeleteUser{Id: user.Id})
+	throwFail(t, err)
+	assert.Equal(t, int64(1), num)
+	cnt, err = qs.WithDeleted().Count()
+	throwFail(t, err)
+	assert.Equal(t, int64(0), cnt)
+}
+
+func TestOptimisticLock(t *testing.T) {
+	o := NewOrm()
+
+	post := &VersionedPost{Title: "first"}
+	_, err := o.Insert(post)
+	throwFail(t, err)
+
+	stale := &VersionedPost{Id: post.Id}
+	throwFail(t, o.Read(stale))
+
+	post.Title = "second"
+	num, err := o.Update(post, "Title")
+	throwFail(t, err)
+	assert.Equal(t, int64(1), num)
+	assert.Equal(t, uint(1), post.Version)
+
+	stale.Title = "stale"
+	num, err = o.Update(stale)
+	assert.Equal(t, int64(0), num)
+	assert.True(t, errors.Is(err, ErrStaleObject))
+	code, ok := berror.FromError(err)
+	assert.True(t, ok)
+	assert.Equal(t, StaleObject, code)
+	assert.Equal(t, uint(0), stale.Version)
+
+	throwFail(t, o.Read(stale))
+	assert.Equal(t, "second", stale.Title)
+	assert.Equal(t, uint(1), stale.Version)
+	stale.Title = "third"
+	_, err = o.Update(stale)
+	throwFail(t, err)
+	assert.Equal(t, uint(2), stale.Version)
+}
+
+func TestModelHooks(t *testing.T) {
+	o := NewOrm()
+
+	_, err := o.Insert(&HookedPost{})
+	assert.EqualError(t, err, "title is required")
+	num, err := o.QueryTable(new(HookedPost)).Count()
+	throwFail(t, err)
+	assert.Equal(t, int64(0), num)
+
+	post := &HookedPost{Title: "first"}
+	_, err = o.Insert(post)
+	throwFail(t, err)
+	assert.Equal(t, []string{"BeforeInsert", "AfterInsert"}, post.Calls)
+	assert.True(t, o.QueryTable(new(HookLog)).Filter("post", post.Id).Filter("action", "insert").Exist())
+
+	post.Calls = nil
+	post.Title = "second"
+	_, err = o.Update(post)
+	throwFail(t, err)
+	assert.Equal(t, []string{"BeforeUpdate", "AfterUpdate"}, post.Calls)
+
+	read := &HookedPost{Id: post.Id}
+	throwFail(t, o.Read(read))
+	assert.Equal(t, []string{"AfterRead"}, read.Calls)
+
+	var posts []*HookedPost
+	_, err = o.QueryTable(new(HookedPost)).All(&posts)
+	throwFail(t, err)
+	assert.Len(t, posts, 1)
+	assert.Equal(t, []string{"AfterRead"}, posts[0].Calls)
+
+	var values []HookedPost
+	_, err = o.QueryTable(new(HookedPost)).All(&values)
+	throwFail(t, err)
+	assert.Len(t, values, 1)
+	assert.Equal(t, []string{"AfterRead"}, values[0].Calls)
+
+	// the hooks run in the transaction of the caller
+	hookErr := errors.New("rollback")
+	err = o.DoTx(func(ctx context.Context, txOrm TxOrmer) error {
+		if _, err := txOrm.DeleteWithCtx(ctx, &HookedPost{Id: post.Id}); err != nil {
+			return err
+		}
+		return hookErr
+	})
+	assert.Equal(t, hookErr, err)
+	assert.True(t, o.QueryTable(new(HookedPost)).Filter("id", post.Id).Exist())
+	assert.False(t, o.QueryTable(new(HookLog)).Filter("post", post.Id).Filter("action", "delete").Exist())
+
+	post.Calls = nil
+	_, err = o.UpdateMulti(10, []*HookedPost{post})
+	throwFail(t, err)
+	assert.Equal(t, []string{"BeforeUpdate", "AfterUpdate"}, post.Calls)
+
+	post.Calls = nil
+	_, err = o.InsertOrUpdateMulti(10, []*HookedPost{post}, nil, nil)
+	throwFail(t, err)
+	assert.Equal(t, []string{"BeforeUpdate", "AfterUpdate"}, post.Calls)
+
+	post.Calls = nil
+	_, err = o.Delete(post)
+	throwFail(t, err)
+	assert.Equal(t, []string{"BeforeDelete", "AfterDelete"}, post.Calls)
+	assert.True(t, o.QueryTable(new(HookLog)).Filter("post", post.Id).Filter("action", "delete").Exist())
+}
+
+func TestInsertOrUpdateMulti(t *testing.T) {
+	products := []*Product{
+		{Code: "a", Name: "A", Stock: 1},
+		{Code: "b", Name: "B", Stock: 2},
+	}
+	num, err := dORM.InsertOrUpdateMulti(10, products, []string{"Code"}, nil)
+	throwFailNow(t, err)
+	throwFail(t, AssertIs(num, 2))
+
+	products = []*Product{
+		{Code: "a", Name: "A2", Stock: 5},
+		{Code: "c", Name: "C", Stock: 3},
+	}
+	_, err = dORM.InsertOrUpdateMulti(1, products, []string{"code"}, []string{"Stock"})
+	throwFailNow(t, err)
+
+	var result []*Product
+	_, err = dORM.QueryTable(new(Product)).OrderBy("Code").All(&result)
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(len(result), 3))
+	throwFail(t, AssertIs(result[0].Name, "A"))
+	throwFail(t, AssertIs(result[0].Stock, 5))
+	throwFail(t, AssertIs(result[1].Stock, 2))
+	throwFail(t, AssertIs(result[2].Name, "C"))
+
+	_, err = dORM.InsertOrUpdateMulti(10, products, []string{"Code"}, []string{"Nothing"})
+	throwFail(t, AssertIs(err != nil, true))
+	_, err = dORM.InsertOrUpdateMulti(10, []*Product{}, nil, nil)
+	throwFail(t, AssertIs(err, ErrArgs))
+}
+
+func TestUpdateMulti(t *testing.T) {
+	var products []*Product
+	_, err := dORM.QueryTable(new(Product)).OrderBy("Code").All(&products)
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(len(products), 3))
+	for i, p := range products {
+		p.Stock = (i + 1) * 10
+		p.Name = "changed"
+		p.Updated = time.Time{}
+	}
+
+	num, err := dORM.UpdateMulti(2, products, "Stock")
+	throwFailNow(t, err)
+	throwFail(t, AssertIs(num, 3))
+
+	var result []*Product
+	_, err = dORM.QueryTable(new(Product)).OrderBy("Code").All(&result)
+	throwFailNow(t, err)
+	for i, p := range result {
+		throwFail(t, AssertIs(p.Stock, (i+1)*10))
+		throwFail(t, AssertIs(p.Name == "changed", false))
+		// the auto_now value is written back to the models
+		throwFail(t, AssertIs(products[i].Updated.IsZero(), false))
+		throwFail(t, AssertIs(p.Updated.Unix(), products[i].Updated.Unix()))
+	}
+
+	num, err = dORM.UpdateMulti(0, products)
+	throwFailNow(t, err)
+	throwFail(t, AssertIs(num, 3))
+	throwFail(t, AssertIs(dORM.QueryTable(new(Product)).Filter("Name", "changed").Exist(), true))
+
+	_, err = dORM.UpdateMulti(0, []*VersionedPost{{Id: 1}})
+	throwFail(t, AssertIs(err != nil, true))
+}
+
+func TestCompositePk(t *testing.T) {
+	var users []*User
+	_, err := dORM.QueryTable(new(User)).OrderBy("Id").Limit(2).All(&users)
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(len(users), 2))
+	group := &Group{Name: "composite"}
+	_, err = dORM.Insert(group)
+	throwFailNow(t, err)
+
+	owner := &GroupMember{User: users[0], Group: group, Role: "owner"}
+	id, err := dORM.Insert(owner)
+	throwFailNow(t, err)
+	throwFail(t, AssertIs(id, 0))
+	num, err := dORM.InsertMulti(10, []*GroupMember{{User: users[1], Group: group, Role: "member"}})
+	throwFailNow(t, err)
+	throwFail(t, AssertIs(num, 1))
+	_, err = dORM.Insert(&GroupMember{User: users[0], Group: group})
+	throwFail(t, AssertIs(err != nil, true))
+
+	member := &GroupMember{User: &User{ID: users[1].ID}, Group: &Group{ID: group.ID}}
+	throwFailNow(t, dORM.Read(member))
+	throwFail(t, AssertIs(member.Role, "member"))
+	throwFail(t, AssertIs(dORM.Read(&GroupMember{User: &User{ID: users[1].ID}}), ErrMissPK))
+
+	member.Role = "admin"
+	num, err = dORM.Update(member)
+	throwFailNow(t, err)
+	throwFail(t, AssertIs(num, 1))
+	read := &GroupMember{User: users[0], Group: group}
+	throwFailNow(t, dORM.Read(read))
+	throwFail(t, AssertIs(read.Role, "owner"))
+
+	var members []*GroupMember
+	num, err = dORM.QueryTable(new(GroupMember)).Filter("Group", group).RelatedSel().OrderBy("User").All(&members)
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(num, 2))
+	throwFail(t, AssertIs(members[0].User.UserName, users[0].UserName))
+	throwFail(t, AssertIs(members[1].Role, "admin"))
+	throwFail(t, AssertIs(members[1].Group.Name, "composite"))
+
+	if !IsSqlite {
+		owner.Role = "maintainer"
+		_, err = dORM.InsertOrUpdate(owner)
+		throwFailNow(t, err)
+		throwFailNow(t, dORM.Read(read))
+		throwFail(t, AssertIs(read.Role, "maintainer"))
+	}
+
+	num, err = dORM.QueryTable(new(GroupMember)).Filter("Group", group).Filter("Role", "admin").Update(Params{"Role": "guest"})
+	throwFailNow(t, err)
+	throwFail(t, AssertIs(num, 1))
+
+	num, err = dORM.Delete(member)
+	throwFailNow(t, err)
+	throwFail(t, AssertIs(num, 1))
+	throwFail(t, AssertIs(dORM.Read(member), ErrNoRows))
+
+	// the members are deleted in cascade by their pk
+	_, err = dORM.Delete(group)
+	throwFailNow(t, err)
+	num, err = dORM.QueryTable(new(GroupMember)).Count()
+	throwFailNow(t, err)
+	throwFail(t, AssertIs(num, 0))
+}
+
+func TestJSONPath(t *testing.T) {
+	docs := []*JSONDoc{
+		{Name: "paris", Attrs: `{"address": {"city": "Paris", "zip": "75001"}, "age": 30, "tags": ["a", "b"]}`},
+		{Name: "berlin", Attrs: `{"address": {"city": "Berlin"}, "age": 9, "tags": ["b"]}`},
+		{Name: "none", Attrs: `{"age": 40}`},
+	}
+	_, err := dORM.InsertMulti(10, docs)
+	throwFailNow(t, err)
+	qs := dORM.QueryTable(new(JSONDoc))
+
+	names := jsonDocNames(t, qs.Filter("Attrs__json__address__city", "Paris"))
+	throwFail(t, AssertIs(strings.Join(names, ","), "paris"))
+	names = jsonDocNames(t, qs.Filter("Attrs__json__address__city__istartswith", "be"))
+	throwFail(t, AssertIs(strings.Join(names, ","), "berlin"))
+	names = jsonDocNames(t, qs.Filter("Attrs__json__age__gt", 10))
+	throwFail(t, AssertIs(strings.Join(names, ","), "paris,none"))
+	names = jsonDocNames(t, qs.Filter("Attrs__json__tags__0", "a"))
+	throwFail(t, AssertIs(strings.Join(names, ","), "paris"))
+
+	names = jsonDocNames(t, qs.Filter("Attrs__jcontains", `{"tags": ["b"]}`))
+	throwFail(t, AssertIs(strings.Join(names, ","), "paris,berlin"))
+	names = jsonDocNames(t, qs.Filter("Attrs__jcontains", map[string]interface{}{"address": map[string]string{"city": "Berlin"}}))
+	throwFail(t, AssertIs(strings.Join(names, ","), "berlin"))
+	names = jsonDocNames(t, qs.Filter("Attrs__json__address__jcontains", map[string]string{"zip": "75001"}))
+	throwFail(t, AssertIs(strings.Join(names, ","), "paris"))
+
+	names = jsonDocNames(t, qs.Filter("Attrs__has_key", "address"))
+	throwFail(t, AssertIs(strings.Join(names, ","), "paris,berlin"))
+	names = jsonDocNames(t, qs.Filter("Attrs__json__address__has_key", "zip"))
+	throwFail(t, AssertIs(strings.Join(names, ","), "paris"))
+	names = jsonDocNames(t, qs.Exclude("Attrs__has_key", "tags"))
+	throwFail(t, AssertIs(strings.Join(names, ","), "none"))
+
+	var ordered []*JSONDoc
+	_, err = qs.OrderBy("-Attrs__json__age").All(&ordered)
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(len(ordered), 3))
+	throwFail(t, AssertIs(ordered[0].Name, "none"))
+	throwFail(t, AssertIs(ordered[2].Name, "berlin"))
+
+	assert.Panics(t, func() {
+		_, _ = qs.Filter("Name__has_key", "address").Count()
+	})
+	assert.Panics(t, func() {
+		_, _ = qs.Filter("Attrs__json__add'ress", "Paris").Count()
+	})
+}
+
+func jsonDocNames(t *testing.T, qs QuerySeter) []string {
+	var docs []*JSONDoc
+	_, err := qs.OrderBy("Id").All(&docs)
+	throwFailNow(t, err)
+	names := make([]string, 0, len(docs))
+	for _, doc := range docs {
+		names = append(names, doc.Name)
+	}
+	return names
+}
+
+func TestExtendedFields(t *testing.T) {
+	driver := getDbAlias("default").Driver
+	created := time.Date(2023, 4, 5, 6, 7, 8, 0, time.FixedZone("", 8*3600))
+	doc := &ExtendedDoc{Status: "draft", Created: DateTimeTZField(created)}
+	throwFailNow(t, doc.Uid.SetRaw("6BA7B810-9DAD-11D1-80B4-00C04FD430C8"))
+	throwFailNow(t, doc.Amount.SetRaw("1234.567891"))
+	throwFailNow(t, doc.IP.SetRaw("2001:db8::1"))
+	doc.Tags.Set([]string{"go", `say "hi"`, "a,b"})
+	doc.Scores.Set([]int64{3, 5})
+	_, err := dORM.Insert(doc)
+	throwFailNow(t, err)
+
+	read := &ExtendedDoc{Id: doc.Id}
+	throwFailNow(t, dORM.Read(read))
+	throwFail(t, AssertIs(read.Uid.String(), "6ba7b810-9dad-11d1-80b4-00c04fd430c8"))
+	throwFail(t, AssertIs(read.Amount.Value(), "1234.567891"))
+	throwFail(t, AssertIs(read.IP.String(), "2001:db8::1"))
+	throwFail(t, AssertIs(read.Created.Value().Equal(created), true))
+	if driver != DRPostgres {
+		// the offset is kept in the text column
+		throwFail(t, AssertIs(read.Created.String(), "2023-04-05T06:07:08+08:00"))
+	}
+	assert.Equal(t, []string{"go", `say "hi"`, "a,b"}, read.Tags.Value())
+	assert.Equal(t, []int64{3, 5}, read.Scores.Value())
+
+	qs := dORM.QueryTable(new(ExtendedDoc))
+	num, err := qs.Filter("Uid", "6ba7b810-9dad-11d1-80b4-00c04fd430c8").Count()
+	throwFailNow(t, err)
+	throwFail(t, AssertIs(num, 1))
+	num, err = qs.Filter("Uid", read.Uid).Count()
+	throwFailNow(t, err)
+	throwFail(t, AssertIs(num, 1))
+	num, err = qs.Filter("IP", netip.MustParseAddr("2001:db8::1")).Count()
+	throwFailNow(t, err)
+	throwFail(t, AssertIs(num, 1))
+
+	_, err = dORM.Insert(&ExtendedDoc{Status: "deleted"})
+	assert.ErrorContains(t, err, "should be one of draft, published")
+
+	if driver == DRPostgres {
+		num, err = qs.Filter("Tags__contains", "go").Count()
+		throwFailNow(t, err)
+		throwFail(t, AssertIs(num, 1))
+		num, err = qs.Filter("Tags__contains", []string{"go", "rust"}).Count()
+		throwFailNow(t, err)
+		throwFail(t, AssertIs(num, 0))
+		num, err = qs.Filter("Scores__overlap", 1, 5).Count()
+		throwFailNow(t, err)
+		throwFail(t, AssertIs(num, 1))
+	} else {
+		assert.Panics(t, func() {
+			_, _ = qs.Filter("Tags__contains", "go").Count()
+		})
+	}
+	assert.Panics(t, func() {
+		_, _ = qs.Filter("Status__overlap", "draft").Count()
+	})
+}
+
+func TestSubQuery(t *testing.T) {
+	qs := dORM.QueryTable("user")
+
+	var users []*User
+	num, err := qs.Filter("Profile__in", dORM.QueryTable("user_profile").Filter("Age__gte", 30)).All(&users)
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(num, 1))
+	throwFail(t, AssertIs(users[0].UserName, "astaxie"))
+
+	posts := dORM.QueryTable("post").Filter("Title", "Examples").GroupBy("User")
+	num, err = qs.Filter("Id__in", posts).All(&users)
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(num, 1))
+	throwFail(t, AssertIs(users[0].UserName, "astaxie"))
+
+	var ids ParamsList
+	_, err = dORM.QueryTable("post").GroupBy("User").ValuesFlat(&ids, "User")
+	throwFailNow(t, err)
+	all, err := qs.Count()
+	throwFailNow(t, err)
+
+	sub := dORM.QueryTable("post").Filter("User", OuterRef("Id"))
+	num, err = qs.SetCond(Exists(sub)).Count()
+	throwFail(t, err)
+	throwFail(t, AssertIs(num, len(ids)))
+	num, err = qs.SetCond(NewCondition().AndNotCond(Exists(sub))).Count()
+	throwFail(t, err)
+	throwFail(t, AssertIs(num, all-int64(len(ids))))
+	num, err = qs.SetCond(Exists(sub.Filter("Title", "Examples"))).Count()
+	throwFail(t, err)
+	throwFail(t, AssertIs(num, 1))
+
+	var depts []*DeptInfo
+	num, err = dORM.QueryTable("dept_info").Filter("Salary", dORM.QueryTable("dept_info").Annotate(Max("Salary"))).All(&depts)
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(num, 1))
+	throwFail(t, AssertIs(depts[0].EmployeeName, "B2"))
+
+	assert.Panics(t, func() {
+		_, _ = qs.Filter("Id", OuterRef("Id")).Count()
+	})
+	assert.Panics(t, func() {
+		_, _ = qs.SetCond(Exists(sub.Filter("User__in", OuterRef("Id")))).Count()
+	})
+}
+
+func TestAnnotate(t *testing.T) {
+	type total struct {
+		DeptName  string
+		Total     int
+		Count     int64
+		AvgSalary float64
+	}
+	qs := dORM.QueryTable("dept_info").GroupBy("DeptName")
+
+	var res []total
+	num, err := qs.Annotate(Sum("Salary").As("total"), Count("*"), Avg("Salary")).OrderBy("-total").All(&res)
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(num, 2))
+	throwFail(t, AssertIs(res[0].DeptName, "B"))
+	throwFail(t, AssertIs(res[0].Total, 9000))
+	throwFail(t, AssertIs(res[0].Count, 3))
+	throwFail(t, AssertIs(res[0].AvgSalary, 3000))
+	throwFail(t, AssertIs(res[1].DeptName, "A"))
+	throwFail(t, AssertIs(res[1].AvgSalary, 1500))
+
+	var ptrs []*total
+	num, err = qs.Annotate(Sum("Salary").As("total"), Count("*")).Having("count__lt", 3).All(&ptrs)
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(num, 1))
+	throwFail(t, AssertIs(ptrs[0].DeptName, "A"))
+	throwFail(t, AssertIs(ptrs[0].Total, 3000))
+
+	var maps []Params
+	num, err = qs.Annotate(Max("Salary"), Count("Salary").Distinct()).OrderBy("DeptName").Values(&maps)
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(num, 2))
+	throwFail(t, AssertIs(maps[0]["DeptName"], "A"))
+	throwFail(t, AssertIs(maps[1]["max_salary"], 4000))
+	throwFail(t, AssertIs(maps[1]["count_salary"], 3))
+
+	num, err = qs.Annotate(Count("*").As("n")).Having("n__gte", 2).Having("DeptName", "B").All(&maps)
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(num, 1))
+	throwFail(t, AssertIs(maps[0]["n"], 3))
+
+	assert.Panics(t, func() {
+		Count("Id").As("n; DROP")
+	})
+}
+
+func TestQueryBuilder(t *testing.T) {
+	qb, err := NewQueryBuilder(DBARGS.Driver)
+	throwFailNow(t, err)
+
+	var users []*User
+	qb.Select("id", "user_name").From("user").Where("status >= ?", 2).And("id").InArgs([]int{2, 3, 4}).OrderBy("id")
+	num, err := dORM.Raw(qb.Build()).QueryRows(&users)
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(num, 2))
+	throwFail(t, AssertIs(users[0].UserName, "astaxie"))
+	throwFail(t, AssertIs(users[1].UserName, "nobody"))
+
+	qb.InsertInto("tag", "name").ValuesArgs("qb' OR 1=1")
+	res, err := dORM.Raw(qb.Build()).Exec()
+	throwFailNow(t, err)
+	num, err = res.RowsAffected()
+	throwFail(t, err)
+	throwFail(t, AssertIs(num, 1))
+
+	var tag Tag
+	qb.Select("id", "name").From("tag").Where("name = ?", "qb' OR 1=1")
+	throwFailNow(t, dORM.Raw(qb.Build()).QueryRow(&tag))
+	throwFail(t, AssertIs(tag.Name, "qb' OR 1=1"))
+
+	qb.Delete().From("tag").Where("id").InArgs(tag.ID)
+	res, err = dORM.Raw(qb.Build()).Exec()
+	throwFailNow(t, err)
+	num, err = res.RowsAffected()
+	throwFail(t, err)
+	throwFail(t, AssertIs(num, 1))
+}
+
+func TestTrackChanges(t *testing.T) {
+	profile := &TrackedProfile{Name: "slene", Email: "slene@example.com", Age: 28}
+	_, err := dORM.Insert(profile)
+	throwFailNow(t, err)
+	throwFail(t, AssertIs(len(Changed(profile)), 0))
+
+	// nothing changed, the update is skipped
+	num, err := dORM.Update(profile)
+	throwFailNow(t, err)
+	throwFail(t, AssertIs(num, 0))
+
+	read := &TrackedProfile{Id: profile.Id}
+	throwFailNow(t, dORM.Read(read))
+	throwFail(t, AssertIs(len(Changed(read)), 0))
+
+	// the concurrent write of the other field is kept
+	_, err = dORM.QueryTable(new(TrackedProfile)).Filter("Id", profile.Id).Update(Params{"email": "other@example.com"})
+	throwFailNow(t, err)
+	read.Age = 30
+	assert.Equal(t, []string{"Age"}, Changed(read))
+	num, err = dORM.Update(read)
+	throwFailNow(t, err)
+	throwFail(t, AssertIs(num, 1))
+	throwFail(t, AssertIs(len(Changed(read)), 0))
+
+	var profiles []TrackedProfile
+	_, err = dORM.QueryTable(new(TrackedProfile)).Filter("Id", profile.Id).All(&profiles)
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(len(profiles), 1))
+	throwFail(t, AssertIs(profiles[0].Email, "other@example.com"))
+	throwFail(t, AssertIs(profiles[0].Age, 30))
+
+	profiles[0].Name = "astaxie"
+	assert.Equal(t, []string{"Name"}, Changed(&profiles[0]))
+	_, err = dORM.Update(&profiles[0])
+	throwFailNow(t, err)
+
+	// the explicit cols are written regardless of the snapshot
+	read.Email = "slene@example.com"
+	num, err = dORM.Update(read, "Email")
+	throwFailNow(t, err)
+	throwFail(t, AssertIs(num, 1))
+
+	throwFailNow(t, dORM.Read(profile))
+	throwFail(t, AssertIs(profile.Name, "astaxie"))
+	throwFail(t, AssertIs(profile.Email, "slene@example.com"))
+	throwFail(t, AssertIs(profile.Age, 30))
+
+	// the untracked model has no snapshot
+	user := &User{ID: 2}
+	throwFailNow(t, dORM.Read(user))
+	throwFail(t, AssertIs(Changed(user) == nil, true))
+	throwFail(t, AssertIs(Changed(&TrackedProfile{Id: profile.Id}) == nil, true))
+
+	// the snapshot is removed by Delete
+	_, err = dORM.Delete(profile)
+	throwFailNow(t, err)
+	throwFail(t, AssertIs(Changed(profile) == nil, true))
+}
+
+func TestQueryCache(t *testing.T) {
+	chains := globalFilterChains
+	defer func() {
+		defaultQueryCache = nil
+		globalFilterChains = chains
+	}()
+	RegisterQueryCache(cache.NewMemoryCache())
+	o := NewOrm()
+
+	qs := o.QueryTable("user").Filter("UserName", "slene").Cache(time.Minute)
+	var users []*User
+	num, err := qs.All(&users)
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(num, 1))
+	status := users[0].Status
+	cnt, err := qs.Count()
+	throwFailNow(t, err)
+	throwFail(t, AssertIs(cnt, 1))
+
+	// dORM is created before the cache, so the update is not seen
+	_, err = dORM.QueryTable("user").Filter("UserName", "slene").Update(Params{"Status": 100})
+	throwFailNow(t, err)
+	users = nil
+	num, err = qs.All(&users)
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(num, 1))
+	throwFail(t, AssertIs(users[0].Status, status))
+	throwFail(t, AssertIs(users[0].UserName, "slene"))
+
+	var maps []Params
+	_, err = qs.Values(&maps, "Status")
+	throwFailNow(t, err)
+	throwFail(t, AssertIs(maps[0]["Status"], int64(100)))
+
+	ctx := WithHints(context.Background(), hints.Cache(time.Minute))
+	user := &User{ID: users[0].ID}
+	throwFailNow(t, o.ReadWithCtx(ctx, user))
+	throwFail(t, AssertIs(user.Status, 100))
+	_, err = dORM.QueryTable("user").Filter("UserName", "slene").Update(Params{"Status": status})
+	throwFailNow(t, err)
+	user = &User{ID: users[0].ID}
+	throwFailNow(t, o.ReadWithCtx(ctx, user))
+	throwFail(t, AssertIs(user.Status, 100))
+
+	// the update through the Ormer invalidates the results of the table
+	_, err = o.QueryTable("user").Filter("UserName", "slene").Update(Params{"Status": status})
+	throwFailNow(t, err)
+	num, err = qs.All(&users)
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(num, 1))
+	throwFail(t, AssertIs(users[0].Status, status))
+	throwFailNow(t, o.ReadWithCtx(ctx, user))
+	throwFail(t, AssertIs(user.Status, status))
+
+	// the update of the tables joined by the exprs invalidates the results too
+	profileQs := o.QueryTable("user").Filter("UserName", "astaxie").Cache(time.Minute)
+	_, err = profileQs.Values(&maps, "Profile__ID", "Profile__Age")
+	throwFailNow(t, err)
+	profileID, age := maps[0]["Profile__ID"], maps[0]["Profile__Age"].(int64)
+	_, err = o.QueryTable("user_profile").Filter("ID", profileID).Update(Params{"Age": age + 1})
+	throwFailNow(t, err)
+	_, err = profileQs.Values(&maps, "Profile__ID", "Profile__Age")
+	throwFailNow(t, err)
+	throwFail(t, AssertIs(maps[0]["Profile__Age"], age+1))
+	_, err = o.QueryTable("user_profile").Filter("ID", profileID).Update(Params{"Age": age})
+	throwFailNow(t, err)
+
+	// the result which cannot be cached is loaded only once
+	loads := 0
+	var ch chan int
+	num, err = defaultQueryCache.load(context.Background(), time.Minute, "unencodable", &ch,
+		func(ctx context.Context, result interface{}) (int64, error) {
+			loads++
+			*result.(*chan int) = make(chan int)
+			return 1, nil
+		})
+	throwFailNow(t, err)
+	throwFail(t, AssertIs(num, 1))
+	throwFail(t, AssertIs(loads, 1))
+	throwFail(t, AssertNot(ch, nil))
+
+	// the evicted version is seeded again, so the old results are not used
+	al := getDbAlias("default")
+	key := defaultQueryCache.key(context.Background(), al, "query", []string{"user"})
+	throwFail(t, AssertIs(defaultQueryCache.key(context.Background(), al, "query", []string{"user"}), key))
+	throwFailNow(t, defaultQueryCache.cache.Delete(context.Background(), defaultQueryCache.versionKey("user")))
+	throwFail(t, AssertNot(defaultQueryCache.key(context.Background(), al, "query", []string{"user"}), key))
+
+	// the queries inside the transaction are not cached
+	err = o.DoTx(func(ctx context.Context, txOrm TxOrmer) error {
+		_, err := txOrm.QueryTable("user").Filter("UserName", "slene").Update(Params{"Status": 100})
+		throwFailNow(t, err)
+		num, err := txOrm.QueryTable("user").Filter("UserName", "slene").Cache(time.Minute).All(&users)
+		throwFailNow(t, err)
+		throwFailNow(t, AssertIs(num, 1))
+		throwFail(t, AssertIs(users[0].Status, 100))
+		return errors.New("rollback")
+	})
+	throwFail(t, AssertIs(err.Error(), "rollback"))
+
+	// the tables written in the nested transaction are invalidated after the outermost transaction commits
+	var version string
+	err = o.DoTx(func(ctx context.Context, txOrm TxOrmer) error {
+		err := txOrm.(NestedTxOrmer).DoTx(func(ctx context.Context, inner TxOrmer) error {
+			_, err := inner.QueryTable("user").Filter("UserName", "slene").Update(Params{"Status": status})
+			return err
+		})
+		throwFailNow(t, err)
+		version = defaultQueryCache.version(context.Background(), "user")
+		return nil
+	})
+	throwFailNow(t, err)
+	throwFail(t, AssertNot(defaultQueryCache.version(context.Background(), "user"), version))
+}
+
+type tenantTestKey struct{}
+
+func TestTenant(t *testing.T) {
+	throwFailNow(t, RegisterTenant("acme", TenantTablePrefix("acme_")))
+	assert.NotNil(t, RegisterTenant("acme"))
+	assert.NotNil(t, RegisterTenant("unknown", TenantAlias("unknown")))
+	if !IsPostgres {
+		assert.NotNil(t, RegisterTenant("schema", TenantSchema("acme")))
+	}
+	throwFailNow(t, AssertIs(len(Tenants()), 1))
+	throwFailNow(t, RunSyncdbTenants(false, false))
+
+	ctx := WithTenant(context.Background(), "acme")
+	group := &Group{Name: "tenant"}
+	_, err := dORM.InsertWithCtx(ctx, group)
+	throwFailNow(t, err)
+	perm := &Permission{Name: "tenant"}
+	_, err = dORM.InsertWithCtx(ctx, perm)
+	throwFailNow(t, err)
+	num, err := dORM.QueryM2M(perm, "Groups").AddWithCtx(ctx, group)
+	throwFailNow(t, err)
+	throwFail(t, AssertIs(num, 1))
+
+	// the rows are in the tables of the tenant
+	cnt, err := dORM.QueryTable("group").Filter("Name", "tenant").CountWithCtx(ctx)
+	throwFailNow(t, err)
+	throwFail(t, AssertIs(cnt, 1))
+	cnt, err = dORM.QueryTable("group").Filter("Name", "tenant").Count()
+	throwFailNow(t, err)
+	throwFail(t, AssertIs(cnt, 0))
+	cnt, err = dORM.QueryTable("permission").Filter("Groups__Group__Name", "tenant").CountWithCtx(ctx)
+	throwFailNow(t, err)
+	throwFail(t, AssertIs(cnt, 1))
+	var n int
+	throwFailNow(t, dORM.Raw("SELECT COUNT(*) FROM acme_permission").QueryRow(&n))
+	throwFail(t, AssertIs(n, 1))
+
+	mi, ok := LookupModel("group")
+	throwFailNow(t, AssertIs(ok, true))
+	assert.Equal(t, []string{"acme_group"}, ModelTables(ctx, "default", mi))
+	assert.Equal(t, []string{"group"}, ModelTables(context.Background(), "default", mi))
+
+	g := &Group{ID: group.ID}
+	throwFailNow(t, dORM.ReadWithCtx(ctx, g))
+	throwFail(t, AssertIs(g.Name, "tenant"))
+
+	// the transaction keeps the tenant when it begins
+	err = dORM.DoTxWithCtx(ctx, func(_ context.Context, txOrm TxOrmer) error {
+		num, err := txOrm.QueryTable("group").Filter("Name", "tenant").Update(Params{"Name": "tenant2"})
+		throwFailNow(t, err)
+		throwFail(t, AssertIs(num, 1))
+		cnt, err := txOrm.QueryTable("group").Filter("Name", "tenant2").Count()
+		throwFailNow(t, err)
+		throwFail(t, AssertIs(cnt, 1))
+		return nil
+	})
+	throwFailNow(t, err)
+
+	RegisterTenantResolver(func(ctx context.Context) string {
+		name, _ := ctx.Value(tenantTestKey{}).(string)
+		return name
+	})
+	defer RegisterTenantResolver(TenantFromContext)
+	cnt, err = dORM.QueryTable("group").Filter("Name", "tenant2").CountWithCtx(context.WithValue(context.Background(), tenantTestKey{}, "acme"))
+	throwFailNow(t, err)
+	throwFail(t, AssertIs(cnt, 1))
+	assert.Panics(t, func() {
+		_ = dORM.ReadWithCtx(context.WithValue(context.Background(), tenantTestKey{}, "unknown"), g)
+	})
+
+	diff, err := DiffSchema(context.WithValue(context.Background(), tenantTestKey{}, "acme"), "default")
+	throwFailNow(t, err)
+	for _, c := range diff.Changes {
+		throwFail(t, AssertNot(c.Kind, SchemaCreateTable))
+	}
+}
+
+func TestSharding(t *testing.T) {
+	throwFailNow(t, RegisterSharding(new(ShardOrder), "UserId", HashSharding(4)))
+	assert.NotNil(t, RegisterSharding(new(ShardOrder), "UserId", HashSharding(4)))
+	assert.NotNil(t, RegisterSharding(new(ExtendedDoc), "Unknown", HashSharding(4)))
+	throwFailNow(t, RunSyncdb("default", false, false))
+
+	al := getDbAlias("default")
+	tables, err := al.DbBaser.GetTables(al.DB)
+	throwFailNow(t, err)
+	for i := 0; i < 4; i++ {
+		throwFail(t, AssertIs(tables[fmt.Sprintf("shard_order_%02d", i)], true))
+	}
+	mi, ok := LookupModel(new(ShardOrder))
+	throwFailNow(t, AssertIs(ok, true))
+	assert.Equal(t, []string{"shard_order_00", "shard_order_01", "shard_order_02", "shard_order_03"},
+		ModelTables(context.Background(), "default", mi))
+
+	orders := make([]*ShardOrder, 0, 8)
+	for i := 1; i <= 8; i++ {
+		orders = append(orders, &ShardOrder{Id: int64(i), UserId: int64((i-1)%4 + 1), Amount: i * 10})
+	}
+	// the zero sharding key cannot tell the shard
+	_, err = dORM.Insert(&ShardOrder{Id: 9})
+	throwFail(t, AssertNot(err, nil))
+	_, err = dORM.InsertMulti(10, []*ShardOrder{{Id: 9, UserId: 1}, {Id: 10}})
+	throwFail(t, AssertNot(err, nil))
+	throwFail(t, AssertNot(dORM.Read(&ShardOrder{Id: 1}), nil))
+	for _, order := range orders[:4] {
+		_, err = dORM.Insert(order)
+		throwFailNow(t, err)
+	}
+	num, err := dORM.InsertMulti(10, orders[4:])
+	throwFailNow(t, err)
+	throwFail(t, AssertIs(num, 4))
+
+	// the rows of user 1 are in its shard only
+	var n int
+	throwFailNow(t, dORM.Raw("SELECT COUNT(*) FROM shard_order_01").QueryRow(&n))
+	throwFail(t, AssertIs(n, 2))
+	throwFailNow(t, dORM.Raw("SELECT COUNT(*) FROM shard_order").QueryRow(&n))
+	throwFail(t, AssertIs(n, 0))
+
+	read := &ShardOrder{Id: 5, UserId: 1}
+	throwFailNow(t, dORM.Read(read))
+	throwFail(t, AssertIs(read.Amount, 50))
+	read.Amount = 55
+	num, err = dORM.Update(read, "Amount")
+	throwFailNow(t, err)
+	throwFail(t, AssertIs(num, 1))
+
+	qs := dORM.QueryTable(new(ShardOrder))
+	cnt, err := qs.Filter("UserId", 2).Count()
+	throwFailNow(t, err)
+	throwFail(t, AssertIs(cnt, 2))
+	cnt, err = qs.Filter("UserId__in", 1, 2).Count()
+	throwFailNow(t, err)
+	throwFail(t, AssertIs(cnt, 4))
+	cnt, err = qs.Count()
+	throwFailNow(t, err)
+	throwFail(t, AssertIs(cnt, 8))
+	throwFail(t, AssertIs(qs.Filter("Amount", 55).Exist(), true))
+
+	// the OR groups route only if all of them filter the key
+	shards, err := qs.(*querySet).SetCond(NewCondition().And("UserId", 1).AndCond(NewCondition().And("Amount", 10).Or("Amount", 20))).(*querySet).shards()
+	throwFailNow(t, err)
+	throwFail(t, AssertIs(len(shards), 1))
+	shards, err = qs.(*querySet).SetCond(NewCondition().And("UserId", 1).Or("UserId", 2)).(*querySet).shards()
+	throwFailNow(t, err)
+	throwFail(t, AssertIs(len(shards), 2))
+	shards, err = qs.(*querySet).SetCond(NewCondition().And("UserId", 1).And("Amount", 10).Or("Amount", 20)).(*querySet).shards()
+	throwFailNow(t, err)
+	throwFail(t, AssertIs(len(shards), 4))
+	cnt, err = qs.SetCond(NewCondition().And("UserId", 1).And("Amount", 10).Or("Amount", 20)).Count()
+	throwFailNow(t, err)
+	throwFail(t, AssertIs(cnt, 2))
+	throwFail(t, AssertIs(qs.Filter("Amount", 50).Exist(), false))
+
+	// the rows of all the shards are merged by the orders
+	var list []*ShardOrder
+	num, err = qs.OrderBy("-Amount").Limit(3, 1).All(&list)
+	throwFailNow(t, err)
+	throwFail(t, AssertIs(num, 3))
+	throwFail(t, AssertIs(list[0].Amount, 70))
+	throwFail(t, AssertIs(list[1].Amount, 60))
+	throwFail(t, AssertIs(list[2].Amount, 55))
+
+	var one ShardOrder
+	throwFailNow(t, qs.OrderBy("Amount").One(&one))
+	throwFail(t, AssertIs(one.Id, 1))
+	throwFail(t, AssertIs(qs.Filter("Amount", 1000).One(&one), ErrNoRows))
+
+	var maps []Params
+	num, err = qs.OrderBy("-Id").Values(&maps, "Id", "Amount")
+	throwFailNow(t, err)
+	throwFail(t, AssertIs(num, 8))
+	throwFail(t, AssertIs(maps[0]["Id"], 8))
+	_, err = qs.OrderBy("-Id").Values(&maps, "Amount")
+	throwFail(t, AssertNot(err, nil))
+
+	var chunks int
+	throwFailNow(t, qs.Chunk(3, func(container interface{}) error {
+		chunks++
+		return nil
+	}))
+	throwFail(t, AssertIs(chunks, 3))
+
+	num, err = qs.Filter("Amount__gte", 60).Update(Params{"Amount": 0})
+	throwFailNow(t, err)
+	throwFail(t, AssertIs(num, 3))
+	num, err = qs.Filter("UserId", 1).Delete()
+	throwFailNow(t, err)
+	throwFail(t, AssertIs(num, 2))
+	num, err = dORM.Delete(&ShardOrder{Id: 2, UserId: 2})
+	throwFailNow(t, err)
+	throwFail(t, AssertIs(num, 1))
+	cnt, err = qs.Count()
+	throwFailNow(t, err)
+	throwFail(t, AssertIs(cnt, 5))
+
+	_, err = qs.GroupBy("UserId").Values(&maps)
+	throwFail(t, AssertIs(err, ErrShardFanOut))
+	_, err = qs.OrderBy("Id").Iterate(func(interface{}) error { return nil })
+	throwFail(t, AssertIs(err, ErrShardFanOut))
+	_, err = qs.PrepareInsert()
+	throwFail(t, AssertNot(err, nil))
+}
+
+func TestShardingRule(t *testing.T) {
+	rule := HashSharding(64, "db1", "db2")
+	shards := rule.Shards("order")
+	assert.Len(t, shards, 64)
+	assert.Equal(t, Shard{Alias: "db1", Table: "order_00"}, shards[0])
+	assert.Equal(t, Shard{Alias: "db2", Table: "order_63"}, shards[63])
+	shard, err := rule.Shard("order", 65)
+	assert.Nil(t, err)
+	assert.Equal(t, "order_01", shard.Table)
+	shard, err = rule.Shard("order", -1)
+	assert.Nil(t, err)
+	assert.Equal(t, "order_63", shard.Table)
+	_, err = rule.Shard("order", nil)
+	assert.NotNil(t, err)
+	_, err = rule.Shard("order", 1.5)
+	assert.NotNil(t, err)
+	_, err = rule.Shard("order", struct{}{})
+	assert.NotNil(t, err)
+
+	// the same instant goes to the same shard
+	now := time.Now()
+	shard, err = rule.Shard("order", now)
+	assert.Nil(t, err)
+	local, err := rule.Shard("order", now.Round(0).In(time.FixedZone("UTC+8", 8*3600)))
+	assert.Nil(t, err)
+	assert.Equal(t, shard, local)
+
+	rule = RangeSharding(
+		RangeShard{Shard: Shard{Table: "order_2022"}, Upper: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)},
+		RangeShard{Shard: Shard{Table: "order_2023"}, Upper: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+	)
+	shard, err = rule.Shard("order", time.Date(2023, 5, 1, 0, 0, 0, 0, time.UTC))
+	assert.Nil(t, err)
+	assert.Equal(t, "order_2023", shard.Table)
+	_, err = rule.Shard("order", time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC))
+	assert.NotNil(t, err)
+
+	assert.Equal(t, -1, compareShardValues(nil, 1))
+	assert.Equal(t, 1, compareShardValues(uint8(2), int64(1)))
+	assert.Equal(t, 0, compareShardValues(1.0, 1))
+	assert.Equal(t, -1, compareShardValues("a", "b"))
+}
+
+func TestTenantSchema(t *testing.T) {
+	al := &alias{Driver: DRPostgres, DbBaser: newdbBaserOfTenant(DRPostgres, "", "acme"), Schema: "acme"}
+	assert.Nil(t, checkTenantSchema(al, "acme"))
+	assert.NotNil(t, checkTenantSchema(al, `acme"; DROP SCHEMA public; --`))
+	assert.NotNil(t, checkTenantSchema(al, ""))
+	assert.NotNil(t, checkTenantSchema(&alias{Driver: DRSqlite}, "acme"))
+	assert.Equal(t, `"acme"`, quoteSchema(al))
+
+	// only the tenant schema searches the tables in the search path
+	assert.Contains(t, al.DbBaser.ShowTablesQuery(), "current_schemas(false)")
+	assert.NotContains(t, newdbBasePostgres().ShowTablesQuery(), "current_schemas(false)")
+	assert.NotContains(t, newdbBasePostgres().ShowColumnsQuery("user"), "current_schemas(false)")
+}
+
 func TestTransactionIsolationLevel(t *testing.T) {
 	// this test worked when database support transaction isolation level
 	if IsSqlite {
@@ -14,9 +14,14 @@
 
 package orm
 
-import "errors"
+import (
+	"errors"
+	"reflect"
+	"strings"
+)
 
-// QueryBuilder is the Query builder interface
+// QueryBuilder is the Query builder interface,
+// the args of the ? marks are collected with the sql, see Build.
 type QueryBuilder interface {
 	Select(fields ...string) QueryBuilder
 	ForUpdate() QueryBuilder
@@ -25,24 +30,36 @@ type QueryBuilder interface {
 	LeftJoin(table string) QueryBuilder
 	RightJoin(table string) QueryBuilder
 	On(cond string) QueryBuilder
-	Where(cond string) QueryBuilder
-	And(cond string) QueryBuilder
-	Or(cond string) QueryBuilder
+	Where(cond string, args ...interface{}) QueryBuilder
+	And(cond string, args ...interface{}) QueryBuilder
+	Or(cond string, args ...interface{}) QueryBuilder
 	In(vals ...string) QueryBuilder
+	// InArgs join the IN by the ? marks of the vals, and appends the vals to the args
+	InArgs(vals ...interface{}) QueryBuilder
 	OrderBy(fields ...string) QueryBuilder
 	Asc() QueryBuilder
 	Desc() QueryBuilder
 	Limit(limit int) QueryBuilder
 	Offset(offset int) QueryBuilder
 	GroupBy(fields ...string) QueryBuilder
-	Having(cond string) QueryBuilder
+	Having(cond string, args ...interface{}) QueryBuilder
 	Update(tables ...string) QueryBuilder
 	Set(kv ...string) QueryBuilder
 	Delete(tables ...string) QueryBuilder
 	InsertInto(table string, fields ...string) QueryBuilder
 	Values(vals ...string) QueryBuilder
+	// ValuesArgs join the VALUES by the ? marks of the vals, and appends the vals to the args
+	ValuesArgs(vals ...interface{}) QueryBuilder
+	// Args appends the args of the ? marks written by the other methods, such as Set and Subquery.
+	// The args are kept in the order they are appended, so call it right after the method writing the marks:
+	//	qb.Update("user").Set("name = ?").Args("slene").Where("id = ?", 2)
+	Args(args ...interface{}) QueryBuilder
 	Subquery(sub string, alias string) string
+	// String returns the sql with ? marks, and resets the builder
 	String() string
+	// Build returns the sql with the marks of the driver and the args, and resets the builder,
+	// the results can be passed to Ormer.Raw directly, such as o.Raw(qb.Build())
+	Build() (string, []interface{})
 }
 
 // NewQueryBuilder return the QueryBuilder
@@ -53,10 +70,31 @@ func NewQueryBuilder(driver string) (qb QueryBuilder, err error) {
 		qb = new(TiDBQueryBuilder)
 	} else if driver == "postgres" {
 		qb = new(PostgresQueryBuilder)
-	} else if driver == "sqlite" {
-		err = errors.New("sqlite query builder is not supported yet")
+	} else if driver == "sqlite" || driver == "sqlite3" {
+		qb = new(SQLiteQueryBuilder)
 	} else {
 		err = errors.New("unknown driver for query builder")
 	}
 	return
 }
+
+// getQBMarks returns the ? marks of the vals and the args,
+// the slice values are expanded, so InArgs([]int{1, 2}) is the same as InArgs(1, 2).
+func getQBMarks(vals []interface{}) (string, []interface{}) {
+	args := make([]interface{}, 0, len(vals))
+	for _, val := range vals {
+		v := reflect.ValueOf(val)
+		if v.Kind() == reflect.Slice && v.Type().Elem().Kind() != reflect.Uint8 {
+			for i := 0; i < v.Len(); i++ {
+				args = append(args, v.Index(i).Interface())
+			}
+			continue
+		}
+		args = append(args, val)
+	}
+	marks := make([]string, len(args))
+	for i := range marks {
+		marks[i] = "?"
+	}
+	return strings.Join(marks, CommaSpace), args
+}
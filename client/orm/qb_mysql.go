@@ -26,6 +26,7 @@ const CommaSpace = ", "
 // MySQLQueryBuilder is the SQL build
 type MySQLQueryBuilder struct {
 	tokens []string
+	args   []interface{}
 }
 
 // Select will join the Fields
@@ -71,20 +72,23 @@ func (qb *MySQLQueryBuilder) On(cond string) QueryBuilder {
 }
 
 // Where join the Where cond
-func (qb *MySQLQueryBuilder) Where(cond string) QueryBuilder {
+func (qb *MySQLQueryBuilder) Where(cond string, args ...interface{}) QueryBuilder {
 	qb.tokens = append(qb.tokens, "WHERE", cond)
+	qb.args = append(qb.args, args...)
 	return qb
 }
 
 // And join the and cond
-func (qb *MySQLQueryBuilder) And(cond string) QueryBuilder {
+func (qb *MySQLQueryBuilder) And(cond string, args ...interface{}) QueryBuilder {
 	qb.tokens = append(qb.tokens, "AND", cond)
+	qb.args = append(qb.args, args...)
 	return qb
 }
 
 // Or join the or cond
-func (qb *MySQLQueryBuilder) Or(cond string) QueryBuilder {
+func (qb *MySQLQueryBuilder) Or(cond string, args ...interface{}) QueryBuilder {
 	qb.tokens = append(qb.tokens, "OR", cond)
+	qb.args = append(qb.args, args...)
 	return qb
 }
 
@@ -94,6 +98,14 @@ func (qb *MySQLQueryBuilder) In(vals ...string) QueryBuilder {
 	return qb
 }
 
+// InArgs join the IN (vals) by the ? marks
+func (qb *MySQLQueryBuilder) InArgs(vals ...interface{}) QueryBuilder {
+	marks, args := getQBMarks(vals)
+	qb.tokens = append(qb.tokens, "IN", "(", marks, ")")
+	qb.args = append(qb.args, args...)
+	return qb
+}
+
 // OrderBy join the Order by Fields
 func (qb *MySQLQueryBuilder) OrderBy(fields ...string) QueryBuilder {
 	qb.tokens = append(qb.tokens, "ORDER BY", strings.Join(fields, CommaSpace))
@@ -131,8 +143,9 @@ func (qb *MySQLQueryBuilder) GroupBy(fields ...string) QueryBuilder {
 }
 
 // Having join the Having cond
-func (qb *MySQLQueryBuilder) Having(cond string) QueryBuilder {
+func (qb *MySQLQueryBuilder) Having(cond string, args ...interface{}) QueryBuilder {
 	qb.tokens = append(qb.tokens, "HAVING", cond)
+	qb.args = append(qb.args, args...)
 	return qb
 }
 
@@ -174,6 +187,20 @@ func (qb *MySQLQueryBuilder) Values(vals ...string) QueryBuilder {
 	return qb
 }
 
+// ValuesArgs join the Values(vals) by the ? marks
+func (qb *MySQLQueryBuilder) ValuesArgs(vals ...interface{}) QueryBuilder {
+	marks, args := getQBMarks(vals)
+	qb.tokens = append(qb.tokens, "VALUES", "(", marks, ")")
+	qb.args = append(qb.args, args...)
+	return qb
+}
+
+// Args append the args of the ? marks
+func (qb *MySQLQueryBuilder) Args(args ...interface{}) QueryBuilder {
+	qb.args = append(qb.args, args...)
+	return qb
+}
+
 // Subquery join the sub as alias
 func (qb *MySQLQueryBuilder) Subquery(sub string, alias string) string {
 	return fmt.Sprintf("(%s) AS %s", sub, alias)
@@ -183,5 +210,12 @@ func (qb *MySQLQueryBuilder) Subquery(sub string, alias string) string {
 func (qb *MySQLQueryBuilder) String() string {
 	s := strings.Join(qb.tokens, " ")
 	qb.tokens = qb.tokens[:0]
+	qb.args = nil
 	return s
 }
+
+// Build join All tokens and return the args
+func (qb *MySQLQueryBuilder) Build() (string, []interface{}) {
+	args := qb.args
+	return qb.String(), args
+}
@@ -11,6 +11,7 @@ var quote string = `"`
 // PostgresQueryBuilder is the SQL build
 type PostgresQueryBuilder struct {
 	tokens []string
+	args   []interface{}
 }
 
 func processingStr(str []string) string {
@@ -98,20 +99,23 @@ func (qb *PostgresQueryBuilder) On(cond string) QueryBuilder {
 }
 
 // Where join the Where cond
-func (qb *PostgresQueryBuilder) Where(cond string) QueryBuilder {
+func (qb *PostgresQueryBuilder) Where(cond string, args ...interface{}) QueryBuilder {
 	qb.tokens = append(qb.tokens, "WHERE", cond)
+	qb.args = append(qb.args, args...)
 	return qb
 }
 
 // And join the and cond
-func (qb *PostgresQueryBuilder) And(cond string) QueryBuilder {
+func (qb *PostgresQueryBuilder) And(cond string, args ...interface{}) QueryBuilder {
 	qb.tokens = append(qb.tokens, "AND", cond)
+	qb.args = append(qb.args, args...)
 	return qb
 }
 
 // Or join the or cond
-func (qb *PostgresQueryBuilder) Or(cond string) QueryBuilder {
+func (qb *PostgresQueryBuilder) Or(cond string, args ...interface{}) QueryBuilder {
 	qb.tokens = append(qb.tokens, "OR", cond)
+	qb.args = append(qb.args, args...)
 	return qb
 }
 
@@ -121,6 +125,14 @@ func (qb *PostgresQueryBuilder) In(vals ...string) QueryBuilder {
 	return qb
 }
 
+// InArgs join the IN (vals) by the ? marks
+func (qb *PostgresQueryBuilder) InArgs(vals ...interface{}) QueryBuilder {
+	marks, args := getQBMarks(vals)
+	qb.tokens = append(qb.tokens, "IN", "(", marks, ")")
+	qb.args = append(qb.args, args...)
+	return qb
+}
+
 // OrderBy join the Order by Fields
 func (qb *PostgresQueryBuilder) OrderBy(fields ...string) QueryBuilder {
 	str := processingStr(fields)
@@ -160,8 +172,9 @@ func (qb *PostgresQueryBuilder) GroupBy(fields ...string) QueryBuilder {
 }
 
 // Having join the Having cond
-func (qb *PostgresQueryBuilder) Having(cond string) QueryBuilder {
+func (qb *PostgresQueryBuilder) Having(cond string, args ...interface{}) QueryBuilder {
 	qb.tokens = append(qb.tokens, "HAVING", cond)
+	qb.args = append(qb.args, args...)
 	return qb
 }
 
@@ -206,6 +219,20 @@ func (qb *PostgresQueryBuilder) Values(vals ...string) QueryBuilder {
 	return qb
 }
 
+// ValuesArgs join the Values(vals) by the ? marks
+func (qb *PostgresQueryBuilder) ValuesArgs(vals ...interface{}) QueryBuilder {
+	marks, args := getQBMarks(vals)
+	qb.tokens = append(qb.tokens, "VALUES", "(", marks, ")")
+	qb.args = append(qb.args, args...)
+	return qb
+}
+
+// Args append the args of the ? marks
+func (qb *PostgresQueryBuilder) Args(args ...interface{}) QueryBuilder {
+	qb.args = append(qb.args, args...)
+	return qb
+}
+
 // Subquery join the sub as alias
 func (qb *PostgresQueryBuilder) Subquery(sub string, alias string) string {
 	return fmt.Sprintf("(%s) AS %s", sub, alias)
@@ -215,5 +242,14 @@ func (qb *PostgresQueryBuilder) Subquery(sub string, alias string) string {
 func (qb *PostgresQueryBuilder) String() string {
 	s := strings.Join(qb.tokens, " ")
 	qb.tokens = qb.tokens[:0]
+	qb.args = nil
 	return s
 }
+
+// Build join All tokens with the $n marks and return the args
+func (qb *PostgresQueryBuilder) Build() (string, []interface{}) {
+	args := qb.args
+	s := qb.String()
+	new(dbBasePostgres).ReplaceMarks(&s)
+	return s, args
+}
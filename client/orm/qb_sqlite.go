@@ -0,0 +1,218 @@
+// Copyright 2023 beego. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package orm
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SQLiteQueryBuilder is the SQL build
+type SQLiteQueryBuilder struct {
+	tokens []string
+	args   []interface{}
+}
+
+// Select will join the Fields
+func (qb *SQLiteQueryBuilder) Select(fields ...string) QueryBuilder {
+	qb.tokens = append(qb.tokens, "SELECT", strings.Join(fields, CommaSpace))
+	return qb
+}
+
+// ForUpdate is ignored, SQLite does not support the FOR UPDATE clause
+func (qb *SQLiteQueryBuilder) ForUpdate() QueryBuilder {
+	DebugLog.Println("[WARN] SQLite does not support SELECT FOR UPDATE query, the FOR UPDATE clause is ignored")
+	return qb
+}
+
+// From join the tables
+func (qb *SQLiteQueryBuilder) From(tables ...string) QueryBuilder {
+	qb.tokens = append(qb.tokens, "FROM", strings.Join(tables, CommaSpace))
+	return qb
+}
+
+// InnerJoin INNER JOIN the table
+func (qb *SQLiteQueryBuilder) InnerJoin(table string) QueryBuilder {
+	qb.tokens = append(qb.tokens, "INNER JOIN", table)
+	return qb
+}
+
+// LeftJoin LEFT JOIN the table
+func (qb *SQLiteQueryBuilder) LeftJoin(table string) QueryBuilder {
+	qb.tokens = append(qb.tokens, "LEFT JOIN", table)
+	return qb
+}
+
+// RightJoin RIGHT JOIN the table
+func (qb *SQLiteQueryBuilder) RightJoin(table string) QueryBuilder {
+	qb.tokens = append(qb.tokens, "RIGHT JOIN", table)
+	return qb
+}
+
+// On join with on cond
+func (qb *SQLiteQueryBuilder) On(cond string) QueryBuilder {
+	qb.tokens = append(qb.tokens, "ON", cond)
+	return qb
+}
+
+// Where join the Where cond
+func (qb *SQLiteQueryBuilder) Where(cond string, args ...interface{}) QueryBuilder {
+	qb.tokens = append(qb.tokens, "WHERE", cond)
+	qb.args = append(qb.args, args...)
+	return qb
+}
+
+// And join the and cond
+func (qb *SQLiteQueryBuilder) And(cond string, args ...interface{}) QueryBuilder {
+	qb.tokens = append(qb.tokens, "AND", cond)
+	qb.args = append(qb.args, args...)
+	return qb
+}
+
+// Or join the or cond
+func (qb *SQLiteQueryBuilder) Or(cond string, args ...interface{}) QueryBuilder {
+	qb.tokens = append(qb.tokens, "OR", cond)
+	qb.args = append(qb.args, args...)
+	return qb
+}
+
+// In join the IN (vals)
+func (qb *SQLiteQueryBuilder) In(vals ...string) QueryBuilder {
+	qb.tokens = append(qb.tokens, "IN", "(", strings.Join(vals, CommaSpace), ")")
+	return qb
+}
+
+// InArgs join the IN (vals) by the ? marks
+func (qb *SQLiteQueryBuilder) InArgs(vals ...interface{}) QueryBuilder {
+	marks, args := getQBMarks(vals)
+	qb.tokens = append(qb.tokens, "IN", "(", marks, ")")
+	qb.args = append(qb.args, args...)
+	return qb
+}
+
+// OrderBy join the Order by Fields
+func (qb *SQLiteQueryBuilder) OrderBy(fields ...string) QueryBuilder {
+	qb.tokens = append(qb.tokens, "ORDER BY", strings.Join(fields, CommaSpace))
+	return qb
+}
+
+// Asc join the asc
+func (qb *SQLiteQueryBuilder) Asc() QueryBuilder {
+	qb.tokens = append(qb.tokens, "ASC")
+	return qb
+}
+
+// Desc join the desc
+func (qb *SQLiteQueryBuilder) Desc() QueryBuilder {
+	qb.tokens = append(qb.tokens, "DESC")
+	return qb
+}
+
+// Limit join the limit num
+func (qb *SQLiteQueryBuilder) Limit(limit int) QueryBuilder {
+	qb.tokens = append(qb.tokens, "LIMIT", strconv.Itoa(limit))
+	return qb
+}
+
+// Offset join the offset num
+func (qb *SQLiteQueryBuilder) Offset(offset int) QueryBuilder {
+	qb.tokens = append(qb.tokens, "OFFSET", strconv.Itoa(offset))
+	return qb
+}
+
+// GroupBy join the Group by Fields
+func (qb *SQLiteQueryBuilder) GroupBy(fields ...string) QueryBuilder {
+	qb.tokens = append(qb.tokens, "GROUP BY", strings.Join(fields, CommaSpace))
+	return qb
+}
+
+// Having join the Having cond
+func (qb *SQLiteQueryBuilder) Having(cond string, args ...interface{}) QueryBuilder {
+	qb.tokens = append(qb.tokens, "HAVING", cond)
+	qb.args = append(qb.args, args...)
+	return qb
+}
+
+// Update join the update table
+func (qb *SQLiteQueryBuilder) Update(tables ...string) QueryBuilder {
+	qb.tokens = append(qb.tokens, "UPDATE", strings.Join(tables, CommaSpace))
+	return qb
+}
+
+// Set join the Set kv
+func (qb *SQLiteQueryBuilder) Set(kv ...string) QueryBuilder {
+	qb.tokens = append(qb.tokens, "SET", strings.Join(kv, CommaSpace))
+	return qb
+}
+
+// Delete join the Delete tables
+func (qb *SQLiteQueryBuilder) Delete(tables ...string) QueryBuilder {
+	qb.tokens = append(qb.tokens, "DELETE")
+	if len(tables) != 0 {
+		qb.tokens = append(qb.tokens, strings.Join(tables, CommaSpace))
+	}
+	return qb
+}
+
+// InsertInto join the insert SQL
+func (qb *SQLiteQueryBuilder) InsertInto(table string, fields ...string) QueryBuilder {
+	qb.tokens = append(qb.tokens, "INSERT INTO", table)
+	if len(fields) != 0 {
+		fieldsStr := strings.Join(fields, CommaSpace)
+		qb.tokens = append(qb.tokens, "(", fieldsStr, ")")
+	}
+	return qb
+}
+
+// Values join the Values(vals)
+func (qb *SQLiteQueryBuilder) Values(vals ...string) QueryBuilder {
+	valsStr := strings.Join(vals, CommaSpace)
+	qb.tokens = append(qb.tokens, "VALUES", "(", valsStr, ")")
+	return qb
+}
+
+// ValuesArgs join the Values(vals) by the ? marks
+func (qb *SQLiteQueryBuilder) ValuesArgs(vals ...interface{}) QueryBuilder {
+	marks, args := getQBMarks(vals)
+	qb.tokens = append(qb.tokens, "VALUES", "(", marks, ")")
+	qb.args = append(qb.args, args...)
+	return qb
+}
+
+// Args append the args of the ? marks
+func (qb *SQLiteQueryBuilder) Args(args ...interface{}) QueryBuilder {
+	qb.args = append(qb.args, args...)
+	return qb
+}
+
+// Subquery join the sub as alias
+func (qb *SQLiteQueryBuilder) Subquery(sub string, alias string) string {
+	return fmt.Sprintf("(%s) AS %s", sub, alias)
+}
+
+// String join All tokens
+func (qb *SQLiteQueryBuilder) String() string {
+	s := strings.Join(qb.tokens, " ")
+	qb.tokens = qb.tokens[:0]
+	qb.args = nil
+	return s
+}
+
+// Build join All tokens and return the args
+func (qb *SQLiteQueryBuilder) Build() (string, []interface{}) {
+	args := qb.args
+	return qb.String(), args
+}
@@ -0,0 +1,123 @@
+// Copyright 2023 beego
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package orm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryBuilder_Build(t *testing.T) {
+	testCases := []struct {
+		name      string
+		driver    string
+		build     func(qb QueryBuilder)
+		wantSQL   string
+		wantArgs  []interface{}
+		wantError bool
+	}{
+		{
+			name:   "mysql select",
+			driver: "mysql",
+			build: func(qb QueryBuilder) {
+				qb.Select("id", "name").From("user").Where("age > ?", 18).And("status").InArgs(1, 2, 3).
+					Or("name = ?", "slene").OrderBy("id").Desc().Limit(10).Offset(5)
+			},
+			wantSQL:  "SELECT id, name FROM user WHERE age > ? AND status IN ( ?, ?, ? ) OR name = ? ORDER BY id DESC LIMIT 10 OFFSET 5",
+			wantArgs: []interface{}{18, 1, 2, 3, "slene"},
+		},
+		{
+			name:   "mysql in slice",
+			driver: "mysql",
+			build: func(qb QueryBuilder) {
+				qb.Select("*").From("user").Where("id").InArgs([]int{1, 2}).GroupBy("status").Having("COUNT(*) > ?", 1)
+			},
+			wantSQL:  "SELECT * FROM user WHERE id IN ( ?, ? ) GROUP BY status HAVING COUNT(*) > ?",
+			wantArgs: []interface{}{1, 2, 1},
+		},
+		{
+			name:   "tidb insert",
+			driver: "tidb",
+			build: func(qb QueryBuilder) {
+				qb.InsertInto("user", "name", "data").ValuesArgs("slene", []byte("raw"))
+			},
+			wantSQL:  "INSERT INTO user ( name, data ) VALUES ( ?, ? )",
+			wantArgs: []interface{}{"slene", []byte("raw")},
+		},
+		{
+			name:   "postgres update",
+			driver: "postgres",
+			build: func(qb QueryBuilder) {
+				qb.Update("user").Set("name = ?", "age = ?").Args("slene", 28).Where("id = ?", 2)
+			},
+			wantSQL:  `UPDATE "user" SET name = $1, age = $2 WHERE id = $3`,
+			wantArgs: []interface{}{"slene", 28, 2},
+		},
+		{
+			name:   "sqlite delete",
+			driver: "sqlite3",
+			build: func(qb QueryBuilder) {
+				qb.Delete().From("user").Where("id").InArgs(1, 2).ForUpdate()
+			},
+			wantSQL:  "DELETE FROM user WHERE id IN ( ?, ? )",
+			wantArgs: []interface{}{1, 2},
+		},
+		{
+			name:   "mysql raw values",
+			driver: "mysql",
+			build: func(qb QueryBuilder) {
+				qb.InsertInto("user", "name", "status").Values("?", "1").Args("slene")
+			},
+			wantSQL:  "INSERT INTO user ( name, status ) VALUES ( ?, 1 )",
+			wantArgs: []interface{}{"slene"},
+		},
+		{
+			name:   "postgres raw in",
+			driver: "postgres",
+			build: func(qb QueryBuilder) {
+				qb.Select("*").From("user").Where("name = ?").Args("slene").And("status").In("1", "2")
+			},
+			wantSQL:  `SELECT * FROM "user" WHERE name = $1 AND status IN ( 1, 2 )`,
+			wantArgs: []interface{}{"slene"},
+		},
+		{
+			name:      "unknown driver",
+			driver:    "oracle",
+			wantError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			qb, err := NewQueryBuilder(tc.driver)
+			if tc.wantError {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+
+			tc.build(qb)
+			sql, args := qb.Build()
+			assert.Equal(t, tc.wantSQL, sql)
+			assert.Equal(t, tc.wantArgs, args)
+
+			// the builder is reset
+			sql, args = qb.Build()
+			assert.Equal(t, "", sql)
+			assert.Nil(t, args)
+		})
+	}
+}
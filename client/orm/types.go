@@ -86,11 +86,88 @@ type TableUniqueI interface {
 	TableUnique() [][]string
 }
 
+// TablePrimaryKeyI is usually used by model
+// when the table has a composite primary key, you can implement this interface
+// for example:
+//
+//	type UserGroup struct {
+//	  UserId  int
+//	  GroupId int
+//	  ...
+//	}
+//
+//	func (u *UserGroup) TablePrimaryKey() []string {
+//	   return []string{"UserId", "GroupId"}
+//	}
+type TablePrimaryKeyI interface {
+	TablePrimaryKey() []string
+}
+
+// TableTrackChangesI is usually used by model
+// when you want Update to write the changed fields only, you can implement this interface,
+// the values of the model are saved after Read, One, All and Insert,
+// and Update without cols writes the fields changed from them, see Changed.
+// The values are saved by the address of the model, so the models in a []Model must not be copied or moved after they are read
+// for example:
+//
+//	func (u *User) TableTrackChanges() bool {
+//	   return true
+//	}
+type TableTrackChangesI interface {
+	TableTrackChanges() bool
+}
+
 // IsApplicableTableForDB if return false, we won't create table to this db
 type IsApplicableTableForDB interface {
 	IsApplicableTableForDB(db string) bool
 }
 
+// BeforeInserter is called by Insert and InsertMulti before inserting the model,
+// returning error aborts the insert.
+// The QueryExecutor runs the queries in the same transaction as the Insert.
+// for example:
+//
+//	func (u *User) BeforeInsert(ctx context.Context, o orm.QueryExecutor) error {
+//	   u.Created = time.Now()
+//	   return nil
+//	}
+type BeforeInserter interface {
+	BeforeInsert(ctx context.Context, o QueryExecutor) error
+}
+
+// AfterInserter is called by Insert and InsertMulti after inserting the model,
+// the error is returned by the Insert while the model is inserted already,
+// so you should use it in the transaction and roll back if you want to undo the insert.
+// Note that the pk of the model is not set when InsertMulti inserts in bulk.
+type AfterInserter interface {
+	AfterInsert(ctx context.Context, o QueryExecutor) error
+}
+
+// BeforeUpdater is called by Update before updating the model, returning error aborts the update
+type BeforeUpdater interface {
+	BeforeUpdate(ctx context.Context, o QueryExecutor) error
+}
+
+// AfterUpdater is called by Update after updating the model
+type AfterUpdater interface {
+	AfterUpdate(ctx context.Context, o QueryExecutor) error
+}
+
+// BeforeDeleter is called by Delete before deleting the model, returning error aborts the delete
+type BeforeDeleter interface {
+	BeforeDelete(ctx context.Context, o QueryExecutor) error
+}
+
+// AfterDeleter is called by Delete after deleting the model
+type AfterDeleter interface {
+	AfterDelete(ctx context.Context, o QueryExecutor) error
+}
+
+// AfterReader is called by Read, QuerySeter.One and QuerySeter.All after the model is read
+type AfterReader interface {
+	AfterRead(ctx context.Context, o QueryExecutor) error
+}
+
 // Driver define database driver
 type Driver interface {
 	Name() string
@@ -160,6 +237,23 @@ type DML interface {
 	// InsertMulti inserts some models to database
 	InsertMulti(bulk int, mds interface{}) (int64, error)
 	InsertMultiWithCtx(ctx context.Context, bulk int, mds interface{}) (int64, error)
+	// InsertOrUpdateMulti inserts some models to database by bulk,
+	// and updates the updateCols of the rows which conflict with the conflictCols.
+	// mysql: INSERT ... ON DUPLICATE KEY UPDATE, the conflictCols are ignored
+	// postgres and sqlite: INSERT ... ON CONFLICT (conflictCols) DO UPDATE
+	// conflictCols default to the pk,
+	// updateCols default to the inserted columns except the conflictCols, pk and auto_now_add columns.
+	// for example:
+	//	num, err = Ormer.InsertOrUpdateMulti(100, users, []string{"UserName"}, []string{"Email", "Status"})
+	InsertOrUpdateMulti(bulk int, mds interface{}, conflictCols []string, updateCols []string) (int64, error)
+	InsertOrUpdateMultiWithCtx(ctx context.Context, bulk int, mds interface{}, conflictCols []string, updateCols []string) (int64, error)
+	// UpdateMulti updates the cols of the models by pk in bulk,
+	// the models can have different values, every column is set by CASE pk WHEN ... THEN ... END.
+	// cols default to all the columns except the pk and auto_now_add columns.
+	// for example:
+	//	num, err = Ormer.UpdateMulti(100, users, "Status")
+	UpdateMulti(bulk int, mds interface{}, cols ...string) (int64, error)
+	UpdateMultiWithCtx(ctx context.Context, bulk int, mds interface{}, cols ...string) (int64, error)
 	// Update updates model to database.
 	// cols Set the Columns those want to update.
 	// find model by Id(pk) field and update Columns specified by Fields, if cols is null then update All Columns
@@ -265,6 +359,15 @@ type TxOrmer interface {
 	TxCommitter
 }
 
+// NestedTxOrmer is the TxOrmer which starts a nested transaction by SAVEPOINT with Begin or DoTx,
+// the TxOrmer created by Ormer implements it:
+//
+//	err := txOrm.(orm.NestedTxOrmer).DoTx(func(ctx context.Context, nested orm.TxOrmer) error { ... })
+type NestedTxOrmer interface {
+	TxOrmer
+	TxBeginner
+}
+
 // Inserter insert prepared statement
 type Inserter interface {
 	Insert(interface{}) (int64, error)
@@ -282,6 +385,8 @@ type QuerySeter interface {
 	//	Filter("profile__Age", 28)
 	// 	 // time compare
 	//	qs.Filter("created", time.Now())
+	// 	 // subquery, which selects the pk or the GroupBy fields
+	//	qs.Filter("Profile__in", o.QueryTable("profile").Filter("Age__gte", 18))
 	Filter(string, ...interface{}) QuerySeter
 	// FilterRaw add raw sql to querySeter.
 	// for example:
@@ -374,6 +479,20 @@ type QuerySeter interface {
 	//	qs.RelatedSel("profile").One(&user)
 	//	user.Profile.Age = 32
 	RelatedSel(params ...interface{}) QuerySeter
+	// Prefetch loads the reverse, m2m and rel fields after All or One,
+	// every level of the relation paths is loaded by one query with IN (pks of the parents),
+	// so it doesn't query the relation for each model like LoadRelated.
+	// hints.Limit, hints.Offset and hints.OrderBy following a name are applied to the related models of each parent.
+	// for example:
+	//	qs.Prefetch("Posts", hints.OrderBy("-Id"), hints.Limit(5), "Posts__Tags").All(&users)
+	Prefetch(params ...interface{}) QuerySeter
+	// Cache caches the results of All, One, Count and Values* for ttl in the cache registered by RegisterQueryCache,
+	// the results are keyed by the sql and the args, and invalidated by the Insert, Update and Delete of the tables
+	// through the Ormer. The queries inside a transaction or for update are not cached, and the models must be
+	// encoded by encoding/gob. for example:
+	//	orm.RegisterQueryCache(cache.NewMemoryCache())
+	//	qs.Filter("Status", 1).Cache(time.Minute).All(&users)
+	Cache(ttl time.Duration) QuerySeter
 	// Distinct Set Distinct
 	// for example:
 	//  o.QueryTable("policy").Filter("Groups__Group__Users__User", user).
@@ -384,6 +503,19 @@ type QuerySeter interface {
 	// for example:
 	//  o.QueryTable("user").Filter("uid", uid).ForUpdate().All(&users)
 	ForUpdate() QuerySeter
+	// WithDeleted includes the soft deleted rows of the model which has the soft_delete field.
+	// for example:
+	//  o.QueryTable("user").WithDeleted().All(&users)
+	WithDeleted() QuerySeter
+	// OnlyDeleted queries the soft deleted rows only.
+	// for example:
+	//  o.QueryTable("user").OnlyDeleted().Count()
+	OnlyDeleted() QuerySeter
+	// HardDelete makes Delete remove the rows instead of setting the soft_delete field,
+	// the soft deleted rows are removed too.
+	// for example:
+	//  o.QueryTable("user").OnlyDeleted().HardDelete().Delete()
+	HardDelete() QuerySeter
 	// Count returns QuerySeter execution result number
 	// for example:
 	//	num, err = qs.Filter("profile__age__gt", 28).Count()
@@ -432,6 +564,26 @@ type QuerySeter interface {
 	//	qs.One(&user) //user.UserName == "slene"
 	One(container interface{}, cols ...string) error
 	OneWithCtx(ctx context.Context, container interface{}, cols ...string) error
+	// Iterate query the data and scan the rows one by one,
+	// fn is called with a new model pointer for each row, and the iteration stops if fn returns error.
+	// It doesn't hold all the rows in memory like All.
+	// for example:
+	//	num, err := qs.Iterate(func(md interface{}) error {
+	//		user := md.(*User)
+	//		return encoder.Encode(user)
+	//	})
+	Iterate(fn func(md interface{}) error, cols ...string) (int64, error)
+	IterateWithCtx(ctx context.Context, fn func(md interface{}) error, cols ...string) (int64, error)
+	// Chunk query the data by pages of size and call fn with each page,
+	// the container is a slice of model pointers, such as []*User.
+	// It pages by the primary key instead of OFFSET, so the orders and the limit of the QuerySeter are ignored.
+	// for example:
+	//	err := qs.Chunk(1000, func(container interface{}) error {
+	//		users := container.([]*User)
+	//		...
+	//	})
+	Chunk(size int, fn func(container interface{}) error) error
+	ChunkWithCtx(ctx context.Context, size int, fn func(container interface{}) error) error
 	// Values query All data and map to []map[string]interface.
 	// expres means condition expression.
 	// it converts data to []map[column]value.
@@ -485,6 +637,20 @@ type QuerySeter interface {
 	// var res []result
 	//  o.QueryTable("dept_info").Aggregate("dept_name,sum(salary) as total").GroupBy("dept_name").All(&res)
 	Aggregate(s string) QuerySeter
+	// Annotate select the GroupBy fields and the aggregations,
+	// All scans the results to the struct fields matching the aliases, or to orm.Params.
+	// for example:
+	// type result struct {
+	//	DeptName string
+	//	Total    int
+	// }
+	// var res []result
+	//	o.QueryTable("dept_info").GroupBy("DeptName").Annotate(orm.Sum("Salary").As("total")).
+	//		Having("total__gt", 1000).OrderBy("-total").All(&res)
+	Annotate(aggs ...Aggregation) QuerySeter
+	// Having add HAVING condition expression,
+	// the expression can use the aliases of the annotations and the GroupBy fields.
+	Having(expr string, args ...interface{}) QuerySeter
 }
 
 // QueryM2Mer model to model query struct
@@ -624,16 +790,19 @@ type dbQuerier interface {
 type dbBaser interface {
 	Read(context.Context, dbQuerier, *models.ModelInfo, reflect.Value, *time.Location, []string, bool) error
 	ReadBatch(context.Context, dbQuerier, querySet, *models.ModelInfo, *Condition, interface{}, *time.Location, []string) (int64, error)
+	IterateBatch(context.Context, dbQuerier, querySet, *models.ModelInfo, *Condition, *time.Location, []string, func(reflect.Value) error) (int64, error)
 	Count(context.Context, dbQuerier, querySet, *models.ModelInfo, *Condition, *time.Location) (int64, error)
 	ReadValues(context.Context, dbQuerier, querySet, *models.ModelInfo, *Condition, []string, interface{}, *time.Location) (int64, error)
 
 	Insert(context.Context, dbQuerier, *models.ModelInfo, reflect.Value, *time.Location) (int64, error)
 	InsertOrUpdate(context.Context, dbQuerier, *models.ModelInfo, reflect.Value, *alias, ...string) (int64, error)
 	InsertMulti(context.Context, dbQuerier, *models.ModelInfo, reflect.Value, int, *time.Location) (int64, error)
+	InsertOrUpdateMulti(context.Context, dbQuerier, *models.ModelInfo, reflect.Value, int, *alias, []string, []string) (int64, error)
 	InsertValue(context.Context, dbQuerier, *models.ModelInfo, bool, []string, []interface{}) (int64, error)
 	InsertStmt(context.Context, stmtQuerier, *models.ModelInfo, reflect.Value, *time.Location) (int64, error)
 
 	Update(context.Context, dbQuerier, *models.ModelInfo, reflect.Value, *time.Location, []string) (int64, error)
+	UpdateMulti(context.Context, dbQuerier, *models.ModelInfo, reflect.Value, int, *time.Location, []string) (int64, error)
 	UpdateBatch(context.Context, dbQuerier, *querySet, *models.ModelInfo, *Condition, Params, *time.Location) (int64, error)
 
 	Delete(context.Context, dbQuerier, *models.ModelInfo, reflect.Value, *time.Location, []string) (int64, error)
@@ -643,9 +812,13 @@ type dbBaser interface {
 	OperatorSQL(string) string
 	GenerateOperatorSQL(*models.ModelInfo, *models.FieldInfo, string, []interface{}, *time.Location) (string, []interface{})
 	GenerateOperatorLeftCol(*models.FieldInfo, string, *string)
+	GenerateJSONPathCol(col string, path []string, typed bool) string
+	GenerateJSONOperatorSQL(col string, path []string, operator string, args []interface{}) (string, []interface{})
+	GenerateArrayOperatorSQL(col string, operator string, arg interface{}) (string, []interface{})
 	PrepareInsert(context.Context, dbQuerier, *models.ModelInfo) (stmtQuerier, string, error)
 	MaxLimit() uint64
 	TableQuote() string
+	TableName(*models.ModelInfo) string
 	ReplaceMarks(*string)
 	HasReturningID(*models.ModelInfo, *string) bool
 	TimeFromDB(*time.Time, *time.Location)
@@ -656,6 +829,10 @@ type dbBaser interface {
 	ShowTablesQuery() string
 	ShowColumnsQuery(string) string
 	IndexExists(context.Context, dbQuerier, string, string) bool
+	GetSchemaColumns(context.Context, dbQuerier, string) ([]*schemaColumn, error)
+	GetSchemaIndexes(context.Context, dbQuerier, string) ([]*schemaIndex, error)
+	GetSchemaKeys(context.Context, dbQuerier, string) (*schemaKeys, error)
+	NormalizeColumnType(string) string
 	collectFieldValue(*models.ModelInfo, *models.FieldInfo, reflect.Value, bool, *time.Location) (interface{}, error)
 	setval(context.Context, dbQuerier, *models.ModelInfo, []string) error
 
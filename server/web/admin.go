@@ -114,6 +114,7 @@ func registerAdmin() error {
 		beeAdminApp.Router("/prof", c, "get:ProfIndex")
 		beeAdminApp.Router("/healthcheck", c, "get:Healthcheck")
 		beeAdminApp.Router("/task", c, "get:TaskStatus")
+		beeAdminApp.Router("/slowquery", c, "get:SlowQuery")
 		beeAdminApp.Router("/listconf", c, "get:ListConf")
 		beeAdminApp.Router("/metrics", c, "get:PrometheusMetrics")
 
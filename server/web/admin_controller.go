@@ -118,6 +118,35 @@ func (a *adminController) TaskStatus() {
 	writeTemplate(rw, data, tasksTpl, defaultScriptsTpl)
 }
 
+// SlowQuery is a http.Handler with the top slow statements of orm, which are recorded by client/orm/filter/slowquery.
+// it's in "/slowquery" pattern in admin module.
+func (a *adminController) SlowQuery() {
+	data := make(map[interface{}]interface{})
+	data["Title"] = "Slow Queries"
+
+	res := admin.GetCommand("orm", "slowquery").Execute()
+	if !res.IsSuccess() {
+		data["Message"] = []string{"warning", "the slow query recorder is not registered"}
+		writeTemplate(a.Ctx.ResponseWriter, data, slowQueryTpl, defaultScriptsTpl)
+		return
+	}
+
+	content := make(M)
+	content["Fields"] = []string{
+		"Fingerprint",
+		"Count",
+		"Total",
+		"Avg",
+		"Max",
+		"Caller",
+		"Args",
+		"Explain",
+	}
+	content["Data"] = res.Content.([][]string)
+	data["Content"] = content
+	writeTemplate(a.Ctx.ResponseWriter, data, slowQueryTpl, defaultScriptsTpl)
+}
+
 func (a *adminController) AdminIndex() {
 	// AdminIndex is the default http.Handler for admin module.
 	// it matches url pattern "/".
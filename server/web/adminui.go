@@ -196,6 +196,47 @@ bg-warning
 
 {{end}}`
 
+var slowQueryTpl = `{{define "content"}}
+
+<h1>{{.Title}}</h1>
+
+{{if .Message }}
+<p class="message bg-warning">
+{{index .Message 1}}
+</p>
+{{end}}
+
+{{if .Content }}
+<table class="table table-striped table-hover ">
+<thead>
+<tr>
+{{range .Content.Fields}}
+<th>
+{{.}}
+</th>
+{{end}}
+</tr>
+</thead>
+
+<tbody>
+{{range $i, $slice := .Content.Data}}
+<tr>
+	<td><code>{{index $slice 0}}</code></td>
+	<td>{{index $slice 1}}</td>
+	<td>{{index $slice 2}}</td>
+	<td>{{index $slice 3}}</td>
+	<td>{{index $slice 4}}</td>
+	<td>{{index $slice 5}}</td>
+	<td>{{index $slice 6}}</td>
+	<td><pre>{{index $slice 7}}</pre></td>
+</tr>
+{{end}}
+</tbody>
+</table>
+{{end}}
+
+{{end}}`
+
 var healthCheckTpl = `
 {{define "content"}}
 
@@ -322,6 +363,12 @@ Healthcheck
 <a href="/task" class="dropdown-toggle disabled" data-toggle="dropdown">Tasks</a>
 </li>
 
+<li>
+<a href="/slowquery">
+Slow Queries
+</a>
+</li>
+
 <li class="dropdown">
 <a href="#" class="dropdown-toggle disabled" data-toggle="dropdown">Config Status<span class="caret"></span></a>
 <ul class="dropdown-menu" role="menu">